@@ -39,11 +39,9 @@ func TestFullFlow(t *testing.T) {
 	}
 	t.Logf("Created Reception: %s", receptionID)
 
-	// 5. добавляем 50 продуктов
-	for i := 1; i <= 50; i++ {
-		if err := createProduct(client, baseURL, empToken, pvzID, "электроника"); err != nil {
-			t.Fatalf("cannot create product #%d: %v", i, err)
-		}
+	// 5. добавляем 50 продуктов одной пачкой
+	if err := createProducts(client, baseURL, empToken, pvzID, "электроника", 50); err != nil {
+		t.Fatalf("cannot create products: %v", err)
 	}
 	t.Logf("Created 50 products")
 
@@ -134,9 +132,17 @@ func createReception(c *http.Client, baseURL, token, pvzID string) (string, erro
 	return rec.ID, nil
 }
 
-func createProduct(c *http.Client, baseURL, token, pvzID, prodType string) error {
-	body := fmt.Sprintf(`{"type":"%s","pvzId":"%s"}`, prodType, pvzID)
-	req, _ := http.NewRequest(http.MethodPost, baseURL+"/products", bytes.NewBuffer([]byte(body)))
+func createProducts(c *http.Client, baseURL, token, pvzID, prodType string, n int) error {
+	type item struct {
+		Type         string `json:"type"`
+		SerialNumber string `json:"serialNumber"`
+	}
+	items := make([]item, 0, n)
+	for i := 1; i <= n; i++ {
+		items = append(items, item{Type: prodType, SerialNumber: fmt.Sprintf("SN-%s-%d", pvzID[:8], i)})
+	}
+	body, _ := json.Marshal(map[string]any{"pvzId": pvzID, "products": items})
+	req, _ := http.NewRequest(http.MethodPost, baseURL+"/products/batch", bytes.NewBuffer(body))
 	req.Header.Set("Authorization", "Bearer "+token)
 	req.Header.Set("Content-Type", "application/json")
 
@@ -147,7 +153,7 @@ func createProduct(c *http.Client, baseURL, token, pvzID, prodType string) error
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusCreated {
-		return fmt.Errorf("createProduct status=%d", resp.StatusCode)
+		return fmt.Errorf("createProducts status=%d", resp.StatusCode)
 	}
 	return nil
 }
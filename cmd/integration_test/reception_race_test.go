@@ -0,0 +1,87 @@
+package integration_test
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+)
+
+// TestParallelReceptionOpen - из N параллельных попыток открыть приёмку
+// на одном ПВЗ успешна ровно одна, остальные получают 409.
+func TestParallelReceptionOpen(t *testing.T) {
+	const n = 20
+
+	baseURL := "http://localhost:8080"
+	client := &http.Client{Timeout: 5 * time.Second}
+
+	modToken, err := getDummyLoginToken(client, baseURL, "moderator")
+	if err != nil {
+		t.Fatalf("cannot get mod token: %v", err)
+	}
+	pvzID, err := createPVZ(client, baseURL, modToken, "Казань")
+	if err != nil {
+		t.Fatalf("cannot create PVZ: %v", err)
+	}
+	empToken, err := getDummyLoginToken(client, baseURL, "employee")
+	if err != nil {
+		t.Fatalf("cannot get employee token: %v", err)
+	}
+
+	var (
+		wg    sync.WaitGroup
+		start = make(chan struct{})
+		codes = make(chan int, n)
+	)
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			<-start
+			code, err := postReception(client, baseURL, empToken, pvzID)
+			if err != nil {
+				t.Errorf("post reception: %v", err)
+				return
+			}
+			codes <- code
+		}()
+	}
+	close(start)
+	wg.Wait()
+	close(codes)
+
+	created, conflicts := 0, 0
+	for code := range codes {
+		switch code {
+		case http.StatusCreated:
+			created++
+		case http.StatusConflict:
+			conflicts++
+		default:
+			t.Errorf("unexpected status %d", code)
+		}
+	}
+	if created != 1 || conflicts != n-1 {
+		t.Fatalf("want 1 created and %d conflicts, got %d created and %d conflicts", n-1, created, conflicts)
+	}
+
+	if err := closeReception(client, baseURL, empToken, pvzID); err != nil {
+		t.Fatalf("close reception failed: %v", err)
+	}
+}
+
+func postReception(c *http.Client, baseURL, token, pvzID string) (int, error) {
+	body := fmt.Sprintf(`{"pvzId":"%s"}`, pvzID)
+	req, _ := http.NewRequest(http.MethodPost, baseURL+"/receptions", bytes.NewBuffer([]byte(body)))
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	return resp.StatusCode, nil
+}
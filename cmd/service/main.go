@@ -1,17 +1,25 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"net"
 	"net/http"
+	"os"
+	"time"
 
 	"github.com/51mans0n/avito-pvz-task/internal/api"
+	"github.com/51mans0n/avito-pvz-task/internal/autoclose"
+	"github.com/51mans0n/avito-pvz-task/internal/blob"
+	"github.com/51mans0n/avito-pvz-task/internal/config"
 	"github.com/51mans0n/avito-pvz-task/internal/db"
 	grpcserver "github.com/51mans0n/avito-pvz-task/internal/grpc"
 	"github.com/51mans0n/avito-pvz-task/internal/logging"
 	"github.com/51mans0n/avito-pvz-task/internal/metrics"
+	"github.com/51mans0n/avito-pvz-task/internal/model"
 	pvz_v1 "github.com/51mans0n/avito-pvz-task/pkg/proto/pvz/v1"
 	"github.com/go-chi/chi/v5"
+	"github.com/jmoiron/sqlx"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"google.golang.org/grpc"
 )
@@ -21,15 +29,101 @@ func main() {
 	logging.Init(false) // dev режим
 	defer logging.Sync()
 
+	// хранилище: postgres (по умолчанию) или memory - данные в памяти процесса,
+	// для локальных демо без базы; после перезапуска всё пропадает
+	var (
+		repo     db.Repository
+		database *sqlx.DB
+		ready    = func() error { return nil } // готовность для /ready
+	)
+	switch storage := config.String("STORAGE", "postgres"); storage {
+	case "memory":
+		logging.S().Warnw("using in-memory storage, data will be lost on restart")
+		repo = db.NewMemRepo()
+	case "postgres":
+		dbCfg := db.ConfigFromEnv()
+		var err error
+		if database, err = db.InitDB(context.Background(), dbCfg); err != nil {
+			logging.S().Fatalf("failed to init DB: %v", err)
+		}
+		defer database.Close()
+
+		// сервис готов, пока основной сервер отвечает на ping
+		health := db.NewHealth(database.DB, dbCfg.ConnectTimeout)
+		go health.Run(context.Background(), config.Duration("DB_HEALTH_INTERVAL", 10*time.Second))
+		ready = health.Ready
+
+		// схема накатывается при старте, если не запущена отдельная команда migrate
+		if config.Bool("MIGRATE_ON_START", false) && (len(os.Args) < 2 || os.Args[1] != "migrate") {
+			if err := runMigrate(context.Background(), database.DB, []string{"up"}); err != nil {
+				logging.S().Fatalf("failed to migrate DB: %v", err)
+			}
+		}
+		metrics.RegisterDBPool("primary", database.DB)
+
+		// реплика для списков и отчётов; без неё всё читается с основного сервера
+		if dsn := config.String("POSTGRES_REPLICA_DSN", ""); dsn != "" {
+			replicaDB, err := db.InitReplica(dbCfg, dsn)
+			if err != nil {
+				logging.S().Fatalf("failed to init replica: %v", err)
+			}
+			defer replicaDB.Close()
+			metrics.RegisterDBPool("replica", replicaDB.DB)
+
+			replica := db.NewReplica(replicaDB, config.Duration("REPLICA_MAX_LAG", 10*time.Second))
+			go replica.Run(context.Background(), config.Duration("REPLICA_CHECK_INTERVAL", 5*time.Second))
+			repo = db.NewRepoWithReplica(database, replica)
+		} else {
+			repo = db.NewRepo(database)
+		}
+	default:
+		logging.S().Fatalf("unknown STORAGE %q: want postgres or memory", storage)
+	}
+
+	// автозакрытие приёмок, забытых в in_progress
+	autoCloser := autoclose.New(repo,
+		config.Duration("AUTOCLOSE_MAX_AGE", 12*time.Hour),
+		config.Duration("AUTOCLOSE_INTERVAL", 10*time.Minute))
+
+	// CLI-подкоманды: `service autoclose` - один проход автозакрытия и выход,
+	// `service migrate up|down [n]|status|goto <version>` - миграции схемы
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "migrate":
+			if database == nil {
+				logging.S().Fatal("migrate requires STORAGE=postgres")
+			}
+			if err := runMigrate(context.Background(), database.DB, os.Args[2:]); err != nil {
+				logging.S().Fatalw("migrate", "err", err)
+			}
+			return
+		case "autoclose":
+			n, err := autoCloser.RunOnce(context.Background())
+			if err != nil {
+				logging.S().Fatalw("auto-close receptions", "closed", n, "err", err)
+			}
+			fmt.Printf("auto-closed %d receptions\n", n)
+			return
+		default:
+			logging.S().Fatalf("unknown command %q", os.Args[1])
+		}
+	}
+
 	fmt.Println("Starting HTTP service on :8080...")
 
-	database, err := db.InitDB()
+	// сколько товаров можно добавить одним пакетом (REST и gRPC)
+	maxProductBatch := config.Int("PRODUCT_BATCH_MAX", 500)
+
+	// окно, в течение которого закрытую приёмку можно переоткрыть
+	reopenWindow := config.Duration("RECEPTION_REOPEN_WINDOW", 15*time.Minute)
+
+	// вложения (фото, накладные) хранятся на локальном диске
+	blobs, err := blob.NewLocalStore(config.String("ATTACHMENTS_DIR", "./data/attachments"))
 	if err != nil {
-		logging.S().Fatalf("failed to init DB: %v", err)
+		logging.S().Fatalf("failed to init attachments store: %v", err)
 	}
-	defer database.Close()
-
-	repo := db.NewRepo(database)
+	attachmentLimits := api.DefaultAttachmentLimits
+	attachmentLimits.MaxBytes = int64(config.Int("ATTACHMENT_MAX_BYTES", int(attachmentLimits.MaxBytes)))
 
 	go func() {
 		lis, _ := net.Listen("tcp", ":3000")
@@ -37,8 +131,12 @@ func main() {
 			logging.S().Fatalw("listen gRPC", "err", err)
 		}
 
-		g := grpc.NewServer()
-		pvz_v1.RegisterPVZServiceServer(g, grpcserver.New(repo))
+		// AddProducts требует токен сотрудника в метаданных authorization
+		g := grpc.NewServer(
+			grpc.UnaryInterceptor(grpcserver.UnaryAuthInterceptor),
+			grpc.StreamInterceptor(grpcserver.StreamAuthInterceptor),
+		)
+		pvz_v1.RegisterPVZServiceServer(g, grpcserver.New(repo, maxProductBatch))
 
 		logging.S().Infow("gRPC :3000 started")
 		if err := g.Serve(lis); err != nil {
@@ -47,6 +145,11 @@ func main() {
 	}()
 
 	metrics.MustRegister()
+
+	if config.Bool("AUTOCLOSE_ENABLED", true) {
+		go autoCloser.Run(context.Background())
+	}
+
 	r := chi.NewRouter()
 	r.Use(logging.RequestLogger)
 	r.Use(metrics.PromMiddleware)
@@ -68,6 +171,9 @@ func main() {
 		}
 	})
 
+	// Readiness: 503, пока база недоступна
+	r.Get("/ready", api.ReadyHandler(ready))
+
 	// Dummy login (не требует авторизации)
 	r.Post("/dummyLogin", api.DummyLoginHandler)
 
@@ -82,15 +188,52 @@ func main() {
 
 			// GET /pvz -> List
 			rpvz.Get("/", api.GetPVZListHandler(repo))
+			rpvz.Get("/{pvzId}", api.GetPVZHandler(repo))
+			rpvz.Get("/{pvzId}/products", api.ListProductsHandler(repo))
+			rpvz.Post("/{pvzId}/cells", api.CreateStorageCellHandler(repo))
+			rpvz.Get("/{pvzId}/cells", api.ListStorageCellsHandler(repo))
 			rpvz.Post("/{pvzId}/delete_last_product", api.DeleteLastProductHandler(repo))
+			rpvz.Post("/{pvzId}/undo_last_delete", api.UndoLastDeleteHandler(repo))
 			rpvz.Post("/{pvzId}/close_last_reception", api.CloseLastReceptionHandler(repo))
+			rpvz.Post("/{pvzId}/reopen_approval", api.ReopenApprovalHandler(reopenWindow))
+			rpvz.Post("/{pvzId}/reopen_last_reception", api.ReopenLastReceptionHandler(repo, reopenWindow))
 		})
 
 		// /receptions
 		sub.Post("/receptions", api.CreateReceptionHandler(repo))
+		sub.Get("/receptions/{receptionId}", api.GetReceptionHandler(repo))
+		sub.Get("/receptions/{receptionId}/discrepancy", api.GetDiscrepancyReportHandler(repo))
+		sub.Post("/receptions/{ownerId}/attachments", api.UploadAttachmentHandler(repo, blobs, model.AttachmentOwnerReception, attachmentLimits))
+		sub.Get("/receptions/{ownerId}/attachments", api.ListAttachmentsHandler(repo, model.AttachmentOwnerReception))
 
 		// /products
+		sub.Get("/products", api.ListProductsHandler(repo))
 		sub.Post("/products", api.CreateProductHandler(repo))
+		sub.Post("/products/batch", api.CreateProductsBatchHandler(repo, maxProductBatch))
+		sub.Get("/products/{productId}", api.GetProductHandler(repo))
+		sub.Delete("/products/{productId}", api.DeleteProductHandler(repo))
+		sub.Post("/products/{productId}/move", api.MoveProductHandler(repo))
+		sub.Get("/products/{productId}/movements", api.ListProductMovementsHandler(repo))
+		sub.Get("/products/by-barcode/{code}", api.GetProductByBarcodeHandler(repo))
+		sub.Post("/products/{productId}/store", api.TransitionProductHandler(repo, model.ProductStatusStored))
+		sub.Post("/products/{productId}/issue", api.TransitionProductHandler(repo, model.ProductStatusIssued))
+		sub.Post("/products/{productId}/return", api.TransitionProductHandler(repo, model.ProductStatusReturned))
+		sub.Post("/products/{ownerId}/attachments", api.UploadAttachmentHandler(repo, blobs, model.AttachmentOwnerProduct, attachmentLimits))
+		sub.Get("/products/{ownerId}/attachments", api.ListAttachmentsHandler(repo, model.AttachmentOwnerProduct))
+
+		// /transfers
+		sub.Post("/transfers", api.CreateTransferHandler(repo))
+		sub.Get("/transfers/{transferId}", api.GetTransferHandler(repo))
+		sub.Post("/transfers/{transferId}/dispatch", api.DispatchTransferHandler(repo))
+		sub.Post("/transfers/{transferId}/receive", api.ReceiveTransferHandler(repo))
+
+		// /product-types
+		sub.Get("/product-types", api.ListProductTypesHandler(repo))
+		sub.Post("/product-types", api.CreateProductTypeHandler(repo))
+		sub.Put("/product-types/{name}", api.UpdateProductTypeHandler(repo))
+
+		// /attachments
+		sub.Get("/attachments/{attachmentId}", api.DownloadAttachmentHandler(repo, blobs))
 	})
 
 	logging.S().Infow("HTTP started", "addr", ":8080")
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+	"strconv"
+	"text/tabwriter"
+
+	"github.com/51mans0n/avito-pvz-task/internal/migrate"
+	"github.com/51mans0n/avito-pvz-task/migrations"
+)
+
+// runMigrate выполняет `service migrate up|down [n]|status|goto <version>`
+func runMigrate(ctx context.Context, db *sql.DB, args []string) error {
+	m, err := migrate.New(db, migrations.FS)
+	if err != nil {
+		return err
+	}
+
+	cmd := "up"
+	if len(args) > 0 {
+		cmd = args[0]
+	}
+
+	switch cmd {
+	case "up":
+		n, err := m.Up(ctx)
+		fmt.Printf("applied %d migrations\n", n)
+		return err
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			if steps, err = strconv.Atoi(args[1]); err != nil || steps <= 0 {
+				return fmt.Errorf("down: invalid step count %q", args[1])
+			}
+		}
+		n, err := m.Down(ctx, steps)
+		fmt.Printf("rolled back %d migrations\n", n)
+		return err
+	case "goto":
+		if len(args) < 2 {
+			return fmt.Errorf("goto: version required")
+		}
+		version, err := strconv.Atoi(args[1])
+		if err != nil || version < 0 {
+			return fmt.Errorf("goto: invalid version %q", args[1])
+		}
+		n, err := m.Goto(ctx, version)
+		fmt.Printf("migrated to %d in %d steps\n", version, n)
+		return err
+	case "status":
+		list, err := m.Status(ctx)
+		if err != nil {
+			return err
+		}
+		tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, "VERSION\tNAME\tAPPLIED AT\tSTATE")
+		for _, s := range list {
+			appliedAt, state := "-", "pending"
+			if s.Applied {
+				appliedAt, state = s.AppliedAt.Format("2006-01-02 15:04:05"), "applied"
+			}
+			if s.Modified {
+				state = "modified"
+			}
+			fmt.Fprintf(tw, "%03d\t%s\t%s\t%s\n", s.Version, s.Name, appliedAt, state)
+		}
+		return tw.Flush()
+	default:
+		return fmt.Errorf("unknown migrate command %q", cmd)
+	}
+}
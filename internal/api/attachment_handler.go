@@ -0,0 +1,317 @@
+package api
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/51mans0n/avito-pvz-task/internal/blob"
+	"github.com/51mans0n/avito-pvz-task/internal/db"
+	"github.com/51mans0n/avito-pvz-task/internal/logging"
+	"github.com/51mans0n/avito-pvz-task/internal/model"
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+)
+
+const (
+	codeAttachmentTooLarge   = "attachment_too_large"
+	codeUnsupportedMediaType = "unsupported_media_type"
+	codeChecksumMismatch     = "checksum_mismatch"
+	codeAttachmentNotFound   = "attachment_not_found"
+	codeOwnerNotFound        = "owner_not_found"
+
+	// checksumHeader - необязательный sha256 (hex), который клиент передаёт при загрузке
+	// и получает при скачивании
+	checksumHeader = "X-Content-SHA256"
+)
+
+// AttachmentLimits - ограничения на загружаемые файлы
+type AttachmentLimits struct {
+	MaxBytes     int64
+	ContentTypes []string
+}
+
+// DefaultAttachmentLimits - фото и сканы накладных до 10 МБ
+var DefaultAttachmentLimits = AttachmentLimits{
+	MaxBytes:     10 << 20,
+	ContentTypes: []string{"image/jpeg", "image/png", "application/pdf"},
+}
+
+func (l AttachmentLimits) allowed(contentType string) bool {
+	for _, ct := range l.ContentTypes {
+		if ct == contentType {
+			return true
+		}
+	}
+	return false
+}
+
+// UploadAttachmentHandler - загрузка файла (multipart, поле "file") к приёмке или товару.
+// Id владельца берётся из параметра пути "ownerId".
+func UploadAttachmentHandler(repo db.Repository, store blob.Store, ownerType string, limits AttachmentLimits) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		role := GetRole(r.Context())
+		if role != "employee" && role != "moderator" {
+			http.Error(w, `{"message":"forbidden"}`, http.StatusForbidden)
+			return
+		}
+
+		ownerID := chi.URLParam(r, "ownerId")
+		if _, err := uuid.Parse(ownerID); err != nil {
+			http.Error(w, `{"message":"invalid `+ownerType+` id"}`, http.StatusBadRequest)
+			return
+		}
+
+		wantSum := strings.ToLower(strings.TrimSpace(r.Header.Get(checksumHeader)))
+
+		// запас на заголовки multipart сверх размера самого файла
+		r.Body = http.MaxBytesReader(w, r.Body, limits.MaxBytes+64<<10)
+		mr, err := r.MultipartReader()
+		if err != nil {
+			http.Error(w, `{"message":"multipart/form-data body expected"}`, http.StatusBadRequest)
+			return
+		}
+		var part *multipart.Part
+		for {
+			p, err := mr.NextPart()
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			if err != nil {
+				http.Error(w, `{"message":"invalid multipart body"}`, http.StatusBadRequest)
+				return
+			}
+			if p.FormName() == "file" {
+				part = p
+				break
+			}
+		}
+		if part == nil {
+			http.Error(w, `{"message":"file is required"}`, http.StatusBadRequest)
+			return
+		}
+
+		// тип определяем по содержимому, а не по заголовку клиента
+		head := make([]byte, 512)
+		n, err := io.ReadFull(part, head)
+		if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) && !errors.Is(err, io.EOF) {
+			http.Error(w, `{"message":"invalid multipart body"}`, http.StatusBadRequest)
+			return
+		}
+		head = head[:n]
+		contentType, _, _ := mime.ParseMediaType(http.DetectContentType(head))
+		if !limits.allowed(contentType) {
+			writeError(w, http.StatusUnsupportedMediaType, codeUnsupportedMediaType,
+				"allowed content types: "+strings.Join(limits.ContentTypes, ", "))
+			return
+		}
+
+		a := &model.Attachment{
+			ID:          uuid.New().String(),
+			OwnerType:   ownerType,
+			OwnerID:     ownerID,
+			FileName:    path.Base(strings.ReplaceAll(part.FileName(), `\`, "/")),
+			ContentType: contentType,
+			UploadedBy:  role,
+			CreatedAt:   time.Now(),
+		}
+		if a.FileName == "." || a.FileName == "/" {
+			a.FileName = a.ID
+		}
+		a.StorageKey = ownerType + "/" + a.ID
+
+		// читаем на байт больше лимита, чтобы отличить "ровно лимит" от "больше"
+		body := io.LimitReader(io.MultiReader(bytes.NewReader(head), part), limits.MaxBytes+1)
+		size, sum, err := store.Put(r.Context(), a.StorageKey, body)
+		if err != nil {
+			var maxErr *http.MaxBytesError
+			if errors.As(err, &maxErr) {
+				writeError(w, http.StatusRequestEntityTooLarge, codeAttachmentTooLarge, "attachment is too large")
+				return
+			}
+			logging.S().Errorw("store attachment", "key", a.StorageKey, "err", err)
+			writeError(w, http.StatusInternalServerError, codeInternal, "server error")
+			return
+		}
+		a.Size, a.SHA256 = size, sum
+
+		// дальше при любой ошибке файл в хранилище больше не нужен
+		reject := func(status int, code, msg string) {
+			if err := store.Delete(r.Context(), a.StorageKey); err != nil {
+				logging.S().Warnw("delete rejected attachment", "key", a.StorageKey, "err", err)
+			}
+			writeError(w, status, code, msg)
+		}
+		if size > limits.MaxBytes {
+			reject(http.StatusRequestEntityTooLarge, codeAttachmentTooLarge, "attachment is too large")
+			return
+		}
+		if wantSum != "" && wantSum != sum {
+			reject(http.StatusUnprocessableEntity, codeChecksumMismatch, "sha256 of uploaded content does not match "+checksumHeader)
+			return
+		}
+
+		if err := repo.CreateAttachment(r.Context(), a); err != nil {
+			if errors.Is(err, db.ErrAttachmentOwnerNotFound) {
+				reject(http.StatusNotFound, codeOwnerNotFound, ownerType+" not found")
+				return
+			}
+			logging.S().Errorw("create attachment", "owner", ownerID, "err", err)
+			reject(http.StatusInternalServerError, codeInternal, "server error")
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		if err := json.NewEncoder(w).Encode(a); err != nil {
+			logging.S().Warnw("encode attachment", "err", err)
+		}
+	}
+}
+
+// ListAttachmentsHandler - метаданные вложений приёмки или товара
+func ListAttachmentsHandler(repo db.Repository, ownerType string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		role := GetRole(r.Context())
+		if role != "employee" && role != "moderator" {
+			http.Error(w, `{"message":"forbidden"}`, http.StatusForbidden)
+			return
+		}
+
+		ownerID := chi.URLParam(r, "ownerId")
+		if _, err := uuid.Parse(ownerID); err != nil {
+			http.Error(w, `{"message":"invalid `+ownerType+` id"}`, http.StatusBadRequest)
+			return
+		}
+
+		list, err := repo.ListAttachments(r.Context(), ownerType, ownerID)
+		if err != nil {
+			logging.S().Errorw("list attachments", "owner", ownerID, "err", err)
+			writeError(w, http.StatusInternalServerError, codeInternal, "server error")
+			return
+		}
+		if list == nil {
+			list = []*model.Attachment{}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(list); err != nil {
+			logging.S().Warnw("encode attachments", "err", err)
+		}
+	}
+}
+
+// DownloadAttachmentHandler отдаёт содержимое вложения. Контрольная сумма
+// считается во время отдачи, испорченный файл целиком не отдаётся.
+func DownloadAttachmentHandler(repo db.Repository, store blob.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		role := GetRole(r.Context())
+		if role != "employee" && role != "moderator" {
+			http.Error(w, `{"message":"forbidden"}`, http.StatusForbidden)
+			return
+		}
+
+		id := chi.URLParam(r, "attachmentId")
+		if _, err := uuid.Parse(id); err != nil {
+			http.Error(w, `{"message":"invalid attachmentId"}`, http.StatusBadRequest)
+			return
+		}
+
+		a, err := repo.GetAttachment(r.Context(), id)
+		if err != nil {
+			logging.S().Errorw("get attachment", "id", id, "err", err)
+			writeError(w, http.StatusInternalServerError, codeInternal, "server error")
+			return
+		}
+		if a == nil {
+			writeError(w, http.StatusNotFound, codeAttachmentNotFound, "attachment not found")
+			return
+		}
+
+		rc, err := store.Get(r.Context(), a.StorageKey)
+		if err != nil {
+			logging.S().Errorw("open attachment", "id", id, "err", err)
+			writeError(w, http.StatusInternalServerError, codeInternal, "attachment content is unavailable")
+			return
+		}
+		defer rc.Close()
+
+		w.Header().Set("Content-Type", a.ContentType)
+		w.Header().Set("Content-Length", strconv.FormatInt(a.Size, 10))
+		w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": a.FileName}))
+		w.Header().Set(checksumHeader, a.SHA256)
+
+		written, err := copyVerified(w, rc, a)
+		switch {
+		case errors.Is(err, errBlobChecksum) && written == 0:
+			logging.S().Errorw("verify attachment", "id", id, "key", a.StorageKey, "err", err)
+			for _, h := range []string{"Content-Type", "Content-Length", "Content-Disposition", checksumHeader} {
+				w.Header().Del(h)
+			}
+			writeError(w, http.StatusInternalServerError, codeInternal, "attachment content is unavailable")
+		case errors.Is(err, errBlobChecksum):
+			// часть тела уже у клиента: обрываем соединение, чтобы ответ
+			// не выглядел полным
+			logging.S().Errorw("verify attachment", "id", id, "key", a.StorageKey, "written", written, "err", err)
+			panic(http.ErrAbortHandler)
+		case err != nil:
+			logging.S().Warnw("write attachment", "id", id, "err", err)
+		}
+	}
+}
+
+// errBlobChecksum - содержимое хранилища не совпадает с метаданными вложения
+var errBlobChecksum = errors.New("checksum mismatch")
+
+// copyBufSize - размер порции при отдаче вложения
+const copyBufSize = 32 << 10
+
+// copyVerified отдаёт blob в w, считая sha256 на лету. Последняя прочитанная
+// порция придерживается до EOF: если размер или хеш не сошлись, она не
+// отправляется, и клиент получает обрезанное тело вместо испорченного файла.
+// Возвращает число записанных в w байт.
+func copyVerified(w io.Writer, src io.Reader, a *model.Attachment) (int64, error) {
+	h := sha256.New()
+	body := io.TeeReader(io.LimitReader(src, a.Size+1), h)
+
+	var (
+		written int64
+		bufs    = [2][]byte{make([]byte, copyBufSize), make([]byte, copyBufSize)}
+		pending []byte
+	)
+	for i := 0; ; i ^= 1 {
+		n, err := io.ReadFull(body, bufs[i])
+		if n > 0 {
+			if pending != nil {
+				m, werr := w.Write(pending)
+				written += int64(m)
+				if werr != nil {
+					return written, werr
+				}
+			}
+			pending = bufs[i][:n]
+		}
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			break
+		}
+		if err != nil {
+			return written, err
+		}
+	}
+
+	size := written + int64(len(pending))
+	if size != a.Size || hex.EncodeToString(h.Sum(nil)) != a.SHA256 {
+		return written, errBlobChecksum
+	}
+	m, err := w.Write(pending)
+	return written + int64(m), err
+}
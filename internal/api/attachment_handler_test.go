@@ -0,0 +1,257 @@
+package api_test
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/51mans0n/avito-pvz-task/internal/api"
+	"github.com/51mans0n/avito-pvz-task/internal/blob"
+	"github.com/51mans0n/avito-pvz-task/internal/db"
+	"github.com/51mans0n/avito-pvz-task/internal/model"
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func (m *mockRepo) CreateAttachment(ctx context.Context, a *model.Attachment) error {
+	args := m.Called(ctx, a)
+	return args.Error(0)
+}
+
+func (m *mockRepo) GetAttachment(ctx context.Context, id string) (*model.Attachment, error) {
+	args := m.Called(ctx, id)
+	a, _ := args.Get(0).(*model.Attachment)
+	return a, args.Error(1)
+}
+
+func (m *mockRepo) ListAttachments(ctx context.Context, ownerType, ownerID string) ([]*model.Attachment, error) {
+	args := m.Called(ctx, ownerType, ownerID)
+	list, _ := args.Get(0).([]*model.Attachment)
+	return list, args.Error(1)
+}
+
+const testReceptionID = "0b6a3f5e-6f1c-4c55-9d0a-3f2f7a0f4c11"
+
+// минимальный валидный PNG-заголовок, по которому DetectContentType узнаёт image/png
+var pngContent = append([]byte("\x89PNG\r\n\x1a\n"), bytes.Repeat([]byte{0}, 100)...)
+
+func multipartBody(t *testing.T, fileName string, content []byte) (*bytes.Buffer, string) {
+	t.Helper()
+	var buf bytes.Buffer
+	mw := multipart.NewWriter(&buf)
+	fw, err := mw.CreateFormFile("file", fileName)
+	require.NoError(t, err)
+	_, err = fw.Write(content)
+	require.NoError(t, err)
+	require.NoError(t, mw.Close())
+	return &buf, mw.FormDataContentType()
+}
+
+func uploadRouter(mr *mockRepo, store blob.Store, limits api.AttachmentLimits) http.Handler {
+	r := chi.NewRouter()
+	r.Post("/receptions/{ownerId}/attachments",
+		api.UploadAttachmentHandler(mr, store, model.AttachmentOwnerReception, limits))
+	return r
+}
+
+func newUploadRequest(t *testing.T, content []byte, role string) *http.Request {
+	body, ct := multipartBody(t, "waybill.png", content)
+	req := httptest.NewRequest(http.MethodPost, "/receptions/"+testReceptionID+"/attachments", body)
+	req.Header.Set("Content-Type", ct)
+	return req.WithContext(api.WithRole(req.Context(), role))
+}
+
+func TestUploadAttachmentHandler_Success(t *testing.T) {
+	store, err := blob.NewLocalStore(t.TempDir())
+	require.NoError(t, err)
+	mr := new(mockRepo)
+	mr.On("CreateAttachment", mock.Anything, mock.MatchedBy(func(a *model.Attachment) bool {
+		return a.OwnerID == testReceptionID && a.ContentType == "image/png" &&
+			a.FileName == "waybill.png" && a.Size == int64(len(pngContent))
+	})).Return(nil).Once()
+
+	sum := sha256.Sum256(pngContent)
+	req := newUploadRequest(t, pngContent, "employee")
+	req.Header.Set("X-Content-SHA256", hex.EncodeToString(sum[:]))
+
+	rr := httptest.NewRecorder()
+	uploadRouter(mr, store, api.DefaultAttachmentLimits).ServeHTTP(rr, req)
+	require.Equal(t, http.StatusCreated, rr.Code)
+
+	var a model.Attachment
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &a))
+	require.Equal(t, hex.EncodeToString(sum[:]), a.SHA256)
+
+	rc, err := store.Get(context.Background(), "reception/"+a.ID)
+	require.NoError(t, err)
+	require.NoError(t, rc.Close())
+	mr.AssertExpectations(t)
+}
+
+func TestUploadAttachmentHandler_TooLarge(t *testing.T) {
+	store, err := blob.NewLocalStore(t.TempDir())
+	require.NoError(t, err)
+	mr := new(mockRepo)
+
+	limits := api.AttachmentLimits{MaxBytes: 50, ContentTypes: []string{"image/png"}}
+	rr := httptest.NewRecorder()
+	uploadRouter(mr, store, limits).ServeHTTP(rr, newUploadRequest(t, pngContent, "employee"))
+
+	require.Equal(t, http.StatusRequestEntityTooLarge, rr.Code)
+	mr.AssertNotCalled(t, "CreateAttachment")
+}
+
+func TestUploadAttachmentHandler_UnsupportedType(t *testing.T) {
+	store, err := blob.NewLocalStore(t.TempDir())
+	require.NoError(t, err)
+	mr := new(mockRepo)
+
+	rr := httptest.NewRecorder()
+	uploadRouter(mr, store, api.DefaultAttachmentLimits).
+		ServeHTTP(rr, newUploadRequest(t, []byte("#!/bin/sh\nrm -rf /\n"), "employee"))
+
+	require.Equal(t, http.StatusUnsupportedMediaType, rr.Code)
+	mr.AssertNotCalled(t, "CreateAttachment")
+}
+
+func TestUploadAttachmentHandler_ChecksumMismatch(t *testing.T) {
+	store, err := blob.NewLocalStore(t.TempDir())
+	require.NoError(t, err)
+	mr := new(mockRepo)
+
+	req := newUploadRequest(t, pngContent, "employee")
+	req.Header.Set("X-Content-SHA256", strings.Repeat("0", 64))
+	rr := httptest.NewRecorder()
+	uploadRouter(mr, store, api.DefaultAttachmentLimits).ServeHTTP(rr, req)
+
+	require.Equal(t, http.StatusUnprocessableEntity, rr.Code)
+	require.Contains(t, rr.Body.String(), "checksum_mismatch")
+	mr.AssertNotCalled(t, "CreateAttachment")
+}
+
+func TestUploadAttachmentHandler_OwnerNotFound(t *testing.T) {
+	store, err := blob.NewLocalStore(t.TempDir())
+	require.NoError(t, err)
+	mr := new(mockRepo)
+	var key string
+	mr.On("CreateAttachment", mock.Anything, mock.AnythingOfType("*model.Attachment")).
+		Run(func(args mock.Arguments) { key = args.Get(1).(*model.Attachment).StorageKey }).
+		Return(db.ErrAttachmentOwnerNotFound).Once()
+
+	rr := httptest.NewRecorder()
+	uploadRouter(mr, store, api.DefaultAttachmentLimits).ServeHTTP(rr, newUploadRequest(t, pngContent, "employee"))
+
+	require.Equal(t, http.StatusNotFound, rr.Code)
+	// загруженный файл не должен остаться в хранилище
+	_, err = store.Get(context.Background(), key)
+	require.ErrorIs(t, err, blob.ErrNotFound)
+}
+
+func TestUploadAttachmentHandler_Forbidden(t *testing.T) {
+	mr := new(mockRepo)
+	rr := httptest.NewRecorder()
+	uploadRouter(mr, nil, api.DefaultAttachmentLimits).ServeHTTP(rr, newUploadRequest(t, pngContent, "client"))
+	require.Equal(t, http.StatusForbidden, rr.Code)
+}
+
+func downloadRouter(mr *mockRepo, store blob.Store) http.Handler {
+	r := chi.NewRouter()
+	r.Get("/attachments/{attachmentId}", api.DownloadAttachmentHandler(mr, store))
+	return r
+}
+
+func storedAttachment(t *testing.T, store blob.Store, content []byte) *model.Attachment {
+	a := &model.Attachment{
+		ID:          "5d1c9a8e-2b7e-4d8a-8f8e-1b2c3d4e5f60",
+		OwnerType:   model.AttachmentOwnerReception,
+		OwnerID:     testReceptionID,
+		FileName:    "waybill.png",
+		ContentType: "image/png",
+		StorageKey:  "reception/5d1c9a8e-2b7e-4d8a-8f8e-1b2c3d4e5f60",
+	}
+	size, sum, err := store.Put(context.Background(), a.StorageKey, bytes.NewReader(content))
+	require.NoError(t, err)
+	a.Size, a.SHA256 = size, sum
+	return a
+}
+
+func TestDownloadAttachmentHandler_Success(t *testing.T) {
+	store, err := blob.NewLocalStore(t.TempDir())
+	require.NoError(t, err)
+	a := storedAttachment(t, store, pngContent)
+	mr := new(mockRepo)
+	mr.On("GetAttachment", mock.Anything, a.ID).Return(a, nil).Once()
+
+	req := httptest.NewRequest(http.MethodGet, "/attachments/"+a.ID, nil)
+	req = req.WithContext(api.WithRole(req.Context(), "moderator"))
+	rr := httptest.NewRecorder()
+	downloadRouter(mr, store).ServeHTTP(rr, req)
+
+	require.Equal(t, http.StatusOK, rr.Code)
+	require.Equal(t, "image/png", rr.Header().Get("Content-Type"))
+	require.Equal(t, a.SHA256, rr.Header().Get("X-Content-SHA256"))
+	require.Equal(t, pngContent, rr.Body.Bytes())
+}
+
+func TestDownloadAttachmentHandler_CorruptedContent(t *testing.T) {
+	store, err := blob.NewLocalStore(t.TempDir())
+	require.NoError(t, err)
+	a := storedAttachment(t, store, pngContent)
+	a.SHA256 = strings.Repeat("0", 64)
+	mr := new(mockRepo)
+	mr.On("GetAttachment", mock.Anything, a.ID).Return(a, nil).Once()
+
+	req := httptest.NewRequest(http.MethodGet, "/attachments/"+a.ID, nil)
+	req = req.WithContext(api.WithRole(req.Context(), "employee"))
+	rr := httptest.NewRecorder()
+	downloadRouter(mr, store).ServeHTTP(rr, req)
+
+	require.Equal(t, http.StatusInternalServerError, rr.Code)
+	require.NotContains(t, rr.Body.String(), "PNG")
+}
+
+func TestDownloadAttachmentHandler_CorruptedLargeContent(t *testing.T) {
+	store, err := blob.NewLocalStore(t.TempDir())
+	require.NoError(t, err)
+	content := bytes.Repeat(pngContent, 100<<10/len(pngContent)+1)
+	a := storedAttachment(t, store, content)
+	a.SHA256 = strings.Repeat("0", 64)
+	mr := new(mockRepo)
+	mr.On("GetAttachment", mock.Anything, a.ID).Return(a, nil).Once()
+
+	router := downloadRouter(mr, store)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		router.ServeHTTP(w, r.WithContext(api.WithRole(r.Context(), "employee")))
+	}))
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL + "/attachments/" + a.ID)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	// заголовки уже ушли, поэтому обрыв виден только по недочитанному телу
+	got, err := io.ReadAll(resp.Body)
+	require.Error(t, err)
+	require.Less(t, len(got), len(content))
+}
+
+func TestDownloadAttachmentHandler_NotFound(t *testing.T) {
+	mr := new(mockRepo)
+	mr.On("GetAttachment", mock.Anything, "5d1c9a8e-2b7e-4d8a-8f8e-1b2c3d4e5f60").Return(nil, nil).Once()
+
+	req := httptest.NewRequest(http.MethodGet, "/attachments/5d1c9a8e-2b7e-4d8a-8f8e-1b2c3d4e5f60", nil)
+	req = req.WithContext(api.WithRole(req.Context(), "employee"))
+	rr := httptest.NewRecorder()
+	downloadRouter(mr, nil).ServeHTTP(rr, req)
+
+	require.Equal(t, http.StatusNotFound, rr.Code)
+}
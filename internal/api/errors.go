@@ -0,0 +1,138 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/51mans0n/avito-pvz-task/internal/db"
+	"github.com/51mans0n/avito-pvz-task/internal/logging"
+	"github.com/51mans0n/avito-pvz-task/internal/model"
+)
+
+// коды ошибок, на которые может опираться клиент
+const (
+	codeReceptionAlreadyOpen = "reception_already_open"
+	codePVZNotFound          = "pvz_not_found"
+	codeProductNotFound      = "product_not_found"
+	codeReceptionClosed      = "reception_closed"
+	codeNothingToUndo        = "nothing_to_undo"
+	codeUnknownProductType   = "unknown_product_type"
+	codeInvalidQuantity      = "invalid_quantity"
+	codeSerialRequired       = "serial_number_required"
+	codeProductTypeExists    = "product_type_exists"
+	codeProductTypeNotFound  = "product_type_not_found"
+	codeDuplicateBarcode     = "duplicate_barcode"
+	codeReceptionOpen        = "reception_open"
+	codeInvalidTransition    = "invalid_transition"
+	codeStorageCellExists    = "storage_cell_exists"
+	codeStorageCellNotFound  = "storage_cell_not_found"
+	codeStorageCellFull      = "storage_cell_full"
+	codeProductNotInStock    = "product_not_in_stock"
+	codeTransferNotFound     = "transfer_not_found"
+	codeInvalidTransferState = "invalid_transfer_state"
+	codeNoActiveReception    = "no_active_reception"
+	codeNoProducts           = "no_products"
+	codeReceptionNotFound    = "reception_not_found"
+	codeReceptionNotClosed   = "reception_not_closed"
+	codeReopenExpired        = "reopen_window_expired"
+	codeApprovalRequired     = "approval_required"
+	codeInvalidApproval      = "invalid_approval"
+	codeApprovalUsed         = "approval_already_used"
+	codeInvalidCursor        = "invalid_cursor"
+	codeVersionMismatch      = "version_mismatch"
+	codeNotFound             = "not_found"
+	codeConflict             = "conflict"
+	codeAlreadyExists        = "already_exists"
+	codeValidation           = "validation_failed"
+	codeBadRequest           = "bad_request"
+	codePrecondition         = "precondition_failed"
+	codeUnavailable          = "unavailable"
+	codeInternal             = "internal_error"
+)
+
+// repoErrorCodes - стабильный код для каждой ошибки репозитория; порядок важен,
+// первой проверяется самая конкретная ошибка
+var repoErrorCodes = []struct {
+	err  error
+	code string
+}{
+	{db.ErrReceptionAlreadyOpen, codeReceptionAlreadyOpen},
+	{db.ErrPVZNotFound, codePVZNotFound},
+	{db.ErrProductNotFound, codeProductNotFound},
+	{db.ErrReceptionClosed, codeReceptionClosed},
+	{db.ErrReceptionOpen, codeReceptionOpen},
+	{db.ErrInvalidTransition, codeInvalidTransition},
+	{db.ErrNothingToUndo, codeNothingToUndo},
+	{db.ErrUnknownProductType, codeUnknownProductType},
+	{db.ErrSerialRequired, codeSerialRequired},
+	{db.ErrSerialQuantity, codeInvalidQuantity},
+	{db.ErrProductTypeExists, codeProductTypeExists},
+	{db.ErrProductTypeNotFound, codeProductTypeNotFound},
+	{db.ErrDuplicateBarcode, codeDuplicateBarcode},
+	{db.ErrStorageCellExists, codeStorageCellExists},
+	{db.ErrStorageCellNotFound, codeStorageCellNotFound},
+	{db.ErrStorageCellFull, codeStorageCellFull},
+	{db.ErrProductNotInStock, codeProductNotInStock},
+	{db.ErrTransferNotFound, codeTransferNotFound},
+	{db.ErrTransferState, codeInvalidTransferState},
+	{db.ErrInvalidCursor, codeInvalidCursor},
+	{db.ErrAttachmentOwnerNotFound, codeOwnerNotFound},
+	{db.ErrNoActiveReception, codeNoActiveReception},
+	{db.ErrNoProducts, codeNoProducts},
+	{db.ErrEmptyBatch, codeValidation},
+	{db.ErrReceptionNotFound, codeReceptionNotFound},
+	{db.ErrReceptionNotClosed, codeReceptionNotClosed},
+	{db.ErrReopenExpired, codeReopenExpired},
+	{db.ErrApprovalUsed, codeApprovalUsed},
+	{db.ErrVersionMismatch, codeVersionMismatch},
+}
+
+// writeError пишет JSON-ошибку со стабильным кодом
+func writeError(w http.ResponseWriter, status int, code, msg string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(model.ErrorResponse{Code: code, Message: msg}); err != nil {
+		logging.S().Warnw("encode error", "err", err)
+	}
+}
+
+// writeRepoError отвечает на ошибку репозитория. Статус выбирается по категории
+// ошибки (404/409/412/422/400), код - по самой ошибке. Остальные ошибки считаются
+// внутренними: они уходят в лог с op и keysAndValues, а клиент видит только 500.
+func writeRepoError(w http.ResponseWriter, err error, op string, keysAndValues ...any) {
+	status, code := classify(err)
+	if status == http.StatusInternalServerError {
+		logging.S().Errorw(op, append(keysAndValues, "err", err)...)
+		writeError(w, status, code, "server error")
+		return
+	}
+	writeError(w, status, code, err.Error())
+}
+
+// classify - HTTP-статус и код ответа для ошибки репозитория
+func classify(err error) (int, string) {
+	status, code := http.StatusInternalServerError, codeInternal
+	switch {
+	case errors.Is(err, db.ErrNotFound):
+		status, code = http.StatusNotFound, codeNotFound
+	case errors.Is(err, db.ErrAlreadyExists):
+		status, code = http.StatusConflict, codeAlreadyExists
+	case errors.Is(err, db.ErrConflict):
+		status, code = http.StatusConflict, codeConflict
+	case errors.Is(err, db.ErrValidation):
+		status, code = http.StatusUnprocessableEntity, codeValidation
+	case errors.Is(err, db.ErrInvalidArgument):
+		status, code = http.StatusBadRequest, codeBadRequest
+	case errors.Is(err, db.ErrPrecondition):
+		status, code = http.StatusPreconditionFailed, codePrecondition
+	default:
+		return status, code
+	}
+	for _, c := range repoErrorCodes {
+		if errors.Is(err, c.err) {
+			return status, c.code
+		}
+	}
+	return status, code
+}
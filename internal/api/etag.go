@@ -0,0 +1,62 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/51mans0n/avito-pvz-task/internal/db"
+)
+
+// etag - сильный ETag для версии объекта: "3"
+func etag(version int) string {
+	return `"` + strconv.Itoa(version) + `"`
+}
+
+// setETag отдаёт версию объекта в заголовке ETag
+func setETag(w http.ResponseWriter, version int) {
+	w.Header().Set("ETag", etag(version))
+}
+
+// notModified - клиенту уже известна текущая версия (If-None-Match, слабое сравнение)
+func notModified(r *http.Request, version int) bool {
+	header := r.Header.Get("If-None-Match")
+	if header == "" {
+		return false
+	}
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimPrefix(strings.TrimSpace(tag), "W/")
+		if tag == "*" || tag == etag(version) {
+			return true
+		}
+	}
+	return false
+}
+
+// ifMatchVersion разбирает If-Match в ожидаемую версию: 0 - заголовка нет или "*",
+// проверять нечего. Слабые и чужие ETag не совпадают ни с одной версией
+// (If-Match сравнивает только сильные), тогда ok == false.
+func ifMatchVersion(r *http.Request) (version int, ok bool) {
+	header := strings.TrimSpace(r.Header.Get("If-Match"))
+	if header == "" || header == "*" {
+		return 0, true
+	}
+	unquoted, found := strings.CutPrefix(header, `"`)
+	if !found {
+		return 0, false
+	}
+	unquoted, found = strings.CutSuffix(unquoted, `"`)
+	if !found {
+		return 0, false
+	}
+	version, err := strconv.Atoi(unquoted)
+	if err != nil || version <= 0 {
+		return 0, false
+	}
+	return version, true
+}
+
+// writeVersionMismatch - 412 на If-Match, который не совпадёт ни с одной версией
+func writeVersionMismatch(w http.ResponseWriter) {
+	writeError(w, http.StatusPreconditionFailed, codeVersionMismatch, db.ErrVersionMismatch.Error())
+}
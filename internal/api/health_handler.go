@@ -0,0 +1,21 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/51mans0n/avito-pvz-task/internal/logging"
+)
+
+// ReadyHandler - readiness-проба: 200, пока ready возвращает nil, иначе 503
+func ReadyHandler(ready func() error) http.HandlerFunc {
+	return func(w http.ResponseWriter, _ *http.Request) {
+		if err := ready(); err != nil {
+			// причина уже в логе проверки, клиенту - без подробностей
+			writeError(w, http.StatusServiceUnavailable, codeUnavailable, "database is unavailable")
+			return
+		}
+		if _, err := w.Write([]byte("OK")); err != nil {
+			logging.S().Warnw("write ready", "err", err)
+		}
+	}
+}
@@ -0,0 +1,23 @@
+package api_test
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/51mans0n/avito-pvz-task/internal/api"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadyHandler(t *testing.T) {
+	rr := httptest.NewRecorder()
+	api.ReadyHandler(func() error { return nil }).ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/ready", nil))
+	require.Equal(t, http.StatusOK, rr.Code)
+
+	rr = httptest.NewRecorder()
+	api.ReadyHandler(func() error { return errors.New("dial tcp 10.0.0.5:5432: connection refused") }).
+		ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/ready", nil))
+	require.Equal(t, http.StatusServiceUnavailable, rr.Code)
+	require.NotContains(t, rr.Body.String(), "10.0.0.5")
+}
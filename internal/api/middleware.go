@@ -5,6 +5,7 @@ import (
 	"strings"
 
 	"github.com/51mans0n/avito-pvz-task/internal/auth"
+	"github.com/51mans0n/avito-pvz-task/internal/logging"
 )
 
 // AuthMiddleware проверяет Bearer‑токен и вкладывает роль в контекст.
@@ -19,7 +20,8 @@ func AuthMiddleware(next http.Handler) http.Handler {
 		token := strings.TrimPrefix(h, "Bearer ")
 		role, err := auth.ExtractRole(token) // <-- auth.ExtractRole мы писали раньше
 		if err != nil {
-			http.Error(w, `unauthorized: `+err.Error(), http.StatusUnauthorized)
+			logging.S().Debugw("reject token", "err", err)
+			http.Error(w, `unauthorized`, http.StatusUnauthorized)
 			return
 		}
 
@@ -3,6 +3,10 @@ package api
 import (
 	"encoding/json"
 	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/51mans0n/avito-pvz-task/internal/logging"
@@ -24,8 +28,11 @@ func CreateProductHandler(repo db.Repository) http.HandlerFunc {
 		}
 
 		var req struct {
-			Type  string `json:"type"`
-			PVZID string `json:"pvzId"`
+			Type         string `json:"type"`
+			PVZID        string `json:"pvzId"`
+			Barcode      string `json:"barcode"`
+			SerialNumber string `json:"serialNumber"`
+			Quantity     int    `json:"quantity"` // не передан - одна единица
 		}
 		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 			http.Error(w, `{"message":"invalid json"}`, http.StatusBadRequest)
@@ -39,19 +46,29 @@ func CreateProductHandler(repo db.Repository) http.HandlerFunc {
 			http.Error(w, `{"message":"pvzId invalid"}`, http.StatusBadRequest)
 			return
 		}
+		if req.Quantity < 0 {
+			writeError(w, http.StatusBadRequest, codeInvalidQuantity, "quantity must be positive")
+			return
+		}
 
 		prod := &model.Product{
-			ID:       uuid.New().String(),
-			Type:     req.Type,
-			DateTime: time.Now(),
+			ID:           uuid.New().String(),
+			Type:         req.Type,
+			Quantity:     req.Quantity,
+			DateTime:     time.Now(),
+			Barcode:      strings.TrimSpace(req.Barcode),
+			SerialNumber: strings.TrimSpace(req.SerialNumber),
 		}
 
 		if err := repo.CreateProduct(r.Context(), req.PVZID, prod); err != nil {
-			http.Error(w, `{"message":"`+err.Error()+`"}`, http.StatusBadRequest)
+			writeRepoError(w, err, "create product", "pvz", req.PVZID)
 			return
 		}
 
-		metrics.ProductsAdded.Inc()
+		metrics.ProductsAdded.Add(float64(prod.Quantity))
+		for _, warn := range prod.Warnings {
+			logging.S().Warnw("duplicate barcode scan", "product", prod.ID, "warning", warn)
+		}
 
 		w.WriteHeader(http.StatusCreated)
 		if err := json.NewEncoder(w).Encode(prod); err != nil {
@@ -60,6 +77,151 @@ func CreateProductHandler(repo db.Repository) http.HandlerFunc {
 	}
 }
 
+// CreateProductsBatchHandler - employee добавляет пачку товаров (паллету) одним
+// запросом; товары вставляются атомарно: либо все, либо ни одного.
+func CreateProductsBatchHandler(repo db.Repository, maxBatch int) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		role := GetRole(r.Context())
+		if role != "employee" {
+			http.Error(w, `{"message":"access denied"}`, http.StatusForbidden)
+			return
+		}
+
+		var req struct {
+			PVZID    string `json:"pvzId"`
+			Products []struct {
+				Type         string `json:"type"`
+				Barcode      string `json:"barcode"`
+				SerialNumber string `json:"serialNumber"`
+				Quantity     int    `json:"quantity"`
+			} `json:"products"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, `{"message":"invalid json"}`, http.StatusBadRequest)
+			return
+		}
+		if _, err := uuid.Parse(req.PVZID); err != nil {
+			http.Error(w, `{"message":"pvzId invalid"}`, http.StatusBadRequest)
+			return
+		}
+		if len(req.Products) == 0 {
+			http.Error(w, `{"message":"products are required"}`, http.StatusBadRequest)
+			return
+		}
+		if len(req.Products) > maxBatch {
+			http.Error(w, `{"message":"too many products, max `+strconv.Itoa(maxBatch)+`"}`, http.StatusBadRequest)
+			return
+		}
+
+		now := time.Now()
+		prods := make([]*model.Product, 0, len(req.Products))
+		for i, p := range req.Products {
+			if p.Type == "" {
+				http.Error(w, `{"message":"products[`+strconv.Itoa(i)+`]: type is required"}`, http.StatusBadRequest)
+				return
+			}
+			if p.Quantity < 0 {
+				writeError(w, http.StatusBadRequest, codeInvalidQuantity, "products["+strconv.Itoa(i)+"]: quantity must be positive")
+				return
+			}
+			prods = append(prods, &model.Product{
+				ID:           uuid.New().String(),
+				Type:         p.Type,
+				Quantity:     p.Quantity,
+				DateTime:     now,
+				Barcode:      strings.TrimSpace(p.Barcode),
+				SerialNumber: strings.TrimSpace(p.SerialNumber),
+			})
+		}
+
+		if err := repo.CreateProducts(r.Context(), req.PVZID, prods); err != nil {
+			writeRepoError(w, err, "create products", "pvz", req.PVZID)
+			return
+		}
+
+		metrics.ProductsAdded.Add(float64(model.TotalQuantity(prods)))
+
+		resp := model.ProductBatchResponse{
+			ReceptionID: prods[0].ReceptionID,
+			IDs:         make([]string, 0, len(prods)),
+		}
+		for _, p := range prods {
+			resp.IDs = append(resp.IDs, p.ID)
+			resp.Warnings = append(resp.Warnings, p.Warnings...)
+		}
+		for _, warn := range resp.Warnings {
+			logging.S().Warnw("duplicate barcode scan", "reception", resp.ReceptionID, "warning", warn)
+		}
+
+		w.WriteHeader(http.StatusCreated)
+		if err := json.NewEncoder(w).Encode(resp); err != nil {
+			logging.S().Warnw("encode batch", "err", err)
+		}
+	}
+}
+
+// GetProductByBarcodeHandler - поиск товара по штрихкоду вместе с приёмкой и ПВЗ
+func GetProductByBarcodeHandler(repo db.Repository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		role := GetRole(r.Context())
+		if role != "employee" && role != "moderator" {
+			http.Error(w, `{"message":"forbidden"}`, http.StatusForbidden)
+			return
+		}
+
+		code, err := url.PathUnescape(chi.URLParam(r, "code"))
+		if err != nil || strings.TrimSpace(code) == "" {
+			http.Error(w, `{"message":"invalid barcode"}`, http.StatusBadRequest)
+			return
+		}
+
+		found, err := repo.GetProductByBarcode(r.Context(), code)
+		if err != nil {
+			logging.S().Errorw("get product by barcode", "barcode", code, "err", err)
+			writeError(w, http.StatusInternalServerError, codeInternal, "server error")
+			return
+		}
+		if found == nil {
+			writeError(w, http.StatusNotFound, codeProductNotFound, "product not found")
+			return
+		}
+		if err := json.NewEncoder(w).Encode(found); err != nil {
+			logging.S().Warnw("encode product", "err", err)
+		}
+	}
+}
+
+// GetProductHandler - товар по id вместе с приёмкой, ПВЗ и ячейкой хранения
+func GetProductHandler(repo db.Repository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		role := GetRole(r.Context())
+		if role != "employee" && role != "moderator" {
+			http.Error(w, `{"message":"forbidden"}`, http.StatusForbidden)
+			return
+		}
+
+		productId := chi.URLParam(r, "productId")
+		if _, err := uuid.Parse(productId); err != nil {
+			http.Error(w, `{"message":"invalid productId"}`, http.StatusBadRequest)
+			return
+		}
+
+		found, err := repo.GetProduct(r.Context(), productId)
+		if err != nil {
+			logging.S().Errorw("get product", "product", productId, "err", err)
+			writeError(w, http.StatusInternalServerError, codeInternal, "server error")
+			return
+		}
+		if found == nil {
+			writeError(w, http.StatusNotFound, codeProductNotFound, "product not found")
+			return
+		}
+		if err := json.NewEncoder(w).Encode(found); err != nil {
+			logging.S().Warnw("encode product", "err", err)
+		}
+	}
+}
+
 // DeleteLastProductHandler - удалить последний товар LIFO
 func DeleteLastProductHandler(repo db.Repository) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
@@ -75,8 +237,8 @@ func DeleteLastProductHandler(repo db.Repository) http.HandlerFunc {
 			return
 		}
 
-		if err := repo.DeleteLastProduct(r.Context(), pvzId); err != nil {
-			http.Error(w, `{"message":"`+err.Error()+`"}`, http.StatusBadRequest)
+		if err := repo.DeleteLastProduct(r.Context(), pvzId, role); err != nil {
+			writeRepoError(w, err, "delete last product", "pvz", pvzId)
 			return
 		}
 		w.WriteHeader(http.StatusOK)
@@ -85,3 +247,233 @@ func DeleteLastProductHandler(repo db.Repository) http.HandlerFunc {
 		}
 	}
 }
+
+// UndoLastDeleteHandler - вернуть последний удалённый товар открытой приёмки
+func UndoLastDeleteHandler(repo db.Repository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		role := GetRole(r.Context())
+		if role != "employee" && role != "moderator" {
+			http.Error(w, `{"message":"forbidden"}`, http.StatusForbidden)
+			return
+		}
+
+		pvzId := chi.URLParam(r, "pvzId")
+		if _, err := uuid.Parse(pvzId); err != nil {
+			http.Error(w, `{"message":"invalid pvzId"}`, http.StatusBadRequest)
+			return
+		}
+
+		prod, err := repo.UndoLastDelete(r.Context(), pvzId)
+		if err != nil {
+			writeRepoError(w, err, "undo last delete", "pvz", pvzId)
+			return
+		}
+
+		resp := model.ProductResponse{ID: prod.ID, DateTime: prod.DateTime, Type: prod.Type, Quantity: prod.Quantity, ReceptionID: prod.ReceptionID}
+		if err := json.NewEncoder(w).Encode(resp); err != nil {
+			logging.S().Warnw("encode product", "err", err)
+		}
+	}
+}
+
+// DeleteProductHandler - удаление конкретного товара по id с кодом причины.
+// Сотрудник удаляет только из открытой приёмки, модератор - и из закрытой.
+func DeleteProductHandler(repo db.Repository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		role := GetRole(r.Context())
+		if role != "employee" && role != "moderator" {
+			http.Error(w, `{"message":"forbidden"}`, http.StatusForbidden)
+			return
+		}
+
+		productId := chi.URLParam(r, "productId")
+		if _, err := uuid.Parse(productId); err != nil {
+			http.Error(w, `{"message":"invalid productId"}`, http.StatusBadRequest)
+			return
+		}
+
+		var req struct {
+			Reason  string `json:"reason"`
+			Comment string `json:"comment"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, `{"message":"invalid json"}`, http.StatusBadRequest)
+			return
+		}
+		if !slices.Contains(model.DeletionReasons, req.Reason) {
+			http.Error(w, `{"message":"reason must be one of: `+strings.Join(model.DeletionReasons, ", ")+`"}`, http.StatusBadRequest)
+			return
+		}
+		if req.Reason == model.DeletionReasonOther && strings.TrimSpace(req.Comment) == "" {
+			http.Error(w, `{"message":"comment is required for reason other"}`, http.StatusBadRequest)
+			return
+		}
+
+		del := &model.ProductDeletion{
+			ID:        uuid.New().String(),
+			ProductID: productId,
+			Reason:    req.Reason,
+			Comment:   req.Comment,
+			DeletedBy: role,
+			DeletedAt: time.Now(),
+		}
+		if err := repo.DeleteProduct(r.Context(), del, role == "moderator"); err != nil {
+			writeRepoError(w, err, "delete product", "product", productId)
+			return
+		}
+
+		metrics.ProductsDeleted.WithLabelValues(del.Reason).Inc()
+
+		if err := json.NewEncoder(w).Encode(del); err != nil {
+			logging.S().Warnw("encode deletion", "err", err)
+		}
+	}
+}
+
+// TransitionProductHandler - перевод товара закрытой приёмки в статус status
+// (на склад, выдача покупателю, возврат отправителю)
+func TransitionProductHandler(repo db.Repository, status string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		role := GetRole(r.Context())
+		if role != "employee" && role != "moderator" {
+			http.Error(w, `{"message":"forbidden"}`, http.StatusForbidden)
+			return
+		}
+
+		productId := chi.URLParam(r, "productId")
+		if _, err := uuid.Parse(productId); err != nil {
+			http.Error(w, `{"message":"invalid productId"}`, http.StatusBadRequest)
+			return
+		}
+
+		prod, err := repo.TransitionProduct(r.Context(), productId, status, time.Now())
+		if err != nil {
+			writeRepoError(w, err, "transition product", "product", productId, "status", status)
+			return
+		}
+
+		metrics.ProductTransitions.WithLabelValues(status).Inc()
+
+		resp := convertProduct(prod)
+		if err := json.NewEncoder(w).Encode(resp); err != nil {
+			logging.S().Warnw("encode product", "err", err)
+		}
+	}
+}
+
+// ListProductsHandler - поиск товаров по всем ПВЗ с фильтрами и курсорной пагинацией.
+// На маршруте /pvz/{pvzId}/products ПВЗ берётся из пути.
+func ListProductsHandler(repo db.Repository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		role := GetRole(r.Context())
+		if role != "employee" && role != "moderator" {
+			http.Error(w, `{"message":"forbidden"}`, http.StatusForbidden)
+			return
+		}
+
+		query := r.URL.Query()
+		f := db.ProductListFilter{
+			PVZID:       query.Get("pvzId"),
+			City:        query.Get("city"),
+			ReceptionID: query.Get("receptionId"),
+			Type:        query.Get("type"),
+			Barcode:     strings.TrimSpace(query.Get("barcode")),
+			Cursor:      query.Get("cursor"),
+			Limit:       parseSearchLimit(query.Get("limit")),
+		}
+		if pvzId := chi.URLParam(r, "pvzId"); pvzId != "" {
+			f.PVZID = pvzId
+		}
+		if _, err := uuid.Parse(f.PVZID); f.PVZID != "" && err != nil {
+			http.Error(w, `{"message":"invalid pvzId"}`, http.StatusBadRequest)
+			return
+		}
+		if _, err := uuid.Parse(f.ReceptionID); f.ReceptionID != "" && err != nil {
+			http.Error(w, `{"message":"invalid receptionId"}`, http.StatusBadRequest)
+			return
+		}
+
+		var ok bool
+		if f.Status, ok = parseProductStatus(query.Get("status")); !ok {
+			http.Error(w, `{"message":"status must be one of: `+strings.Join(model.ProductStatuses, ", ")+`"}`, http.StatusBadRequest)
+			return
+		}
+		switch query.Get("sort") {
+		case "", "-dateTime":
+		case "dateTime":
+			f.Asc = true
+		default:
+			http.Error(w, `{"message":"sort must be dateTime or -dateTime"}`, http.StatusBadRequest)
+			return
+		}
+		var err error
+		if f.From, err = parseOptionalTime(query.Get("from")); err != nil {
+			http.Error(w, `{"message":"from must be RFC3339"}`, http.StatusBadRequest)
+			return
+		}
+		if f.To, err = parseOptionalTime(query.Get("to")); err != nil {
+			http.Error(w, `{"message":"to must be RFC3339"}`, http.StatusBadRequest)
+			return
+		}
+
+		prods, next, err := repo.ListProducts(r.Context(), f)
+		if err != nil {
+			writeRepoError(w, err, "list products")
+			return
+		}
+
+		resp := model.ProductPage{Items: make([]model.ProductResponse, 0, len(prods)), NextCursor: next}
+		for _, p := range prods {
+			resp.Items = append(resp.Items, convertProduct(p))
+		}
+		if err := json.NewEncoder(w).Encode(resp); err != nil {
+			logging.S().Warnw("encode products", "err", err)
+		}
+	}
+}
+
+// parseOptionalTime - пустая строка означает "без ограничения"
+func parseOptionalTime(s string) (*time.Time, error) {
+	if s == "" {
+		return nil, nil
+	}
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+// parseSearchLimit - размер страницы поиска: по умолчанию 20, не больше 100
+func parseSearchLimit(s string) int {
+	if l, err := strconv.Atoi(s); err == nil && l > 0 {
+		return min(l, 100)
+	}
+	return 20
+}
+
+// parseProductStatus - пустой статус означает "любой"
+func parseProductStatus(s string) (string, bool) {
+	if s == "" {
+		return "", true
+	}
+	return s, slices.Contains(model.ProductStatuses, s)
+}
+
+func convertProduct(p *model.Product) model.ProductResponse {
+	return model.ProductResponse{
+		ID:           p.ID,
+		DateTime:     p.DateTime,
+		Type:         p.Type,
+		Quantity:     p.Quantity,
+		ReceptionID:  p.ReceptionID,
+		PVZID:        p.PVZID,
+		Barcode:      p.Barcode,
+		SerialNumber: p.SerialNumber,
+		CellID:       p.CellID,
+		Status:       p.Status,
+		StoredAt:     p.StoredAt,
+		IssuedAt:     p.IssuedAt,
+		ReturnedAt:   p.ReturnedAt,
+	}
+}
@@ -3,11 +3,16 @@ package api_test
 import (
 	"bytes"
 	"context"
+	"encoding/json"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
 	"testing"
+	"time"
 
 	"github.com/51mans0n/avito-pvz-task/internal/api"
+	"github.com/51mans0n/avito-pvz-task/internal/db"
 	"github.com/51mans0n/avito-pvz-task/internal/model"
 	"github.com/go-chi/chi/v5"
 	"github.com/stretchr/testify/mock"
@@ -18,8 +23,41 @@ func (m *mockRepo) CreateProduct(ctx context.Context, pvzID string, prod *model.
 	args := m.Called(ctx, pvzID, prod)
 	return args.Error(0)
 }
-func (m *mockRepo) DeleteLastProduct(ctx context.Context, pvzID string) error {
+func (m *mockRepo) DeleteLastProduct(ctx context.Context, pvzID, actor string) error {
+	args := m.Called(ctx, pvzID, actor)
+	return args.Error(0)
+}
+func (m *mockRepo) UndoLastDelete(ctx context.Context, pvzID string) (*model.Product, error) {
 	args := m.Called(ctx, pvzID)
+	prod, _ := args.Get(0).(*model.Product)
+	return prod, args.Error(1)
+}
+
+func (m *mockRepo) DeleteProduct(ctx context.Context, del *model.ProductDeletion, allowClosed bool) error {
+	args := m.Called(ctx, del, allowClosed)
+	return args.Error(0)
+}
+
+func (m *mockRepo) GetProductByBarcode(ctx context.Context, barcode string) (*model.ProductLookup, error) {
+	args := m.Called(ctx, barcode)
+	found, _ := args.Get(0).(*model.ProductLookup)
+	return found, args.Error(1)
+}
+
+func (m *mockRepo) TransitionProduct(ctx context.Context, productID, status string, at time.Time) (*model.Product, error) {
+	args := m.Called(ctx, productID, status, at)
+	prod, _ := args.Get(0).(*model.Product)
+	return prod, args.Error(1)
+}
+
+func (m *mockRepo) ListProducts(ctx context.Context, f db.ProductListFilter) ([]*model.Product, string, error) {
+	args := m.Called(ctx, f)
+	prods, _ := args.Get(0).([]*model.Product)
+	return prods, args.String(1), args.Error(2)
+}
+
+func (m *mockRepo) CreateProducts(ctx context.Context, pvzID string, prods []*model.Product) error {
+	args := m.Called(ctx, pvzID, prods)
 	return args.Error(0)
 }
 
@@ -60,7 +98,7 @@ func TestCreateProductHandler_NoActiveReception(t *testing.T) {
 	h := api.CreateProductHandler(mr)
 
 	mr.On("CreateProduct", mock.Anything, "82cc7cda-bd24-468f-b7b7-844d66b6693c", mock.AnythingOfType("*model.Product")).
-		Return(assertAnErrorWithMessage("no active reception found")).Once()
+		Return(fmt.Errorf("%w for pvz 82cc7cda-bd24-468f-b7b7-844d66b6693c", db.ErrNoActiveReception)).Once()
 
 	body := `{"type":"обувь","pvzId":"82cc7cda-bd24-468f-b7b7-844d66b6693c"}`
 	req := httptest.NewRequest(http.MethodPost, "/products", bytes.NewBufferString(body))
@@ -69,7 +107,8 @@ func TestCreateProductHandler_NoActiveReception(t *testing.T) {
 
 	rr := httptest.NewRecorder()
 	h.ServeHTTP(rr, req)
-	require.Equal(t, http.StatusBadRequest, rr.Code)
+	require.Equal(t, http.StatusConflict, rr.Code)
+	require.Contains(t, rr.Body.String(), `"code":"no_active_reception"`)
 
 	mr.AssertExpectations(t)
 }
@@ -93,7 +132,7 @@ func TestDeleteLastProductHandler_Success(t *testing.T) {
 	mr := new(mockRepo)
 	h := api.DeleteLastProductHandler(mr)
 
-	mr.On("DeleteLastProduct", mock.Anything, "82cc7cda-bd24-468f-b7b7-844d66b6693c").
+	mr.On("DeleteLastProduct", mock.Anything, "82cc7cda-bd24-468f-b7b7-844d66b6693c", "employee").
 		Return(nil).Once()
 
 	r := chi.NewRouter()
@@ -116,8 +155,8 @@ func TestDeleteLastProductHandler_NoProducts(t *testing.T) {
 	mr := new(mockRepo)
 	h := api.DeleteLastProductHandler(mr)
 
-	mr.On("DeleteLastProduct", mock.Anything, "82cc7cda-bd24-468f-b7b7-844d66b6693c").
-		Return(assertAnErrorWithMessage("no products to delete")).Once()
+	mr.On("DeleteLastProduct", mock.Anything, "82cc7cda-bd24-468f-b7b7-844d66b6693c", "employee").
+		Return(db.ErrNoProducts).Once()
 
 	r := chi.NewRouter()
 	r.Post("/pvz/{pvzId}/delete_last_product", h)
@@ -129,8 +168,411 @@ func TestDeleteLastProductHandler_NoProducts(t *testing.T) {
 	rr := httptest.NewRecorder()
 	r.ServeHTTP(rr, req)
 
+	require.Equal(t, http.StatusConflict, rr.Code)
+	require.Contains(t, rr.Body.String(), `"code":"no_products"`)
+
+	mr.AssertExpectations(t)
+}
+
+func deleteProductRequest(t *testing.T, mr *mockRepo, role, body string) *httptest.ResponseRecorder {
+	t.Helper()
+	r := chi.NewRouter()
+	r.Delete("/products/{productId}", api.DeleteProductHandler(mr))
+
+	req := httptest.NewRequest(http.MethodDelete, "/products/5f0c8d2e-9a51-4c1b-8f3e-2d7a6b4c1e90", bytes.NewBufferString(body))
+	req = req.WithContext(api.WithRole(req.Context(), role))
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, req)
+	return rr
+}
+
+func TestDeleteProductHandler_EmployeeOpenReceptionOnly(t *testing.T) {
+	mr := new(mockRepo)
+	mr.On("DeleteProduct", mock.Anything, mock.MatchedBy(func(d *model.ProductDeletion) bool {
+		return d.ProductID == "5f0c8d2e-9a51-4c1b-8f3e-2d7a6b4c1e90" && d.Reason == "duplicate" && d.DeletedBy == "employee"
+	}), false).Return(nil).Once()
+
+	rr := deleteProductRequest(t, mr, "employee", `{"reason":"duplicate"}`)
+	require.Equal(t, http.StatusOK, rr.Code)
+	require.Contains(t, rr.Body.String(), `"reason":"duplicate"`)
+	mr.AssertExpectations(t)
+}
+
+func TestDeleteProductHandler_ModeratorMayDeleteFromClosed(t *testing.T) {
+	mr := new(mockRepo)
+	mr.On("DeleteProduct", mock.Anything, mock.AnythingOfType("*model.ProductDeletion"), true).Return(nil).Once()
+
+	rr := deleteProductRequest(t, mr, "moderator", `{"reason":"damaged"}`)
+	require.Equal(t, http.StatusOK, rr.Code)
+	mr.AssertExpectations(t)
+}
+
+func TestDeleteProductHandler_ReasonValidation(t *testing.T) {
+	mr := new(mockRepo)
+
+	rr := deleteProductRequest(t, mr, "employee", `{}`)
+	require.Equal(t, http.StatusBadRequest, rr.Code)
+
+	rr = deleteProductRequest(t, mr, "employee", `{"reason":"oops"}`)
+	require.Equal(t, http.StatusBadRequest, rr.Code)
+
+	rr = deleteProductRequest(t, mr, "employee", `{"reason":"other"}`)
+	require.Equal(t, http.StatusBadRequest, rr.Code)
+	require.Contains(t, rr.Body.String(), "comment is required")
+
+	mr.AssertNotCalled(t, "DeleteProduct")
+}
+
+func TestDeleteProductHandler_Errors(t *testing.T) {
+	mr := new(mockRepo)
+	mr.On("DeleteProduct", mock.Anything, mock.Anything, false).Return(db.ErrReceptionClosed).Once()
+	rr := deleteProductRequest(t, mr, "employee", `{"reason":"scan_error"}`)
+	require.Equal(t, http.StatusConflict, rr.Code)
+	require.Contains(t, rr.Body.String(), "reception_closed")
+
+	mr.On("DeleteProduct", mock.Anything, mock.Anything, false).Return(db.ErrProductNotFound).Once()
+	rr = deleteProductRequest(t, mr, "employee", `{"reason":"scan_error"}`)
+	require.Equal(t, http.StatusNotFound, rr.Code)
+
+	rr = deleteProductRequest(t, mr, "client", `{"reason":"scan_error"}`)
+	require.Equal(t, http.StatusForbidden, rr.Code)
+	mr.AssertExpectations(t)
+}
+
+func TestUndoLastDeleteHandler(t *testing.T) {
+	mr := new(mockRepo)
+	r := chi.NewRouter()
+	r.Post("/pvz/{pvzId}/undo_last_delete", api.UndoLastDeleteHandler(mr))
+
+	call := func(role string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/pvz/82cc7cda-bd24-468f-b7b7-844d66b6693c/undo_last_delete", nil)
+		req = req.WithContext(api.WithRole(req.Context(), role))
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, req)
+		return rr
+	}
+
+	mr.On("UndoLastDelete", mock.Anything, "82cc7cda-bd24-468f-b7b7-844d66b6693c").
+		Return(&model.Product{ID: "prod-1", ReceptionID: "rec-1", Type: "обувь"}, nil).Once()
+	rr := call("employee")
+	require.Equal(t, http.StatusOK, rr.Code)
+	require.Contains(t, rr.Body.String(), `"id":"prod-1"`)
+
+	mr.On("UndoLastDelete", mock.Anything, "82cc7cda-bd24-468f-b7b7-844d66b6693c").
+		Return(nil, db.ErrNothingToUndo).Once()
+	rr = call("employee")
+	require.Equal(t, http.StatusConflict, rr.Code)
+	require.Contains(t, rr.Body.String(), "nothing_to_undo")
+
+	rr = call("client")
+	require.Equal(t, http.StatusForbidden, rr.Code)
+	mr.AssertExpectations(t)
+}
+
+func TestCreateProductHandler_TypeRules(t *testing.T) {
+	mr := new(mockRepo)
+	h := api.CreateProductHandler(mr)
+	post := func(body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/products", bytes.NewBufferString(body))
+		req = req.WithContext(api.WithRole(req.Context(), "employee"))
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, req)
+		return rr
+	}
+
+	mr.On("CreateProduct", mock.Anything, "82cc7cda-bd24-468f-b7b7-844d66b6693c", mock.AnythingOfType("*model.Product")).
+		Return(db.ErrUnknownProductType).Once()
+	rr := post(`{"type":"электроникa","pvzId":"82cc7cda-bd24-468f-b7b7-844d66b6693c"}`)
+	require.Equal(t, http.StatusUnprocessableEntity, rr.Code)
+	require.Contains(t, rr.Body.String(), "unknown_product_type")
+
+	mr.On("CreateProduct", mock.Anything, "82cc7cda-bd24-468f-b7b7-844d66b6693c", mock.AnythingOfType("*model.Product")).
+		Return(db.ErrSerialRequired).Once()
+	rr = post(`{"type":"электроника","pvzId":"82cc7cda-bd24-468f-b7b7-844d66b6693c"}`)
+	require.Equal(t, http.StatusUnprocessableEntity, rr.Code)
+	require.Contains(t, rr.Body.String(), "serial_number_required")
+
+	mr.On("CreateProduct", mock.Anything, "82cc7cda-bd24-468f-b7b7-844d66b6693c", mock.MatchedBy(func(p *model.Product) bool {
+		return p.SerialNumber == "SN-42"
+	})).Return(nil).Once()
+	rr = post(`{"type":"электроника","pvzId":"82cc7cda-bd24-468f-b7b7-844d66b6693c","serialNumber":" SN-42 "}`)
+	require.Equal(t, http.StatusCreated, rr.Code)
+
+	mr.AssertExpectations(t)
+}
+
+func TestCreateProductHandler_Quantity(t *testing.T) {
+	mr := new(mockRepo)
+	h := api.CreateProductHandler(mr)
+	post := func(body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/products", bytes.NewBufferString(body))
+		req = req.WithContext(api.WithRole(req.Context(), "employee"))
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, req)
+		return rr
+	}
+
+	// коробка одинаковых футболок - одна строка
+	mr.On("CreateProduct", mock.Anything, "82cc7cda-bd24-468f-b7b7-844d66b6693c", mock.MatchedBy(func(p *model.Product) bool {
+		return p.Quantity == 200
+	})).Return(nil).Once()
+	rr := post(`{"type":"одежда","pvzId":"82cc7cda-bd24-468f-b7b7-844d66b6693c","quantity":200}`)
+	require.Equal(t, http.StatusCreated, rr.Code)
+
+	rr = post(`{"type":"одежда","pvzId":"82cc7cda-bd24-468f-b7b7-844d66b6693c","quantity":-1}`)
+	require.Equal(t, http.StatusBadRequest, rr.Code)
+	require.Contains(t, rr.Body.String(), "invalid_quantity")
+
+	mr.On("CreateProduct", mock.Anything, mock.Anything, mock.Anything).Return(db.ErrSerialQuantity).Once()
+	rr = post(`{"type":"электроника","pvzId":"82cc7cda-bd24-468f-b7b7-844d66b6693c","serialNumber":"SN-1","quantity":3}`)
+	require.Equal(t, http.StatusUnprocessableEntity, rr.Code)
+	require.Contains(t, rr.Body.String(), "invalid_quantity")
+
+	mr.AssertExpectations(t)
+}
+
+func TestCreateProductHandler_Barcode(t *testing.T) {
+	mr := new(mockRepo)
+	h := api.CreateProductHandler(mr)
+	post := func(body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/products", bytes.NewBufferString(body))
+		req = req.WithContext(api.WithRole(req.Context(), "employee"))
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, req)
+		return rr
+	}
+	body := `{"type":"обувь","pvzId":"82cc7cda-bd24-468f-b7b7-844d66b6693c","barcode":"4600000000017"}`
+
+	// штрихкод уже в другой открытой приёмке - принимаем с предупреждением
+	mr.On("CreateProduct", mock.Anything, "82cc7cda-bd24-468f-b7b7-844d66b6693c", mock.MatchedBy(func(p *model.Product) bool {
+		return p.Barcode == "4600000000017"
+	})).Run(func(args mock.Arguments) {
+		args.Get(2).(*model.Product).Warnings = []string{"barcode 4600000000017 is already scanned in open reception rec-2 of pvz pvz-2"}
+	}).Return(nil).Once()
+	rr := post(body)
+	require.Equal(t, http.StatusCreated, rr.Code)
+	require.Contains(t, rr.Body.String(), "already scanned in open reception rec-2")
+
+	// повтор в той же приёмке - отказ
+	mr.On("CreateProduct", mock.Anything, "82cc7cda-bd24-468f-b7b7-844d66b6693c", mock.Anything).
+		Return(db.ErrDuplicateBarcode).Once()
+	rr = post(body)
+	require.Equal(t, http.StatusConflict, rr.Code)
+	require.Contains(t, rr.Body.String(), "duplicate_barcode")
+
+	mr.AssertExpectations(t)
+}
+
+func TestGetProductByBarcodeHandler(t *testing.T) {
+	mr := new(mockRepo)
+	r := chi.NewRouter()
+	r.Get("/products/by-barcode/{code}", api.GetProductByBarcodeHandler(mr))
+	get := func(code string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/products/by-barcode/"+code, nil)
+		req = req.WithContext(api.WithRole(req.Context(), "employee"))
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, req)
+		return rr
+	}
+
+	mr.On("GetProductByBarcode", mock.Anything, "4600000000017").Return(&model.ProductLookup{
+		Product:   model.ProductResponse{ID: "prod-1", Barcode: "4600000000017", ReceptionID: "rec-1"},
+		Reception: model.ReceptionResponse{ID: "rec-1", PVZID: "pvz-1", Status: "in_progress"},
+		PVZ:       model.PVZResponse{ID: "pvz-1", City: "Казань"},
+	}, nil).Once()
+	rr := get("4600000000017")
+	require.Equal(t, http.StatusOK, rr.Code)
+	var found model.ProductLookup
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &found))
+	require.Equal(t, "prod-1", found.Product.ID)
+	require.Equal(t, "Казань", found.PVZ.City)
+
+	mr.On("GetProductByBarcode", mock.Anything, "000").Return(nil, nil).Once()
+	require.Equal(t, http.StatusNotFound, get("000").Code)
+
+	mr.AssertExpectations(t)
+}
+
+func batchRequest(t *testing.T, h http.Handler, role, body string) *httptest.ResponseRecorder {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodPost, "/products/batch", bytes.NewBufferString(body))
+	req = req.WithContext(api.WithRole(req.Context(), role))
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, req)
+	return rr
+}
+
+func TestCreateProductsBatchHandler_Success(t *testing.T) {
+	mr := new(mockRepo)
+	h := api.CreateProductsBatchHandler(mr, 3)
+
+	mr.On("CreateProducts", mock.Anything, "82cc7cda-bd24-468f-b7b7-844d66b6693c", mock.MatchedBy(func(ps []*model.Product) bool {
+		return len(ps) == 2 && ps[0].Type == "обувь" && ps[1].SerialNumber == "SN-1"
+	})).Run(func(args mock.Arguments) {
+		for _, p := range args.Get(2).([]*model.Product) {
+			p.ReceptionID = "rec-1"
+		}
+	}).Return(nil).Once()
+
+	rr := batchRequest(t, h, "employee", `{"pvzId":"82cc7cda-bd24-468f-b7b7-844d66b6693c","products":[
+		{"type":"обувь","barcode":"111"},
+		{"type":"электроника","serialNumber":"SN-1"}]}`)
+	require.Equal(t, http.StatusCreated, rr.Code)
+
+	var resp model.ProductBatchResponse
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &resp))
+	require.Equal(t, "rec-1", resp.ReceptionID)
+	require.Len(t, resp.IDs, 2)
+	mr.AssertExpectations(t)
+}
+
+func TestCreateProductsBatchHandler_Validation(t *testing.T) {
+	mr := new(mockRepo)
+	h := api.CreateProductsBatchHandler(mr, 2)
+
+	rr := batchRequest(t, h, "employee", `{"pvzId":"82cc7cda-bd24-468f-b7b7-844d66b6693c","products":[]}`)
+	require.Equal(t, http.StatusBadRequest, rr.Code)
+
+	rr = batchRequest(t, h, "employee", `{"pvzId":"82cc7cda-bd24-468f-b7b7-844d66b6693c","products":[{"type":"обувь"},{"type":"обувь"},{"type":"обувь"}]}`)
+	require.Equal(t, http.StatusBadRequest, rr.Code)
+	require.Contains(t, rr.Body.String(), "max 2")
+
+	rr = batchRequest(t, h, "employee", `{"pvzId":"82cc7cda-bd24-468f-b7b7-844d66b6693c","products":[{"type":"обувь"},{"type":""}]}`)
+	require.Equal(t, http.StatusBadRequest, rr.Code)
+	require.Contains(t, rr.Body.String(), "products[1]")
+
+	rr = batchRequest(t, h, "moderator", `{"pvzId":"82cc7cda-bd24-468f-b7b7-844d66b6693c","products":[{"type":"обувь"}]}`)
+	require.Equal(t, http.StatusForbidden, rr.Code)
+
+	mr.AssertNotCalled(t, "CreateProducts")
+}
+
+func TestCreateProductsBatchHandler_ItemError(t *testing.T) {
+	mr := new(mockRepo)
+	h := api.CreateProductsBatchHandler(mr, 10)
+
+	mr.On("CreateProducts", mock.Anything, mock.Anything, mock.Anything).
+		Return(&db.ProductError{Index: 1, Err: db.ErrDuplicateBarcode}).Once()
+
+	rr := batchRequest(t, h, "employee", `{"pvzId":"82cc7cda-bd24-468f-b7b7-844d66b6693c","products":[{"type":"обувь","barcode":"1"},{"type":"обувь","barcode":"1"}]}`)
+	require.Equal(t, http.StatusConflict, rr.Code)
+	require.Contains(t, rr.Body.String(), "products[1]")
+	mr.AssertExpectations(t)
+}
+
+func transitionRequest(t *testing.T, mr *mockRepo, role, status string) *httptest.ResponseRecorder {
+	t.Helper()
+	r := chi.NewRouter()
+	r.Post("/products/{productId}/"+status, api.TransitionProductHandler(mr, status))
+
+	req := httptest.NewRequest(http.MethodPost, "/products/5f0c8d2e-9a51-4c1b-8f3e-2d7a6b4c1e90/"+status, nil)
+	req = req.WithContext(api.WithRole(req.Context(), role))
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, req)
+	return rr
+}
+
+func TestTransitionProductHandler(t *testing.T) {
+	mr := new(mockRepo)
+	at := time.Now()
+	mr.On("TransitionProduct", mock.Anything, "5f0c8d2e-9a51-4c1b-8f3e-2d7a6b4c1e90", model.ProductStatusStored, mock.AnythingOfType("time.Time")).
+		Return(&model.Product{ID: "5f0c8d2e-9a51-4c1b-8f3e-2d7a6b4c1e90", Status: model.ProductStatusStored, StoredAt: &at}, nil).Once()
+
+	rr := transitionRequest(t, mr, "employee", model.ProductStatusStored)
+	require.Equal(t, http.StatusOK, rr.Code)
+
+	var resp model.ProductResponse
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &resp))
+	require.Equal(t, model.ProductStatusStored, resp.Status)
+	require.NotNil(t, resp.StoredAt)
+	mr.AssertExpectations(t)
+}
+
+func TestTransitionProductHandler_Errors(t *testing.T) {
+	mr := new(mockRepo)
+
+	mr.On("TransitionProduct", mock.Anything, mock.Anything, model.ProductStatusIssued, mock.Anything).
+		Return(nil, fmt.Errorf("%w: received -> issued", db.ErrInvalidTransition)).Once()
+	rr := transitionRequest(t, mr, "employee", model.ProductStatusIssued)
+	require.Equal(t, http.StatusConflict, rr.Code)
+	require.Contains(t, rr.Body.String(), "invalid_transition")
+
+	mr.On("TransitionProduct", mock.Anything, mock.Anything, model.ProductStatusStored, mock.Anything).
+		Return(nil, db.ErrReceptionOpen).Once()
+	rr = transitionRequest(t, mr, "employee", model.ProductStatusStored)
+	require.Equal(t, http.StatusConflict, rr.Code)
+	require.Contains(t, rr.Body.String(), "reception_open")
+
+	mr.On("TransitionProduct", mock.Anything, mock.Anything, model.ProductStatusReturned, mock.Anything).
+		Return(nil, db.ErrProductNotFound).Once()
+	rr = transitionRequest(t, mr, "moderator", model.ProductStatusReturned)
+	require.Equal(t, http.StatusNotFound, rr.Code)
+
+	rr = transitionRequest(t, mr, "client", model.ProductStatusStored)
+	require.Equal(t, http.StatusForbidden, rr.Code)
+	mr.AssertExpectations(t)
+}
+
+func listProductsRouter(mr *mockRepo) http.Handler {
+	r := chi.NewRouter()
+	r.Get("/products", api.ListProductsHandler(mr))
+	r.Get("/pvz/{pvzId}/products", api.ListProductsHandler(mr))
+	return r
+}
+
+func TestListProductsHandler_Filters(t *testing.T) {
+	mr := new(mockRepo)
+	from := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2025, 2, 1, 0, 0, 0, 0, time.UTC)
+
+	mr.On("ListProducts", mock.Anything, db.ProductListFilter{
+		City: "Москва", ReceptionID: "5f0c8d2e-9a51-4c1b-8f3e-2d7a6b4c1e90", Type: "обувь",
+		Status: model.ProductStatusStored, Barcode: "111", From: &from, To: &to,
+		Asc: true, Cursor: "abc", Limit: 50,
+	}).Return([]*model.Product{{ID: "p1", PVZID: "pvz-1", Status: model.ProductStatusStored}}, "next-1", nil).Once()
+
+	q := url.Values{
+		"city": {"Москва"}, "receptionId": {"5f0c8d2e-9a51-4c1b-8f3e-2d7a6b4c1e90"}, "type": {"обувь"},
+		"status": {"stored"}, "barcode": {"111"}, "from": {"2025-01-01T00:00:00Z"}, "to": {"2025-02-01T00:00:00Z"},
+		"sort": {"dateTime"}, "cursor": {"abc"}, "limit": {"50"},
+	}
+	rr := serveAs(listProductsRouter(mr), "moderator", http.MethodGet, "/products?"+q.Encode(), "")
+	require.Equal(t, http.StatusOK, rr.Code)
+
+	var page model.ProductPage
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &page))
+	require.Equal(t, "next-1", page.NextCursor)
+	require.Len(t, page.Items, 1)
+	require.Equal(t, "pvz-1", page.Items[0].PVZID)
+	mr.AssertExpectations(t)
+}
+
+func TestListProductsHandler_PVZRoute(t *testing.T) {
+	mr := new(mockRepo)
+	mr.On("ListProducts", mock.Anything, db.ProductListFilter{
+		PVZID: "82cc7cda-bd24-468f-b7b7-844d66b6693c", Status: model.ProductStatusStored, Limit: 20,
+	}).Return([]*model.Product{}, "", nil).Once()
+
+	rr := serveAs(listProductsRouter(mr), "employee", http.MethodGet, "/pvz/82cc7cda-bd24-468f-b7b7-844d66b6693c/products?status=stored", "")
+	require.Equal(t, http.StatusOK, rr.Code)
+	require.JSONEq(t, `{"items":[]}`, rr.Body.String())
+	mr.AssertExpectations(t)
+}
+
+func TestListProductsHandler_Validation(t *testing.T) {
+	mr := new(mockRepo)
+	h := listProductsRouter(mr)
+
+	for _, q := range []string{"status=lost", "sort=type", "from=yesterday", "pvzId=nope", "receptionId=nope"} {
+		rr := serveAs(h, "employee", http.MethodGet, "/products?"+q, "")
+		require.Equal(t, http.StatusBadRequest, rr.Code, q)
+	}
+
+	mr.On("ListProducts", mock.Anything, mock.Anything).Return(nil, "", db.ErrInvalidCursor).Once()
+	rr := serveAs(h, "employee", http.MethodGet, "/products?cursor=garbage", "")
 	require.Equal(t, http.StatusBadRequest, rr.Code)
-	require.Contains(t, rr.Body.String(), "no products to delete")
 
+	rr = serveAs(h, "client", http.MethodGet, "/products", "")
+	require.Equal(t, http.StatusForbidden, rr.Code)
 	mr.AssertExpectations(t)
 }
@@ -0,0 +1,121 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/51mans0n/avito-pvz-task/internal/db"
+	"github.com/51mans0n/avito-pvz-task/internal/logging"
+	"github.com/51mans0n/avito-pvz-task/internal/model"
+	"github.com/go-chi/chi/v5"
+)
+
+// ListProductTypesHandler - справочник типов товаров; отключённые видит только модератор
+func ListProductTypesHandler(repo db.Repository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		role := GetRole(r.Context())
+		if role != "employee" && role != "moderator" {
+			http.Error(w, `{"message":"forbidden"}`, http.StatusForbidden)
+			return
+		}
+
+		includeInactive := r.URL.Query().Get("includeInactive") == "true"
+		if includeInactive && role != "moderator" {
+			http.Error(w, `{"message":"includeInactive is allowed for moderators only"}`, http.StatusForbidden)
+			return
+		}
+
+		list, err := repo.ListProductTypes(r.Context(), includeInactive)
+		if err != nil {
+			logging.S().Errorw("list product types", "err", err)
+			writeError(w, http.StatusInternalServerError, codeInternal, "server error")
+			return
+		}
+		if list == nil {
+			list = []*model.ProductType{}
+		}
+		if err := json.NewEncoder(w).Encode(list); err != nil {
+			logging.S().Warnw("encode product types", "err", err)
+		}
+	}
+}
+
+// CreateProductTypeHandler - модератор добавляет тип товара в справочник
+func CreateProductTypeHandler(repo db.Repository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		role := GetRole(r.Context())
+		if role != "moderator" {
+			http.Error(w, `{"message":"access denied"}`, http.StatusForbidden)
+			return
+		}
+
+		var req struct {
+			Name           string `json:"name"`
+			RequiresSerial bool   `json:"requiresSerial"`
+			Active         *bool  `json:"active"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, `{"message":"invalid json"}`, http.StatusBadRequest)
+			return
+		}
+		name := strings.TrimSpace(req.Name)
+		if name == "" {
+			http.Error(w, `{"message":"name is required"}`, http.StatusBadRequest)
+			return
+		}
+
+		pt := &model.ProductType{
+			Name:           name,
+			RequiresSerial: req.RequiresSerial,
+			Active:         req.Active == nil || *req.Active,
+			CreatedAt:      time.Now(),
+		}
+		if err := repo.CreateProductType(r.Context(), pt); err != nil {
+			writeRepoError(w, err, "create product type", "name", name)
+			return
+		}
+
+		w.WriteHeader(http.StatusCreated)
+		if err := json.NewEncoder(w).Encode(pt); err != nil {
+			logging.S().Warnw("encode product type", "err", err)
+		}
+	}
+}
+
+// UpdateProductTypeHandler - модератор меняет правила типа или отключает его
+func UpdateProductTypeHandler(repo db.Repository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		role := GetRole(r.Context())
+		if role != "moderator" {
+			http.Error(w, `{"message":"access denied"}`, http.StatusForbidden)
+			return
+		}
+
+		name, err := url.PathUnescape(chi.URLParam(r, "name"))
+		if err != nil || strings.TrimSpace(name) == "" {
+			http.Error(w, `{"message":"invalid product type name"}`, http.StatusBadRequest)
+			return
+		}
+
+		var req struct {
+			RequiresSerial bool `json:"requiresSerial"`
+			Active         bool `json:"active"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, `{"message":"invalid json"}`, http.StatusBadRequest)
+			return
+		}
+
+		pt := &model.ProductType{Name: name, RequiresSerial: req.RequiresSerial, Active: req.Active}
+		if err := repo.UpdateProductType(r.Context(), pt); err != nil {
+			writeRepoError(w, err, "update product type", "name", name)
+			return
+		}
+		if err := json.NewEncoder(w).Encode(pt); err != nil {
+			logging.S().Warnw("encode product type", "err", err)
+		}
+	}
+}
@@ -0,0 +1,105 @@
+package api_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/51mans0n/avito-pvz-task/internal/api"
+	"github.com/51mans0n/avito-pvz-task/internal/db"
+	"github.com/51mans0n/avito-pvz-task/internal/model"
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func (m *mockRepo) ListProductTypes(ctx context.Context, includeInactive bool) ([]*model.ProductType, error) {
+	args := m.Called(ctx, includeInactive)
+	list, _ := args.Get(0).([]*model.ProductType)
+	return list, args.Error(1)
+}
+
+func (m *mockRepo) CreateProductType(ctx context.Context, pt *model.ProductType) error {
+	args := m.Called(ctx, pt)
+	return args.Error(0)
+}
+
+func (m *mockRepo) UpdateProductType(ctx context.Context, pt *model.ProductType) error {
+	args := m.Called(ctx, pt)
+	return args.Error(0)
+}
+
+func TestListProductTypesHandler(t *testing.T) {
+	mr := new(mockRepo)
+	h := api.ListProductTypesHandler(mr)
+
+	mr.On("ListProductTypes", mock.Anything, false).
+		Return([]*model.ProductType{{Name: "электроника", RequiresSerial: true, Active: true}}, nil).Once()
+
+	req := httptest.NewRequest(http.MethodGet, "/product-types", nil)
+	req = req.WithContext(api.WithRole(req.Context(), "employee"))
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, req)
+
+	require.Equal(t, http.StatusOK, rr.Code)
+	var list []model.ProductType
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &list))
+	require.Len(t, list, 1)
+	require.True(t, list[0].RequiresSerial)
+
+	// отключённые типы - только модератору
+	req = httptest.NewRequest(http.MethodGet, "/product-types?includeInactive=true", nil)
+	req = req.WithContext(api.WithRole(req.Context(), "employee"))
+	rr = httptest.NewRecorder()
+	h.ServeHTTP(rr, req)
+	require.Equal(t, http.StatusForbidden, rr.Code)
+
+	mr.AssertExpectations(t)
+}
+
+func TestCreateProductTypeHandler(t *testing.T) {
+	mr := new(mockRepo)
+	h := api.CreateProductTypeHandler(mr)
+	post := func(role, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/product-types", bytes.NewBufferString(body))
+		req = req.WithContext(api.WithRole(req.Context(), role))
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, req)
+		return rr
+	}
+
+	mr.On("CreateProductType", mock.Anything, mock.MatchedBy(func(pt *model.ProductType) bool {
+		return pt.Name == "мебель" && pt.RequiresSerial && pt.Active
+	})).Return(nil).Once()
+	require.Equal(t, http.StatusCreated, post("moderator", `{"name":" мебель ","requiresSerial":true}`).Code)
+
+	mr.On("CreateProductType", mock.Anything, mock.Anything).Return(db.ErrProductTypeExists).Once()
+	rr := post("moderator", `{"name":"обувь"}`)
+	require.Equal(t, http.StatusConflict, rr.Code)
+	require.Contains(t, rr.Body.String(), "product_type_exists")
+
+	require.Equal(t, http.StatusBadRequest, post("moderator", `{"name":""}`).Code)
+	require.Equal(t, http.StatusForbidden, post("employee", `{"name":"мебель"}`).Code)
+	mr.AssertExpectations(t)
+}
+
+func TestUpdateProductTypeHandler(t *testing.T) {
+	mr := new(mockRepo)
+	r := chi.NewRouter()
+	r.Put("/product-types/{name}", api.UpdateProductTypeHandler(mr))
+
+	mr.On("UpdateProductType", mock.Anything, &model.ProductType{Name: "обувь", Active: false}).
+		Return(db.ErrProductTypeNotFound).Once()
+
+	req := httptest.NewRequest(http.MethodPut, "/product-types/"+url.PathEscape("обувь"), bytes.NewBufferString(`{"active":false}`))
+	req = req.WithContext(api.WithRole(req.Context(), "moderator"))
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, req)
+
+	require.Equal(t, http.StatusNotFound, rr.Code)
+	mr.AssertExpectations(t)
+}
@@ -2,7 +2,6 @@ package api
 
 import (
 	"encoding/json"
-	"fmt"
 	"net/http"
 	"strconv"
 	"time"
@@ -11,6 +10,7 @@ import (
 
 	"github.com/51mans0n/avito-pvz-task/internal/metrics"
 
+	"github.com/go-chi/chi/v5"
 	"github.com/google/uuid"
 
 	"github.com/51mans0n/avito-pvz-task/internal/db"
@@ -55,13 +55,13 @@ func CreatePVZHandler(repo db.Repository) http.HandlerFunc {
 			RegistrationDate: time.Now(),
 		}
 		if err := repo.CreatePVZ(r.Context(), pvz); err != nil {
-			fmt.Printf("Create PVZ error: %v\n", err)
-			http.Error(w, `{"message":"server error"}`, http.StatusInternalServerError)
+			writeRepoError(w, err, "create pvz", "city", req.City)
 			return
 		}
 
 		metrics.PVZCreated.Inc()
 
+		setETag(w, pvz.Version)
 		w.WriteHeader(http.StatusCreated)
 		if err := json.NewEncoder(w).Encode(pvz); err != nil {
 			logging.S().Warnw("encode pvz", "err", err)
@@ -69,6 +69,37 @@ func CreatePVZHandler(repo db.Repository) http.HandlerFunc {
 	}
 }
 
+// GetPVZHandler возвращает ПВЗ по id; версия отдаётся в ETag
+func GetPVZHandler(repo db.Repository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		role := GetRole(r.Context())
+		if role != "moderator" && role != "employee" {
+			http.Error(w, `{"message":"forbidden"}`, http.StatusForbidden)
+			return
+		}
+
+		pvzId := chi.URLParam(r, "pvzId")
+		if _, err := uuid.Parse(pvzId); err != nil {
+			http.Error(w, `{"message":"invalid pvzId"}`, http.StatusBadRequest)
+			return
+		}
+
+		pvz, err := repo.GetPVZ(r.Context(), pvzId)
+		if err != nil {
+			writeRepoError(w, err, "get pvz", "pvz", pvzId)
+			return
+		}
+		setETag(w, pvz.Version)
+		if notModified(r, pvz.Version) {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		if err := json.NewEncoder(w).Encode(pvz); err != nil {
+			logging.S().Warnw("encode pvz", "err", err)
+		}
+	}
+}
+
 // GetPVZListHandler возвращает список ПВЗ (и их приёмок, товаров) с фильтром и пагинацией
 func GetPVZListHandler(repo db.Repository) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
@@ -97,9 +128,30 @@ func GetPVZListHandler(repo db.Repository) http.HandlerFunc {
 			}
 		}
 
-		result, err := repo.GetPVZListWithFilter(r.Context(), startDate, endDate, page, limit)
+		// удалённые товары видны только модератору
+		includeDeleted := r.URL.Query().Get("includeDeleted") == "true"
+		if includeDeleted && role != "moderator" {
+			http.Error(w, `{"message":"includeDeleted is allowed for moderators only"}`, http.StatusForbidden)
+			return
+		}
+
+		productStatus, ok := parseProductStatus(r.URL.Query().Get("productStatus"))
+		if !ok {
+			http.Error(w, `{"message":"invalid productStatus"}`, http.StatusBadRequest)
+			return
+		}
+
+		result, err := repo.GetPVZListWithFilter(r.Context(), db.PVZListFilter{
+			StartDate:      startDate,
+			EndDate:        endDate,
+			Page:           page,
+			Limit:          limit,
+			IncludeDeleted: includeDeleted,
+			ProductStatus:  productStatus,
+			IncludeEmpty:   r.URL.Query().Get("includeEmpty") == "true",
+		})
 		if err != nil {
-			http.Error(w, `{"message":"server error"}`, http.StatusInternalServerError)
+			writeRepoError(w, err, "list pvz")
 			return
 		}
 		w.WriteHeader(http.StatusOK)
@@ -12,6 +12,7 @@ import (
 	"github.com/51mans0n/avito-pvz-task/internal/api"
 	"github.com/51mans0n/avito-pvz-task/internal/db"
 	"github.com/51mans0n/avito-pvz-task/internal/model"
+	"github.com/go-chi/chi/v5"
 	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/require"
 )
@@ -23,6 +24,11 @@ type mockRepo struct {
 
 var _ db.Repository = (*mockRepo)(nil)
 
+// WithTx в моке просто выполняет fn на самом моке
+func (m *mockRepo) WithTx(_ context.Context, fn func(repo db.Repository) error) error {
+	return fn(m)
+}
+
 func (m *mockRepo) CreateUser(ctx context.Context, u *model.User) error {
 	args := m.Called(ctx, u)
 	return args.Error(0)
@@ -38,8 +44,14 @@ func (m *mockRepo) CreatePVZ(ctx context.Context, pvz *model.PVZ) error {
 	args := m.Called(ctx, pvz)
 	return args.Error(0)
 }
-func (m *mockRepo) GetPVZListWithFilter(ctx context.Context, startDate, endDate *time.Time, page, limit int) ([]model.PVZWithReceptions, error) {
-	args := m.Called(ctx, startDate, endDate, page, limit)
+func (m *mockRepo) GetPVZ(ctx context.Context, id string) (*model.PVZ, error) {
+	args := m.Called(ctx, id)
+	pvz, _ := args.Get(0).(*model.PVZ)
+	return pvz, args.Error(1)
+}
+
+func (m *mockRepo) GetPVZListWithFilter(ctx context.Context, f db.PVZListFilter) ([]model.PVZWithReceptions, error) {
+	args := m.Called(ctx, f)
 	return args.Get(0).([]model.PVZWithReceptions), args.Error(1)
 }
 
@@ -144,7 +156,7 @@ func TestGetPVZListHandler_Success(t *testing.T) {
 		},
 	}
 
-	mr.On("GetPVZListWithFilter", mock.Anything, (*time.Time)(nil), (*time.Time)(nil), 1, 10).
+	mr.On("GetPVZListWithFilter", mock.Anything, db.PVZListFilter{Page: 1, Limit: 10}).
 		Return(result, nil).
 		Once()
 
@@ -183,7 +195,7 @@ func TestGetPVZListHandler_RepoError(t *testing.T) {
 	mr := new(mockRepo)
 	h := api.GetPVZListHandler(mr)
 
-	mr.On("GetPVZListWithFilter", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).
+	mr.On("GetPVZListWithFilter", mock.Anything, mock.Anything).
 		Return([]model.PVZWithReceptions(nil), assertAnErrorWithMessage("db error")).
 		Once()
 
@@ -197,3 +209,75 @@ func TestGetPVZListHandler_RepoError(t *testing.T) {
 	require.Equal(t, http.StatusInternalServerError, rr.Code)
 	mr.AssertExpectations(t)
 }
+
+func TestGetPVZHandler(t *testing.T) {
+	mr := new(mockRepo)
+	r := chi.NewRouter()
+	r.Get("/pvz/{pvzId}", api.GetPVZHandler(mr))
+
+	const id = "82cc7cda-bd24-468f-b7b7-844d66b6693c"
+	mr.On("GetPVZ", mock.Anything, id).
+		Return(&model.PVZ{ID: id, City: "Москва", Version: 5}, nil).Once()
+	mr.On("GetPVZ", mock.Anything, id).
+		Return(nil, db.ErrPVZNotFound).Once()
+
+	req := httptest.NewRequest(http.MethodGet, "/pvz/"+id, nil)
+	req = req.WithContext(api.WithRole(req.Context(), "employee"))
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, req)
+	require.Equal(t, http.StatusOK, rr.Code)
+	require.Equal(t, `"5"`, rr.Header().Get("ETag"))
+	require.Contains(t, rr.Body.String(), `"version":5`)
+
+	rr = httptest.NewRecorder()
+	r.ServeHTTP(rr, req)
+	require.Equal(t, http.StatusNotFound, rr.Code)
+	require.Contains(t, rr.Body.String(), `"code":"pvz_not_found"`)
+
+	mr.AssertExpectations(t)
+}
+
+func TestGetPVZListHandler_IncludeDeleted(t *testing.T) {
+	mr := new(mockRepo)
+	h := api.GetPVZListHandler(mr)
+
+	mr.On("GetPVZListWithFilter", mock.Anything, db.PVZListFilter{Page: 1, Limit: 10, IncludeDeleted: true}).
+		Return([]model.PVZWithReceptions{}, nil).
+		Once()
+
+	req := httptest.NewRequest(http.MethodGet, "/pvz?includeDeleted=true", nil)
+	req = req.WithContext(api.WithRole(req.Context(), "moderator"))
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, req)
+	require.Equal(t, http.StatusOK, rr.Code)
+
+	// сотруднику удалённые товары не показываем
+	req = httptest.NewRequest(http.MethodGet, "/pvz?includeDeleted=true", nil)
+	req = req.WithContext(api.WithRole(req.Context(), "employee"))
+	rr = httptest.NewRecorder()
+	h.ServeHTTP(rr, req)
+	require.Equal(t, http.StatusForbidden, rr.Code)
+
+	mr.AssertExpectations(t)
+}
+
+func TestGetPVZListHandler_DateFilterMode(t *testing.T) {
+	mr := new(mockRepo)
+	h := api.GetPVZListHandler(mr)
+	start := time.Date(2025, 4, 1, 0, 0, 0, 0, time.UTC)
+	end := time.Date(2025, 4, 30, 0, 0, 0, 0, time.UTC)
+
+	mr.On("GetPVZListWithFilter", mock.Anything, db.PVZListFilter{StartDate: &start, EndDate: &end, Page: 1, Limit: 10}).
+		Return([]model.PVZWithReceptions{}, nil).Once()
+	mr.On("GetPVZListWithFilter", mock.Anything, db.PVZListFilter{StartDate: &start, EndDate: &end, Page: 1, Limit: 10, IncludeEmpty: true}).
+		Return([]model.PVZWithReceptions{}, nil).Once()
+
+	for _, q := range []string{"", "&includeEmpty=true"} {
+		req := httptest.NewRequest(http.MethodGet, "/pvz?startDate=2025-04-01T00:00:00Z&endDate=2025-04-30T00:00:00Z"+q, nil)
+		req = req.WithContext(api.WithRole(req.Context(), "employee"))
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, req)
+		require.Equal(t, http.StatusOK, rr.Code)
+	}
+	mr.AssertExpectations(t)
+}
@@ -3,8 +3,10 @@ package api
 import (
 	"encoding/json"
 	"net/http"
+	"strings"
 	"time"
 
+	"github.com/51mans0n/avito-pvz-task/internal/auth"
 	"github.com/51mans0n/avito-pvz-task/internal/logging"
 
 	"github.com/51mans0n/avito-pvz-task/internal/db"
@@ -24,7 +26,8 @@ func CreateReceptionHandler(repo db.Repository) http.HandlerFunc {
 		}
 
 		var req struct {
-			PVZID string `json:"pvzId"`
+			PVZID    string               `json:"pvzId"`
+			Manifest []model.ManifestItem `json:"manifest"`
 		}
 		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 			http.Error(w, `{"message":"invalid json"}`, http.StatusBadRequest)
@@ -39,19 +42,26 @@ func CreateReceptionHandler(repo db.Repository) http.HandlerFunc {
 			return
 		}
 
+		if msg := validateManifest(req.Manifest); msg != "" {
+			http.Error(w, `{"message":"`+msg+`"}`, http.StatusBadRequest)
+			return
+		}
+
 		rec := &model.Reception{
 			ID:       uuid.New().String(),
 			PVZID:    req.PVZID,
 			DateTime: time.Now(),
 			Status:   "in_progress",
+			Manifest: req.Manifest,
 		}
 		if err := repo.CreateReception(r.Context(), rec); err != nil {
-			http.Error(w, `{"message":"`+err.Error()+`"}`, http.StatusBadRequest)
+			writeRepoError(w, err, "create reception", "pvz", req.PVZID)
 			return
 		}
 
 		metrics.ReceptionsAdded.Inc()
 
+		setETag(w, rec.Version)
 		w.WriteHeader(http.StatusCreated)
 		if err := json.NewEncoder(w).Encode(rec); err != nil {
 			logging.S().Warnw("encode rec", "err", err)
@@ -59,7 +69,39 @@ func CreateReceptionHandler(repo db.Repository) http.HandlerFunc {
 	}
 }
 
-// CloseLastReceptionHandler - закрытие приёмки
+// GetReceptionHandler - приёмка по id; версия отдаётся в ETag
+func GetReceptionHandler(repo db.Repository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		role := GetRole(r.Context())
+		if role != "employee" && role != "moderator" {
+			http.Error(w, `{"message":"forbidden"}`, http.StatusForbidden)
+			return
+		}
+
+		receptionId := chi.URLParam(r, "receptionId")
+		if _, err := uuid.Parse(receptionId); err != nil {
+			http.Error(w, `{"message":"invalid receptionId"}`, http.StatusBadRequest)
+			return
+		}
+
+		rec, err := repo.GetReception(r.Context(), receptionId)
+		if err != nil {
+			writeRepoError(w, err, "get reception", "reception", receptionId)
+			return
+		}
+		setETag(w, rec.Version)
+		if notModified(r, rec.Version) {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		if err := json.NewEncoder(w).Encode(rec); err != nil {
+			logging.S().Warnw("encode rec", "err", err)
+		}
+	}
+}
+
+// CloseLastReceptionHandler - закрытие приёмки. С If-Match приёмка закрывается,
+// только если её версия не менялась, иначе 412.
 func CloseLastReceptionHandler(repo db.Repository) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		role := GetRole(r.Context())
@@ -74,13 +116,168 @@ func CloseLastReceptionHandler(repo db.Repository) http.HandlerFunc {
 			return
 		}
 
-		rec, err := repo.CloseLastReception(r.Context(), pvzId)
+		version, ok := ifMatchVersion(r)
+		if !ok {
+			writeVersionMismatch(w)
+			return
+		}
+
+		rec, err := repo.CloseLastReception(r.Context(), pvzId, version)
+		if err != nil {
+			writeRepoError(w, err, "close reception", "pvz", pvzId)
+			return
+		}
+		setETag(w, rec.Version)
+		if err := json.NewEncoder(w).Encode(rec); err != nil {
+			logging.S().Warnw("encode rec", "err", err)
+		}
+	}
+}
+
+// ReopenApprovalHandler - модератор выдаёт разрешение на переоткрытие последней приёмки
+func ReopenApprovalHandler(ttl time.Duration) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		role := GetRole(r.Context())
+		if role != "moderator" {
+			http.Error(w, `{"message":"forbidden"}`, http.StatusForbidden)
+			return
+		}
+
+		pvzId := chi.URLParam(r, "pvzId")
+		if _, err := uuid.Parse(pvzId); err != nil {
+			http.Error(w, `{"message":"invalid pvzId"}`, http.StatusBadRequest)
+			return
+		}
+
+		token, id, err := auth.IssueReopenApproval(pvzId, ttl)
+		if err != nil {
+			logging.S().Errorw("issue reopen approval", "err", err)
+			writeError(w, http.StatusInternalServerError, codeInternal, "server error")
+			return
+		}
+
+		w.WriteHeader(http.StatusCreated)
+		if err := json.NewEncoder(w).Encode(map[string]string{"approvalId": id, "approvalToken": token}); err != nil {
+			logging.S().Warnw("encode approval", "err", err)
+		}
+	}
+}
+
+// ReopenLastReceptionHandler - переоткрытие недавно закрытой приёмки.
+// Модератор переоткрывает сам, сотруднику нужно разрешение модератора.
+// If-Match проверяется так же, как при закрытии.
+func ReopenLastReceptionHandler(repo db.Repository, window time.Duration) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		role := GetRole(r.Context())
+		if role != "employee" && role != "moderator" {
+			http.Error(w, `{"message":"forbidden"}`, http.StatusForbidden)
+			return
+		}
+
+		pvzId := chi.URLParam(r, "pvzId")
+		if _, err := uuid.Parse(pvzId); err != nil {
+			http.Error(w, `{"message":"invalid pvzId"}`, http.StatusBadRequest)
+			return
+		}
+
+		version, ok := ifMatchVersion(r)
+		if !ok {
+			writeVersionMismatch(w)
+			return
+		}
+
+		var req struct {
+			Reason        string `json:"reason"`
+			ApprovalToken string `json:"approvalToken"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, `{"message":"invalid json"}`, http.StatusBadRequest)
+			return
+		}
+		if strings.TrimSpace(req.Reason) == "" {
+			http.Error(w, `{"message":"reason is required"}`, http.StatusBadRequest)
+			return
+		}
+
+		reopen := &model.ReceptionReopen{
+			ID:         uuid.New().String(),
+			Reason:     req.Reason,
+			ReopenedBy: role,
+			CreatedAt:  time.Now(),
+		}
+		if role == "employee" {
+			if req.ApprovalToken == "" {
+				writeError(w, http.StatusForbidden, codeApprovalRequired, "moderator approval is required")
+				return
+			}
+			approvalID, err := auth.VerifyReopenApproval(req.ApprovalToken, pvzId)
+			if err != nil {
+				logging.S().Debugw("reopen approval rejected", "pvz", pvzId, "err", err)
+				writeError(w, http.StatusForbidden, codeInvalidApproval, "invalid reopen approval")
+				return
+			}
+			reopen.ApprovalID = approvalID
+		}
+
+		rec, err := repo.ReopenLastReception(r.Context(), pvzId, version, reopen.CreatedAt.Add(-window), reopen)
 		if err != nil {
-			http.Error(w, `{"message":"`+err.Error()+`"}`, http.StatusBadRequest)
+			writeRepoError(w, err, "reopen reception", "pvz", pvzId)
 			return
 		}
+		setETag(w, rec.Version)
 		if err := json.NewEncoder(w).Encode(rec); err != nil {
 			logging.S().Warnw("encode rec", "err", err)
 		}
 	}
 }
+
+// GetDiscrepancyReportHandler - отчёт о расхождениях с манифестом по закрытой приёмке
+func GetDiscrepancyReportHandler(repo db.Repository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		role := GetRole(r.Context())
+		if role != "employee" && role != "moderator" {
+			http.Error(w, `{"message":"forbidden"}`, http.StatusForbidden)
+			return
+		}
+
+		receptionId := chi.URLParam(r, "receptionId")
+		if _, err := uuid.Parse(receptionId); err != nil {
+			http.Error(w, `{"message":"invalid receptionId"}`, http.StatusBadRequest)
+			return
+		}
+
+		report, err := repo.GetDiscrepancyReport(r.Context(), receptionId)
+		if err != nil {
+			writeRepoError(w, err, "get discrepancy report", "reception", receptionId)
+			return
+		}
+		if report == nil {
+			http.Error(w, `{"message":"discrepancy report not found"}`, http.StatusNotFound)
+			return
+		}
+		if err := json.NewEncoder(w).Encode(report); err != nil {
+			logging.S().Warnw("encode report", "err", err)
+		}
+	}
+}
+
+// validateManifest проверяет манифест поставки; пустая строка - всё в порядке
+func validateManifest(items []model.ManifestItem) string {
+	seen := make(map[string]bool, len(items))
+	for _, it := range items {
+		if it.Type == "" {
+			return "manifest item type is required"
+		}
+		if it.Count < 0 {
+			return "manifest item count must not be negative"
+		}
+		if len(it.Barcodes) > it.Count {
+			return "manifest item has more barcodes than count"
+		}
+		if seen[it.Type] {
+			return "manifest has duplicate types"
+		}
+		seen[it.Type] = true
+	}
+	return ""
+}
@@ -4,15 +4,20 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/require"
 
 	"github.com/51mans0n/avito-pvz-task/internal/api"
+	"github.com/51mans0n/avito-pvz-task/internal/auth"
+	"github.com/51mans0n/avito-pvz-task/internal/db"
 	"github.com/51mans0n/avito-pvz-task/internal/model"
 )
 
@@ -21,12 +26,36 @@ func (m *mockRepo) CreateReception(ctx context.Context, rec *model.Reception) er
 	return args.Error(0)
 }
 
-func (m *mockRepo) CloseLastReception(ctx context.Context, pvzID string) (*model.Reception, error) {
-	args := m.Called(ctx, pvzID)
+func (m *mockRepo) GetReception(ctx context.Context, id string) (*model.Reception, error) {
+	args := m.Called(ctx, id)
 	rec, _ := args.Get(0).(*model.Reception)
 	return rec, args.Error(1)
 }
 
+func (m *mockRepo) CloseLastReception(ctx context.Context, pvzID string, ifVersion int) (*model.Reception, error) {
+	args := m.Called(ctx, pvzID, ifVersion)
+	rec, _ := args.Get(0).(*model.Reception)
+	return rec, args.Error(1)
+}
+
+func (m *mockRepo) ReopenLastReception(ctx context.Context, pvzID string, ifVersion int, closedAfter time.Time, reopen *model.ReceptionReopen) (*model.Reception, error) {
+	args := m.Called(ctx, pvzID, ifVersion, closedAfter, reopen)
+	rec, _ := args.Get(0).(*model.Reception)
+	return rec, args.Error(1)
+}
+
+func (m *mockRepo) CloseStaleReceptions(ctx context.Context, openedBefore time.Time, actor, reason string) ([]*model.Reception, error) {
+	args := m.Called(ctx, openedBefore, actor, reason)
+	recs, _ := args.Get(0).([]*model.Reception)
+	return recs, args.Error(1)
+}
+
+func (m *mockRepo) GetDiscrepancyReport(ctx context.Context, receptionID string) (*model.DiscrepancyReport, error) {
+	args := m.Called(ctx, receptionID)
+	rep, _ := args.Get(0).(*model.DiscrepancyReport)
+	return rep, args.Error(1)
+}
+
 func TestCreateReceptionHandler_Success(t *testing.T) {
 	mr := new(mockRepo)
 	h := api.CreateReceptionHandler(mr)
@@ -65,7 +94,7 @@ func TestCloseLastReceptionHandler_Success(t *testing.T) {
 		Status: "close",
 	}
 
-	mr.On("CloseLastReception", mock.Anything, "82cc7cda-bd24-468f-b7b7-844d66b6693c").
+	mr.On("CloseLastReception", mock.Anything, "82cc7cda-bd24-468f-b7b7-844d66b6693c", 0).
 		Return(rec, nil).
 		Once()
 
@@ -133,7 +162,7 @@ func TestCreateReception_RepoErr(t *testing.T) {
 	h := api.CreateReceptionHandler(mr)
 
 	mr.On("CreateReception", mock.Anything, mock.Anything).
-		Return(assertAnErrorWithMessage("open reception")).Once()
+		Return(assertAnErrorWithMessage("pq: connection refused")).Once()
 
 	req := httptest.NewRequest(http.MethodPost, "/receptions",
 		bytes.NewBufferString(`{"pvzId":"31ae2e29-0460-4748-a9f3-2b5747f78960"}`))
@@ -143,7 +172,49 @@ func TestCreateReception_RepoErr(t *testing.T) {
 	rr := httptest.NewRecorder()
 	h.ServeHTTP(rr, req)
 
-	require.Equal(t, http.StatusBadRequest, rr.Code)
+	require.Equal(t, http.StatusInternalServerError, rr.Code)
+	require.NotContains(t, rr.Body.String(), "connection refused")
+	mr.AssertExpectations(t)
+}
+
+func TestCreateReception_AlreadyOpen(t *testing.T) {
+	mr := new(mockRepo)
+	h := api.CreateReceptionHandler(mr)
+
+	mr.On("CreateReception", mock.Anything, mock.Anything).
+		Return(db.ErrReceptionAlreadyOpen).Once()
+
+	req := httptest.NewRequest(http.MethodPost, "/receptions",
+		bytes.NewBufferString(`{"pvzId":"31ae2e29-0460-4748-a9f3-2b5747f78960"}`))
+	ctx := api.WithRole(req.Context(), "employee")
+	req = req.WithContext(ctx)
+
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, req)
+
+	require.Equal(t, http.StatusConflict, rr.Code)
+	var resp model.ErrorResponse
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &resp))
+	require.Equal(t, "reception_already_open", resp.Code)
+	mr.AssertExpectations(t)
+}
+
+func TestCreateReception_PVZNotFound(t *testing.T) {
+	mr := new(mockRepo)
+	h := api.CreateReceptionHandler(mr)
+
+	mr.On("CreateReception", mock.Anything, mock.Anything).
+		Return(db.ErrPVZNotFound).Once()
+
+	req := httptest.NewRequest(http.MethodPost, "/receptions",
+		bytes.NewBufferString(`{"pvzId":"31ae2e29-0460-4748-a9f3-2b5747f78960"}`))
+	ctx := api.WithRole(req.Context(), "employee")
+	req = req.WithContext(ctx)
+
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, req)
+
+	require.Equal(t, http.StatusNotFound, rr.Code)
 	mr.AssertExpectations(t)
 }
 
@@ -161,3 +232,398 @@ func TestCloseLastReception_Forbidden(t *testing.T) {
 	require.Equal(t, http.StatusForbidden, rr.Code)
 	mr.AssertNotCalled(t, "CloseLastReception")
 }
+
+func TestCloseLastReception_Errors(t *testing.T) {
+	mr := new(mockRepo)
+	r := chi.NewRouter()
+	r.Post("/pvz/{pvzId}/close_last_reception", api.CloseLastReceptionHandler(mr))
+
+	cases := []struct {
+		err  error
+		code int
+		body string
+	}{
+		{db.ErrNoActiveReception, http.StatusConflict, `"code":"no_active_reception"`},
+		{fmt.Errorf("%w: pvz 82cc7cda", db.ErrNotFound), http.StatusNotFound, `"code":"not_found"`},
+		{errors.New("pq: password authentication failed for user \"pvz\""), http.StatusInternalServerError, `{"code":"internal_error","message":"server error"}`},
+	}
+	for _, tc := range cases {
+		mr.On("CloseLastReception", mock.Anything, "82cc7cda-bd24-468f-b7b7-844d66b6693c", 0).Return(nil, tc.err).Once()
+
+		req := httptest.NewRequest(http.MethodPost, "/pvz/82cc7cda-bd24-468f-b7b7-844d66b6693c/close_last_reception", nil)
+		req = req.WithContext(api.WithRole(req.Context(), "employee"))
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, req)
+
+		require.Equal(t, tc.code, rr.Code)
+		require.Contains(t, rr.Body.String(), tc.body)
+		require.NotContains(t, rr.Body.String(), "pq:")
+	}
+	mr.AssertExpectations(t)
+}
+
+func TestCloseLastReception_IfMatch(t *testing.T) {
+	mr := new(mockRepo)
+	r := chi.NewRouter()
+	r.Post("/pvz/{pvzId}/close_last_reception", api.CloseLastReceptionHandler(mr))
+
+	closeWith := func(ifMatch string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/pvz/82cc7cda-bd24-468f-b7b7-844d66b6693c/close_last_reception", nil)
+		req.Header.Set("If-Match", ifMatch)
+		req = req.WithContext(api.WithRole(req.Context(), "employee"))
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, req)
+		return rr
+	}
+
+	mr.On("CloseLastReception", mock.Anything, "82cc7cda-bd24-468f-b7b7-844d66b6693c", 3).
+		Return(&model.Reception{ID: "rec-xyz", Status: "close", Version: 4}, nil).Once()
+	rr := closeWith(`"3"`)
+	require.Equal(t, http.StatusOK, rr.Code)
+	require.Equal(t, `"4"`, rr.Header().Get("ETag"))
+
+	// приёмку успели изменить с другого устройства
+	mr.On("CloseLastReception", mock.Anything, "82cc7cda-bd24-468f-b7b7-844d66b6693c", 3).
+		Return(nil, db.ErrVersionMismatch).Once()
+	rr = closeWith(`"3"`)
+	require.Equal(t, http.StatusPreconditionFailed, rr.Code)
+	require.Contains(t, rr.Body.String(), `"code":"version_mismatch"`)
+
+	// слабый или чужой ETag не совпадает ни с одной версией - до репозитория не доходит
+	for _, tag := range []string{`W/"3"`, `3`, `"abc"`} {
+		rr = closeWith(tag)
+		require.Equal(t, http.StatusPreconditionFailed, rr.Code, tag)
+	}
+
+	mr.On("CloseLastReception", mock.Anything, "82cc7cda-bd24-468f-b7b7-844d66b6693c", 0).
+		Return(&model.Reception{ID: "rec-xyz", Status: "close", Version: 2}, nil).Once()
+	rr = closeWith("*")
+	require.Equal(t, http.StatusOK, rr.Code)
+
+	mr.AssertExpectations(t)
+}
+
+func TestGetReceptionHandler_ETag(t *testing.T) {
+	mr := new(mockRepo)
+	r := chi.NewRouter()
+	r.Get("/receptions/{receptionId}", api.GetReceptionHandler(mr))
+
+	const id = "5b1a0f44-28a8-4a43-8c1b-0b0c8a6b2f10"
+	mr.On("GetReception", mock.Anything, id).
+		Return(&model.Reception{ID: id, Status: "in_progress", Version: 2}, nil)
+
+	get := func(ifNoneMatch string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/receptions/"+id, nil)
+		if ifNoneMatch != "" {
+			req.Header.Set("If-None-Match", ifNoneMatch)
+		}
+		req = req.WithContext(api.WithRole(req.Context(), "moderator"))
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, req)
+		return rr
+	}
+
+	rr := get("")
+	require.Equal(t, http.StatusOK, rr.Code)
+	require.Equal(t, `"2"`, rr.Header().Get("ETag"))
+	require.Contains(t, rr.Body.String(), `"version":2`)
+
+	rr = get(`"1", W/"2"`)
+	require.Equal(t, http.StatusNotModified, rr.Code)
+	require.Empty(t, rr.Body.String())
+
+	rr = get(`"1"`)
+	require.Equal(t, http.StatusOK, rr.Code)
+}
+
+func TestReopenApprovalHandler_Success(t *testing.T) {
+	r := chi.NewRouter()
+	r.Post("/pvz/{pvzId}/reopen_approval", api.ReopenApprovalHandler(time.Minute))
+
+	req := httptest.NewRequest(http.MethodPost, "/pvz/82cc7cda-bd24-468f-b7b7-844d66b6693c/reopen_approval", nil)
+	req = req.WithContext(api.WithRole(req.Context(), "moderator"))
+
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, req)
+	require.Equal(t, http.StatusCreated, rr.Code)
+
+	var resp map[string]string
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &resp))
+	id, err := auth.VerifyReopenApproval(resp["approvalToken"], "82cc7cda-bd24-468f-b7b7-844d66b6693c")
+	require.NoError(t, err)
+	require.Equal(t, resp["approvalId"], id)
+}
+
+func TestReopenApprovalHandler_Forbidden(t *testing.T) {
+	r := chi.NewRouter()
+	r.Post("/pvz/{pvzId}/reopen_approval", api.ReopenApprovalHandler(time.Minute))
+
+	req := httptest.NewRequest(http.MethodPost, "/pvz/82cc7cda-bd24-468f-b7b7-844d66b6693c/reopen_approval", nil)
+	req = req.WithContext(api.WithRole(req.Context(), "employee"))
+
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, req)
+	require.Equal(t, http.StatusForbidden, rr.Code)
+}
+
+func TestReopenLastReception_EmployeeWithApproval(t *testing.T) {
+	mr := new(mockRepo)
+	r := chi.NewRouter()
+	r.Post("/pvz/{pvzId}/reopen_last_reception", api.ReopenLastReceptionHandler(mr, 15*time.Minute))
+
+	token, approvalID, err := auth.IssueReopenApproval("82cc7cda-bd24-468f-b7b7-844d66b6693c", time.Minute)
+	require.NoError(t, err)
+
+	mr.On("ReopenLastReception", mock.Anything, "82cc7cda-bd24-468f-b7b7-844d66b6693c", 0, mock.AnythingOfType("time.Time"),
+		mock.MatchedBy(func(ro *model.ReceptionReopen) bool {
+			return ro.Reason == "one more box" && ro.ReopenedBy == "employee" && ro.ApprovalID == approvalID
+		})).
+		Return(&model.Reception{ID: "rec-1", Status: "in_progress"}, nil).
+		Once()
+
+	body := `{"reason":"one more box","approvalToken":"` + token + `"}`
+	req := httptest.NewRequest(http.MethodPost, "/pvz/82cc7cda-bd24-468f-b7b7-844d66b6693c/reopen_last_reception", bytes.NewBufferString(body))
+	req = req.WithContext(api.WithRole(req.Context(), "employee"))
+
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, req)
+	require.Equal(t, http.StatusOK, rr.Code)
+
+	mr.AssertExpectations(t)
+}
+
+func TestReopenLastReception_EmployeeWithoutApproval(t *testing.T) {
+	mr := new(mockRepo)
+	r := chi.NewRouter()
+	r.Post("/pvz/{pvzId}/reopen_last_reception", api.ReopenLastReceptionHandler(mr, 15*time.Minute))
+
+	req := httptest.NewRequest(http.MethodPost, "/pvz/82cc7cda-bd24-468f-b7b7-844d66b6693c/reopen_last_reception",
+		bytes.NewBufferString(`{"reason":"one more box"}`))
+	req = req.WithContext(api.WithRole(req.Context(), "employee"))
+
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, req)
+	require.Equal(t, http.StatusForbidden, rr.Code)
+	mr.AssertNotCalled(t, "ReopenLastReception")
+}
+
+func TestReopenLastReception_ApprovalErrors(t *testing.T) {
+	mr := new(mockRepo)
+	r := chi.NewRouter()
+	r.Post("/pvz/{pvzId}/reopen_last_reception", api.ReopenLastReceptionHandler(mr, 15*time.Minute))
+
+	reopenWith := func(token string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/pvz/82cc7cda-bd24-468f-b7b7-844d66b6693c/reopen_last_reception",
+			bytes.NewBufferString(`{"reason":"one more box","approvalToken":"`+token+`"}`))
+		req = req.WithContext(api.WithRole(req.Context(), "employee"))
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, req)
+		return rr
+	}
+
+	// разрешение на другой ПВЗ: причина только в логе, клиенту - фиксированный код
+	other, _, err := auth.IssueReopenApproval("5f0c8d2e-9a51-4c1b-8f3e-2d7a6b4c1e90", time.Minute)
+	require.NoError(t, err)
+	rr := reopenWith(other)
+	require.Equal(t, http.StatusForbidden, rr.Code)
+	require.JSONEq(t, `{"code":"invalid_approval","message":"invalid reopen approval"}`, rr.Body.String())
+	mr.AssertNotCalled(t, "ReopenLastReception")
+
+	// повторное использование одного разрешения
+	token, _, err := auth.IssueReopenApproval("82cc7cda-bd24-468f-b7b7-844d66b6693c", time.Minute)
+	require.NoError(t, err)
+	mr.On("ReopenLastReception", mock.Anything, "82cc7cda-bd24-468f-b7b7-844d66b6693c", 0, mock.Anything, mock.Anything).
+		Return(nil, db.ErrApprovalUsed).Once()
+	rr = reopenWith(token)
+	require.Equal(t, http.StatusConflict, rr.Code)
+	require.Contains(t, rr.Body.String(), `"code":"approval_already_used"`)
+	mr.AssertExpectations(t)
+}
+
+func TestReopenLastReception_ModeratorNoReason(t *testing.T) {
+	mr := new(mockRepo)
+	r := chi.NewRouter()
+	r.Post("/pvz/{pvzId}/reopen_last_reception", api.ReopenLastReceptionHandler(mr, 15*time.Minute))
+
+	req := httptest.NewRequest(http.MethodPost, "/pvz/82cc7cda-bd24-468f-b7b7-844d66b6693c/reopen_last_reception",
+		bytes.NewBufferString(`{"reason":"  "}`))
+	req = req.WithContext(api.WithRole(req.Context(), "moderator"))
+
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, req)
+	require.Equal(t, http.StatusBadRequest, rr.Code)
+	mr.AssertNotCalled(t, "ReopenLastReception")
+}
+
+func TestReopenLastReception_WindowExpired(t *testing.T) {
+	mr := new(mockRepo)
+	r := chi.NewRouter()
+	r.Post("/pvz/{pvzId}/reopen_last_reception", api.ReopenLastReceptionHandler(mr, 15*time.Minute))
+
+	mr.On("ReopenLastReception", mock.Anything, "82cc7cda-bd24-468f-b7b7-844d66b6693c", 0, mock.Anything, mock.Anything).
+		Return(nil, db.ErrReopenExpired).
+		Once()
+
+	req := httptest.NewRequest(http.MethodPost, "/pvz/82cc7cda-bd24-468f-b7b7-844d66b6693c/reopen_last_reception",
+		bytes.NewBufferString(`{"reason":"one more box"}`))
+	req = req.WithContext(api.WithRole(req.Context(), "moderator"))
+
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, req)
+	require.Equal(t, http.StatusConflict, rr.Code)
+	require.Contains(t, rr.Body.String(), "reopen_window_expired")
+	mr.AssertExpectations(t)
+}
+
+func TestCreateReception_WithManifest(t *testing.T) {
+	mr := new(mockRepo)
+	h := api.CreateReceptionHandler(mr)
+
+	mr.On("CreateReception", mock.Anything, mock.MatchedBy(func(rec *model.Reception) bool {
+		return len(rec.Manifest) == 1 && rec.Manifest[0].Type == "обувь" && rec.Manifest[0].Count == 5
+	})).Return(nil).Once()
+
+	body := `{"pvzId":"31ae2e29-0460-4748-a9f3-2b5747f78960","manifest":[{"type":"обувь","count":5}]}`
+	req := httptest.NewRequest(http.MethodPost, "/receptions", bytes.NewBufferString(body))
+	req = req.WithContext(api.WithRole(req.Context(), "employee"))
+
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, req)
+	require.Equal(t, http.StatusCreated, rr.Code)
+	mr.AssertExpectations(t)
+}
+
+func TestCreateReception_InvalidManifest(t *testing.T) {
+	mr := new(mockRepo)
+	h := api.CreateReceptionHandler(mr)
+
+	body := `{"pvzId":"31ae2e29-0460-4748-a9f3-2b5747f78960","manifest":[{"type":"обувь","count":1},{"type":"обувь","count":2}]}`
+	req := httptest.NewRequest(http.MethodPost, "/receptions", bytes.NewBufferString(body))
+	req = req.WithContext(api.WithRole(req.Context(), "employee"))
+
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, req)
+	require.Equal(t, http.StatusBadRequest, rr.Code)
+	mr.AssertNotCalled(t, "CreateReception")
+}
+
+func TestCloseLastReception_ReturnsDiscrepancy(t *testing.T) {
+	mr := new(mockRepo)
+	r := chi.NewRouter()
+	r.Post("/pvz/{pvzId}/close_last_reception", api.CloseLastReceptionHandler(mr))
+
+	mr.On("CloseLastReception", mock.Anything, "82cc7cda-bd24-468f-b7b7-844d66b6693c", 0).
+		Return(&model.Reception{
+			ID:     "rec-xyz",
+			Status: "close",
+			Discrepancy: &model.DiscrepancyReport{
+				ReceptionID:  "rec-xyz",
+				Lines:        []model.DiscrepancyLine{{Type: "обувь", Expected: 2, Received: 1, Missing: 1}},
+				TotalMissing: 1,
+			},
+		}, nil).
+		Once()
+
+	req := httptest.NewRequest(http.MethodPost, "/pvz/82cc7cda-bd24-468f-b7b7-844d66b6693c/close_last_reception", nil)
+	req = req.WithContext(api.WithRole(req.Context(), "employee"))
+
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, req)
+	require.Equal(t, http.StatusOK, rr.Code)
+
+	var got model.Reception
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &got))
+	require.NotNil(t, got.Discrepancy)
+	require.Equal(t, 1, got.Discrepancy.TotalMissing)
+	mr.AssertExpectations(t)
+}
+
+func TestGetDiscrepancyReport_NotFound(t *testing.T) {
+	mr := new(mockRepo)
+	r := chi.NewRouter()
+	r.Get("/receptions/{receptionId}/discrepancy", api.GetDiscrepancyReportHandler(mr))
+
+	mr.On("GetDiscrepancyReport", mock.Anything, "31ae2e29-0460-4748-a9f3-2b5747f78960").
+		Return(nil, nil).Once()
+
+	req := httptest.NewRequest(http.MethodGet, "/receptions/31ae2e29-0460-4748-a9f3-2b5747f78960/discrepancy", nil)
+	req = req.WithContext(api.WithRole(req.Context(), "moderator"))
+
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, req)
+	require.Equal(t, http.StatusNotFound, rr.Code)
+	mr.AssertExpectations(t)
+}
+
+func TestGetDiscrepancyReport_InternalError(t *testing.T) {
+	mr := new(mockRepo)
+	r := chi.NewRouter()
+	r.Get("/receptions/{receptionId}/discrepancy", api.GetDiscrepancyReportHandler(mr))
+
+	mr.On("GetDiscrepancyReport", mock.Anything, "31ae2e29-0460-4748-a9f3-2b5747f78960").
+		Return(nil, errors.New("pq: relation \"reception_discrepancies\" does not exist")).Once()
+
+	req := httptest.NewRequest(http.MethodGet, "/receptions/31ae2e29-0460-4748-a9f3-2b5747f78960/discrepancy", nil)
+	req = req.WithContext(api.WithRole(req.Context(), "moderator"))
+
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, req)
+	require.Equal(t, http.StatusInternalServerError, rr.Code)
+	require.JSONEq(t, `{"code":"internal_error","message":"server error"}`, rr.Body.String())
+	mr.AssertExpectations(t)
+}
+
+func TestGetDiscrepancyReport_Success(t *testing.T) {
+	mr := new(mockRepo)
+	r := chi.NewRouter()
+	r.Get("/receptions/{receptionId}/discrepancy", api.GetDiscrepancyReportHandler(mr))
+
+	mr.On("GetDiscrepancyReport", mock.Anything, "31ae2e29-0460-4748-a9f3-2b5747f78960").
+		Return(&model.DiscrepancyReport{ReceptionID: "31ae2e29-0460-4748-a9f3-2b5747f78960", TotalSurplus: 2}, nil).Once()
+
+	req := httptest.NewRequest(http.MethodGet, "/receptions/31ae2e29-0460-4748-a9f3-2b5747f78960/discrepancy", nil)
+	req = req.WithContext(api.WithRole(req.Context(), "employee"))
+
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, req)
+	require.Equal(t, http.StatusOK, rr.Code)
+
+	var got model.DiscrepancyReport
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &got))
+	require.Equal(t, 2, got.TotalSurplus)
+	mr.AssertExpectations(t)
+}
+
+func TestCloseLastReception_ReturnsSummary(t *testing.T) {
+	mr := new(mockRepo)
+	r := chi.NewRouter()
+	r.Post("/pvz/{pvzId}/close_last_reception", api.CloseLastReceptionHandler(mr))
+
+	mr.On("CloseLastReception", mock.Anything, "82cc7cda-bd24-468f-b7b7-844d66b6693c", 0).
+		Return(&model.Reception{
+			ID:     "rec-xyz",
+			Status: "close",
+			Summary: &model.ReceptionSummary{
+				TotalProducts:   3,
+				CountsByType:    map[string]int{"обувь": 1, "одежда": 2},
+				DurationSeconds: 600,
+				LIFODeletions:   1,
+			},
+		}, nil).
+		Once()
+
+	req := httptest.NewRequest(http.MethodPost, "/pvz/82cc7cda-bd24-468f-b7b7-844d66b6693c/close_last_reception", nil)
+	req = req.WithContext(api.WithRole(req.Context(), "employee"))
+
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, req)
+	require.Equal(t, http.StatusOK, rr.Code)
+
+	var got model.Reception
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &got))
+	require.NotNil(t, got.Summary)
+	require.Equal(t, 3, got.Summary.TotalProducts)
+	require.Equal(t, 2, got.Summary.CountsByType["одежда"])
+	require.Equal(t, 1, got.Summary.LIFODeletions)
+	mr.AssertExpectations(t)
+}
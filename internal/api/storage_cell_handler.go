@@ -0,0 +1,141 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/51mans0n/avito-pvz-task/internal/db"
+	"github.com/51mans0n/avito-pvz-task/internal/logging"
+	"github.com/51mans0n/avito-pvz-task/internal/model"
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+)
+
+// CreateStorageCellHandler - модератор заводит ячейку хранения в ПВЗ
+func CreateStorageCellHandler(repo db.Repository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		role := GetRole(r.Context())
+		if role != "moderator" {
+			http.Error(w, `{"message":"access denied"}`, http.StatusForbidden)
+			return
+		}
+
+		pvzId := chi.URLParam(r, "pvzId")
+		if _, err := uuid.Parse(pvzId); err != nil {
+			http.Error(w, `{"message":"invalid pvzId"}`, http.StatusBadRequest)
+			return
+		}
+
+		var req struct {
+			Zone     string `json:"zone"`
+			Rack     string `json:"rack"`
+			Shelf    string `json:"shelf"`
+			Capacity int    `json:"capacity"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, `{"message":"invalid json"}`, http.StatusBadRequest)
+			return
+		}
+		cell := &model.StorageCell{
+			ID:        uuid.New().String(),
+			PVZID:     pvzId,
+			Zone:      strings.TrimSpace(req.Zone),
+			Rack:      strings.TrimSpace(req.Rack),
+			Shelf:     strings.TrimSpace(req.Shelf),
+			Capacity:  req.Capacity,
+			CreatedAt: time.Now(),
+		}
+		if cell.Zone == "" || cell.Rack == "" || cell.Shelf == "" {
+			http.Error(w, `{"message":"zone, rack and shelf are required"}`, http.StatusBadRequest)
+			return
+		}
+		if cell.Capacity <= 0 {
+			http.Error(w, `{"message":"capacity must be positive"}`, http.StatusBadRequest)
+			return
+		}
+
+		if err := repo.CreateStorageCell(r.Context(), cell); err != nil {
+			writeRepoError(w, err, "create storage cell", "pvz", pvzId)
+			return
+		}
+
+		w.WriteHeader(http.StatusCreated)
+		if err := json.NewEncoder(w).Encode(cell); err != nil {
+			logging.S().Warnw("encode storage cell", "err", err)
+		}
+	}
+}
+
+// ListStorageCellsHandler - ячейки ПВЗ с заполненностью
+func ListStorageCellsHandler(repo db.Repository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		role := GetRole(r.Context())
+		if role != "employee" && role != "moderator" {
+			http.Error(w, `{"message":"forbidden"}`, http.StatusForbidden)
+			return
+		}
+
+		pvzId := chi.URLParam(r, "pvzId")
+		if _, err := uuid.Parse(pvzId); err != nil {
+			http.Error(w, `{"message":"invalid pvzId"}`, http.StatusBadRequest)
+			return
+		}
+
+		cells, err := repo.ListStorageCells(r.Context(), pvzId)
+		if err != nil {
+			logging.S().Errorw("list storage cells", "pvz", pvzId, "err", err)
+			writeError(w, http.StatusInternalServerError, codeInternal, "server error")
+			return
+		}
+		if cells == nil {
+			cells = []*model.StorageCell{}
+		}
+		if err := json.NewEncoder(w).Encode(cells); err != nil {
+			logging.S().Warnw("encode storage cells", "err", err)
+		}
+	}
+}
+
+// MoveProductHandler - переложить товар в другую ячейку того же ПВЗ
+func MoveProductHandler(repo db.Repository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		role := GetRole(r.Context())
+		if role != "employee" && role != "moderator" {
+			http.Error(w, `{"message":"forbidden"}`, http.StatusForbidden)
+			return
+		}
+
+		productId := chi.URLParam(r, "productId")
+		if _, err := uuid.Parse(productId); err != nil {
+			http.Error(w, `{"message":"invalid productId"}`, http.StatusBadRequest)
+			return
+		}
+		var req struct {
+			CellID string `json:"cellId"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, `{"message":"invalid json"}`, http.StatusBadRequest)
+			return
+		}
+		if _, err := uuid.Parse(req.CellID); err != nil {
+			http.Error(w, `{"message":"invalid cellId"}`, http.StatusBadRequest)
+			return
+		}
+
+		prod, err := repo.MoveProduct(r.Context(), productId, req.CellID, role)
+		if err != nil {
+			writeRepoError(w, err, "move product", "product", productId, "cell", req.CellID)
+			return
+		}
+
+		resp := struct {
+			Product model.ProductResponse `json:"product"`
+			Cell    *model.StorageCell    `json:"cell"`
+		}{convertProduct(prod), prod.Cell}
+		if err := json.NewEncoder(w).Encode(resp); err != nil {
+			logging.S().Warnw("encode product", "err", err)
+		}
+	}
+}
@@ -0,0 +1,153 @@
+package api_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/51mans0n/avito-pvz-task/internal/api"
+	"github.com/51mans0n/avito-pvz-task/internal/db"
+	"github.com/51mans0n/avito-pvz-task/internal/model"
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func (m *mockRepo) CreateStorageCell(ctx context.Context, c *model.StorageCell) error {
+	args := m.Called(ctx, c)
+	return args.Error(0)
+}
+
+func (m *mockRepo) ListStorageCells(ctx context.Context, pvzID string) ([]*model.StorageCell, error) {
+	args := m.Called(ctx, pvzID)
+	cells, _ := args.Get(0).([]*model.StorageCell)
+	return cells, args.Error(1)
+}
+
+func (m *mockRepo) MoveProduct(ctx context.Context, productID, cellID, actor string) (*model.Product, error) {
+	args := m.Called(ctx, productID, cellID, actor)
+	prod, _ := args.Get(0).(*model.Product)
+	return prod, args.Error(1)
+}
+
+func (m *mockRepo) GetProduct(ctx context.Context, productID string) (*model.ProductLookup, error) {
+	args := m.Called(ctx, productID)
+	found, _ := args.Get(0).(*model.ProductLookup)
+	return found, args.Error(1)
+}
+
+const cellPVZ = "82cc7cda-bd24-468f-b7b7-844d66b6693c"
+
+func cellsRouter(mr *mockRepo) http.Handler {
+	r := chi.NewRouter()
+	r.Post("/pvz/{pvzId}/cells", api.CreateStorageCellHandler(mr))
+	r.Get("/pvz/{pvzId}/cells", api.ListStorageCellsHandler(mr))
+	r.Post("/products/{productId}/move", api.MoveProductHandler(mr))
+	return r
+}
+
+func serveAs(h http.Handler, role, method, path, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, bytes.NewBufferString(body))
+	req = req.WithContext(api.WithRole(req.Context(), role))
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, req)
+	return rr
+}
+
+func TestCreateStorageCellHandler(t *testing.T) {
+	mr := new(mockRepo)
+	h := cellsRouter(mr)
+
+	mr.On("CreateStorageCell", mock.Anything, mock.MatchedBy(func(c *model.StorageCell) bool {
+		return c.PVZID == cellPVZ && c.Zone == "A" && c.Rack == "03" && c.Shelf == "2" && c.Capacity == 10
+	})).Return(nil).Once()
+	rr := serveAs(h, "moderator", http.MethodPost, "/pvz/"+cellPVZ+"/cells", `{"zone":"A","rack":"03","shelf":"2","capacity":10}`)
+	require.Equal(t, http.StatusCreated, rr.Code)
+
+	mr.On("CreateStorageCell", mock.Anything, mock.Anything).Return(db.ErrStorageCellExists).Once()
+	rr = serveAs(h, "moderator", http.MethodPost, "/pvz/"+cellPVZ+"/cells", `{"zone":"A","rack":"03","shelf":"2","capacity":10}`)
+	require.Equal(t, http.StatusConflict, rr.Code)
+	require.Contains(t, rr.Body.String(), "storage_cell_exists")
+
+	rr = serveAs(h, "moderator", http.MethodPost, "/pvz/"+cellPVZ+"/cells", `{"zone":"A","rack":"03","shelf":"2","capacity":0}`)
+	require.Equal(t, http.StatusBadRequest, rr.Code)
+
+	rr = serveAs(h, "employee", http.MethodPost, "/pvz/"+cellPVZ+"/cells", `{"zone":"A","rack":"03","shelf":"2","capacity":10}`)
+	require.Equal(t, http.StatusForbidden, rr.Code)
+	mr.AssertExpectations(t)
+}
+
+func TestListStorageCellsHandler(t *testing.T) {
+	mr := new(mockRepo)
+	mr.On("ListStorageCells", mock.Anything, cellPVZ).
+		Return([]*model.StorageCell{{ID: "c1", Zone: "A", Rack: "1", Shelf: "1", Capacity: 5, Occupied: 2}}, nil).Once()
+
+	rr := serveAs(cellsRouter(mr), "employee", http.MethodGet, "/pvz/"+cellPVZ+"/cells", "")
+	require.Equal(t, http.StatusOK, rr.Code)
+
+	var cells []model.StorageCell
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &cells))
+	require.Len(t, cells, 1)
+	require.Equal(t, 2, cells[0].Occupied)
+	mr.AssertExpectations(t)
+}
+
+func TestMoveProductHandler(t *testing.T) {
+	mr := new(mockRepo)
+	h := cellsRouter(mr)
+	path := "/products/5f0c8d2e-9a51-4c1b-8f3e-2d7a6b4c1e90/move"
+	body := `{"cellId":"0b6a3f2e-1c4d-4e5f-9a8b-7c6d5e4f3a21"}`
+
+	mr.On("MoveProduct", mock.Anything, "5f0c8d2e-9a51-4c1b-8f3e-2d7a6b4c1e90", "0b6a3f2e-1c4d-4e5f-9a8b-7c6d5e4f3a21", "employee").
+		Return(&model.Product{ID: "p1", CellID: "0b6a3f2e-1c4d-4e5f-9a8b-7c6d5e4f3a21",
+			Cell: &model.StorageCell{Zone: "B", Rack: "1", Shelf: "4"}}, nil).Once()
+	rr := serveAs(h, "employee", http.MethodPost, path, body)
+	require.Equal(t, http.StatusOK, rr.Code)
+	require.Contains(t, rr.Body.String(), `"cellId":"0b6a3f2e-1c4d-4e5f-9a8b-7c6d5e4f3a21"`)
+	require.Contains(t, rr.Body.String(), `"zone":"B"`)
+
+	cases := []struct {
+		err  error
+		code int
+		body string
+	}{
+		{fmt.Errorf("%w: B-1-4", db.ErrStorageCellFull), http.StatusConflict, "storage_cell_full"},
+		{db.ErrStorageCellNotFound, http.StatusNotFound, "storage_cell_not_found"},
+		{db.ErrProductNotInStock, http.StatusConflict, "product_not_in_stock"},
+		{db.ErrProductNotFound, http.StatusNotFound, "product_not_found"},
+	}
+	for _, tc := range cases {
+		mr.On("MoveProduct", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(nil, tc.err).Once()
+		rr = serveAs(h, "employee", http.MethodPost, path, body)
+		require.Equal(t, tc.code, rr.Code)
+		require.Contains(t, rr.Body.String(), tc.body)
+	}
+
+	rr = serveAs(h, "employee", http.MethodPost, path, `{"cellId":"A-1-1"}`)
+	require.Equal(t, http.StatusBadRequest, rr.Code)
+	mr.AssertExpectations(t)
+}
+
+func TestGetProductHandler(t *testing.T) {
+	mr := new(mockRepo)
+	r := chi.NewRouter()
+	r.Get("/products/{productId}", api.GetProductHandler(mr))
+
+	mr.On("GetProduct", mock.Anything, "5f0c8d2e-9a51-4c1b-8f3e-2d7a6b4c1e90").
+		Return(&model.ProductLookup{
+			Product: model.ProductResponse{ID: "5f0c8d2e-9a51-4c1b-8f3e-2d7a6b4c1e90", CellID: "c1"},
+			Cell:    &model.StorageCell{ID: "c1", Zone: "A", Rack: "03", Shelf: "2"},
+		}, nil).Once()
+	rr := serveAs(r, "employee", http.MethodGet, "/products/5f0c8d2e-9a51-4c1b-8f3e-2d7a6b4c1e90", "")
+	require.Equal(t, http.StatusOK, rr.Code)
+	require.Contains(t, rr.Body.String(), `"rack":"03"`)
+
+	mr.On("GetProduct", mock.Anything, mock.Anything).Return(nil, nil).Once()
+	rr = serveAs(r, "employee", http.MethodGet, "/products/5f0c8d2e-9a51-4c1b-8f3e-2d7a6b4c1e90", "")
+	require.Equal(t, http.StatusNotFound, rr.Code)
+	mr.AssertExpectations(t)
+}
@@ -0,0 +1,173 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/51mans0n/avito-pvz-task/internal/db"
+	"github.com/51mans0n/avito-pvz-task/internal/logging"
+	"github.com/51mans0n/avito-pvz-task/internal/model"
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+)
+
+// CreateTransferHandler - черновик перемещения выбранных товаров в другой ПВЗ
+func CreateTransferHandler(repo db.Repository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		role := GetRole(r.Context())
+		if role != "employee" && role != "moderator" {
+			http.Error(w, `{"message":"forbidden"}`, http.StatusForbidden)
+			return
+		}
+
+		var req struct {
+			FromPVZID  string   `json:"fromPvzId"`
+			ToPVZID    string   `json:"toPvzId"`
+			ProductIDs []string `json:"productIds"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, `{"message":"invalid json"}`, http.StatusBadRequest)
+			return
+		}
+		if _, err := uuid.Parse(req.FromPVZID); err != nil {
+			http.Error(w, `{"message":"invalid fromPvzId"}`, http.StatusBadRequest)
+			return
+		}
+		if _, err := uuid.Parse(req.ToPVZID); err != nil {
+			http.Error(w, `{"message":"invalid toPvzId"}`, http.StatusBadRequest)
+			return
+		}
+		if req.FromPVZID == req.ToPVZID {
+			http.Error(w, `{"message":"fromPvzId and toPvzId must differ"}`, http.StatusBadRequest)
+			return
+		}
+		if len(req.ProductIDs) == 0 {
+			http.Error(w, `{"message":"productIds are required"}`, http.StatusBadRequest)
+			return
+		}
+		for i, id := range req.ProductIDs {
+			if _, err := uuid.Parse(id); err != nil || slices.Contains(req.ProductIDs[:i], id) {
+				http.Error(w, `{"message":"productIds[`+strconv.Itoa(i)+`]: invalid or duplicate id"}`, http.StatusBadRequest)
+				return
+			}
+		}
+
+		t := &model.Transfer{
+			ID:         uuid.New().String(),
+			FromPVZID:  req.FromPVZID,
+			ToPVZID:    req.ToPVZID,
+			Status:     model.TransferStatusDraft,
+			CreatedBy:  role,
+			CreatedAt:  time.Now(),
+			ProductIDs: req.ProductIDs,
+		}
+		if err := repo.CreateTransfer(r.Context(), t); err != nil {
+			writeRepoError(w, err, "create transfer", "transfer", t.ID)
+			return
+		}
+
+		w.WriteHeader(http.StatusCreated)
+		if err := json.NewEncoder(w).Encode(t); err != nil {
+			logging.S().Warnw("encode transfer", "err", err)
+		}
+	}
+}
+
+// GetTransferHandler - перемещение с id товаров
+func GetTransferHandler(repo db.Repository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		role := GetRole(r.Context())
+		if role != "employee" && role != "moderator" {
+			http.Error(w, `{"message":"forbidden"}`, http.StatusForbidden)
+			return
+		}
+
+		id := chi.URLParam(r, "transferId")
+		if _, err := uuid.Parse(id); err != nil {
+			http.Error(w, `{"message":"invalid transferId"}`, http.StatusBadRequest)
+			return
+		}
+
+		t, err := repo.GetTransfer(r.Context(), id)
+		if err != nil {
+			writeRepoError(w, err, "get transfer", "transfer", id)
+			return
+		}
+		if t == nil {
+			writeError(w, http.StatusNotFound, codeTransferNotFound, db.ErrTransferNotFound.Error())
+			return
+		}
+		if err := json.NewEncoder(w).Encode(t); err != nil {
+			logging.S().Warnw("encode transfer", "err", err)
+		}
+	}
+}
+
+// DispatchTransferHandler - отправить перемещение (draft -> dispatched)
+func DispatchTransferHandler(repo db.Repository) http.HandlerFunc {
+	return transferStepHandler(repo.DispatchTransfer)
+}
+
+// ReceiveTransferHandler - принять перемещение в ПВЗ назначения (dispatched -> received)
+func ReceiveTransferHandler(repo db.Repository) http.HandlerFunc {
+	return transferStepHandler(repo.ReceiveTransfer)
+}
+
+func transferStepHandler(step func(ctx context.Context, id, actor string, at time.Time) (*model.Transfer, error)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		role := GetRole(r.Context())
+		if role != "employee" && role != "moderator" {
+			http.Error(w, `{"message":"forbidden"}`, http.StatusForbidden)
+			return
+		}
+
+		id := chi.URLParam(r, "transferId")
+		if _, err := uuid.Parse(id); err != nil {
+			http.Error(w, `{"message":"invalid transferId"}`, http.StatusBadRequest)
+			return
+		}
+
+		t, err := step(r.Context(), id, role, time.Now())
+		if err != nil {
+			writeRepoError(w, err, "transfer step", "transfer", id)
+			return
+		}
+		if err := json.NewEncoder(w).Encode(t); err != nil {
+			logging.S().Warnw("encode transfer", "err", err)
+		}
+	}
+}
+
+// ListProductMovementsHandler - история перемещений товара
+func ListProductMovementsHandler(repo db.Repository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		role := GetRole(r.Context())
+		if role != "employee" && role != "moderator" {
+			http.Error(w, `{"message":"forbidden"}`, http.StatusForbidden)
+			return
+		}
+
+		productId := chi.URLParam(r, "productId")
+		if _, err := uuid.Parse(productId); err != nil {
+			http.Error(w, `{"message":"invalid productId"}`, http.StatusBadRequest)
+			return
+		}
+
+		moves, err := repo.ListProductMovements(r.Context(), productId)
+		if err != nil {
+			logging.S().Errorw("list product movements", "product", productId, "err", err)
+			writeError(w, http.StatusInternalServerError, codeInternal, "server error")
+			return
+		}
+		if moves == nil {
+			moves = []*model.ProductMovement{}
+		}
+		if err := json.NewEncoder(w).Encode(moves); err != nil {
+			logging.S().Warnw("encode movements", "err", err)
+		}
+	}
+}
@@ -0,0 +1,175 @@
+package api_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/51mans0n/avito-pvz-task/internal/api"
+	"github.com/51mans0n/avito-pvz-task/internal/db"
+	"github.com/51mans0n/avito-pvz-task/internal/model"
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func (m *mockRepo) CreateTransfer(ctx context.Context, t *model.Transfer) error {
+	args := m.Called(ctx, t)
+	return args.Error(0)
+}
+
+func (m *mockRepo) GetTransfer(ctx context.Context, id string) (*model.Transfer, error) {
+	args := m.Called(ctx, id)
+	t, _ := args.Get(0).(*model.Transfer)
+	return t, args.Error(1)
+}
+
+func (m *mockRepo) DispatchTransfer(ctx context.Context, id, actor string, at time.Time) (*model.Transfer, error) {
+	args := m.Called(ctx, id, actor, at)
+	t, _ := args.Get(0).(*model.Transfer)
+	return t, args.Error(1)
+}
+
+func (m *mockRepo) ReceiveTransfer(ctx context.Context, id, actor string, at time.Time) (*model.Transfer, error) {
+	args := m.Called(ctx, id, actor, at)
+	t, _ := args.Get(0).(*model.Transfer)
+	return t, args.Error(1)
+}
+
+func (m *mockRepo) ListProductMovements(ctx context.Context, productID string) ([]*model.ProductMovement, error) {
+	args := m.Called(ctx, productID)
+	moves, _ := args.Get(0).([]*model.ProductMovement)
+	return moves, args.Error(1)
+}
+
+const (
+	transferID  = "3c1f8e2a-6b4d-4f7a-9e2c-5d8b1a7f4e36"
+	transferTo  = "a4e2c9d1-7f3b-4c8e-b1a6-2d5f8e9c3b70"
+	transferPrd = "5f0c8d2e-9a51-4c1b-8f3e-2d7a6b4c1e90"
+)
+
+func transfersRouter(mr *mockRepo) http.Handler {
+	r := chi.NewRouter()
+	r.Post("/transfers", api.CreateTransferHandler(mr))
+	r.Get("/transfers/{transferId}", api.GetTransferHandler(mr))
+	r.Post("/transfers/{transferId}/dispatch", api.DispatchTransferHandler(mr))
+	r.Post("/transfers/{transferId}/receive", api.ReceiveTransferHandler(mr))
+	r.Get("/products/{productId}/movements", api.ListProductMovementsHandler(mr))
+	return r
+}
+
+func TestCreateTransferHandler(t *testing.T) {
+	mr := new(mockRepo)
+	h := transfersRouter(mr)
+
+	mr.On("CreateTransfer", mock.Anything, mock.MatchedBy(func(tr *model.Transfer) bool {
+		return tr.FromPVZID == cellPVZ && tr.ToPVZID == transferTo &&
+			tr.Status == model.TransferStatusDraft && tr.CreatedBy == "employee" && len(tr.ProductIDs) == 1
+	})).Return(nil).Once()
+	body := `{"fromPvzId":"` + cellPVZ + `","toPvzId":"` + transferTo + `","productIds":["` + transferPrd + `"]}`
+	rr := serveAs(h, "employee", http.MethodPost, "/transfers", body)
+	require.Equal(t, http.StatusCreated, rr.Code)
+	require.Contains(t, rr.Body.String(), `"status":"draft"`)
+
+	bad := []string{
+		`{"fromPvzId":"` + cellPVZ + `","toPvzId":"` + cellPVZ + `","productIds":["` + transferPrd + `"]}`,
+		`{"fromPvzId":"` + cellPVZ + `","toPvzId":"` + transferTo + `","productIds":[]}`,
+		`{"fromPvzId":"` + cellPVZ + `","toPvzId":"` + transferTo + `","productIds":["` + transferPrd + `","` + transferPrd + `"]}`,
+		`{"fromPvzId":"x","toPvzId":"` + transferTo + `","productIds":["` + transferPrd + `"]}`,
+	}
+	for _, b := range bad {
+		rr = serveAs(h, "employee", http.MethodPost, "/transfers", b)
+		require.Equal(t, http.StatusBadRequest, rr.Code, b)
+	}
+
+	cases := []struct {
+		err  error
+		code int
+		body string
+	}{
+		{&db.ProductError{Index: 0, Err: db.ErrProductNotFound}, http.StatusNotFound, "product_not_found"},
+		{&db.ProductError{Index: 0, Err: fmt.Errorf("%w: product is issued", db.ErrProductNotInStock)}, http.StatusConflict, "product_not_in_stock"},
+		{&db.ProductError{Index: 0, Err: db.ErrReceptionOpen}, http.StatusConflict, "reception_open"},
+		{db.ErrPVZNotFound, http.StatusNotFound, "pvz_not_found"},
+	}
+	for _, tc := range cases {
+		mr.On("CreateTransfer", mock.Anything, mock.Anything).Return(tc.err).Once()
+		rr = serveAs(h, "employee", http.MethodPost, "/transfers", body)
+		require.Equal(t, tc.code, rr.Code)
+		require.Contains(t, rr.Body.String(), tc.body)
+	}
+
+	rr = serveAs(h, "client", http.MethodPost, "/transfers", body)
+	require.Equal(t, http.StatusForbidden, rr.Code)
+	mr.AssertExpectations(t)
+}
+
+func TestTransferStepHandlers(t *testing.T) {
+	mr := new(mockRepo)
+	h := transfersRouter(mr)
+
+	mr.On("DispatchTransfer", mock.Anything, transferID, "employee", mock.Anything).
+		Return(&model.Transfer{ID: transferID, Status: model.TransferStatusDispatched}, nil).Once()
+	rr := serveAs(h, "employee", http.MethodPost, "/transfers/"+transferID+"/dispatch", "")
+	require.Equal(t, http.StatusOK, rr.Code)
+	require.Contains(t, rr.Body.String(), `"status":"dispatched"`)
+
+	mr.On("ReceiveTransfer", mock.Anything, transferID, "moderator", mock.Anything).
+		Return(&model.Transfer{ID: transferID, Status: model.TransferStatusReceived, ReceptionID: "rec-9"}, nil).Once()
+	rr = serveAs(h, "moderator", http.MethodPost, "/transfers/"+transferID+"/receive", "")
+	require.Equal(t, http.StatusOK, rr.Code)
+	require.Contains(t, rr.Body.String(), `"receptionId":"rec-9"`)
+
+	mr.On("DispatchTransfer", mock.Anything, transferID, "employee", mock.Anything).
+		Return(nil, fmt.Errorf("%w: transfer is received", db.ErrTransferState)).Once()
+	rr = serveAs(h, "employee", http.MethodPost, "/transfers/"+transferID+"/dispatch", "")
+	require.Equal(t, http.StatusConflict, rr.Code)
+	require.Contains(t, rr.Body.String(), "invalid_transfer_state")
+
+	mr.On("ReceiveTransfer", mock.Anything, transferID, "employee", mock.Anything).
+		Return(nil, db.ErrTransferNotFound).Once()
+	rr = serveAs(h, "employee", http.MethodPost, "/transfers/"+transferID+"/receive", "")
+	require.Equal(t, http.StatusNotFound, rr.Code)
+	require.Contains(t, rr.Body.String(), "transfer_not_found")
+
+	rr = serveAs(h, "employee", http.MethodPost, "/transfers/bad/dispatch", "")
+	require.Equal(t, http.StatusBadRequest, rr.Code)
+	mr.AssertExpectations(t)
+}
+
+func TestGetTransferHandler(t *testing.T) {
+	mr := new(mockRepo)
+	h := transfersRouter(mr)
+
+	mr.On("GetTransfer", mock.Anything, transferID).
+		Return(&model.Transfer{ID: transferID, Status: model.TransferStatusDraft, ProductIDs: []string{transferPrd}}, nil).Once()
+	rr := serveAs(h, "moderator", http.MethodGet, "/transfers/"+transferID, "")
+	require.Equal(t, http.StatusOK, rr.Code)
+	require.Contains(t, rr.Body.String(), transferPrd)
+
+	mr.On("GetTransfer", mock.Anything, transferID).Return(nil, nil).Once()
+	rr = serveAs(h, "moderator", http.MethodGet, "/transfers/"+transferID, "")
+	require.Equal(t, http.StatusNotFound, rr.Code)
+	mr.AssertExpectations(t)
+}
+
+func TestListProductMovementsHandler(t *testing.T) {
+	mr := new(mockRepo)
+	h := transfersRouter(mr)
+
+	mr.On("ListProductMovements", mock.Anything, transferPrd).
+		Return([]*model.ProductMovement{
+			{ProductID: transferPrd, Event: model.MovementReceived, ToPVZID: cellPVZ},
+			{ProductID: transferPrd, Event: model.MovementDispatched, FromPVZID: cellPVZ, ToPVZID: transferTo, TransferID: transferID},
+		}, nil).Once()
+	rr := serveAs(h, "employee", http.MethodGet, "/products/"+transferPrd+"/movements", "")
+	require.Equal(t, http.StatusOK, rr.Code)
+	require.Contains(t, rr.Body.String(), `"event":"transfer_dispatched"`)
+
+	mr.On("ListProductMovements", mock.Anything, transferPrd).Return(nil, nil).Once()
+	rr = serveAs(h, "employee", http.MethodGet, "/products/"+transferPrd+"/movements", "")
+	require.Equal(t, "[]\n", rr.Body.String())
+	mr.AssertExpectations(t)
+}
@@ -2,12 +2,17 @@ package auth
 
 import (
 	"errors"
-	"github.com/golang-jwt/jwt/v5"
 	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
 )
 
 var secret = []byte("supersecret") // TODO: брать из config/env
 
+const reopenApprovalKind = "reopen_approval"
+
 func ExtractRole(token string) (string, error) {
 	// dummy‑token
 	if strings.HasPrefix(token, "SOME_TOKEN_") {
@@ -47,3 +52,48 @@ func ExtractRole(token string) (string, error) {
 func IssueDummyToken(role string) string { // "moderator"/"employee"/"client"
 	return "SOME_TOKEN_" + role
 }
+
+// IssueReopenApproval выдаёт подписанное модератором разрешение
+// на переоткрытие последней приёмки ПВЗ. Возвращает токен и его id.
+func IssueReopenApproval(pvzID string, ttl time.Duration) (string, string, error) {
+	id := uuid.New().String()
+	now := time.Now()
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"kind":   reopenApprovalKind,
+		"pvz_id": pvzID,
+		"jti":    id,
+		"iat":    now.Unix(),
+		"exp":    now.Add(ttl).Unix(),
+	})
+	signed, err := token.SignedString(secret)
+	if err != nil {
+		return "", "", err
+	}
+	return signed, id, nil
+}
+
+// VerifyReopenApproval проверяет разрешение на переоткрытие для ПВЗ и возвращает его id.
+func VerifyReopenApproval(token, pvzID string) (string, error) {
+	parsed, err := jwt.Parse(token, func(t *jwt.Token) (any, error) {
+		if _, ok := t.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, errors.New("unexpected signing method")
+		}
+		return secret, nil
+	})
+	if err != nil || !parsed.Valid {
+		return "", errors.New("invalid approval")
+	}
+
+	claims, ok := parsed.Claims.(jwt.MapClaims)
+	if !ok {
+		return "", errors.New("claims cast error")
+	}
+	if kind, _ := claims["kind"].(string); kind != reopenApprovalKind {
+		return "", errors.New("invalid approval")
+	}
+	if id, _ := claims["pvz_id"].(string); id != pvzID {
+		return "", errors.New("approval issued for another pvz")
+	}
+	jti, _ := claims["jti"].(string)
+	return jti, nil
+}
@@ -2,6 +2,7 @@ package auth_test
 
 import (
 	"testing"
+	"time"
 
 	"github.com/51mans0n/avito-pvz-task/internal/auth"
 	"github.com/stretchr/testify/require"
@@ -22,3 +23,36 @@ func TestExtractRole_BadFormat(t *testing.T) {
 	_, err := auth.ExtractRole("Bearer xxx")
 	require.Error(t, err)
 }
+
+func TestReopenApproval_RoundTrip(t *testing.T) {
+	token, id, err := auth.IssueReopenApproval("pvz-1", time.Minute)
+	require.NoError(t, err)
+
+	got, err := auth.VerifyReopenApproval(token, "pvz-1")
+	require.NoError(t, err)
+	require.Equal(t, id, got)
+}
+
+func TestReopenApproval_OtherPVZ(t *testing.T) {
+	token, _, err := auth.IssueReopenApproval("pvz-1", time.Minute)
+	require.NoError(t, err)
+
+	_, err = auth.VerifyReopenApproval(token, "pvz-2")
+	require.Error(t, err)
+}
+
+func TestReopenApproval_Expired(t *testing.T) {
+	token, _, err := auth.IssueReopenApproval("pvz-1", -time.Minute)
+	require.NoError(t, err)
+
+	_, err = auth.VerifyReopenApproval(token, "pvz-1")
+	require.Error(t, err)
+}
+
+func TestReopenApproval_NotUsableAsAuth(t *testing.T) {
+	token, _, err := auth.IssueReopenApproval("pvz-1", time.Minute)
+	require.NoError(t, err)
+
+	_, err = auth.ExtractRole(token)
+	require.Error(t, err)
+}
@@ -0,0 +1,57 @@
+// Package autoclose закрывает приёмки, забытые в статусе in_progress.
+package autoclose
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/51mans0n/avito-pvz-task/internal/db"
+	"github.com/51mans0n/avito-pvz-task/internal/logging"
+	"github.com/51mans0n/avito-pvz-task/internal/metrics"
+)
+
+// SystemActor - от чьего имени закрываются приёмки
+const SystemActor = "system"
+
+// Worker периодически закрывает приёмки, открытые дольше maxAge
+type Worker struct {
+	repo     db.Repository
+	maxAge   time.Duration
+	interval time.Duration
+	now      func() time.Time
+}
+
+func New(repo db.Repository, maxAge, interval time.Duration) *Worker {
+	return &Worker{repo: repo, maxAge: maxAge, interval: interval, now: time.Now}
+}
+
+// RunOnce делает один проход и возвращает число закрытых приёмок
+func (w *Worker) RunOnce(ctx context.Context) (int, error) {
+	reason := fmt.Sprintf("auto-closed: in progress longer than %s", w.maxAge)
+	closed, err := w.repo.CloseStaleReceptions(ctx, w.now().Add(-w.maxAge), SystemActor, reason)
+
+	metrics.ReceptionsAutoClosed.Add(float64(len(closed)))
+	for _, rec := range closed {
+		logging.S().Infow("reception auto-closed", "reception", rec.ID, "pvz", rec.PVZID, "opened", rec.DateTime)
+	}
+	return len(closed), err
+}
+
+// Run крутит RunOnce раз в interval, пока не отменён ctx
+func (w *Worker) Run(ctx context.Context) {
+	t := time.NewTicker(w.interval)
+	defer t.Stop()
+
+	for {
+		if _, err := w.RunOnce(ctx); err != nil && ctx.Err() == nil {
+			logging.S().Errorw("auto-close receptions", "err", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+	}
+}
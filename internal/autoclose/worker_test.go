@@ -0,0 +1,58 @@
+package autoclose
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/51mans0n/avito-pvz-task/internal/db"
+	"github.com/51mans0n/avito-pvz-task/internal/metrics"
+	"github.com/51mans0n/avito-pvz-task/internal/model"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeRepo реализует только CloseStaleReceptions, остальное - nil-интерфейс
+type fakeRepo struct {
+	db.Repository
+
+	openedBefore time.Time
+	actor        string
+	reason       string
+	closed       []*model.Reception
+	err          error
+}
+
+func (f *fakeRepo) CloseStaleReceptions(_ context.Context, openedBefore time.Time, actor, reason string) ([]*model.Reception, error) {
+	f.openedBefore, f.actor, f.reason = openedBefore, actor, reason
+	return f.closed, f.err
+}
+
+func TestWorker_RunOnce(t *testing.T) {
+	now := time.Date(2025, 4, 1, 9, 0, 0, 0, time.UTC)
+	repo := &fakeRepo{closed: []*model.Reception{{ID: "rec-1"}, {ID: "rec-2"}}}
+	w := New(repo, 12*time.Hour, time.Minute)
+	w.now = func() time.Time { return now }
+
+	before := testutil.ToFloat64(metrics.ReceptionsAutoClosed)
+	n, err := w.RunOnce(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, 2, n)
+
+	require.Equal(t, now.Add(-12*time.Hour), repo.openedBefore)
+	require.Equal(t, SystemActor, repo.actor)
+	require.Contains(t, repo.reason, "12h0m0s")
+	require.Equal(t, before+2, testutil.ToFloat64(metrics.ReceptionsAutoClosed))
+}
+
+func TestWorker_RunOnce_PartialFailure(t *testing.T) {
+	repo := &fakeRepo{closed: []*model.Reception{{ID: "rec-1"}}, err: errors.New("db down")}
+	w := New(repo, time.Hour, time.Minute)
+
+	before := testutil.ToFloat64(metrics.ReceptionsAutoClosed)
+	n, err := w.RunOnce(context.Background())
+	require.Error(t, err)
+	require.Equal(t, 1, n)
+	require.Equal(t, before+1, testutil.ToFloat64(metrics.ReceptionsAutoClosed))
+}
@@ -0,0 +1,46 @@
+package blob_test
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/51mans0n/avito-pvz-task/internal/blob"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLocalStore_PutGetDelete(t *testing.T) {
+	s, err := blob.NewLocalStore(t.TempDir())
+	require.NoError(t, err)
+	ctx := context.Background()
+
+	size, sum, err := s.Put(ctx, "reception/abc", strings.NewReader("waybill"))
+	require.NoError(t, err)
+	require.Equal(t, int64(7), size)
+	want := sha256.Sum256([]byte("waybill"))
+	require.Equal(t, hex.EncodeToString(want[:]), sum)
+
+	rc, err := s.Get(ctx, "reception/abc")
+	require.NoError(t, err)
+	data, err := io.ReadAll(rc)
+	require.NoError(t, err)
+	require.NoError(t, rc.Close())
+	require.Equal(t, "waybill", string(data))
+
+	require.NoError(t, s.Delete(ctx, "reception/abc"))
+	_, err = s.Get(ctx, "reception/abc")
+	require.ErrorIs(t, err, blob.ErrNotFound)
+}
+
+func TestLocalStore_RejectsTraversal(t *testing.T) {
+	s, err := blob.NewLocalStore(t.TempDir())
+	require.NoError(t, err)
+
+	_, _, err = s.Put(context.Background(), "../escape", strings.NewReader("x"))
+	require.Error(t, err)
+	_, err = s.Get(context.Background(), "/etc/passwd")
+	require.Error(t, err)
+}
@@ -0,0 +1,20 @@
+// Package blob хранит содержимое вложений (фото, накладные) вне БД.
+package blob
+
+import (
+	"context"
+	"errors"
+	"io"
+)
+
+// ErrNotFound - объекта с таким ключом нет
+var ErrNotFound = errors.New("blob not found")
+
+// Store - хранилище бинарных объектов по ключу
+type Store interface {
+	// Put сохраняет содержимое r под ключом key и возвращает размер и sha256 (hex)
+	Put(ctx context.Context, key string, r io.Reader) (size int64, sha256Hex string, err error)
+	// Get открывает объект на чтение; вызывающий закрывает ReadCloser
+	Get(ctx context.Context, key string) (io.ReadCloser, error)
+	Delete(ctx context.Context, key string) error
+}
@@ -0,0 +1,40 @@
+// Package config читает настройки сервиса из переменных окружения.
+package config
+
+import (
+	"os"
+	"strconv"
+	"time"
+)
+
+// String возвращает значение переменной или def, если она не задана.
+func String(key, def string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return def
+}
+
+// Int возвращает целое значение переменной или def, если она не задана или некорректна.
+func Int(key string, def int) int {
+	if n, err := strconv.Atoi(os.Getenv(key)); err == nil {
+		return n
+	}
+	return def
+}
+
+// Bool возвращает булево значение переменной ("true", "1", ...) или def.
+func Bool(key string, def bool) bool {
+	if b, err := strconv.ParseBool(os.Getenv(key)); err == nil {
+		return b
+	}
+	return def
+}
+
+// Duration возвращает длительность ("15m", "2h") или def.
+func Duration(key string, def time.Duration) time.Duration {
+	if d, err := time.ParseDuration(os.Getenv(key)); err == nil && d > 0 {
+		return d
+	}
+	return def
+}
@@ -0,0 +1,91 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+
+	sq "github.com/Masterminds/squirrel"
+
+	"github.com/51mans0n/avito-pvz-task/internal/model"
+)
+
+// attachmentOwners - условие существования владельца вложения ($3 - его id)
+var attachmentOwners = map[string]string{
+	model.AttachmentOwnerReception: "SELECT 1 FROM receptions WHERE id = $3",
+	model.AttachmentOwnerProduct:   "SELECT 1 FROM products WHERE id = $3 AND deleted_at IS NULL",
+}
+
+var attachmentColumns = []string{
+	"id", "owner_type", "owner_id", "file_name", "content_type",
+	"size_bytes", "sha256", "storage_key", "uploaded_by", "created_at",
+}
+
+// CreateAttachment сохраняет метаданные вложения. Владелец проверяется в том же
+// запросе: если его нет, возвращается ErrAttachmentOwnerNotFound.
+func (r *Repo) CreateAttachment(ctx context.Context, a *model.Attachment) error {
+	ownerExists, ok := attachmentOwners[a.OwnerType]
+	if !ok {
+		return fmt.Errorf("unknown attachment owner type %q", a.OwnerType)
+	}
+
+	q := `INSERT INTO attachments (` + strings.Join(attachmentColumns, ", ") + `)
+		SELECT $1, $2, $3, $4, $5, $6, $7, $8, $9, $10
+		WHERE EXISTS (` + ownerExists + `)`
+	res, err := r.db.ExecContext(ctx, q,
+		a.ID, a.OwnerType, a.OwnerID, a.FileName, a.ContentType,
+		a.Size, a.SHA256, a.StorageKey, a.UploadedBy, a.CreatedAt)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrAttachmentOwnerNotFound
+	}
+	return nil
+}
+
+// GetAttachment возвращает метаданные вложения (nil, если его нет)
+func (r *Repo) GetAttachment(ctx context.Context, id string) (*model.Attachment, error) {
+	q, args, err := sq.Select(attachmentColumns...).
+		From("attachments").
+		Where(sq.Eq{"id": id}).
+		PlaceholderFormat(sq.Dollar).
+		ToSql()
+	if err != nil {
+		return nil, err
+	}
+
+	var a model.Attachment
+	if err := r.db.GetContext(ctx, &a, q, args...); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &a, nil
+}
+
+// ListAttachments - вложения приёмки или товара в порядке загрузки
+func (r *Repo) ListAttachments(ctx context.Context, ownerType, ownerID string) ([]*model.Attachment, error) {
+	q, args, err := sq.Select(attachmentColumns...).
+		From("attachments").
+		Where(sq.Eq{"owner_type": ownerType, "owner_id": ownerID}).
+		OrderBy("created_at").
+		PlaceholderFormat(sq.Dollar).
+		ToSql()
+	if err != nil {
+		return nil, err
+	}
+
+	var list []*model.Attachment
+	if err := r.db.SelectContext(ctx, &list, q, args...); err != nil {
+		return nil, err
+	}
+	return list, nil
+}
@@ -0,0 +1,75 @@
+package db
+
+import (
+	"context"
+	"fmt"
+
+	sq "github.com/Masterminds/squirrel"
+
+	"github.com/51mans0n/avito-pvz-task/internal/model"
+)
+
+// checkBarcodes ищет штрихкоды пачки в открытых приёмках. Повтор в той же
+// приёмке (или внутри пачки) - ErrDuplicateBarcode, в чужой открытой приёмке -
+// только предупреждение в Warnings товара.
+func (r *Repo) checkBarcodes(ctx context.Context, receptionID string, prods []*model.Product) error {
+	byBarcode := make(map[string]*model.Product, len(prods))
+	codes := make([]string, 0, len(prods))
+	for i, p := range prods {
+		if p.Barcode == "" {
+			continue
+		}
+		if _, dup := byBarcode[p.Barcode]; dup {
+			return &ProductError{Index: i, Err: ErrDuplicateBarcode}
+		}
+		byBarcode[p.Barcode] = p
+		codes = append(codes, p.Barcode)
+	}
+	if len(codes) == 0 {
+		return nil
+	}
+
+	q, args, err := sq.Select("p.barcode", "r.id", "r.pvz_id").
+		From("products p").
+		Join("receptions r ON r.id = p.reception_id").
+		Where(sq.Eq{"p.barcode": codes, "p.deleted_at": nil, "r.status": "in_progress"}).
+		PlaceholderFormat(sq.Dollar).
+		ToSql()
+	if err != nil {
+		return err
+	}
+
+	var rows []struct {
+		Barcode     string `db:"barcode"`
+		ReceptionID string `db:"id"`
+		PVZID       string `db:"pvz_id"`
+	}
+	if err := r.db.SelectContext(ctx, &rows, q, args...); err != nil {
+		return err
+	}
+
+	for _, row := range rows {
+		p := byBarcode[row.Barcode]
+		if row.ReceptionID == receptionID {
+			return &ProductError{Index: indexOf(prods, p), Err: ErrDuplicateBarcode}
+		}
+		p.Warnings = append(p.Warnings, fmt.Sprintf("barcode %s is already scanned in open reception %s of pvz %s",
+			row.Barcode, row.ReceptionID, row.PVZID))
+	}
+	return nil
+}
+
+func indexOf(prods []*model.Product, p *model.Product) int {
+	for i := range prods {
+		if prods[i] == p {
+			return i
+		}
+	}
+	return -1
+}
+
+// GetProductByBarcode возвращает последний принятый товар с этим штрихкодом
+// вместе с приёмкой, ПВЗ и ячейкой (nil, если не найден)
+func (r *Repo) GetProductByBarcode(ctx context.Context, barcode string) (*model.ProductLookup, error) {
+	return r.lookupProduct(ctx, sq.Eq{"p.barcode": barcode})
+}
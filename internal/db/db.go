@@ -1,46 +1,215 @@
 package db
 
 import (
+	"context"
+	"database/sql"
 	"fmt"
-	"os"
+	"net"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/jmoiron/sqlx"
 	_ "github.com/lib/pq"
+
+	"github.com/51mans0n/avito-pvz-task/internal/config"
+	"github.com/51mans0n/avito-pvz-task/internal/logging"
 )
 
-func InitDB() (*sqlx.DB, error) {
-	host := os.Getenv("POSTGRES_HOST")
-	if host == "" {
-		host = "localhost"
+// sslmode, которые понимает lib/pq
+var sslModes = []string{"disable", "require", "verify-ca", "verify-full"}
+
+// Config - параметры подключения к PostgreSQL и пула соединений
+type Config struct {
+	// URL - строка подключения целиком (postgres://... или key=value);
+	// если задана, Host, Port, User, Password и Name не используются
+	URL string
+
+	Host, Port, User, Password, Name string
+
+	SSLMode     string // disable, require, verify-ca, verify-full; "" - disable, а для URL - как в нём
+	SSLRootCert string // CA для verify-ca и verify-full
+	SSLCert     string // клиентский сертификат
+	SSLKey      string
+
+	ConnectTimeout   time.Duration // на установку одного соединения
+	StatementTimeout time.Duration // statement_timeout сессии; 0 - без ограничения
+
+	MaxOpenConns    int
+	MaxIdleConns    int
+	ConnMaxLifetime time.Duration
+	ConnMaxIdleTime time.Duration
+
+	Retry Backoff // ожидание базы при старте
+}
+
+// Backoff - экспоненциальная задержка между попытками подключения
+type Backoff struct {
+	Initial time.Duration // задержка после первой неудачи, дальше удваивается
+	Max     time.Duration // потолок задержки
+	Wait    time.Duration // сколько всего ждать базу; 0 - одна попытка
+}
+
+// ConfigFromEnv читает настройки подключения из окружения: DATABASE_URL или
+// POSTGRES_HOST/PORT/USER/PASSWORD/DB, TLS из POSTGRES_SSL*, пул и таймауты из DB_*
+func ConfigFromEnv() Config {
+	return Config{
+		URL:      config.String("DATABASE_URL", ""),
+		Host:     config.String("POSTGRES_HOST", "localhost"),
+		Port:     config.String("POSTGRES_PORT", "5432"),
+		User:     config.String("POSTGRES_USER", "master"),
+		Password: config.String("POSTGRES_PASSWORD", "master"),
+		Name:     config.String("POSTGRES_DB", "master"),
+
+		SSLMode:     config.String("POSTGRES_SSLMODE", ""),
+		SSLRootCert: config.String("POSTGRES_SSLROOTCERT", ""),
+		SSLCert:     config.String("POSTGRES_SSLCERT", ""),
+		SSLKey:      config.String("POSTGRES_SSLKEY", ""),
+
+		ConnectTimeout:   config.Duration("DB_CONNECT_TIMEOUT", 5*time.Second),
+		StatementTimeout: config.Duration("DB_STATEMENT_TIMEOUT", 0),
+
+		MaxOpenConns:    config.Int("DB_MAX_OPEN_CONNS", 10),
+		MaxIdleConns:    config.Int("DB_MAX_IDLE_CONNS", 5),
+		ConnMaxLifetime: config.Duration("DB_CONN_MAX_LIFETIME", 30*time.Minute),
+		ConnMaxIdleTime: config.Duration("DB_CONN_MAX_IDLE_TIME", 5*time.Minute),
+
+		Retry: Backoff{
+			Initial: config.Duration("DB_RETRY_INITIAL", 250*time.Millisecond),
+			Max:     config.Duration("DB_RETRY_MAX", 5*time.Second),
+			Wait:    config.Duration("DB_CONNECT_WAIT", time.Minute),
+		},
 	}
-	port := os.Getenv("POSTGRES_PORT")
-	if port == "" {
-		port = "5432"
+}
+
+// DSN собирает строку подключения. Параметры TLS и таймаутов добавляются к URL,
+// только если в нём их нет.
+func (c Config) DSN() (string, error) {
+	if c.SSLMode != "" && !slices.Contains(sslModes, c.SSLMode) {
+		return "", fmt.Errorf("unsupported sslmode %q: want one of %s", c.SSLMode, strings.Join(sslModes, ", "))
+	}
+
+	params := make([][2]string, 0, 6)
+	add := func(k, v string) {
+		if v != "" {
+			params = append(params, [2]string{k, v})
+		}
 	}
-	user := os.Getenv("POSTGRES_USER")
-	if user == "" {
-		user = "master"
+	add("sslmode", c.SSLMode)
+	add("sslrootcert", c.SSLRootCert)
+	add("sslcert", c.SSLCert)
+	add("sslkey", c.SSLKey)
+	if c.ConnectTimeout > 0 {
+		// pq принимает целые секунды; меньше секунды - округляем вверх
+		add("connect_timeout", strconv.Itoa(int((c.ConnectTimeout+time.Second-1)/time.Second)))
 	}
-	pass := os.Getenv("POSTGRES_PASSWORD")
-	if pass == "" {
-		pass = "master"
+	if c.StatementTimeout > 0 {
+		add("statement_timeout", strconv.FormatInt(c.StatementTimeout.Milliseconds(), 10))
 	}
-	dbname := os.Getenv("POSTGRES_DB")
-	if dbname == "" {
-		dbname = "master"
+
+	switch {
+	case c.URL == "":
+		if c.SSLMode == "" {
+			params = append([][2]string{{"sslmode", "disable"}}, params...)
+		}
+		u := &url.URL{
+			Scheme: "postgres",
+			User:   url.UserPassword(c.User, c.Password),
+			Host:   net.JoinHostPort(c.Host, c.Port),
+			Path:   "/" + c.Name,
+		}
+		q := url.Values{}
+		for _, p := range params {
+			q.Set(p[0], p[1])
+		}
+		u.RawQuery = q.Encode()
+		return u.String(), nil
+	case strings.HasPrefix(c.URL, "postgres://"), strings.HasPrefix(c.URL, "postgresql://"):
+		u, err := url.Parse(c.URL)
+		if err != nil {
+			return "", fmt.Errorf("parse DATABASE_URL: %w", err)
+		}
+		q := u.Query()
+		for _, p := range params {
+			if !q.Has(p[0]) {
+				q.Set(p[0], p[1])
+			}
+		}
+		u.RawQuery = q.Encode()
+		return u.String(), nil
+	default: // key=value
+		dsn := c.URL
+		for _, p := range params {
+			if !strings.Contains(dsn, p[0]+"=") {
+				dsn += " " + p[0] + "=" + p[1]
+			}
+		}
+		return dsn, nil
 	}
+}
 
-	dsn := fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s sslmode=disable",
-		host, port, user, pass, dbname)
-	db, err := sqlx.Connect("postgres", dsn)
+// Open создаёт пул по cfg, не подключаясь к базе
+func Open(cfg Config) (*sqlx.DB, error) {
+	dsn, err := cfg.DSN()
+	if err != nil {
+		return nil, err
+	}
+	db, err := sqlx.Open("postgres", dsn)
 	if err != nil {
 		return nil, err
 	}
 
-	db.SetMaxOpenConns(10)
-	db.SetMaxIdleConns(5)
-	db.SetConnMaxLifetime(30 * time.Minute)
+	db.SetMaxOpenConns(cfg.MaxOpenConns)
+	db.SetMaxIdleConns(cfg.MaxIdleConns)
+	db.SetConnMaxLifetime(cfg.ConnMaxLifetime)
+	db.SetConnMaxIdleTime(cfg.ConnMaxIdleTime)
 
 	return db, nil
 }
+
+// InitDB открывает пул основного сервера и ждёт, пока база станет доступна
+func InitDB(ctx context.Context, cfg Config) (*sqlx.DB, error) {
+	db, err := Open(cfg)
+	if err != nil {
+		return nil, err
+	}
+	if err := WaitForDB(ctx, db.DB, cfg.Retry); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return db, nil
+}
+
+// InitReplica открывает пул реплики с теми же настройками, что у основного
+// сервера. Соединение не проверяется: реплика может подняться позже основного
+// сервера, пока её нет, чтения идут на основной пул.
+func InitReplica(cfg Config, dsn string) (*sqlx.DB, error) {
+	cfg.URL = dsn
+	return Open(cfg)
+}
+
+// WaitForDB пингует базу, пока она не ответит: после каждой неудачи ждёт
+// b.Initial, 2*b.Initial, ... (не больше b.Max), всего не дольше b.Wait
+func WaitForDB(ctx context.Context, db *sql.DB, b Backoff) error {
+	deadline := time.Now().Add(b.Wait)
+	delay := b.Initial
+	for attempt := 1; ; attempt++ {
+		err := db.PingContext(ctx)
+		if err == nil {
+			return nil
+		}
+		if ctx.Err() != nil || !time.Now().Add(delay).Before(deadline) {
+			return fmt.Errorf("database is unavailable after %d attempts: %w", attempt, err)
+		}
+		logging.S().Warnw("database is not ready, retrying", "attempt", attempt, "in", delay, "err", err)
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(delay):
+		}
+		delay = min(delay*2, b.Max)
+	}
+}
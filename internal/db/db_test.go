@@ -0,0 +1,103 @@
+package db_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/51mans0n/avito-pvz-task/internal/db"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConfig_DSN(t *testing.T) {
+	base := db.Config{Host: "db", Port: "5432", User: "master", Password: "p@ss word", Name: "pvz", ConnectTimeout: 1500 * time.Millisecond}
+
+	dsn, err := base.DSN()
+	require.NoError(t, err)
+	require.Equal(t, "postgres://master:p%40ss%20word@db:5432/pvz?connect_timeout=2&sslmode=disable", dsn)
+
+	withTLS := base
+	withTLS.SSLMode, withTLS.SSLRootCert, withTLS.StatementTimeout = "verify-full", "/certs/ca.pem", 30*time.Second
+	dsn, err = withTLS.DSN()
+	require.NoError(t, err)
+	require.Contains(t, dsn, "sslmode=verify-full")
+	require.Contains(t, dsn, "sslrootcert=%2Fcerts%2Fca.pem")
+	require.Contains(t, dsn, "statement_timeout=30000")
+
+	// параметры из DATABASE_URL важнее настроек из окружения
+	fromURL := withTLS
+	fromURL.URL = "postgres://u:p@primary:6432/app?sslmode=require"
+	dsn, err = fromURL.DSN()
+	require.NoError(t, err)
+	require.Contains(t, dsn, "postgres://u:p@primary:6432/app?")
+	require.Contains(t, dsn, "sslmode=require")
+	require.NotContains(t, dsn, "verify-full")
+	require.Contains(t, dsn, "connect_timeout=2")
+
+	kv := base
+	kv.URL = "host=primary dbname=app connect_timeout=10"
+	dsn, err = kv.DSN()
+	require.NoError(t, err)
+	require.Equal(t, "host=primary dbname=app connect_timeout=10", dsn)
+
+	bad := base
+	bad.SSLMode = "prefer"
+	_, err = bad.DSN()
+	require.Error(t, err)
+}
+
+func TestWaitForDB(t *testing.T) {
+	sqlDB, mock, err := sqlmock.New(sqlmock.MonitorPingsOption(true))
+	require.NoError(t, err)
+	defer sqlDB.Close()
+
+	down := errors.New("connection refused")
+	mock.ExpectPing().WillReturnError(down)
+	mock.ExpectPing().WillReturnError(down)
+	mock.ExpectPing()
+
+	backoff := db.Backoff{Initial: time.Millisecond, Max: 2 * time.Millisecond, Wait: time.Second}
+	require.NoError(t, db.WaitForDB(context.Background(), sqlDB, backoff))
+	require.NoError(t, mock.ExpectationsWereMet())
+
+	// база так и не поднялась - ошибка после исчерпания Wait
+	for i := 0; i < 3; i++ {
+		mock.ExpectPing().WillReturnError(down)
+	}
+	backoff.Wait = 4 * time.Millisecond
+	err = db.WaitForDB(context.Background(), sqlDB, backoff)
+	require.ErrorIs(t, err, down)
+}
+
+func TestHealth(t *testing.T) {
+	sqlDB, mock, err := sqlmock.New(sqlmock.MonitorPingsOption(true))
+	require.NoError(t, err)
+	defer sqlDB.Close()
+
+	h := db.NewHealth(sqlDB, time.Second)
+	require.Error(t, h.Ready(), "not ready before the first check")
+
+	mock.ExpectPing()
+	require.NoError(t, h.Check(context.Background()))
+	require.NoError(t, h.Ready())
+
+	mock.ExpectPing().WillReturnError(errors.New("connection reset"))
+	require.Error(t, h.Check(context.Background()))
+	require.Error(t, h.Ready())
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestHealth_ZeroTimeout(t *testing.T) {
+	sqlDB, mock, err := sqlmock.New(sqlmock.MonitorPingsOption(true))
+	require.NoError(t, err)
+	defer sqlDB.Close()
+
+	// нулевой таймаут не должен превращаться в мгновенно истёкший контекст
+	h := db.NewHealth(sqlDB, 0)
+	mock.ExpectPing()
+	require.NoError(t, h.Check(context.Background()))
+	require.NoError(t, h.Ready())
+	require.NoError(t, mock.ExpectationsWereMet())
+}
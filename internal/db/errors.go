@@ -0,0 +1,152 @@
+package db
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/lib/pq"
+)
+
+// Категории ошибок. Каждая ошибка предметной области ниже относится к одной из
+// них, и по категории API выбирает HTTP-статус и gRPC-код. Всё, что не
+// относится ни к одной категории, - внутренняя ошибка, её текст клиенту не отдаётся.
+var (
+	ErrNotFound        = errors.New("not found")                 // 404 / NotFound
+	ErrConflict        = errors.New("conflict")                  // 409 / FailedPrecondition: мешает текущее состояние
+	ErrAlreadyExists   = newError(ErrConflict, "already exists") // 409 / AlreadyExists
+	ErrValidation      = errors.New("validation failed")         // 422 / InvalidArgument: нарушены правила предметной области
+	ErrInvalidArgument = errors.New("invalid argument")          // 400 / InvalidArgument: запрос не разобрать
+	ErrPrecondition    = errors.New("precondition failed")       // 412 / Aborted: объект изменился после чтения
+)
+
+// domainError - ошибка предметной области с категорией kind
+type domainError struct {
+	msg  string
+	kind error
+}
+
+func newError(kind error, msg string) error { return &domainError{msg: msg, kind: kind} }
+
+func (e *domainError) Error() string { return e.msg }
+func (e *domainError) Unwrap() error { return e.kind }
+
+// ErrReceptionAlreadyOpen - у ПВЗ уже есть приёмка в статусе in_progress
+var ErrReceptionAlreadyOpen = newError(ErrConflict, "there is already an open reception")
+
+// ErrVersionMismatch - версия объекта не совпала с If-Match: его уже изменил кто-то другой
+var ErrVersionMismatch = newError(ErrPrecondition, "resource has been modified")
+
+// ErrPVZNotFound - ПВЗ с таким id не существует
+var ErrPVZNotFound = newError(ErrNotFound, "pvz not found")
+
+// ErrProductNotFound - товара с таким id не существует
+var ErrProductNotFound = newError(ErrNotFound, "product not found")
+
+// ErrReceptionClosed - приёмка закрыта, а операция разрешена только для открытой
+var ErrReceptionClosed = newError(ErrConflict, "reception is closed")
+
+// ErrReceptionOpen - приёмка ещё открыта, а операция разрешена только для закрытой
+var ErrReceptionOpen = newError(ErrConflict, "reception is still open")
+
+// ErrInvalidTransition - из текущего статуса товара в запрошенный перейти нельзя
+var ErrInvalidTransition = newError(ErrConflict, "invalid product status transition")
+
+// ErrNothingToUndo - в открытой приёмке нет удалённых товаров
+var ErrNothingToUndo = newError(ErrConflict, "no deleted products to restore")
+
+// ErrUnknownProductType - типа нет в справочнике или он отключён
+var ErrUnknownProductType = newError(ErrValidation, "unknown product type")
+
+// ErrSerialRequired - для этого типа товара обязателен серийный номер
+var ErrSerialRequired = newError(ErrValidation, "serial number is required for this product type")
+
+// ErrSerialQuantity - серийный номер принадлежит одной единице, строка с ним не может иметь quantity > 1
+var ErrSerialQuantity = newError(ErrValidation, "product with serial number must have quantity 1")
+
+// ErrProductTypeExists - тип с таким названием уже есть в справочнике
+var ErrProductTypeExists = newError(ErrAlreadyExists, "product type already exists")
+
+// ErrProductTypeNotFound - типа с таким названием нет в справочнике
+var ErrProductTypeNotFound = newError(ErrNotFound, "product type not found")
+
+// ErrDuplicateBarcode - товар с таким штрихкодом уже есть в этой приёмке
+var ErrDuplicateBarcode = newError(ErrAlreadyExists, "product with this barcode is already in the reception")
+
+// ErrStorageCellExists - ячейка с таким адресом в ПВЗ уже есть
+var ErrStorageCellExists = newError(ErrAlreadyExists, "storage cell already exists")
+
+// ErrStorageCellNotFound - ячейки нет в ПВЗ товара
+var ErrStorageCellNotFound = newError(ErrNotFound, "storage cell not found")
+
+// ErrStorageCellFull - в ячейке не осталось места
+var ErrStorageCellFull = newError(ErrConflict, "storage cell is full")
+
+// ErrProductNotInStock - товар уже выдан или возвращён и в ячейке не лежит
+var ErrProductNotInStock = newError(ErrConflict, "product is no longer in stock")
+
+// ErrTransferNotFound - перемещения с таким id нет
+var ErrTransferNotFound = newError(ErrNotFound, "transfer not found")
+
+// ErrTransferState - операция не подходит к текущему статусу перемещения
+var ErrTransferState = newError(ErrConflict, "invalid transfer state")
+
+// ErrInvalidCursor - курсор пагинации повреждён или выдан не этим API
+var ErrInvalidCursor = newError(ErrInvalidArgument, "invalid cursor")
+
+// ErrAttachmentOwnerNotFound - приёмки или товара, к которому прикладывают файл, нет
+var ErrAttachmentOwnerNotFound = newError(ErrNotFound, "attachment owner not found")
+
+// ErrNoActiveReception - у ПВЗ нет открытой приёмки, а операция работает только с ней
+var ErrNoActiveReception = newError(ErrConflict, "no active reception found")
+
+// ErrNoProducts - в открытой приёмке не осталось товаров для удаления
+var ErrNoProducts = newError(ErrConflict, "no products to delete")
+
+// ErrEmptyBatch - в пачке на добавление нет ни одного товара
+var ErrEmptyBatch = newError(ErrValidation, "no products to create")
+
+// ErrReceptionNotFound - приёмки с таким id нет или у ПВЗ ещё не было ни одной приёмки
+var ErrReceptionNotFound = newError(ErrNotFound, "reception not found")
+
+// ErrReceptionNotClosed - переоткрыть можно только закрытую приёмку
+var ErrReceptionNotClosed = newError(ErrConflict, "last reception is not closed")
+
+// ErrReopenExpired - приёмка закрыта слишком давно, чтобы её переоткрыть
+var ErrReopenExpired = newError(ErrConflict, "reopen window has expired")
+
+// ErrApprovalUsed - разрешение модератора на переоткрытие уже использовано
+var ErrApprovalUsed = newError(ErrConflict, "reopen approval has already been used")
+
+// ProductError - ошибка конкретного товара в пачке (Index - его позиция в запросе)
+type ProductError struct {
+	Index int
+	Err   error
+}
+
+func (e *ProductError) Error() string { return fmt.Sprintf("products[%d]: %v", e.Index, e.Err) }
+func (e *ProductError) Unwrap() error { return e.Err }
+
+const (
+	pgUniqueViolation     = "23505"
+	pgForeignKeyViolation = "23503"
+
+	oneOpenReceptionIndex = "receptions_one_open_per_pvz"
+	receptionBarcodeIndex = "products_reception_barcode"
+	storageCellAddress    = "storage_cells_address"
+	reopenApprovalIndex   = "reception_reopens_approval_id"
+)
+
+// pgError достаёт *pq.Error с указанным кодом
+func pgError(err error, code string) (*pq.Error, bool) {
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && string(pqErr.Code) == code {
+		return pqErr, true
+	}
+	return nil, false
+}
+
+// isOpenReceptionConflict - сработал уникальный индекс "одна открытая приёмка на ПВЗ"
+func isOpenReceptionConflict(err error) bool {
+	pqErr, ok := pgError(err, pgUniqueViolation)
+	return ok && pqErr.Constraint == oneOpenReceptionIndex
+}
@@ -0,0 +1,39 @@
+package db_test
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/51mans0n/avito-pvz-task/internal/db"
+	"github.com/stretchr/testify/require"
+)
+
+func TestErrorCategories(t *testing.T) {
+	cases := []struct {
+		err  error
+		kind error
+	}{
+		{db.ErrPVZNotFound, db.ErrNotFound},
+		{db.ErrReceptionNotFound, db.ErrNotFound},
+		{db.ErrNoActiveReception, db.ErrConflict},
+		{db.ErrNoProducts, db.ErrConflict},
+		{db.ErrReopenExpired, db.ErrConflict},
+		{db.ErrDuplicateBarcode, db.ErrAlreadyExists},
+		{db.ErrDuplicateBarcode, db.ErrConflict},
+		{db.ErrUnknownProductType, db.ErrValidation},
+		{db.ErrEmptyBatch, db.ErrValidation},
+		{db.ErrInvalidCursor, db.ErrInvalidArgument},
+		{db.ErrVersionMismatch, db.ErrPrecondition},
+		// категория видна и сквозь обёртки
+		{fmt.Errorf("%w for pvz 1", db.ErrNoActiveReception), db.ErrConflict},
+		{&db.ProductError{Index: 2, Err: db.ErrSerialRequired}, db.ErrValidation},
+	}
+	for _, tc := range cases {
+		require.ErrorIs(t, tc.err, tc.kind, tc.err.Error())
+	}
+
+	require.NotErrorIs(t, db.ErrNoActiveReception, db.ErrAlreadyExists)
+	require.NotErrorIs(t, db.ErrPVZNotFound, db.ErrProductNotFound)
+	require.False(t, errors.Is(errors.New("pq: connection refused"), db.ErrConflict))
+}
@@ -0,0 +1,75 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/51mans0n/avito-pvz-task/internal/logging"
+)
+
+var errNotChecked = errors.New("database has not been checked yet")
+
+// defaultPingTimeout - таймаут пинга, если передан нулевой (DB_CONNECT_TIMEOUT=0
+// отключает только connect_timeout в DSN, а пинг без срока мог бы висеть вечно)
+const defaultPingTimeout = 5 * time.Second
+
+// Health периодически пингует основной сервер; результат последней проверки -
+// готовность сервиса принимать запросы
+type Health struct {
+	db      *sql.DB
+	timeout time.Duration // на один пинг
+
+	mu  sync.RWMutex
+	err error
+}
+
+func NewHealth(db *sql.DB, timeout time.Duration) *Health {
+	if timeout <= 0 {
+		timeout = defaultPingTimeout
+	}
+	return &Health{db: db, timeout: timeout, err: errNotChecked}
+}
+
+// Run проверяет базу сразу и затем каждые interval, пока не отменён ctx
+func (h *Health) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		h.Check(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Check - один пинг базы; его результат возвращает Ready
+func (h *Health) Check(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, h.timeout)
+	defer cancel()
+	err := h.db.PingContext(ctx)
+
+	h.mu.Lock()
+	prev := h.err
+	h.err = err
+	h.mu.Unlock()
+
+	switch {
+	case err != nil && prev == nil:
+		logging.S().Warnw("database became unavailable", "err", err)
+	case err == nil && prev != nil:
+		logging.S().Infow("database is available")
+	}
+	return err
+}
+
+// Ready - nil, если последняя проверка прошла
+func (h *Health) Ready() error {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return h.err
+}
@@ -0,0 +1,292 @@
+package db
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/lib/pq"
+
+	"github.com/51mans0n/avito-pvz-task/internal/model"
+)
+
+func (r *Repo) insertManifest(ctx context.Context, receptionID string, items []model.ManifestItem) error {
+	ins := sq.Insert("reception_manifest_items").
+		Columns("reception_id", "type", "expected_count", "barcodes").
+		PlaceholderFormat(sq.Dollar)
+	for _, it := range items {
+		ins = ins.Values(receptionID, it.Type, it.Count, pq.Array(nonNil(it.Barcodes)))
+	}
+
+	q, args, err := ins.ToSql()
+	if err != nil {
+		return err
+	}
+	_, err = r.db.ExecContext(ctx, q, args...)
+	return err
+}
+
+func (r *Repo) getManifest(ctx context.Context, receptionID string) ([]model.ManifestItem, error) {
+	q, args, err := sq.Select("type", "expected_count", "barcodes").
+		From("reception_manifest_items").
+		Where(sq.Eq{"reception_id": receptionID}).
+		OrderBy("type").
+		PlaceholderFormat(sq.Dollar).
+		ToSql()
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := r.db.QueryxContext(ctx, q, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var items []model.ManifestItem
+	for rows.Next() {
+		var it model.ManifestItem
+		if err := rows.Scan(&it.Type, &it.Count, pq.Array(&it.Barcodes)); err != nil {
+			return nil, err
+		}
+		items = append(items, it)
+	}
+	return items, rows.Err()
+}
+
+// countProductsByType - сколько единиц товара каждого типа фактически принято
+func (r *Repo) countProductsByType(ctx context.Context, receptionID string) (map[string]int, error) {
+	q, args, err := sq.Select("type", "sum(quantity) AS cnt").
+		From("products").
+		Where(sq.Eq{"reception_id": receptionID, "deleted_at": nil}).
+		GroupBy("type").
+		PlaceholderFormat(sq.Dollar).
+		ToSql()
+	if err != nil {
+		return nil, err
+	}
+
+	var rows []struct {
+		Type string `db:"type"`
+		Cnt  int    `db:"cnt"`
+	}
+	if err := r.db.SelectContext(ctx, &rows, q, args...); err != nil {
+		return nil, err
+	}
+	counts := make(map[string]int, len(rows))
+	for _, row := range rows {
+		counts[row.Type] = row.Cnt
+	}
+	return counts, nil
+}
+
+// scannedBarcodes - штрихкоды принятых товаров приёмки по типам
+func (r *Repo) scannedBarcodes(ctx context.Context, receptionID string) (map[string][]string, error) {
+	q, args, err := sq.Select("type", "barcode").
+		From("products").
+		Where(sq.Eq{"reception_id": receptionID, "deleted_at": nil}).
+		Where(sq.NotEq{"barcode": nil}).
+		OrderBy("barcode").
+		PlaceholderFormat(sq.Dollar).
+		ToSql()
+	if err != nil {
+		return nil, err
+	}
+
+	var rows []struct {
+		Type    string `db:"type"`
+		Barcode string `db:"barcode"`
+	}
+	if err := r.db.SelectContext(ctx, &rows, q, args...); err != nil {
+		return nil, err
+	}
+	scanned := make(map[string][]string)
+	for _, row := range rows {
+		scanned[row.Type] = append(scanned[row.Type], row.Barcode)
+	}
+	return scanned, nil
+}
+
+// saveDiscrepancyReport сравнивает принятое (received по типам) с манифестом и сохраняет расхождения.
+// Если манифеста у приёмки нет, отчёт не строится и возвращается nil.
+func (r *Repo) saveDiscrepancyReport(ctx context.Context, receptionID string, received map[string]int, at time.Time) (*model.DiscrepancyReport, error) {
+	manifest, err := r.getManifest(ctx, receptionID)
+	if err != nil || len(manifest) == 0 {
+		return nil, err
+	}
+	var scanned map[string][]string
+	if manifestHasBarcodes(manifest) {
+		if scanned, err = r.scannedBarcodes(ctx, receptionID); err != nil {
+			return nil, err
+		}
+	}
+	report := buildDiscrepancyReport(receptionID, manifest, received, scanned, at)
+
+	// приёмку могли переоткрыть и закрыть повторно - отчёт пересчитываем
+	qDel, argsDel, err := sq.Delete("reception_discrepancies").
+		Where(sq.Eq{"reception_id": receptionID}).
+		PlaceholderFormat(sq.Dollar).
+		ToSql()
+	if err != nil {
+		return nil, err
+	}
+	if _, err := r.db.ExecContext(ctx, qDel, argsDel...); err != nil {
+		return nil, err
+	}
+
+	ins := sq.Insert("reception_discrepancies").
+		Columns("reception_id", "type", "expected", "received", "missing", "surplus",
+			"missing_barcodes", "unexpected_barcodes", "created_at").
+		PlaceholderFormat(sq.Dollar)
+	for _, l := range report.Lines {
+		ins = ins.Values(receptionID, l.Type, l.Expected, l.Received, l.Missing, l.Surplus,
+			pq.StringArray(nonNil(l.MissingBarcodes)), pq.StringArray(nonNil(l.UnexpectedBarcodes)), at)
+	}
+	qIns, argsIns, err := ins.ToSql()
+	if err != nil {
+		return nil, err
+	}
+	if _, err := r.db.ExecContext(ctx, qIns, argsIns...); err != nil {
+		return nil, err
+	}
+	return report, nil
+}
+
+// GetDiscrepancyReport возвращает сохранённый отчёт о расхождениях (nil, если его нет)
+func (r *Repo) GetDiscrepancyReport(ctx context.Context, receptionID string) (*model.DiscrepancyReport, error) {
+	var (
+		report    *model.DiscrepancyReport
+		onReplica bool
+	)
+	err := r.onReader(ctx, func(rr *Repo) error {
+		var err error
+		onReplica = rr != r
+		report, err = rr.discrepancyReport(ctx, receptionID)
+		return err
+	})
+	if err == nil && report == nil && onReplica {
+		// приёмку могли закрыть только что, и отчёт ещё не доехал до реплики
+		return r.discrepancyReport(ctx, receptionID)
+	}
+	return report, err
+}
+
+func (r *Repo) discrepancyReport(ctx context.Context, receptionID string) (*model.DiscrepancyReport, error) {
+	q, args, err := sq.Select("type", "expected", "received", "missing", "surplus",
+		"missing_barcodes", "unexpected_barcodes", "created_at").
+		From("reception_discrepancies").
+		Where(sq.Eq{"reception_id": receptionID}).
+		OrderBy("type").
+		PlaceholderFormat(sq.Dollar).
+		ToSql()
+	if err != nil {
+		return nil, err
+	}
+
+	var rows []struct {
+		model.DiscrepancyLine
+		MissingBarcodes    pq.StringArray `db:"missing_barcodes"`
+		UnexpectedBarcodes pq.StringArray `db:"unexpected_barcodes"`
+		CreatedAt          time.Time      `db:"created_at"`
+	}
+	if err := r.db.SelectContext(ctx, &rows, q, args...); err != nil {
+		return nil, err
+	}
+	if len(rows) == 0 {
+		return nil, nil
+	}
+
+	report := &model.DiscrepancyReport{ReceptionID: receptionID, CreatedAt: rows[0].CreatedAt}
+	for _, row := range rows {
+		line := row.DiscrepancyLine
+		if len(row.MissingBarcodes) > 0 {
+			line.MissingBarcodes = row.MissingBarcodes
+		}
+		if len(row.UnexpectedBarcodes) > 0 {
+			line.UnexpectedBarcodes = row.UnexpectedBarcodes
+		}
+		report.Lines = append(report.Lines, line)
+		report.TotalMissing += row.Missing
+		report.TotalSurplus += row.Surplus
+	}
+	return report, nil
+}
+
+// buildDiscrepancyReport сравнивает количество по типам, а для типов со штрихкодами
+// в манифесте ещё и сами штрихкоды со scanned (отсканированные штрихкоды по типам)
+func buildDiscrepancyReport(receptionID string, manifest []model.ManifestItem, received map[string]int, scanned map[string][]string, at time.Time) *model.DiscrepancyReport {
+	expected := make(map[string]int, len(manifest))
+	expectedCodes := make(map[string][]string)
+	for _, it := range manifest {
+		expected[it.Type] += it.Count
+		expectedCodes[it.Type] = append(expectedCodes[it.Type], it.Barcodes...)
+	}
+
+	types := make([]string, 0, len(expected)+len(received))
+	for t := range expected {
+		types = append(types, t)
+	}
+	for t := range received {
+		if _, ok := expected[t]; !ok {
+			types = append(types, t)
+		}
+	}
+	sort.Strings(types)
+
+	report := &model.DiscrepancyReport{ReceptionID: receptionID, CreatedAt: at, Lines: make([]model.DiscrepancyLine, 0, len(types))}
+	for _, t := range types {
+		l := model.DiscrepancyLine{Type: t, Expected: expected[t], Received: received[t]}
+		if l.Expected > l.Received {
+			l.Missing = l.Expected - l.Received
+		} else {
+			l.Surplus = l.Received - l.Expected
+		}
+		if codes := expectedCodes[t]; len(codes) > 0 {
+			l.MissingBarcodes, l.UnexpectedBarcodes = diffBarcodes(codes, scanned[t])
+		}
+		report.Lines = append(report.Lines, l)
+		report.TotalMissing += l.Missing
+		report.TotalSurplus += l.Surplus
+	}
+	return report
+}
+
+func manifestHasBarcodes(manifest []model.ManifestItem) bool {
+	for _, it := range manifest {
+		if len(it.Barcodes) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// diffBarcodes - штрихкоды из expected, которых нет в scanned, и наоборот (отсортированы)
+func diffBarcodes(expected, scanned []string) (missing, unexpected []string) {
+	seen := make(map[string]bool, len(scanned))
+	for _, c := range scanned {
+		seen[c] = true
+	}
+	want := make(map[string]bool, len(expected))
+	for _, c := range expected {
+		want[c] = true
+		if !seen[c] {
+			missing = append(missing, c)
+		}
+	}
+	for _, c := range scanned {
+		if !want[c] {
+			unexpected = append(unexpected, c)
+		}
+	}
+	sort.Strings(missing)
+	sort.Strings(unexpected)
+	return missing, unexpected
+}
+
+func nonNil(list []string) []string {
+	if list == nil {
+		return []string{}
+	}
+	return list
+}
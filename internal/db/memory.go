@@ -0,0 +1,523 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/51mans0n/avito-pvz-task/internal/model"
+)
+
+// Убедимся, что *MemRepo реализует Repository:
+var _ Repository = (*MemRepo)(nil)
+
+// MemRepo - Repository в памяти процесса для локальных демо и тестов без PostgreSQL.
+// Держит те же инварианты, что и Repo: одна открытая приёмка на ПВЗ, LIFO-удаление,
+// фильтры по датам, пагинация. Каждая операция пишет в копию состояния под общим
+// мьютексом и публикует её только при успехе, поэтому ошибки ничего не меняют,
+// а WithTx атомарен.
+type MemRepo struct {
+	mu *sync.RWMutex
+	st *memState
+	tx bool // MemRepo создан внутри WithTx: мьютекс уже захвачен
+}
+
+func NewMemRepo() *MemRepo {
+	now := time.Now()
+	st := newMemState()
+	// тот же начальный справочник, что в миграции 011
+	for _, pt := range []model.ProductType{
+		{Name: "электроника", RequiresSerial: true, Active: true, CreatedAt: now},
+		{Name: "одежда", Active: true, CreatedAt: now},
+		{Name: "обувь", Active: true, CreatedAt: now},
+	} {
+		st.productTypes[pt.Name] = pt
+	}
+	return &MemRepo{mu: &sync.RWMutex{}, st: st}
+}
+
+// memState - все "таблицы". Значения в map не меняются на месте:
+// запись кладёт новую структуру, так что поверхностной копии хватает для отката.
+type memState struct {
+	seq int64 // порядок вставки: разрешает равные date_time и нумерует BIGSERIAL
+
+	pvz           map[string]model.PVZ
+	receptions    map[string]memReception
+	products      map[string]memProduct
+	manifests     map[string][]model.ManifestItem
+	summaries     map[string]model.ReceptionSummary
+	discrepancies map[string]model.DiscrepancyReport
+	reopens       []model.ReceptionReopen
+	deletions     []memDeletion
+	unitRemovals  []memUnitRemoval
+	productTypes  map[string]model.ProductType
+	cells         map[string]model.StorageCell
+	attachments   map[string]model.Attachment
+	transfers     map[string]model.Transfer
+	movements     []model.ProductMovement
+	users         map[string]model.User // по email
+}
+
+type memReception struct {
+	model.Reception
+	seq           int64
+	lifoDeletions int
+}
+
+type memProduct struct {
+	model.Product
+	seq int64
+}
+
+type memDeletion struct {
+	model.ProductDeletion
+	undoneAt *time.Time
+}
+
+type memUnitRemoval struct {
+	unitRemoval
+	receptionID string
+	removedBy   string
+}
+
+func newMemState() *memState {
+	return &memState{
+		pvz:           map[string]model.PVZ{},
+		receptions:    map[string]memReception{},
+		products:      map[string]memProduct{},
+		manifests:     map[string][]model.ManifestItem{},
+		summaries:     map[string]model.ReceptionSummary{},
+		discrepancies: map[string]model.DiscrepancyReport{},
+		productTypes:  map[string]model.ProductType{},
+		cells:         map[string]model.StorageCell{},
+		attachments:   map[string]model.Attachment{},
+		transfers:     map[string]model.Transfer{},
+		users:         map[string]model.User{},
+	}
+}
+
+func (s *memState) clone() *memState {
+	return &memState{
+		seq:           s.seq,
+		pvz:           maps.Clone(s.pvz),
+		receptions:    maps.Clone(s.receptions),
+		products:      maps.Clone(s.products),
+		manifests:     maps.Clone(s.manifests),
+		summaries:     maps.Clone(s.summaries),
+		discrepancies: maps.Clone(s.discrepancies),
+		reopens:       slices.Clone(s.reopens),
+		deletions:     slices.Clone(s.deletions),
+		unitRemovals:  slices.Clone(s.unitRemovals),
+		productTypes:  maps.Clone(s.productTypes),
+		cells:         maps.Clone(s.cells),
+		attachments:   maps.Clone(s.attachments),
+		transfers:     maps.Clone(s.transfers),
+		movements:     slices.Clone(s.movements),
+		users:         maps.Clone(s.users),
+	}
+}
+
+func (s *memState) nextSeq() int64 {
+	s.seq++
+	return s.seq
+}
+
+// WithTx выполняет fn атомарно: все операции fn видят одно состояние,
+// а при ошибке или панике оно отбрасывается целиком.
+func (m *MemRepo) WithTx(ctx context.Context, fn func(repo Repository) error) error {
+	if m.tx {
+		return fn(m)
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	tx := &MemRepo{mu: m.mu, st: m.st.clone(), tx: true}
+	if err := fn(tx); err != nil {
+		return err
+	}
+	m.st = tx.st
+	return nil
+}
+
+// write - то же, что WithTx, но отдаёт в fn состояние напрямую
+func (m *MemRepo) write(ctx context.Context, fn func(st *memState) error) error {
+	return m.WithTx(ctx, func(repo Repository) error {
+		return fn(repo.(*MemRepo).st)
+	})
+}
+
+// read выполняет fn над текущим состоянием без копирования
+func (m *MemRepo) read(ctx context.Context, fn func(st *memState) error) error {
+	if m.tx {
+		return fn(m.st)
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return fn(m.st)
+}
+
+func (m *MemRepo) CreatePVZ(ctx context.Context, pvz *model.PVZ) error {
+	return m.write(ctx, func(st *memState) error {
+		if _, ok := st.pvz[pvz.ID]; ok {
+			return fmt.Errorf("%w: pvz %s", ErrAlreadyExists, pvz.ID)
+		}
+		pvz.Version = 1
+		st.pvz[pvz.ID] = *pvz
+		return nil
+	})
+}
+
+func (m *MemRepo) GetPVZ(ctx context.Context, id string) (*model.PVZ, error) {
+	var out *model.PVZ
+	err := m.read(ctx, func(st *memState) error {
+		p, ok := st.pvz[id]
+		if !ok {
+			return ErrPVZNotFound
+		}
+		out = &p
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (m *MemRepo) GetPVZListWithFilter(ctx context.Context, f PVZListFilter) ([]model.PVZWithReceptions, error) {
+	var result []model.PVZWithReceptions
+	err := m.read(ctx, func(st *memState) error {
+		dateFilter := (f.StartDate != nil || f.EndDate != nil) && !f.IncludeEmpty
+		var page []model.PVZ
+		for _, p := range st.pvz {
+			if dateFilter && len(st.receptionsOf(p.ID, f.StartDate, f.EndDate)) == 0 {
+				continue
+			}
+			page = append(page, p)
+		}
+		sort.Slice(page, func(i, j int) bool {
+			if !page[i].RegistrationDate.Equal(page[j].RegistrationDate) {
+				return page[i].RegistrationDate.After(page[j].RegistrationDate)
+			}
+			return page[i].ID < page[j].ID
+		})
+		page = paginate(page, (f.Page-1)*f.Limit, f.Limit)
+
+		result = make([]model.PVZWithReceptions, 0, len(page))
+		for _, p := range page {
+			item := model.PVZWithReceptions{
+				PVZ:        &model.PVZResponse{ID: p.ID, City: p.City, RegistrationDate: p.RegistrationDate, Version: p.Version},
+				Receptions: []model.ReceptionWithProd{},
+			}
+			for _, rec := range st.receptionsOf(p.ID, f.StartDate, f.EndDate) {
+				var prods []*model.Product
+				for _, prod := range st.productsOf(rec.ID, f.IncludeDeleted) {
+					if f.ProductStatus == "" || prod.Status == f.ProductStatus {
+						prods = append(prods, &prod.Product)
+					}
+				}
+				resp := &model.ReceptionResponse{ID: rec.ID, PVZID: rec.PVZID, DateTime: rec.DateTime, Status: rec.Status, Version: rec.Version}
+				if sum, ok := st.summaries[rec.ID]; ok {
+					resp.Summary = &sum
+				}
+				item.Receptions = append(item.Receptions, model.ReceptionWithProd{Reception: resp, Products: convertProducts(prods)})
+			}
+			result = append(result, item)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// paginate - срез [offset, offset+limit) с поправкой на границы, как OFFSET/LIMIT
+func paginate[T any](list []T, offset, limit int) []T {
+	if offset < 0 {
+		offset = 0
+	}
+	if offset >= len(list) || limit <= 0 {
+		return nil
+	}
+	return list[offset:min(offset+limit, len(list))]
+}
+
+// receptionsOf - приёмки ПВЗ в диапазоне дат (границы включительно), новые первыми
+func (s *memState) receptionsOf(pvzID string, startDate, endDate *time.Time) []memReception {
+	var list []memReception
+	for _, rec := range s.receptions {
+		if rec.PVZID != pvzID ||
+			(startDate != nil && rec.DateTime.Before(*startDate)) ||
+			(endDate != nil && rec.DateTime.After(*endDate)) {
+			continue
+		}
+		list = append(list, rec)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if !list[i].DateTime.Equal(list[j].DateTime) {
+			return list[i].DateTime.After(list[j].DateTime)
+		}
+		return list[i].seq > list[j].seq
+	})
+	return list
+}
+
+// productsOf - товары приёмки, последние добавленные первыми
+func (s *memState) productsOf(receptionID string, includeDeleted bool) []memProduct {
+	var list []memProduct
+	for _, p := range s.products {
+		if p.ReceptionID == receptionID && (includeDeleted || p.DeletedAt == nil) {
+			list = append(list, p)
+		}
+	}
+	sortProductsDesc(list)
+	return list
+}
+
+func sortProductsDesc(list []memProduct) {
+	sort.Slice(list, func(i, j int) bool {
+		if !list[i].DateTime.Equal(list[j].DateTime) {
+			return list[i].DateTime.After(list[j].DateTime)
+		}
+		return list[i].seq > list[j].seq
+	})
+}
+
+// activeReception - открытая приёмка ПВЗ или nil
+func (s *memState) activeReception(pvzID string) *memReception {
+	for _, rec := range s.receptionsOf(pvzID, nil, nil) {
+		if rec.Status == "in_progress" {
+			return &rec
+		}
+	}
+	return nil
+}
+
+func (m *MemRepo) CreateReception(ctx context.Context, rec *model.Reception) error {
+	return m.write(ctx, func(st *memState) error {
+		return st.createReception(rec)
+	})
+}
+
+func (s *memState) createReception(rec *model.Reception) error {
+	if _, ok := s.pvz[rec.PVZID]; !ok {
+		return ErrPVZNotFound
+	}
+	if _, ok := s.receptions[rec.ID]; ok {
+		return fmt.Errorf("%w: reception %s", ErrAlreadyExists, rec.ID)
+	}
+	if rec.Status == "in_progress" && s.activeReception(rec.PVZID) != nil {
+		return ErrReceptionAlreadyOpen
+	}
+
+	rec.Version = 1
+	stored := *rec
+	stored.Manifest, stored.Summary, stored.Discrepancy = nil, nil, nil
+	s.receptions[rec.ID] = memReception{Reception: stored, seq: s.nextSeq()}
+	if len(rec.Manifest) > 0 {
+		s.manifests[rec.ID] = slices.Clone(rec.Manifest)
+	}
+	return nil
+}
+
+func (m *MemRepo) GetReception(ctx context.Context, id string) (*model.Reception, error) {
+	var out *model.Reception
+	err := m.read(ctx, func(st *memState) error {
+		rec, ok := st.receptions[id]
+		if !ok {
+			return ErrReceptionNotFound
+		}
+		out = &model.Reception{ID: rec.ID, PVZID: rec.PVZID, DateTime: rec.DateTime, Status: rec.Status,
+			ClosedAt: rec.ClosedAt, Version: rec.Version}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (m *MemRepo) CloseLastReception(ctx context.Context, pvzID string, ifVersion int) (*model.Reception, error) {
+	var rec *model.Reception
+	err := m.write(ctx, func(st *memState) error {
+		active := st.activeReception(pvzID)
+		if active == nil {
+			return ErrNoActiveReception
+		}
+		if err := checkVersion(active.Version, ifVersion); err != nil {
+			return err
+		}
+		rec = st.closeReception(active.ID, "", "")
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return rec, nil
+}
+
+func (m *MemRepo) CloseStaleReceptions(ctx context.Context, openedBefore time.Time, actor, reason string) ([]*model.Reception, error) {
+	var closed []*model.Reception
+	err := m.write(ctx, func(st *memState) error {
+		var stale []memReception
+		for _, rec := range st.receptions {
+			if rec.Status == "in_progress" && rec.DateTime.Before(openedBefore) {
+				stale = append(stale, rec)
+			}
+		}
+		sort.Slice(stale, func(i, j int) bool { return stale[i].DateTime.Before(stale[j].DateTime) })
+
+		closed = make([]*model.Reception, 0, len(stale))
+		for _, rec := range stale {
+			closed = append(closed, st.closeReception(rec.ID, actor, reason))
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return closed, nil
+}
+
+// closeReception закрывает приёмку и сохраняет итог и расхождения
+func (s *memState) closeReception(receptionID, actor, reason string) *model.Reception {
+	closedAt := time.Now()
+	rec := s.receptions[receptionID]
+	rec.Status = "close"
+	rec.ClosedAt = &closedAt
+	rec.ClosedBy = actor
+	rec.CloseReason = reason
+	rec.Version++
+	s.receptions[receptionID] = rec
+
+	out := model.Reception{ID: rec.ID, PVZID: rec.PVZID, DateTime: rec.DateTime, Status: rec.Status,
+		ClosedAt: rec.ClosedAt, Version: rec.Version, ClosedBy: actor, CloseReason: reason}
+	out.Summary, out.Discrepancy = s.refreshClosedReception(rec, closedAt)
+	return &out
+}
+
+// refreshClosedReception пересчитывает итог и расхождения закрытой приёмки
+func (s *memState) refreshClosedReception(rec memReception, closedAt time.Time) (*model.ReceptionSummary, *model.DiscrepancyReport) {
+	counts := map[string]int{}
+	sum := model.ReceptionSummary{
+		CountsByType:    counts,
+		DurationSeconds: int64(closedAt.Sub(rec.DateTime).Seconds()),
+		LIFODeletions:   rec.lifoDeletions,
+	}
+	for _, p := range s.productsOf(rec.ID, false) {
+		counts[p.Type] += p.Quantity
+		sum.TotalProducts += p.Quantity
+		at := p.DateTime
+		if sum.FirstScanAt == nil || at.Before(*sum.FirstScanAt) {
+			sum.FirstScanAt = &at
+		}
+		if sum.LastScanAt == nil || at.After(*sum.LastScanAt) {
+			sum.LastScanAt = &at
+		}
+	}
+	s.summaries[rec.ID] = sum
+
+	manifest := s.manifests[rec.ID]
+	if len(manifest) == 0 {
+		return &sum, nil
+	}
+	scanned := map[string][]string{}
+	for _, p := range s.productsOf(rec.ID, false) {
+		if p.Barcode != "" {
+			scanned[p.Type] = append(scanned[p.Type], p.Barcode)
+		}
+	}
+	report := buildDiscrepancyReport(rec.ID, manifest, counts, scanned, closedAt)
+	s.discrepancies[rec.ID] = *report
+	return &sum, report
+}
+
+func (m *MemRepo) ReopenLastReception(ctx context.Context, pvzID string, ifVersion int, closedAfter time.Time, reopen *model.ReceptionReopen) (*model.Reception, error) {
+	var out *model.Reception
+	err := m.write(ctx, func(st *memState) error {
+		list := st.receptionsOf(pvzID, nil, nil)
+		if len(list) == 0 {
+			return fmt.Errorf("%w for pvz %s", ErrReceptionNotFound, pvzID)
+		}
+		rec := list[0]
+		if err := checkVersion(rec.Version, ifVersion); err != nil {
+			return err
+		}
+		if rec.Status != "close" {
+			return ErrReceptionNotClosed
+		}
+		if rec.ClosedAt == nil || rec.ClosedAt.Before(closedAfter) {
+			return ErrReopenExpired
+		}
+		if st.activeReception(pvzID) != nil {
+			return ErrReceptionAlreadyOpen
+		}
+		if reopen.ApprovalID != "" {
+			for _, ro := range st.reopens {
+				if ro.ApprovalID == reopen.ApprovalID {
+					return ErrApprovalUsed
+				}
+			}
+		}
+
+		rec.Status = "in_progress"
+		rec.ClosedAt = nil
+		rec.Version++
+		st.receptions[rec.ID] = rec
+		delete(st.summaries, rec.ID)
+		delete(st.discrepancies, rec.ID)
+
+		reopen.ReceptionID = rec.ID
+		st.reopens = append(st.reopens, *reopen)
+
+		out = &model.Reception{ID: rec.ID, PVZID: rec.PVZID, DateTime: rec.DateTime, Status: rec.Status, Version: rec.Version}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (m *MemRepo) GetDiscrepancyReport(ctx context.Context, receptionID string) (*model.DiscrepancyReport, error) {
+	var report *model.DiscrepancyReport
+	err := m.read(ctx, func(st *memState) error {
+		if r, ok := st.discrepancies[receptionID]; ok {
+			report = &r
+		}
+		return nil
+	})
+	return report, err
+}
+
+func (m *MemRepo) CreateUser(ctx context.Context, u *model.User) error {
+	return m.write(ctx, func(st *memState) error {
+		if _, ok := st.users[u.Email]; ok {
+			return fmt.Errorf("%w: user %s", ErrAlreadyExists, u.Email)
+		}
+		st.users[u.Email] = *u
+		return nil
+	})
+}
+
+func (m *MemRepo) GetUserByEmail(ctx context.Context, email string) (*model.User, error) {
+	var u *model.User
+	err := m.read(ctx, func(st *memState) error {
+		if found, ok := st.users[email]; ok {
+			u = &found
+		}
+		return nil
+	})
+	return u, err
+}
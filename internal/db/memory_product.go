@@ -0,0 +1,527 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/51mans0n/avito-pvz-task/internal/model"
+)
+
+func (m *MemRepo) CreateProduct(ctx context.Context, pvzID string, prod *model.Product) error {
+	err := m.CreateProducts(ctx, pvzID, []*model.Product{prod})
+	var pe *ProductError
+	if errors.As(err, &pe) {
+		return pe.Err
+	}
+	return err
+}
+
+func (m *MemRepo) CreateProducts(ctx context.Context, pvzID string, prods []*model.Product) error {
+	if len(prods) == 0 {
+		return ErrEmptyBatch
+	}
+	for _, prod := range prods {
+		if prod.Quantity == 0 {
+			prod.Quantity = 1
+		}
+	}
+	return m.write(ctx, func(st *memState) error {
+		rec := st.activeReception(pvzID)
+		if rec == nil {
+			return fmt.Errorf("%w for pvz %s", ErrNoActiveReception, pvzID)
+		}
+		if err := validateProductTypes(prods, st.productTypes); err != nil {
+			return err
+		}
+		if err := st.checkBarcodes(rec.ID, prods); err != nil {
+			return err
+		}
+		placeInCells(st.storageCells(pvzID), prods)
+
+		for _, prod := range prods {
+			if _, ok := st.products[prod.ID]; ok {
+				return fmt.Errorf("%w: product %s", ErrAlreadyExists, prod.ID)
+			}
+			prod.ReceptionID = rec.ID
+			prod.Status = model.ProductStatusReceived
+
+			stored := *prod
+			stored.Cell, stored.Warnings = nil, nil
+			st.products[prod.ID] = memProduct{Product: stored, seq: st.nextSeq()}
+			st.addMovement(model.ProductMovement{
+				ProductID: prod.ID, Event: model.MovementReceived, ToPVZID: pvzID,
+				ReceptionID: rec.ID, CellID: prod.CellID, CreatedAt: prod.DateTime,
+			})
+		}
+		st.touchReception(rec.ID)
+		return nil
+	})
+}
+
+// checkBarcodes - как Repo.checkBarcodes: повтор в той же приёмке - ошибка,
+// в чужой открытой приёмке - предупреждение
+func (s *memState) checkBarcodes(receptionID string, prods []*model.Product) error {
+	seen := make(map[string]bool, len(prods))
+	for i, p := range prods {
+		if p.Barcode == "" {
+			continue
+		}
+		if seen[p.Barcode] {
+			return &ProductError{Index: i, Err: ErrDuplicateBarcode}
+		}
+		seen[p.Barcode] = true
+	}
+
+	for i, p := range prods {
+		if p.Barcode == "" {
+			continue
+		}
+		for _, other := range s.products {
+			if other.Barcode != p.Barcode || other.DeletedAt != nil {
+				continue
+			}
+			rec := s.receptions[other.ReceptionID]
+			if rec.Status != "in_progress" {
+				continue
+			}
+			if rec.ID == receptionID {
+				return &ProductError{Index: i, Err: ErrDuplicateBarcode}
+			}
+			p.Warnings = append(p.Warnings, fmt.Sprintf("barcode %s is already scanned in open reception %s of pvz %s",
+				p.Barcode, rec.ID, rec.PVZID))
+		}
+	}
+	return nil
+}
+
+func (m *MemRepo) DeleteLastProduct(ctx context.Context, pvzID, actor string) error {
+	return m.write(ctx, func(st *memState) error {
+		rec := st.activeReception(pvzID)
+		if rec == nil {
+			return fmt.Errorf("%w for pvz %s", ErrNoActiveReception, pvzID)
+		}
+		alive := st.productsOf(rec.ID, false)
+		if len(alive) == 0 {
+			return ErrNoProducts
+		}
+
+		last, now := alive[0], time.Now()
+		if last.Quantity > 1 {
+			last.Quantity--
+			st.unitRemovals = append(st.unitRemovals, memUnitRemoval{
+				unitRemoval: unitRemoval{ID: st.nextSeq(), ProductID: last.ID, RemovedAt: now},
+				receptionID: rec.ID,
+				removedBy:   actor,
+			})
+		} else {
+			last.DeletedAt, last.DeletedBy = &now, actor
+		}
+		st.products[last.ID] = last
+
+		rec.lifoDeletions++
+		rec.Version++
+		st.receptions[rec.ID] = *rec
+		return nil
+	})
+}
+
+func (m *MemRepo) UndoLastDelete(ctx context.Context, pvzID string) (*model.Product, error) {
+	var out *model.Product
+	err := m.write(ctx, func(st *memState) error {
+		rec := st.activeReception(pvzID)
+		if rec == nil {
+			return fmt.Errorf("%w for pvz %s", ErrNoActiveReception, pvzID)
+		}
+
+		var deleted *memProduct
+		for _, p := range st.productsOf(rec.ID, true) {
+			if p.DeletedAt != nil && (deleted == nil || p.DeletedAt.After(*deleted.DeletedAt) ||
+				p.DeletedAt.Equal(*deleted.DeletedAt) && p.seq > deleted.seq) {
+				deleted = &p
+			}
+		}
+		removal := -1
+		for i, rm := range st.unitRemovals {
+			if rm.receptionID == rec.ID && (removal < 0 || !rm.RemovedAt.Before(st.unitRemovals[removal].RemovedAt)) {
+				removal = i
+			}
+		}
+
+		switch {
+		case removal >= 0 && (deleted == nil || st.unitRemovals[removal].RemovedAt.After(*deleted.DeletedAt)):
+			p := st.products[st.unitRemovals[removal].ProductID]
+			p.Quantity++
+			st.products[p.ID] = p
+			st.unitRemovals = append(st.unitRemovals[:removal], st.unitRemovals[removal+1:]...)
+			st.decrementLIFODeletions(rec.ID)
+			out = &p.Product
+			return nil
+		case deleted == nil:
+			return ErrNothingToUndo
+		}
+
+		p := *deleted
+		if p.Barcode != "" {
+			// после удаления тот же штрихкод могли отсканировать заново
+			for _, other := range st.productsOf(rec.ID, false) {
+				if other.Barcode == p.Barcode {
+					return ErrDuplicateBarcode
+				}
+			}
+		}
+		p.DeletedAt, p.DeletedBy = nil, ""
+		st.products[p.ID] = p
+		out = &p.Product
+
+		now, journaled := time.Now(), false
+		for i := range st.deletions {
+			if st.deletions[i].ProductID == p.ID && st.deletions[i].undoneAt == nil {
+				st.deletions[i].undoneAt = &now
+				journaled = true
+			}
+		}
+		if !journaled {
+			// в журнале записи нет - товар удаляли через LIFO
+			st.decrementLIFODeletions(rec.ID)
+			return nil
+		}
+		st.touchReception(rec.ID)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// touchReception увеличивает версию приёмки, у которой изменился состав товаров
+func (s *memState) touchReception(receptionID string) {
+	rec := s.receptions[receptionID]
+	rec.Version++
+	s.receptions[receptionID] = rec
+}
+
+func (s *memState) decrementLIFODeletions(receptionID string) {
+	rec := s.receptions[receptionID]
+	rec.lifoDeletions = max(rec.lifoDeletions-1, 0)
+	rec.Version++
+	s.receptions[receptionID] = rec
+}
+
+func (m *MemRepo) DeleteProduct(ctx context.Context, del *model.ProductDeletion, allowClosed bool) error {
+	return m.write(ctx, func(st *memState) error {
+		prod, ok := st.products[del.ProductID]
+		if !ok || prod.DeletedAt != nil {
+			return ErrProductNotFound
+		}
+		rec := st.receptions[prod.ReceptionID]
+		if rec.Status != "in_progress" && !allowClosed {
+			return ErrReceptionClosed
+		}
+
+		at := del.DeletedAt
+		prod.DeletedAt, prod.DeletedBy = &at, del.DeletedBy
+		st.products[prod.ID] = prod
+
+		del.ReceptionID = prod.ReceptionID
+		del.ProductType = prod.Type
+		del.Quantity = prod.Quantity
+		st.deletions = append(st.deletions, memDeletion{ProductDeletion: *del})
+		st.touchReception(rec.ID)
+
+		if rec.Status == "in_progress" {
+			return nil
+		}
+		closedAt := time.Now()
+		if rec.ClosedAt != nil {
+			closedAt = *rec.ClosedAt
+		}
+		st.refreshClosedReception(rec, closedAt)
+		return nil
+	})
+}
+
+func (m *MemRepo) TransitionProduct(ctx context.Context, productID, status string, at time.Time) (*model.Product, error) {
+	if _, ok := statusTimestamps[status]; !ok {
+		return nil, fmt.Errorf("%w: to %s", ErrInvalidTransition, status)
+	}
+
+	var out *model.Product
+	err := m.write(ctx, func(st *memState) error {
+		prod, ok := st.products[productID]
+		if !ok || prod.DeletedAt != nil {
+			return ErrProductNotFound
+		}
+		if st.receptions[prod.ReceptionID].Status == "in_progress" {
+			return ErrReceptionOpen
+		}
+		if !model.CanTransition(prod.Status, status) {
+			return fmt.Errorf("%w: %s -> %s", ErrInvalidTransition, prod.Status, status)
+		}
+
+		prod.Status = status
+		switch status {
+		case model.ProductStatusStored:
+			prod.StoredAt = &at
+		case model.ProductStatusIssued:
+			prod.IssuedAt = &at
+		case model.ProductStatusReturned:
+			prod.ReturnedAt = &at
+		}
+		if status != model.ProductStatusStored {
+			prod.CellID = ""
+		}
+		st.products[productID] = prod
+		out = &prod.Product
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (m *MemRepo) ListProducts(ctx context.Context, f ProductListFilter) ([]*model.Product, string, error) {
+	var (
+		curAt time.Time
+		curID string
+	)
+	if f.Cursor != "" {
+		var err error
+		if curAt, curID, err = decodeProductCursor(f); err != nil {
+			return nil, "", err
+		}
+	}
+	// (a.date_time, a.id) < (b.date_time, b.id)
+	less := func(aAt time.Time, aID string, bAt time.Time, bID string) bool {
+		if !aAt.Equal(bAt) {
+			return aAt.Before(bAt)
+		}
+		return aID < bID
+	}
+
+	var prods []*model.Product
+	err := m.read(ctx, func(st *memState) error {
+		for _, p := range st.products {
+			rec := st.receptions[p.ReceptionID]
+			switch {
+			case p.DeletedAt != nil,
+				f.City != "" && st.pvz[rec.PVZID].City != f.City,
+				f.PVZID != "" && rec.PVZID != f.PVZID,
+				f.ReceptionID != "" && p.ReceptionID != f.ReceptionID,
+				f.Type != "" && p.Type != f.Type,
+				f.Status != "" && p.Status != f.Status,
+				f.Barcode != "" && p.Barcode != f.Barcode,
+				f.From != nil && p.DateTime.Before(*f.From),
+				f.To != nil && p.DateTime.After(*f.To),
+				f.Cursor != "" && f.Asc && !less(curAt, curID, p.DateTime, p.ID),
+				f.Cursor != "" && !f.Asc && !less(p.DateTime, p.ID, curAt, curID):
+				continue
+			}
+			prod := p.Product
+			prod.PVZID = rec.PVZID
+			prod.DeletedAt, prod.DeletedBy = nil, ""
+			prods = append(prods, &prod)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, "", err
+	}
+
+	sort.Slice(prods, func(i, j int) bool {
+		if f.Asc {
+			return less(prods[i].DateTime, prods[i].ID, prods[j].DateTime, prods[j].ID)
+		}
+		return less(prods[j].DateTime, prods[j].ID, prods[i].DateTime, prods[i].ID)
+	})
+	if len(prods) <= f.Limit {
+		return prods, "", nil
+	}
+	prods = prods[:f.Limit]
+	last := prods[len(prods)-1]
+	return prods, encodeProductCursor(f, last.DateTime, last.ID), nil
+}
+
+func (m *MemRepo) GetProductByBarcode(ctx context.Context, barcode string) (*model.ProductLookup, error) {
+	return m.lookupProduct(ctx, func(p memProduct) bool { return p.Barcode == barcode })
+}
+
+func (m *MemRepo) GetProduct(ctx context.Context, productID string) (*model.ProductLookup, error) {
+	return m.lookupProduct(ctx, func(p memProduct) bool { return p.ID == productID })
+}
+
+// lookupProduct - самый свежий живой товар, подходящий под match
+func (m *MemRepo) lookupProduct(ctx context.Context, match func(p memProduct) bool) (*model.ProductLookup, error) {
+	var found *model.ProductLookup
+	err := m.read(ctx, func(st *memState) error {
+		var list []memProduct
+		for _, p := range st.products {
+			if p.DeletedAt == nil && match(p) {
+				list = append(list, p)
+			}
+		}
+		if len(list) == 0 {
+			return nil
+		}
+		sortProductsDesc(list)
+
+		p := list[0].Product
+		rec := st.receptions[p.ReceptionID]
+		pvz := st.pvz[rec.PVZID]
+		found = &model.ProductLookup{
+			Product:   convertProducts([]*model.Product{&p})[0],
+			Reception: model.ReceptionResponse{ID: rec.ID, PVZID: rec.PVZID, DateTime: rec.DateTime, Status: rec.Status},
+			PVZ:       model.PVZResponse{ID: pvz.ID, City: pvz.City, RegistrationDate: pvz.RegistrationDate},
+		}
+		if c, ok := st.cells[p.CellID]; ok {
+			found.Cell = &model.StorageCell{ID: c.ID, PVZID: rec.PVZID, Zone: c.Zone, Rack: c.Rack, Shelf: c.Shelf, Capacity: c.Capacity}
+		}
+		return nil
+	})
+	return found, err
+}
+
+func (m *MemRepo) MoveProduct(ctx context.Context, productID, cellID, actor string) (*model.Product, error) {
+	var out *model.Product
+	err := m.write(ctx, func(st *memState) error {
+		prod, ok := st.products[productID]
+		if !ok || prod.DeletedAt != nil {
+			return ErrProductNotFound
+		}
+		if prod.Status == model.ProductStatusIssued || prod.Status == model.ProductStatusReturned || prod.Status == model.ProductStatusInTransit {
+			return ErrProductNotInStock
+		}
+
+		pvzID := st.receptions[prod.ReceptionID].PVZID
+		var cell *model.StorageCell
+		for _, c := range st.storageCells(pvzID) {
+			if c.ID == cellID {
+				cell = c
+			}
+		}
+		if cell == nil {
+			return ErrStorageCellNotFound
+		}
+		if prod.CellID != cellID && !cell.Fits(prod.Quantity) {
+			return fmt.Errorf("%w: %s", ErrStorageCellFull, cell.Address())
+		}
+
+		moved := prod.CellID != cellID
+		prod.CellID = cellID
+		st.products[productID] = prod
+		out = &prod.Product
+		out.Cell = cell
+		if !moved {
+			return nil
+		}
+		cell.Occupied += prod.Quantity
+		st.addMovement(model.ProductMovement{
+			ProductID: productID, Event: model.MovementCellChanged, ToPVZID: pvzID,
+			ReceptionID: prod.ReceptionID, CellID: cellID, Actor: actor, CreatedAt: time.Now(),
+		})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (m *MemRepo) ListProductTypes(ctx context.Context, includeInactive bool) ([]*model.ProductType, error) {
+	var list []*model.ProductType
+	err := m.read(ctx, func(st *memState) error {
+		for _, pt := range st.productTypes {
+			if includeInactive || pt.Active {
+				list = append(list, &pt)
+			}
+		}
+		return nil
+	})
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	return list, err
+}
+
+func (m *MemRepo) CreateProductType(ctx context.Context, pt *model.ProductType) error {
+	return m.write(ctx, func(st *memState) error {
+		if _, ok := st.productTypes[pt.Name]; ok {
+			return ErrProductTypeExists
+		}
+		st.productTypes[pt.Name] = *pt
+		return nil
+	})
+}
+
+func (m *MemRepo) UpdateProductType(ctx context.Context, pt *model.ProductType) error {
+	return m.write(ctx, func(st *memState) error {
+		cur, ok := st.productTypes[pt.Name]
+		if !ok {
+			return ErrProductTypeNotFound
+		}
+		cur.RequiresSerial, cur.Active = pt.RequiresSerial, pt.Active
+		st.productTypes[pt.Name] = cur
+		pt.CreatedAt = cur.CreatedAt
+		return nil
+	})
+}
+
+func (m *MemRepo) CreateStorageCell(ctx context.Context, c *model.StorageCell) error {
+	return m.write(ctx, func(st *memState) error {
+		if _, ok := st.pvz[c.PVZID]; !ok {
+			return ErrPVZNotFound
+		}
+		if _, ok := st.cells[c.ID]; ok {
+			return fmt.Errorf("%w: storage cell %s", ErrAlreadyExists, c.ID)
+		}
+		for _, other := range st.cells {
+			if other.PVZID == c.PVZID && other.Zone == c.Zone && other.Rack == c.Rack && other.Shelf == c.Shelf {
+				return ErrStorageCellExists
+			}
+		}
+		stored := *c
+		stored.Occupied = 0
+		st.cells[c.ID] = stored
+		return nil
+	})
+}
+
+func (m *MemRepo) ListStorageCells(ctx context.Context, pvzID string) ([]*model.StorageCell, error) {
+	var cells []*model.StorageCell
+	err := m.read(ctx, func(st *memState) error {
+		cells = st.storageCells(pvzID)
+		return nil
+	})
+	return cells, err
+}
+
+// storageCells - ячейки ПВЗ по адресу с текущей заполненностью
+func (s *memState) storageCells(pvzID string) []*model.StorageCell {
+	occupied := map[string]int{}
+	for _, p := range s.products {
+		if p.CellID != "" && p.DeletedAt == nil {
+			occupied[p.CellID] += p.Quantity
+		}
+	}
+
+	var cells []*model.StorageCell
+	for _, c := range s.cells {
+		if c.PVZID == pvzID {
+			c.Occupied = occupied[c.ID]
+			cells = append(cells, &c)
+		}
+	}
+	sort.Slice(cells, func(i, j int) bool {
+		a, b := cells[i], cells[j]
+		if a.Zone != b.Zone {
+			return a.Zone < b.Zone
+		}
+		if a.Rack != b.Rack {
+			return a.Rack < b.Rack
+		}
+		return a.Shelf < b.Shelf
+	})
+	return cells
+}
@@ -0,0 +1,244 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/51mans0n/avito-pvz-task/internal/metrics"
+	"github.com/51mans0n/avito-pvz-task/internal/model"
+)
+
+func (m *MemRepo) CreateTransfer(ctx context.Context, t *model.Transfer) error {
+	return m.write(ctx, func(st *memState) error {
+		if _, err := st.checkTransferProducts(t.FromPVZID, t.ProductIDs, model.ProductStatusReceived, model.ProductStatusStored); err != nil {
+			return err
+		}
+		if _, ok := st.pvz[t.FromPVZID]; !ok {
+			return ErrPVZNotFound
+		}
+		if _, ok := st.pvz[t.ToPVZID]; !ok {
+			return ErrPVZNotFound
+		}
+		if _, ok := st.transfers[t.ID]; ok {
+			return fmt.Errorf("%w: transfer %s", ErrAlreadyExists, t.ID)
+		}
+
+		stored := *t
+		stored.ProductIDs = slices.Sorted(slices.Values(t.ProductIDs))
+		st.transfers[t.ID] = stored
+		return nil
+	})
+}
+
+func (m *MemRepo) GetTransfer(ctx context.Context, id string) (*model.Transfer, error) {
+	var t *model.Transfer
+	err := m.read(ctx, func(st *memState) error {
+		if found, ok := st.transfers[id]; ok {
+			found.ProductIDs = slices.Clone(found.ProductIDs)
+			t = &found
+		}
+		return nil
+	})
+	return t, err
+}
+
+func (m *MemRepo) DispatchTransfer(ctx context.Context, id, actor string, at time.Time) (*model.Transfer, error) {
+	var out *model.Transfer
+	err := m.write(ctx, func(st *memState) error {
+		t, ok := st.transfers[id]
+		if !ok {
+			return ErrTransferNotFound
+		}
+		if t.Status != model.TransferStatusDraft {
+			return fmt.Errorf("%w: transfer is %s", ErrTransferState, t.Status)
+		}
+
+		prods, err := st.checkTransferProducts(t.FromPVZID, t.ProductIDs, model.ProductStatusReceived, model.ProductStatusStored)
+		if err != nil {
+			return err
+		}
+		for _, tp := range prods {
+			p := st.products[tp.ID]
+			p.Status, p.CellID = model.ProductStatusInTransit, ""
+			st.products[p.ID] = p
+			st.addMovement(model.ProductMovement{
+				ProductID: p.ID, Event: model.MovementDispatched, FromPVZID: t.FromPVZID, ToPVZID: t.ToPVZID,
+				ReceptionID: tp.ReceptionID, TransferID: t.ID, Actor: actor, CreatedAt: at,
+			})
+		}
+
+		t.Status, t.DispatchedAt = model.TransferStatusDispatched, &at
+		st.transfers[id] = t
+		out = &t
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (m *MemRepo) ReceiveTransfer(ctx context.Context, id, actor string, at time.Time) (*model.Transfer, error) {
+	var (
+		out     *model.Transfer
+		created bool
+	)
+	err := m.write(ctx, func(st *memState) error {
+		t, ok := st.transfers[id]
+		if !ok {
+			return ErrTransferNotFound
+		}
+		if t.Status != model.TransferStatusDispatched {
+			return fmt.Errorf("%w: transfer is %s", ErrTransferState, t.Status)
+		}
+
+		recID := ""
+		if rec := st.activeReception(t.ToPVZID); rec != nil {
+			recID = rec.ID
+		} else {
+			rec := &model.Reception{ID: uuid.New().String(), PVZID: t.ToPVZID, DateTime: at, Status: "in_progress"}
+			if err := st.createReception(rec); err != nil {
+				return err
+			}
+			recID, created = rec.ID, true
+		}
+
+		prods, err := st.checkTransferProducts(t.FromPVZID, t.ProductIDs, model.ProductStatusInTransit)
+		if err != nil {
+			return err
+		}
+		placed := make([]*model.Product, 0, len(prods))
+		for _, tp := range prods {
+			placed = append(placed, &model.Product{ID: tp.ID, Quantity: tp.Quantity})
+		}
+		placeInCells(st.storageCells(t.ToPVZID), placed)
+
+		// состав меняется и у приёмки назначения, и у приёмок, откуда товары уехали
+		touched := map[string]bool{recID: true}
+		for i, pl := range placed {
+			p := st.products[pl.ID]
+			if p.Barcode != "" {
+				for _, other := range st.productsOf(recID, false) {
+					if other.Barcode == p.Barcode {
+						return &ProductError{Index: i, Err: ErrDuplicateBarcode}
+					}
+				}
+			}
+			touched[p.ReceptionID] = true
+			p.ReceptionID, p.DateTime, p.Status, p.CellID = recID, at, model.ProductStatusReceived, pl.CellID
+			st.products[p.ID] = p
+			st.addMovement(model.ProductMovement{
+				ProductID: p.ID, Event: model.MovementArrived, FromPVZID: t.FromPVZID, ToPVZID: t.ToPVZID,
+				ReceptionID: recID, TransferID: t.ID, CellID: pl.CellID, Actor: actor, CreatedAt: at,
+			})
+		}
+
+		for recID := range touched {
+			st.touchReception(recID)
+		}
+
+		t.Status, t.ReceivedAt, t.ReceptionID = model.TransferStatusReceived, &at, recID
+		st.transfers[id] = t
+		out = &t
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if created {
+		metrics.ReceptionsAdded.Inc()
+	}
+	return out, nil
+}
+
+// checkTransferProducts - как Repo.checkTransferProducts, но по состоянию в памяти
+func (s *memState) checkTransferProducts(pvzID string, ids []string, allowed ...string) ([]transferProduct, error) {
+	byID := make(map[string]transferProduct, len(ids))
+	for _, id := range ids {
+		p, ok := s.products[id]
+		if !ok || p.DeletedAt != nil {
+			continue
+		}
+		rec := s.receptions[p.ReceptionID]
+		byID[id] = transferProduct{ID: id, Status: p.Status, ReceptionID: rec.ID, ReceptionStatus: rec.Status, PVZID: rec.PVZID, Quantity: p.Quantity}
+	}
+	return validateTransferProducts(pvzID, ids, byID, allowed)
+}
+
+func (m *MemRepo) ListProductMovements(ctx context.Context, productID string) ([]*model.ProductMovement, error) {
+	var moves []*model.ProductMovement
+	err := m.read(ctx, func(st *memState) error {
+		for _, mv := range st.movements {
+			if mv.ProductID == productID {
+				moves = append(moves, &mv)
+			}
+		}
+		return nil
+	})
+	sort.SliceStable(moves, func(i, j int) bool { return moves[i].CreatedAt.Before(moves[j].CreatedAt) })
+	return moves, err
+}
+
+// addMovement дописывает запись в историю перемещений
+func (s *memState) addMovement(mv model.ProductMovement) {
+	mv.ID = s.nextSeq()
+	s.movements = append(s.movements, mv)
+}
+
+func (m *MemRepo) CreateAttachment(ctx context.Context, a *model.Attachment) error {
+	return m.write(ctx, func(st *memState) error {
+		var exists bool
+		switch a.OwnerType {
+		case model.AttachmentOwnerReception:
+			_, exists = st.receptions[a.OwnerID]
+		case model.AttachmentOwnerProduct:
+			p, ok := st.products[a.OwnerID]
+			exists = ok && p.DeletedAt == nil
+		default:
+			return fmt.Errorf("unknown attachment owner type %q", a.OwnerType)
+		}
+		if !exists {
+			return ErrAttachmentOwnerNotFound
+		}
+		if _, ok := st.attachments[a.ID]; ok {
+			return fmt.Errorf("%w: attachment %s", ErrAlreadyExists, a.ID)
+		}
+		st.attachments[a.ID] = *a
+		return nil
+	})
+}
+
+func (m *MemRepo) GetAttachment(ctx context.Context, id string) (*model.Attachment, error) {
+	var a *model.Attachment
+	err := m.read(ctx, func(st *memState) error {
+		if found, ok := st.attachments[id]; ok {
+			a = &found
+		}
+		return nil
+	})
+	return a, err
+}
+
+func (m *MemRepo) ListAttachments(ctx context.Context, ownerType, ownerID string) ([]*model.Attachment, error) {
+	var list []*model.Attachment
+	err := m.read(ctx, func(st *memState) error {
+		for _, a := range st.attachments {
+			if a.OwnerType == ownerType && a.OwnerID == ownerID {
+				list = append(list, &a)
+			}
+		}
+		return nil
+	})
+	sort.Slice(list, func(i, j int) bool {
+		if !list[i].CreatedAt.Equal(list[j].CreatedAt) {
+			return list[i].CreatedAt.Before(list[j].CreatedAt)
+		}
+		return list[i].ID < list[j].ID
+	})
+	return list, err
+}
@@ -0,0 +1,318 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	sq "github.com/Masterminds/squirrel"
+
+	"github.com/51mans0n/avito-pvz-task/internal/model"
+)
+
+// DeleteProduct помечает товар del.ProductID удалённым и записывает причину в журнал удалений.
+// Товар из закрытой приёмки удаляется только при allowClosed (модератор);
+// итог и отчёт о расхождениях такой приёмки пересчитываются.
+func (r *Repo) DeleteProduct(ctx context.Context, del *model.ProductDeletion, allowClosed bool) error {
+	return r.inTx(ctx, func(tx *Repo) error {
+		q, args, err := sq.Select("reception_id", "type", "quantity").
+			From("products").
+			Where(sq.Eq{"id": del.ProductID, "deleted_at": nil}).
+			PlaceholderFormat(sq.Dollar).
+			ToSql()
+		if err != nil {
+			return err
+		}
+		var prod model.Product
+		if err := tx.db.GetContext(ctx, &prod, q, args...); err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return ErrProductNotFound
+			}
+			return err
+		}
+
+		rec, err := tx.lockReception(ctx, prod.ReceptionID)
+		if err != nil {
+			return err
+		}
+		if rec.Status != "in_progress" && !allowClosed {
+			return ErrReceptionClosed
+		}
+
+		deleted, err := tx.softDeleteProduct(ctx, del.ProductID, del.DeletedBy, del.DeletedAt)
+		if err != nil {
+			return err
+		}
+		if !deleted {
+			// удалили параллельно, пока ждали блокировку приёмки
+			return ErrProductNotFound
+		}
+
+		del.ReceptionID = prod.ReceptionID
+		del.ProductType = prod.Type
+		del.Quantity = prod.Quantity
+		qIns, argsIns, err := sq.Insert("product_deletions").
+			Columns("id", "product_id", "reception_id", "product_type", "quantity", "reason", "comment", "deleted_by", "deleted_at").
+			Values(del.ID, del.ProductID, del.ReceptionID, del.ProductType, del.Quantity, del.Reason,
+				nullString(del.Comment), del.DeletedBy, del.DeletedAt).
+			PlaceholderFormat(sq.Dollar).
+			ToSql()
+		if err != nil {
+			return err
+		}
+		if _, err := tx.db.ExecContext(ctx, qIns, argsIns...); err != nil {
+			return err
+		}
+		if err := tx.touchReceptions(ctx, rec.ID); err != nil {
+			return err
+		}
+
+		if rec.Status == "in_progress" {
+			return nil
+		}
+		return tx.refreshClosedReception(ctx, rec)
+	})
+}
+
+// softDeleteProduct помечает товар удалённым; false - товара нет или он уже удалён
+func (r *Repo) softDeleteProduct(ctx context.Context, productID, actor string, at time.Time) (bool, error) {
+	q, args, err := sq.Update("products").
+		Set("deleted_at", at).
+		Set("deleted_by", nullString(actor)).
+		Where(sq.Eq{"id": productID, "deleted_at": nil}).
+		PlaceholderFormat(sq.Dollar).
+		ToSql()
+	if err != nil {
+		return false, err
+	}
+	res, err := r.db.ExecContext(ctx, q, args...)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+// removeProductUnit убирает одну единицу из строки товара и запоминает это для отмены
+func (r *Repo) removeProductUnit(ctx context.Context, productID, receptionID, actor string, at time.Time) error {
+	q, args, err := sq.Update("products").
+		Set("quantity", sq.Expr("quantity - 1")).
+		Where(sq.Eq{"id": productID}).
+		PlaceholderFormat(sq.Dollar).
+		ToSql()
+	if err != nil {
+		return err
+	}
+	if _, err := r.db.ExecContext(ctx, q, args...); err != nil {
+		return err
+	}
+
+	qIns, argsIns, err := sq.Insert("product_unit_removals").
+		Columns("product_id", "reception_id", "removed_by", "removed_at").
+		Values(productID, receptionID, nullString(actor), at).
+		PlaceholderFormat(sq.Dollar).
+		ToSql()
+	if err != nil {
+		return err
+	}
+	_, err = r.db.ExecContext(ctx, qIns, argsIns...)
+	return err
+}
+
+// unitRemoval - последняя LIFO-выемка единицы из строки товара
+type unitRemoval struct {
+	ID        int64     `db:"id"`
+	ProductID string    `db:"product_id"`
+	RemovedAt time.Time `db:"removed_at"`
+}
+
+// UndoLastDelete отменяет последнее удаление в открытой приёмке ПВЗ: возвращает
+// удалённую строку товара или единицу, убранную из строки через LIFO.
+// Запись в журнале удалений помечается отменённой, а если товар убирали
+// через LIFO, уменьшается счётчик LIFO-удалений.
+func (r *Repo) UndoLastDelete(ctx context.Context, pvzID string) (*model.Product, error) {
+	var prod model.Product
+	err := r.inTx(ctx, func(tx *Repo) error {
+		rec, err := tx.getActiveReception(ctx, pvzID)
+		if err != nil {
+			return err
+		}
+		if rec == nil {
+			return fmt.Errorf("%w for pvz %s", ErrNoActiveReception, pvzID)
+		}
+
+		q, args, err := sq.Select("id", "reception_id", "date_time", "type", "quantity", "deleted_at").
+			From("products").
+			Where(sq.Eq{"reception_id": rec.ID}).
+			Where(sq.NotEq{"deleted_at": nil}).
+			OrderBy("deleted_at DESC", "seq DESC").
+			Limit(1).
+			PlaceholderFormat(sq.Dollar).
+			ToSql()
+		if err != nil {
+			return err
+		}
+		deletedFound := true
+		if err := tx.db.GetContext(ctx, &prod, q, args...); err != nil {
+			if !errors.Is(err, sql.ErrNoRows) {
+				return err
+			}
+			deletedFound = false
+		}
+
+		qRm, argsRm, err := sq.Select("id", "product_id", "removed_at").
+			From("product_unit_removals").
+			Where(sq.Eq{"reception_id": rec.ID}).
+			OrderBy("removed_at DESC", "id DESC").
+			Limit(1).
+			PlaceholderFormat(sq.Dollar).
+			ToSql()
+		if err != nil {
+			return err
+		}
+		var rm unitRemoval
+		removalFound := true
+		if err := tx.db.GetContext(ctx, &rm, qRm, argsRm...); err != nil {
+			if !errors.Is(err, sql.ErrNoRows) {
+				return err
+			}
+			removalFound = false
+		}
+
+		switch {
+		case removalFound && (!deletedFound || rm.RemovedAt.After(*prod.DeletedAt)):
+			if err := tx.restoreProductUnit(ctx, rm, &prod); err != nil {
+				return err
+			}
+			return tx.decrementLIFODeletions(ctx, rec.ID)
+		case !deletedFound:
+			return ErrNothingToUndo
+		}
+
+		qUp, argsUp, err := sq.Update("products").
+			Set("deleted_at", nil).
+			Set("deleted_by", nil).
+			Where(sq.Eq{"id": prod.ID}).
+			PlaceholderFormat(sq.Dollar).
+			ToSql()
+		if err != nil {
+			return err
+		}
+		if _, err := tx.db.ExecContext(ctx, qUp, argsUp...); err != nil {
+			// после удаления тот же штрихкод могли отсканировать заново
+			if pqErr, ok := pgError(err, pgUniqueViolation); ok && pqErr.Constraint == receptionBarcodeIndex {
+				return ErrDuplicateBarcode
+			}
+			return err
+		}
+		prod.DeletedAt = nil
+
+		qLog, argsLog, err := sq.Update("product_deletions").
+			Set("undone_at", time.Now()).
+			Where(sq.Eq{"product_id": prod.ID, "undone_at": nil}).
+			PlaceholderFormat(sq.Dollar).
+			ToSql()
+		if err != nil {
+			return err
+		}
+		res, err := tx.db.ExecContext(ctx, qLog, argsLog...)
+		if err != nil {
+			return err
+		}
+		n, err := res.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if n > 0 {
+			return tx.touchReceptions(ctx, rec.ID)
+		}
+
+		// в журнале записи нет - товар удаляли через LIFO
+		return tx.decrementLIFODeletions(ctx, rec.ID)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &prod, nil
+}
+
+// restoreProductUnit возвращает в строку товара единицу, убранную через LIFO
+func (r *Repo) restoreProductUnit(ctx context.Context, rm unitRemoval, prod *model.Product) error {
+	q, args, err := sq.Update("products").
+		Set("quantity", sq.Expr("quantity + 1")).
+		Where(sq.Eq{"id": rm.ProductID}).
+		Suffix("RETURNING id, reception_id, date_time, type, quantity").
+		PlaceholderFormat(sq.Dollar).
+		ToSql()
+	if err != nil {
+		return err
+	}
+	*prod = model.Product{}
+	if err := r.db.GetContext(ctx, prod, q, args...); err != nil {
+		return err
+	}
+
+	qDel, argsDel, err := sq.Delete("product_unit_removals").
+		Where(sq.Eq{"id": rm.ID}).
+		PlaceholderFormat(sq.Dollar).
+		ToSql()
+	if err != nil {
+		return err
+	}
+	_, err = r.db.ExecContext(ctx, qDel, argsDel...)
+	return err
+}
+
+func (r *Repo) decrementLIFODeletions(ctx context.Context, receptionID string) error {
+	q, args, err := sq.Update("receptions").
+		Set("lifo_deletions", sq.Expr("GREATEST(lifo_deletions - 1, 0)")).
+		Set("version", sq.Expr("version + 1")).
+		Where(sq.Eq{"id": receptionID}).
+		PlaceholderFormat(sq.Dollar).
+		ToSql()
+	if err != nil {
+		return err
+	}
+	_, err = r.db.ExecContext(ctx, q, args...)
+	return err
+}
+
+// lockReception блокирует строку приёмки по id независимо от статуса
+func (r *Repo) lockReception(ctx context.Context, receptionID string) (*model.Reception, error) {
+	q, args, err := sq.Select(receptionColumns...).
+		From("receptions").
+		Where(sq.Eq{"id": receptionID}).
+		Suffix("FOR UPDATE").
+		PlaceholderFormat(sq.Dollar).
+		ToSql()
+	if err != nil {
+		return nil, err
+	}
+
+	var rec model.Reception
+	if err := r.db.GetContext(ctx, &rec, q, args...); err != nil {
+		return nil, err
+	}
+	return &rec, nil
+}
+
+// refreshClosedReception пересчитывает итог и расхождения закрытой приёмки
+// после изменения её состава
+func (r *Repo) refreshClosedReception(ctx context.Context, rec *model.Reception) error {
+	closedAt := time.Now()
+	if rec.ClosedAt != nil {
+		closedAt = *rec.ClosedAt
+	}
+
+	counts, err := r.countProductsByType(ctx, rec.ID)
+	if err != nil {
+		return err
+	}
+	if rec.Summary, err = r.saveReceptionSummary(ctx, rec, counts, closedAt); err != nil {
+		return err
+	}
+	rec.Discrepancy, err = r.saveDiscrepancyReport(ctx, rec.ID, counts, closedAt)
+	return err
+}
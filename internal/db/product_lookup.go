@@ -0,0 +1,87 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	sq "github.com/Masterminds/squirrel"
+
+	"github.com/51mans0n/avito-pvz-task/internal/model"
+)
+
+// GetProduct возвращает товар по id вместе с приёмкой, ПВЗ и ячейкой (nil, если не найден)
+func (r *Repo) GetProduct(ctx context.Context, productID string) (*model.ProductLookup, error) {
+	return r.lookupProduct(ctx, sq.Eq{"p.id": productID})
+}
+
+// lookupProduct - самый свежий живой товар по условию where
+func (r *Repo) lookupProduct(ctx context.Context, where sq.Eq) (*model.ProductLookup, error) {
+	q, args, err := sq.Select(
+		"p.id", "p.reception_id", "p.date_time", "p.type", "p.quantity",
+		"COALESCE(p.barcode, '') AS barcode", "COALESCE(p.serial_number, '') AS serial_number",
+		"p.status", "p.stored_at", "p.issued_at", "p.returned_at", "COALESCE(p.cell_id::text, '') AS cell_id",
+		"r.date_time AS reception_date_time", "r.status AS reception_status",
+		"v.id AS pvz_id", "v.city", "v.registration_date",
+		"c.zone AS cell_zone", "c.rack AS cell_rack", "c.shelf AS cell_shelf", "c.capacity AS cell_capacity",
+	).
+		From("products p").
+		Join("receptions r ON r.id = p.reception_id").
+		Join("pvz v ON v.id = r.pvz_id").
+		LeftJoin("storage_cells c ON c.id = p.cell_id").
+		Where(where).
+		Where(sq.Eq{"p.deleted_at": nil}).
+		OrderBy("p.date_time DESC", "p.seq DESC").
+		Limit(1).
+		PlaceholderFormat(sq.Dollar).
+		ToSql()
+	if err != nil {
+		return nil, err
+	}
+
+	var row struct {
+		model.Product
+		ReceptionDateTime time.Time `db:"reception_date_time"`
+		ReceptionStatus   string    `db:"reception_status"`
+		PVZID             string    `db:"pvz_id"`
+		City              string    `db:"city"`
+		RegistrationDate  time.Time `db:"registration_date"`
+		CellZone          *string   `db:"cell_zone"`
+		CellRack          *string   `db:"cell_rack"`
+		CellShelf         *string   `db:"cell_shelf"`
+		CellCapacity      *int      `db:"cell_capacity"`
+	}
+	if err := r.db.GetContext(ctx, &row, q, args...); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	found := &model.ProductLookup{
+		Product: convertProducts([]*model.Product{&row.Product})[0],
+		Reception: model.ReceptionResponse{
+			ID:       row.ReceptionID,
+			PVZID:    row.PVZID,
+			DateTime: row.ReceptionDateTime,
+			Status:   row.ReceptionStatus,
+		},
+		PVZ: model.PVZResponse{
+			ID:               row.PVZID,
+			City:             row.City,
+			RegistrationDate: row.RegistrationDate,
+		},
+	}
+	if row.CellID != "" && row.CellZone != nil {
+		found.Cell = &model.StorageCell{
+			ID:       row.CellID,
+			PVZID:    row.PVZID,
+			Zone:     *row.CellZone,
+			Rack:     *row.CellRack,
+			Shelf:    *row.CellShelf,
+			Capacity: *row.CellCapacity,
+		}
+	}
+	return found, nil
+}
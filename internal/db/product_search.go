@@ -0,0 +1,163 @@
+package db
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"time"
+
+	sq "github.com/Masterminds/squirrel"
+
+	"github.com/51mans0n/avito-pvz-task/internal/model"
+)
+
+// ProductListFilter - параметры поиска товаров по всем ПВЗ; пустые поля не фильтруют
+type ProductListFilter struct {
+	PVZID       string
+	City        string
+	ReceptionID string
+	Type        string
+	Status      string
+	Barcode     string
+	From, To    *time.Time // диапазон времени приёмки товара
+
+	Asc    bool   // сначала старые; по умолчанию сначала новые
+	Cursor string // NextCursor предыдущей страницы
+	Limit  int
+}
+
+// searchColumns - productColumns с алиасом p и ПВЗ товара
+var searchColumns = []string{
+	"p.id", "p.reception_id", "p.date_time", "p.type", "p.quantity",
+	"COALESCE(p.barcode, '') AS barcode", "COALESCE(p.serial_number, '') AS serial_number",
+	"p.status", "p.stored_at", "p.issued_at", "p.returned_at", "COALESCE(p.cell_id::text, '') AS cell_id",
+	"r.pvz_id",
+}
+
+// ListProducts ищет живые товары одним запросом с keyset-пагинацией по
+// (date_time, id). Возвращает страницу и курсор следующей ("" - страниц больше нет).
+func (r *Repo) ListProducts(ctx context.Context, f ProductListFilter) ([]*model.Product, string, error) {
+	var (
+		prods []*model.Product
+		next  string
+	)
+	err := r.onReader(ctx, func(rr *Repo) error {
+		var err error
+		prods, next, err = rr.listProducts(ctx, f)
+		return err
+	})
+	return prods, next, err
+}
+
+func (r *Repo) listProducts(ctx context.Context, f ProductListFilter) ([]*model.Product, string, error) {
+	q := sq.Select(searchColumns...).
+		From("products p").
+		Join("receptions r ON r.id = p.reception_id").
+		Where(sq.Eq{"p.deleted_at": nil}).
+		PlaceholderFormat(sq.Dollar)
+
+	if f.City != "" {
+		q = q.Join("pvz v ON v.id = r.pvz_id").Where(sq.Eq{"v.city": f.City})
+	}
+	if f.PVZID != "" {
+		q = q.Where(sq.Eq{"r.pvz_id": f.PVZID})
+	}
+	if f.ReceptionID != "" {
+		q = q.Where(sq.Eq{"p.reception_id": f.ReceptionID})
+	}
+	if f.Type != "" {
+		q = q.Where(sq.Eq{"p.type": f.Type})
+	}
+	if f.Status != "" {
+		q = q.Where(sq.Eq{"p.status": f.Status})
+	}
+	if f.Barcode != "" {
+		q = q.Where(sq.Eq{"p.barcode": f.Barcode})
+	}
+	if f.From != nil {
+		q = q.Where(sq.GtOrEq{"p.date_time": *f.From})
+	}
+	if f.To != nil {
+		q = q.Where(sq.LtOrEq{"p.date_time": *f.To})
+	}
+
+	order, cmp := "DESC", "<"
+	if f.Asc {
+		order, cmp = "ASC", ">"
+	}
+	if f.Cursor != "" {
+		at, id, err := decodeProductCursor(f)
+		if err != nil {
+			return nil, "", err
+		}
+		q = q.Where("(p.date_time, p.id) "+cmp+" (?, ?)", at, id)
+	}
+	// лишняя строка показывает, есть ли следующая страница
+	q = q.OrderBy("p.date_time "+order, "p.id "+order).Limit(uint64(f.Limit) + 1)
+
+	sqlProd, args, err := q.ToSql()
+	if err != nil {
+		return nil, "", err
+	}
+	var prods []*model.Product
+	if err := r.db.SelectContext(ctx, &prods, sqlProd, args...); err != nil {
+		return nil, "", err
+	}
+
+	if len(prods) <= f.Limit {
+		return prods, "", nil
+	}
+	prods = prods[:f.Limit]
+	last := prods[len(prods)-1]
+	return prods, encodeProductCursor(f, last.DateTime, last.ID), nil
+}
+
+// Курсор хранит сортировку и отпечаток фильтров, с которыми выдан: продолжить
+// им выдачу с другими параметрами нельзя, иначе страницы молча перепутаются.
+func encodeProductCursor(f ProductListFilter, at time.Time, id string) string {
+	raw := strings.Join([]string{f.sortKey(), f.fingerprint(), at.UTC().Format(time.RFC3339Nano), id}, "|")
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+func decodeProductCursor(f ProductListFilter) (time.Time, string, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(f.Cursor)
+	if err != nil {
+		return time.Time{}, "", ErrInvalidCursor
+	}
+	parts := strings.Split(string(raw), "|")
+	if len(parts) != 4 || parts[3] == "" {
+		return time.Time{}, "", ErrInvalidCursor
+	}
+	if parts[0] != f.sortKey() || parts[1] != f.fingerprint() {
+		return time.Time{}, "", fmt.Errorf("%w: issued for another sort or filter", ErrInvalidCursor)
+	}
+	at, err := time.Parse(time.RFC3339Nano, parts[2])
+	if err != nil {
+		return time.Time{}, "", fmt.Errorf("%w: %v", ErrInvalidCursor, err)
+	}
+	return at, parts[3], nil
+}
+
+func (f ProductListFilter) sortKey() string {
+	if f.Asc {
+		return "asc"
+	}
+	return "desc"
+}
+
+// fingerprint - короткий хеш фильтров (без курсора и размера страницы)
+func (f ProductListFilter) fingerprint() string {
+	bound := func(t *time.Time) string {
+		if t == nil {
+			return ""
+		}
+		return t.UTC().Format(time.RFC3339Nano)
+	}
+	sum := sha256.Sum256([]byte(strings.Join([]string{
+		f.PVZID, f.City, f.ReceptionID, f.Type, f.Status, f.Barcode, bound(f.From), bound(f.To),
+	}, "\x00")))
+	return hex.EncodeToString(sum[:8])
+}
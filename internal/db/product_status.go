@@ -0,0 +1,81 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	sq "github.com/Masterminds/squirrel"
+
+	"github.com/51mans0n/avito-pvz-task/internal/model"
+)
+
+// statusTimestamps - колонка, в которую пишется время перехода в статус
+var statusTimestamps = map[string]string{
+	model.ProductStatusStored:   "stored_at",
+	model.ProductStatusIssued:   "issued_at",
+	model.ProductStatusReturned: "returned_at",
+}
+
+// TransitionProduct переводит товар закрытой приёмки в статус status и
+// запоминает время перехода. Товар блокируется, приёмка - от переоткрытия.
+func (r *Repo) TransitionProduct(ctx context.Context, productID, status string, at time.Time) (*model.Product, error) {
+	column, ok := statusTimestamps[status]
+	if !ok {
+		return nil, fmt.Errorf("%w: to %s", ErrInvalidTransition, status)
+	}
+
+	var prod model.Product
+	err := r.inTx(ctx, func(tx *Repo) error {
+		q, args, err := sq.Select("p.status", "r.status AS reception_status").
+			From("products p").
+			Join("receptions r ON r.id = p.reception_id").
+			Where(sq.Eq{"p.id": productID, "p.deleted_at": nil}).
+			Suffix("FOR UPDATE OF p FOR SHARE OF r").
+			PlaceholderFormat(sq.Dollar).
+			ToSql()
+		if err != nil {
+			return err
+		}
+		var cur struct {
+			Status          string `db:"status"`
+			ReceptionStatus string `db:"reception_status"`
+		}
+		if err := tx.db.GetContext(ctx, &cur, q, args...); err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return ErrProductNotFound
+			}
+			return err
+		}
+		if cur.ReceptionStatus == "in_progress" {
+			return ErrReceptionOpen
+		}
+		if !model.CanTransition(cur.Status, status) {
+			return fmt.Errorf("%w: %s -> %s", ErrInvalidTransition, cur.Status, status)
+		}
+
+		upd := sq.Update("products").
+			Set("status", status).
+			Set(column, at)
+		if status != model.ProductStatusStored {
+			// товар уходит из ПВЗ и освобождает ячейку
+			upd = upd.Set("cell_id", nil)
+		}
+		qUpd, argsUpd, err := upd.
+			Where(sq.Eq{"id": productID}).
+			Suffix("RETURNING " + strings.Join(productColumns, ", ")).
+			PlaceholderFormat(sq.Dollar).
+			ToSql()
+		if err != nil {
+			return err
+		}
+		return tx.db.GetContext(ctx, &prod, qUpd, argsUpd...)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &prod, nil
+}
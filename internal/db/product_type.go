@@ -0,0 +1,121 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	sq "github.com/Masterminds/squirrel"
+
+	"github.com/51mans0n/avito-pvz-task/internal/model"
+)
+
+// ListProductTypes - справочник типов товаров по алфавиту
+func (r *Repo) ListProductTypes(ctx context.Context, includeInactive bool) ([]*model.ProductType, error) {
+	q := sq.Select("name", "requires_serial", "active", "created_at").
+		From("product_types").
+		OrderBy("name").
+		PlaceholderFormat(sq.Dollar)
+	if !includeInactive {
+		q = q.Where(sq.Eq{"active": true})
+	}
+
+	sqlStr, args, err := q.ToSql()
+	if err != nil {
+		return nil, err
+	}
+	var list []*model.ProductType
+	if err := r.db.SelectContext(ctx, &list, sqlStr, args...); err != nil {
+		return nil, err
+	}
+	return list, nil
+}
+
+func (r *Repo) CreateProductType(ctx context.Context, pt *model.ProductType) error {
+	q, args, err := sq.Insert("product_types").
+		Columns("name", "requires_serial", "active", "created_at").
+		Values(pt.Name, pt.RequiresSerial, pt.Active, pt.CreatedAt).
+		PlaceholderFormat(sq.Dollar).
+		ToSql()
+	if err != nil {
+		return err
+	}
+	if _, err := r.db.ExecContext(ctx, q, args...); err != nil {
+		if _, ok := pgError(err, pgUniqueViolation); ok {
+			return ErrProductTypeExists
+		}
+		return err
+	}
+	return nil
+}
+
+// UpdateProductType меняет правила типа; тип не удаляется, а отключается
+// (Active=false), чтобы не ломать уже принятые товары.
+func (r *Repo) UpdateProductType(ctx context.Context, pt *model.ProductType) error {
+	q, args, err := sq.Update("product_types").
+		Set("requires_serial", pt.RequiresSerial).
+		Set("active", pt.Active).
+		Where(sq.Eq{"name": pt.Name}).
+		Suffix("RETURNING created_at").
+		PlaceholderFormat(sq.Dollar).
+		ToSql()
+	if err != nil {
+		return err
+	}
+	if err := r.db.GetContext(ctx, &pt.CreatedAt, q, args...); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrProductTypeNotFound
+		}
+		return err
+	}
+	return nil
+}
+
+// checkProductTypes проверяет типы товаров по справочнику и правила этих типов
+// одним запросом на всю пачку
+func (r *Repo) checkProductTypes(ctx context.Context, prods []*model.Product) error {
+	names := make([]string, 0, len(prods))
+	seen := make(map[string]bool, len(prods))
+	for _, p := range prods {
+		if !seen[p.Type] {
+			seen[p.Type] = true
+			names = append(names, p.Type)
+		}
+	}
+
+	q, args, err := sq.Select("name", "requires_serial", "active").
+		From("product_types").
+		Where(sq.Eq{"name": names}).
+		PlaceholderFormat(sq.Dollar).
+		ToSql()
+	if err != nil {
+		return err
+	}
+	var list []model.ProductType
+	if err := r.db.SelectContext(ctx, &list, q, args...); err != nil {
+		return err
+	}
+	types := make(map[string]model.ProductType, len(list))
+	for _, pt := range list {
+		types[pt.Name] = pt
+	}
+	return validateProductTypes(prods, types)
+}
+
+// validateProductTypes проверяет товары пачки по найденным типам справочника
+func validateProductTypes(prods []*model.Product, types map[string]model.ProductType) error {
+	for i, p := range prods {
+		pt, ok := types[p.Type]
+		if !ok || !pt.Active {
+			return &ProductError{Index: i, Err: fmt.Errorf("%w: %s", ErrUnknownProductType, p.Type)}
+		}
+		if pt.RequiresSerial && p.SerialNumber == "" {
+			return &ProductError{Index: i, Err: ErrSerialRequired}
+		}
+		if p.SerialNumber != "" && p.Quantity > 1 {
+			return &ProductError{Index: i, Err: ErrSerialQuantity}
+		}
+	}
+	return nil
+}
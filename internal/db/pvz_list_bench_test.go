@@ -0,0 +1,198 @@
+package db_test
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"io"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/51mans0n/avito-pvz-task/internal/db"
+	"github.com/jmoiron/sqlx"
+)
+
+// Бенчмарк списка ПВЗ на фейковом драйвере: каждый запрос стоит roundTrip,
+// как поход в PostgreSQL по сети, а драйвер считает запросы.
+//
+//	go test ./internal/db -run '^$' -bench GetPVZList -benchtime 20x
+//
+// Страница 30 ПВЗ x 5 приёмок x 20 товаров, roundTrip 200µs (time.Sleep на
+// тестовой машине спит дольше, абсолютные цифры важны только в сравнении):
+//
+//	before (запрос на каждый ПВЗ и каждую приёмку)  181 queries/op  ~200ms/op
+//	after  (ПВЗ, приёмки и товары через ANY)          3 queries/op   ~12ms/op
+const (
+	benchPVZ        = 30
+	benchReceptions = 5
+	benchProducts   = 20
+	benchRoundTrip  = 200 * time.Microsecond
+)
+
+func BenchmarkGetPVZListWithFilter(b *testing.B) {
+	// before повторяет запросы прежней реализации: список ПВЗ, затем приёмки
+	// каждого ПВЗ и товары каждой приёмки отдельными запросами
+	b.Run("before", func(b *testing.B) {
+		xdb, drv := openPVZListBench(b)
+
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			pvzIDs := benchSelectIDs(b, xdb,
+				`SELECT id, city, registration_date FROM pvz ORDER BY registration_date DESC LIMIT 30 OFFSET 0`)
+			products := 0
+			for _, pvzID := range pvzIDs {
+				recIDs := benchSelectIDs(b, xdb,
+					`SELECT r.id, r.pvz_id, r.date_time, r.status FROM receptions r WHERE r.pvz_id = $1 ORDER BY r.date_time DESC`, pvzID)
+				for _, recID := range recIDs {
+					products += len(benchSelectIDs(b, xdb,
+						`SELECT id, reception_id, date_time, type FROM products WHERE reception_id = $1 AND deleted_at IS NULL ORDER BY date_time DESC`, recID))
+				}
+			}
+			if len(pvzIDs) != benchPVZ || products != benchPVZ*benchReceptions*benchProducts {
+				b.Fatalf("unexpected page shape")
+			}
+		}
+		b.ReportMetric(float64(drv.queries.Load())/float64(b.N), "queries/op")
+	})
+
+	b.Run("after", func(b *testing.B) {
+		xdb, drv := openPVZListBench(b)
+		repo := db.NewRepo(xdb)
+		f := db.PVZListFilter{Page: 1, Limit: benchPVZ}
+
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			list, err := repo.GetPVZListWithFilter(context.Background(), f)
+			if err != nil {
+				b.Fatal(err)
+			}
+			if len(list) != benchPVZ || len(list[0].Receptions) != benchReceptions ||
+				len(list[0].Receptions[0].Products) != benchProducts {
+				b.Fatalf("unexpected page shape")
+			}
+		}
+		b.ReportMetric(float64(drv.queries.Load())/float64(b.N), "queries/op")
+	})
+}
+
+// openPVZListBench - база на отдельном экземпляре фейкового драйвера со своим счётчиком
+func openPVZListBench(b *testing.B) (*sqlx.DB, *pvzListDriver) {
+	drv := &pvzListDriver{}
+	name := fmt.Sprintf("pvzlist-%p", drv)
+	sql.Register(name, drv)
+	sqlDB, err := sql.Open(name, "")
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.Cleanup(func() { sqlDB.Close() })
+	return sqlx.NewDb(sqlDB, "postgres"), drv
+}
+
+// benchSelectIDs выполняет запрос и возвращает первую колонку строк
+func benchSelectIDs(b *testing.B, xdb *sqlx.DB, query string, args ...any) []string {
+	rows, err := xdb.QueryxContext(context.Background(), query, args...)
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer rows.Close()
+	var ids []string
+	for rows.Next() {
+		row, err := rows.SliceScan()
+		if err != nil {
+			b.Fatal(err)
+		}
+		ids = append(ids, row[0].(string))
+	}
+	if err := rows.Err(); err != nil {
+		b.Fatal(err)
+	}
+	return ids
+}
+
+// pvzListDriver отвечает на запросы списка ПВЗ заготовленными строками
+type pvzListDriver struct {
+	queries atomic.Int64
+}
+
+func (d *pvzListDriver) Open(string) (driver.Conn, error) { return &pvzListConn{d: d}, nil }
+
+type pvzListConn struct{ d *pvzListDriver }
+
+func (c *pvzListConn) Prepare(string) (driver.Stmt, error) {
+	return nil, driver.ErrSkip
+}
+func (c *pvzListConn) Close() error              { return nil }
+func (c *pvzListConn) Begin() (driver.Tx, error) { return nil, driver.ErrSkip }
+
+func (c *pvzListConn) QueryContext(_ context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	c.d.queries.Add(1)
+	time.Sleep(benchRoundTrip)
+
+	now := time.Now()
+	rows := &pvzListRows{}
+	switch {
+	case strings.Contains(query, "FROM pvz"):
+		rows.cols = []string{"id", "city", "registration_date"}
+		for i := 0; i < benchPVZ; i++ {
+			rows.data = append(rows.data, []driver.Value{fmt.Sprintf("pvz-%d", i), "Москва", now})
+		}
+	case strings.Contains(query, "FROM receptions"):
+		rows.cols = []string{"id", "pvz_id", "date_time", "status", "total_products", "counts_by_type",
+			"first_scan_at", "last_scan_at", "duration_seconds", "summary_lifo_deletions"}
+		for _, pvzID := range benchIDs(args) {
+			for j := 0; j < benchReceptions; j++ {
+				rows.data = append(rows.data, []driver.Value{fmt.Sprintf("%s-rec-%d", pvzID, j), pvzID, now,
+					"close", int64(benchProducts), []byte(`{"обувь":20}`), now, now, int64(60), int64(0)})
+			}
+		}
+	case strings.Contains(query, "FROM products"):
+		rows.cols = []string{"id", "reception_id", "date_time", "type", "quantity", "barcode", "serial_number",
+			"status", "stored_at", "issued_at", "returned_at", "cell_id", "deleted_at", "deleted_by"}
+		for _, recID := range benchIDs(args) {
+			for j := 0; j < benchProducts; j++ {
+				rows.data = append(rows.data, []driver.Value{fmt.Sprintf("%s-p-%d", recID, j), recID, now, "обувь",
+					int64(1), "", "", "received", nil, nil, nil, "", nil, ""})
+			}
+		}
+	default:
+		return nil, fmt.Errorf("unexpected query: %s", query)
+	}
+	return rows, nil
+}
+
+// benchIDs - id из первого аргумента: одиночный или массив pq.Array '{"a","b"}'
+func benchIDs(args []driver.NamedValue) []string {
+	if len(args) == 0 {
+		return nil
+	}
+	s, _ := args[0].Value.(string)
+	if !strings.HasPrefix(s, "{") {
+		return []string{s}
+	}
+	ids := strings.Split(strings.Trim(s, "{}"), ",")
+	for i := range ids {
+		ids[i] = strings.Trim(ids[i], `"`)
+	}
+	return ids
+}
+
+type pvzListRows struct {
+	cols []string
+	data [][]driver.Value
+	pos  int
+}
+
+func (r *pvzListRows) Columns() []string { return r.cols }
+func (r *pvzListRows) Close() error      { return nil }
+
+func (r *pvzListRows) Next(dest []driver.Value) error {
+	if r.pos == len(r.data) {
+		return io.EOF
+	}
+	copy(dest, r.data[r.pos])
+	r.pos++
+	return nil
+}
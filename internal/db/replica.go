@@ -0,0 +1,152 @@
+package db
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"net"
+	"sync/atomic"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+
+	"github.com/51mans0n/avito-pvz-task/internal/logging"
+	"github.com/51mans0n/avito-pvz-task/internal/metrics"
+)
+
+// причины, по которым чтение ушло на основной пул вместо реплики
+const (
+	replicaUnchecked = "unchecked"
+	replicaDown      = "down"
+	replicaLagging   = "lagging"
+	replicaDetached  = "detached"
+	replicaError     = "error"
+)
+
+// состояние репликации. streaming - WAL receiver подключён к основному серверу:
+// без него receive LSN перестаёт расти, и нулевое отставание ничего не значит.
+// status виден только ролям с pg_read_all_stats, остальным приходит NULL, поэтому
+// для них достаточно самого процесса receiver.
+// lag - 0, если всё полученное WAL уже применено (иначе на простаивающем
+// основном сервере время последней транзакции только растёт).
+const replicaLagQuery = `SELECT
+	EXISTS (SELECT 1 FROM pg_stat_wal_receiver WHERE status IS NULL OR status = 'streaming') AS streaming,
+	CASE
+		WHEN pg_last_wal_receive_lsn() = pg_last_wal_replay_lsn() THEN 0
+		ELSE COALESCE(EXTRACT(EPOCH FROM now() - pg_last_xact_replay_timestamp()), 0)
+	END AS lag`
+
+// Replica - пул реплики для чтения. Run периодически пингует реплику и меряет
+// отставание; пока реплика недоступна или отстаёт больше maxLag, чтения идут
+// на основной пул.
+type Replica struct {
+	db     *sqlx.DB
+	maxLag time.Duration
+	state  atomic.Value // string: "" - реплика в порядке, иначе причина
+}
+
+func NewReplica(db *sqlx.DB, maxLag time.Duration) *Replica {
+	r := &Replica{db: db, maxLag: maxLag}
+	r.state.Store(replicaUnchecked)
+	return r
+}
+
+// Run проверяет реплику сразу и затем каждые interval, пока не отменён ctx
+func (r *Replica) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if err := r.Check(ctx); err != nil && ctx.Err() == nil {
+			logging.S().Warnw("replica check", "err", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Check - одна проверка: доступность, связь с основным сервером и отставание реплики
+func (r *Replica) Check(ctx context.Context) error {
+	var st struct {
+		Streaming bool    `db:"streaming"`
+		Lag       float64 `db:"lag"`
+	}
+	if err := r.db.GetContext(ctx, &st, replicaLagQuery); err != nil {
+		r.setState(replicaDown)
+		return err
+	}
+	metrics.ReplicaLag.Set(st.Lag)
+	if !st.Streaming {
+		r.setState(replicaDetached)
+		return nil
+	}
+	if time.Duration(st.Lag*float64(time.Second)) > r.maxLag {
+		r.setState(replicaLagging)
+		return nil
+	}
+	r.setState("")
+	return nil
+}
+
+// Healthy - можно ли сейчас читать с реплики
+func (r *Replica) Healthy() bool { return r.reason() == "" }
+
+func (r *Replica) reason() string { return r.state.Load().(string) }
+
+func (r *Replica) setState(reason string) {
+	if prev := r.state.Swap(reason); prev != reason {
+		logging.S().Infow("replica state changed", "from", prev, "to", reason)
+	}
+	if reason == "" {
+		metrics.ReplicaUp.Set(1)
+	} else {
+		metrics.ReplicaUp.Set(0)
+	}
+}
+
+// onReader выполняет чтение fn на реплике, если она задана и в порядке, иначе на
+// основном пуле; внутри транзакции читает её же. Если реплика отвалилась
+// посреди запроса, она помечается недоступной, а чтение повторяется на основном пуле.
+func (r *Repo) onReader(ctx context.Context, fn func(rr *Repo) error) error {
+	if r.tx == nil && r.replica != nil {
+		reason := r.replica.reason()
+		if reason == "" {
+			err := fn(&Repo{pool: r.pool, db: r.replica.db})
+			if !isConnError(err) {
+				metrics.DBReads.WithLabelValues("replica").Inc()
+				return err
+			}
+			logging.S().Warnw("replica read failed, retrying on primary", "err", err)
+			r.replica.setState(replicaDown)
+			reason = replicaError
+		}
+		metrics.ReplicaFallbacks.WithLabelValues(reason).Inc()
+	}
+	metrics.DBReads.WithLabelValues("primary").Inc()
+	return fn(r)
+}
+
+// isConnError - ошибка соединения или сервера, а не запроса: такое чтение
+// имеет смысл повторить на другом пуле
+func isConnError(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	var netErr net.Error
+	if errors.Is(err, driver.ErrBadConn) || errors.As(err, &netErr) {
+		return true
+	}
+	var pqErr *pq.Error
+	if !errors.As(err, &pqErr) {
+		return false
+	}
+	switch pqErr.Code {
+	case "57P01", "57P03", // admin_shutdown, cannot_connect_now
+		pgSerializationFailure: // на реплике - запрос отменён из-за конфликта с восстановлением
+		return true
+	}
+	return pqErr.Code.Class() == "08" // connection_exception
+}
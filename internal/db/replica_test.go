@@ -0,0 +1,149 @@
+package db_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/51mans0n/avito-pvz-task/internal/db"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/require"
+)
+
+// newReplicaRepo - Repo с двумя sqlmock-пулами: основным и репликой
+func newReplicaRepo(t *testing.T, maxLag time.Duration) (*db.Repo, *db.Replica, sqlmock.Sqlmock, sqlmock.Sqlmock) {
+	t.Helper()
+	primaryDB, primary, err := sqlmock.New()
+	require.NoError(t, err)
+	t.Cleanup(func() { primaryDB.Close() })
+	replicaDB, replica, err := sqlmock.New()
+	require.NoError(t, err)
+	t.Cleanup(func() { replicaDB.Close() })
+
+	rep := db.NewReplica(sqlx.NewDb(replicaDB, "postgres"), maxLag)
+	return db.NewRepoWithReplica(sqlx.NewDb(primaryDB, "postgres"), rep), rep, primary, replica
+}
+
+func expectLag(mock sqlmock.Sqlmock, seconds float64) {
+	mock.ExpectQuery(`pg_last_wal_replay_lsn`).WillReturnRows(sqlmock.NewRows([]string{"streaming", "lag"}).AddRow(true, seconds))
+}
+
+func movementRows() *sqlmock.Rows {
+	return sqlmock.NewRows([]string{"id", "product_id", "event", "from_pvz_id", "to_pvz_id", "reception_id",
+		"transfer_id", "cell_id", "actor", "created_at"}).
+		AddRow(1, "prod-1", "received", "", "pvz-1", "rec-1", "", "", "", time.Now())
+}
+
+func TestReplica_Routing(t *testing.T) {
+	ctx := context.Background()
+	repo, rep, primary, replica := newReplicaRepo(t, 5*time.Second)
+
+	// до первой проверки реплика считается непроверенной
+	require.False(t, rep.Healthy())
+	primary.ExpectQuery(`FROM product_movements`).WillReturnRows(movementRows())
+	_, err := repo.ListProductMovements(ctx, "prod-1")
+	require.NoError(t, err)
+
+	expectLag(replica, 0.5)
+	require.NoError(t, rep.Check(ctx))
+	require.True(t, rep.Healthy())
+	replica.ExpectQuery(`FROM product_movements`).WillReturnRows(movementRows())
+	moves, err := repo.ListProductMovements(ctx, "prod-1")
+	require.NoError(t, err)
+	require.Len(t, moves, 1)
+
+	// отстающая реплика выключается из чтения до следующей проверки
+	expectLag(replica, 30)
+	require.NoError(t, rep.Check(ctx))
+	require.False(t, rep.Healthy())
+	primary.ExpectQuery(`FROM product_movements`).WillReturnRows(movementRows())
+	_, err = repo.ListProductMovements(ctx, "prod-1")
+	require.NoError(t, err)
+
+	require.NoError(t, primary.ExpectationsWereMet())
+	require.NoError(t, replica.ExpectationsWereMet())
+}
+
+func TestReplica_FallbackOnConnectionError(t *testing.T) {
+	ctx := context.Background()
+	repo, rep, primary, replica := newReplicaRepo(t, 5*time.Second)
+	expectLag(replica, 0)
+	require.NoError(t, rep.Check(ctx))
+
+	replica.ExpectQuery(`FROM product_movements`).WillReturnError(&pq.Error{Code: "08006"}) // connection_failure
+	primary.ExpectQuery(`FROM product_movements`).WillReturnRows(movementRows())
+	moves, err := repo.ListProductMovements(ctx, "prod-1")
+	require.NoError(t, err)
+	require.Len(t, moves, 1)
+	require.False(t, rep.Healthy())
+
+	// реплика недоступна - проверка это фиксирует
+	replica.ExpectQuery(`pg_last_wal_replay_lsn`).WillReturnError(&pq.Error{Code: "08006"})
+	require.Error(t, rep.Check(ctx))
+	require.False(t, rep.Healthy())
+
+	require.NoError(t, primary.ExpectationsWereMet())
+	require.NoError(t, replica.ExpectationsWereMet())
+}
+
+func TestReplica_DetachedReceiver(t *testing.T) {
+	ctx := context.Background()
+	repo, rep, primary, replica := newReplicaRepo(t, 5*time.Second)
+	expectLag(replica, 0)
+	require.NoError(t, rep.Check(ctx))
+	require.True(t, rep.Healthy())
+
+	// receiver отключился: применено всё полученное, но новое WAL не приходит
+	replica.ExpectQuery(`pg_stat_wal_receiver`).
+		WillReturnRows(sqlmock.NewRows([]string{"streaming", "lag"}).AddRow(false, 0))
+	require.NoError(t, rep.Check(ctx))
+	require.False(t, rep.Healthy())
+
+	primary.ExpectQuery(`FROM product_movements`).WillReturnRows(movementRows())
+	_, err := repo.ListProductMovements(ctx, "prod-1")
+	require.NoError(t, err)
+
+	require.NoError(t, primary.ExpectationsWereMet())
+	require.NoError(t, replica.ExpectationsWereMet())
+}
+
+func TestReplica_DiscrepancyReportMissFallsBackToPrimary(t *testing.T) {
+	ctx := context.Background()
+	repo, rep, primary, replica := newReplicaRepo(t, 5*time.Second)
+	expectLag(replica, 0)
+	require.NoError(t, rep.Check(ctx))
+
+	cols := []string{"type", "expected", "received", "missing", "surplus", "created_at"}
+	replica.ExpectQuery(`FROM reception_discrepancies`).WithArgs("rec-xyz").WillReturnRows(sqlmock.NewRows(cols))
+	primary.ExpectQuery(`FROM reception_discrepancies`).WithArgs("rec-xyz").
+		WillReturnRows(sqlmock.NewRows(cols).AddRow("обувь", 3, 1, 2, 0, time.Now()))
+
+	report, err := repo.GetDiscrepancyReport(ctx, "rec-xyz")
+	require.NoError(t, err)
+	require.NotNil(t, report)
+	require.Equal(t, 2, report.TotalMissing)
+
+	require.NoError(t, primary.ExpectationsWereMet())
+	require.NoError(t, replica.ExpectationsWereMet())
+}
+
+func TestReplica_TxReadsPrimary(t *testing.T) {
+	ctx := context.Background()
+	repo, rep, primary, replica := newReplicaRepo(t, 5*time.Second)
+	expectLag(replica, 0)
+	require.NoError(t, rep.Check(ctx))
+
+	primary.ExpectBegin()
+	primary.ExpectQuery(`FROM product_movements`).WillReturnRows(movementRows())
+	primary.ExpectCommit()
+	err := repo.WithTx(ctx, func(tx db.Repository) error {
+		_, err := tx.ListProductMovements(ctx, "prod-1")
+		return err
+	})
+	require.NoError(t, err)
+
+	require.NoError(t, primary.ExpectationsWereMet())
+	require.NoError(t, replica.ExpectationsWereMet())
+}
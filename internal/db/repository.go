@@ -5,11 +5,11 @@ import (
 	"database/sql"
 	"errors"
 	"fmt"
-	"strings"
 	"time"
 
 	sq "github.com/Masterminds/squirrel"
 	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
 
 	"github.com/51mans0n/avito-pvz-task/internal/model"
 )
@@ -18,24 +18,75 @@ import (
 // чтобы не зависеть от конкретной *Repo
 type Repository interface {
 	CreatePVZ(ctx context.Context, pvz *model.PVZ) error
-	GetPVZListWithFilter(ctx context.Context, startDate, endDate *time.Time, page, limit int) ([]model.PVZWithReceptions, error)
+	GetPVZ(ctx context.Context, id string) (*model.PVZ, error)
+	GetPVZListWithFilter(ctx context.Context, f PVZListFilter) ([]model.PVZWithReceptions, error)
 	CreateReception(ctx context.Context, rec *model.Reception) error
+	GetReception(ctx context.Context, id string) (*model.Reception, error)
 	CreateProduct(ctx context.Context, pvzID string, prod *model.Product) error
-	DeleteLastProduct(ctx context.Context, pvzID string) error
-	CloseLastReception(ctx context.Context, pvzID string) (*model.Reception, error)
+	CreateProducts(ctx context.Context, pvzID string, prods []*model.Product) error
+	DeleteLastProduct(ctx context.Context, pvzID, actor string) error
+	UndoLastDelete(ctx context.Context, pvzID string) (*model.Product, error)
+	DeleteProduct(ctx context.Context, del *model.ProductDeletion, allowClosed bool) error
+	CloseLastReception(ctx context.Context, pvzID string, ifVersion int) (*model.Reception, error)
+	ReopenLastReception(ctx context.Context, pvzID string, ifVersion int, closedAfter time.Time, reopen *model.ReceptionReopen) (*model.Reception, error)
+	CloseStaleReceptions(ctx context.Context, openedBefore time.Time, actor, reason string) ([]*model.Reception, error)
+	TransitionProduct(ctx context.Context, productID, status string, at time.Time) (*model.Product, error)
+	ListProducts(ctx context.Context, f ProductListFilter) ([]*model.Product, string, error)
+	GetProductByBarcode(ctx context.Context, barcode string) (*model.ProductLookup, error)
+	GetProduct(ctx context.Context, productID string) (*model.ProductLookup, error)
+	MoveProduct(ctx context.Context, productID, cellID, actor string) (*model.Product, error)
+	ListProductMovements(ctx context.Context, productID string) ([]*model.ProductMovement, error)
+	CreateTransfer(ctx context.Context, t *model.Transfer) error
+	GetTransfer(ctx context.Context, id string) (*model.Transfer, error)
+	DispatchTransfer(ctx context.Context, id, actor string, at time.Time) (*model.Transfer, error)
+	ReceiveTransfer(ctx context.Context, id, actor string, at time.Time) (*model.Transfer, error)
+	CreateStorageCell(ctx context.Context, c *model.StorageCell) error
+	ListStorageCells(ctx context.Context, pvzID string) ([]*model.StorageCell, error)
+	GetDiscrepancyReport(ctx context.Context, receptionID string) (*model.DiscrepancyReport, error)
+	ListProductTypes(ctx context.Context, includeInactive bool) ([]*model.ProductType, error)
+	CreateProductType(ctx context.Context, pt *model.ProductType) error
+	UpdateProductType(ctx context.Context, pt *model.ProductType) error
+	CreateAttachment(ctx context.Context, a *model.Attachment) error
+	GetAttachment(ctx context.Context, id string) (*model.Attachment, error)
+	ListAttachments(ctx context.Context, ownerType, ownerID string) ([]*model.Attachment, error)
 	CreateUser(ctx context.Context, user *model.User) error
 	GetUserByEmail(ctx context.Context, email string) (*model.User, error)
+
+	// WithTx выполняет fn атомарно; repo внутри fn привязан к транзакции
+	WithTx(ctx context.Context, fn func(repo Repository) error) error
+}
+
+// PVZListFilter - параметры выборки списка ПВЗ
+type PVZListFilter struct {
+	StartDate, EndDate *time.Time // диапазон дат приёмок
+	Page, Limit        int
+
+	IncludeDeleted bool   // показывать удалённые товары (только для модераторов)
+	ProductStatus  string // только товары в этом статусе; "" - все
+
+	// при фильтре по датам страница строится только из ПВЗ с приёмками в диапазоне;
+	// IncludeEmpty возвращает прежний режим: все ПВЗ, фильтруются только их приёмки
+	IncludeEmpty bool
 }
 
 // Убедимся, что *Repo реализует Repository:
 var _ Repository = (*Repo)(nil)
 
 type Repo struct {
-	db *sqlx.DB
+	pool    *sqlx.DB
+	db      dbtx     // pool либо текущая транзакция
+	tx      *sqlx.Tx // != nil, если Repo создан внутри WithTx
+	replica *Replica // nil - всё читается с основного пула
 }
 
 func NewRepo(db *sqlx.DB) *Repo {
-	return &Repo{db: db}
+	return &Repo{pool: db, db: db}
+}
+
+// NewRepoWithReplica - Repo, у которого списки и отчёты читаются с реплики,
+// а записи и чтения внутри транзакций идут на основной пул
+func NewRepoWithReplica(db *sqlx.DB, replica *Replica) *Repo {
+	return &Repo{pool: db, db: db, replica: replica}
 }
 
 func (r *Repo) CreatePVZ(ctx context.Context, pvz *model.PVZ) error {
@@ -47,17 +98,64 @@ func (r *Repo) CreatePVZ(ctx context.Context, pvz *model.PVZ) error {
 	if err != nil {
 		return err
 	}
-	_, err = r.db.ExecContext(ctx, query, args...)
-	return err
+	if _, err = r.db.ExecContext(ctx, query, args...); err != nil {
+		return err
+	}
+	pvz.Version = 1
+	return nil
+}
+
+// GetPVZ возвращает ПВЗ по id вместе с его версией
+func (r *Repo) GetPVZ(ctx context.Context, id string) (*model.PVZ, error) {
+	q, args, err := sq.Select("id", "city", "registration_date", "version").
+		From("pvz").
+		Where(sq.Eq{"id": id}).
+		PlaceholderFormat(sq.Dollar).
+		ToSql()
+	if err != nil {
+		return nil, err
+	}
+
+	var row struct {
+		ID               string    `db:"id"`
+		City             string    `db:"city"`
+		RegistrationDate time.Time `db:"registration_date"`
+		Version          int       `db:"version"`
+	}
+	if err := r.db.GetContext(ctx, &row, q, args...); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrPVZNotFound
+		}
+		return nil, err
+	}
+	return &model.PVZ{ID: row.ID, City: row.City, RegistrationDate: row.RegistrationDate, Version: row.Version}, nil
+}
+
+func (r *Repo) GetPVZListWithFilter(ctx context.Context, f PVZListFilter) ([]model.PVZWithReceptions, error) {
+	var out []model.PVZWithReceptions
+	err := r.onReader(ctx, func(rr *Repo) error {
+		var err error
+		out, err = rr.getPVZList(ctx, f)
+		return err
+	})
+	return out, err
 }
 
-func (r *Repo) GetPVZListWithFilter(ctx context.Context, startDate, endDate *time.Time, page, limit int) ([]model.PVZWithReceptions, error) {
-	q := sq.Select("id", "city", "registration_date").
+func (r *Repo) getPVZList(ctx context.Context, f PVZListFilter) ([]model.PVZWithReceptions, error) {
+	q := sq.Select("id", "city", "registration_date", "version").
 		From("pvz").
 		OrderBy("registration_date DESC").
-		Limit(uint64(limit)).
-		Offset(uint64((page - 1) * limit)).
+		Limit(uint64(f.Limit)).
+		Offset(uint64((f.Page - 1) * f.Limit)).
 		PlaceholderFormat(sq.Dollar)
+	if (f.StartDate != nil || f.EndDate != nil) && !f.IncludeEmpty {
+		sub, subArgs, err := receptionDateRange(sq.Select("1").From("receptions r").Where("r.pvz_id = pvz.id"),
+			f.StartDate, f.EndDate).ToSql()
+		if err != nil {
+			return nil, err
+		}
+		q = q.Where("EXISTS ("+sub+")", subArgs...)
+	}
 
 	sqlPVZ, argsPVZ, err := q.ToSql()
 	if err != nil {
@@ -68,12 +166,50 @@ func (r *Repo) GetPVZListWithFilter(ctx context.Context, startDate, endDate *tim
 		ID               string    `db:"id"`
 		City             string    `db:"city"`
 		RegistrationDate time.Time `db:"registration_date"`
+		Version          int       `db:"version"`
 	}
 	err = r.db.SelectContext(ctx, &pvzRows, sqlPVZ, argsPVZ...)
 	if err != nil {
 		return nil, err
 	}
 
+	// приёмки и товары всей страницы - по одному запросу, дальше раскладываем по ПВЗ
+	pvzIDs := make([]string, 0, len(pvzRows))
+	for _, row := range pvzRows {
+		pvzIDs = append(pvzIDs, row.ID)
+	}
+	recs, err := r.getReceptions(ctx, pvzIDs, f.StartDate, f.EndDate)
+	if err != nil {
+		return nil, err
+	}
+	recIDs := make([]string, 0, len(recs))
+	for _, rc := range recs {
+		recIDs = append(recIDs, rc.ID)
+	}
+	prods, err := r.getProducts(ctx, recIDs, f.IncludeDeleted, f.ProductStatus)
+	if err != nil {
+		return nil, err
+	}
+
+	prodsByRec := make(map[string][]*model.Product, len(recs))
+	for _, p := range prods {
+		prodsByRec[p.ReceptionID] = append(prodsByRec[p.ReceptionID], p)
+	}
+	recsByPVZ := make(map[string][]model.ReceptionWithProd, len(pvzRows))
+	for _, rc := range recs {
+		recsByPVZ[rc.PVZID] = append(recsByPVZ[rc.PVZID], model.ReceptionWithProd{
+			Reception: &model.ReceptionResponse{
+				ID:       rc.ID,
+				PVZID:    rc.PVZID,
+				DateTime: rc.DateTime,
+				Status:   rc.Status,
+				Version:  rc.Version,
+				Summary:  rc.Summary,
+			},
+			Products: convertProducts(prodsByRec[rc.ID]),
+		})
+	}
+
 	result := make([]model.PVZWithReceptions, 0, len(pvzRows))
 	for _, row := range pvzRows {
 		item := model.PVZWithReceptions{
@@ -81,159 +217,416 @@ func (r *Repo) GetPVZListWithFilter(ctx context.Context, startDate, endDate *tim
 				ID:               row.ID,
 				City:             row.City,
 				RegistrationDate: row.RegistrationDate,
+				Version:          row.Version,
 			},
-			Receptions: []model.ReceptionWithProd{},
+			Receptions: recsByPVZ[row.ID],
 		}
-
-		recs, err := r.getReceptions(ctx, row.ID, startDate, endDate)
-		if err != nil {
-			return nil, err
+		if item.Receptions == nil {
+			item.Receptions = []model.ReceptionWithProd{}
 		}
-		rwp := make([]model.ReceptionWithProd, 0, len(recs))
-		for _, rc := range recs {
-			prods, err := r.getProducts(ctx, rc.ID)
-			if err != nil {
-				return nil, err
-			}
-			rwp = append(rwp, model.ReceptionWithProd{
-				Reception: &model.ReceptionResponse{
-					ID:       rc.ID,
-					PVZID:    rc.PVZID,
-					DateTime: rc.DateTime,
-					Status:   rc.Status,
-				},
-				Products: convertProducts(prods),
-			})
-		}
-		item.Receptions = rwp
-
 		result = append(result, item)
 	}
 	return result, nil
 }
 
+// CreateReception открывает приёмку вместе с ожидаемым манифестом (если он есть).
+// Инвариант "одна открытая приёмка на ПВЗ" держит частичный уникальный индекс,
+// поэтому параллельные запросы не проскочат.
 func (r *Repo) CreateReception(ctx context.Context, rec *model.Reception) error {
-	var countOpen int
-	qCheck := sq.Select("count(*)").From("receptions").
-		Where(sq.Eq{"pvz_id": rec.PVZID, "status": "in_progress"}).
-		PlaceholderFormat(sq.Dollar)
+	return r.inTx(ctx, func(tx *Repo) error {
+		qIns, argsIns, err := sq.Insert("receptions").
+			Columns("id", "pvz_id", "date_time", "status").
+			Values(rec.ID, rec.PVZID, rec.DateTime, rec.Status).
+			PlaceholderFormat(sq.Dollar).
+			ToSql()
+		if err != nil {
+			return err
+		}
+		if _, err = tx.db.ExecContext(ctx, qIns, argsIns...); err != nil {
+			if isOpenReceptionConflict(err) {
+				return ErrReceptionAlreadyOpen
+			}
+			if _, ok := pgError(err, pgForeignKeyViolation); ok {
+				return ErrPVZNotFound
+			}
+			return err
+		}
 
-	sqlCheck, argsCheck, err := qCheck.ToSql()
-	if err != nil {
-		return err
-	}
-	if err := r.db.GetContext(ctx, &countOpen, sqlCheck, argsCheck...); err != nil {
-		return err
-	}
-	if countOpen > 0 {
-		return fmt.Errorf("there is already an open reception")
-	}
+		rec.Version = 1
 
-	qIns, argsIns, err := sq.Insert("receptions").
-		Columns("id", "pvz_id", "date_time", "status").
-		Values(rec.ID, rec.PVZID, rec.DateTime, rec.Status).
+		if len(rec.Manifest) == 0 {
+			return nil
+		}
+		return tx.insertManifest(ctx, rec.ID, rec.Manifest)
+	})
+}
+
+// GetReception возвращает приёмку по id вместе с её версией
+func (r *Repo) GetReception(ctx context.Context, id string) (*model.Reception, error) {
+	q, args, err := sq.Select(receptionColumns...).
+		From("receptions").
+		Where(sq.Eq{"id": id}).
 		PlaceholderFormat(sq.Dollar).
 		ToSql()
 	if err != nil {
-		return err
+		return nil, err
 	}
-	_, err = r.db.ExecContext(ctx, qIns, argsIns...)
-	return err
+
+	var rec model.Reception
+	if err := r.db.GetContext(ctx, &rec, q, args...); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrReceptionNotFound
+		}
+		return nil, err
+	}
+	return &rec, nil
 }
 
+// CreateProduct добавляет товар в открытую приёмку; приёмка блокируется
+// до конца транзакции, чтобы её не закрыли между проверкой и вставкой.
 func (r *Repo) CreateProduct(ctx context.Context, pvzID string, prod *model.Product) error {
-	rec, err := r.getActiveReception(ctx, pvzID)
-	if err != nil {
-		return err
+	err := r.CreateProducts(ctx, pvzID, []*model.Product{prod})
+	// для одиночного товара позиция в пачке не нужна
+	var pe *ProductError
+	if errors.As(err, &pe) {
+		return pe.Err
 	}
-	if rec == nil {
-		return fmt.Errorf("no active reception found for pvz %s", pvzID)
+	return err
+}
+
+// CreateProducts атомарно добавляет пачку товаров в открытую приёмку одним
+// многострочным INSERT. Ошибка конкретного товара возвращается как *ProductError.
+func (r *Repo) CreateProducts(ctx context.Context, pvzID string, prods []*model.Product) error {
+	if len(prods) == 0 {
+		return ErrEmptyBatch
 	}
+	for _, prod := range prods {
+		if prod.Quantity == 0 {
+			prod.Quantity = 1 // старые клиенты количество не передают
+		}
+	}
+	return r.inTx(ctx, func(tx *Repo) error {
+		// при повторе после дедлока ячейки и предупреждения считаются заново
+		for _, prod := range prods {
+			prod.CellID, prod.Cell, prod.Warnings = "", nil, nil
+		}
+		rec, err := tx.getActiveReception(ctx, pvzID)
+		if err != nil {
+			return err
+		}
+		if rec == nil {
+			return fmt.Errorf("%w for pvz %s", ErrNoActiveReception, pvzID)
+		}
+		if err := tx.checkProductTypes(ctx, prods); err != nil {
+			return err
+		}
+		if err := tx.checkBarcodes(ctx, rec.ID, prods); err != nil {
+			return err
+		}
+		if err := tx.assignCells(ctx, pvzID, prods); err != nil {
+			return err
+		}
 
-	prod.ReceptionID = rec.ID
-	q, args, err := sq.Insert("products").
-		Columns("id", "reception_id", "date_time", "type").
-		Values(prod.ID, prod.ReceptionID, prod.DateTime, prod.Type).
+		// история перемещений начинается с приёмки; пишется тем же запросом
+		ins := sq.Insert("products").
+			Prefix("WITH ins AS (").
+			Columns("id", "reception_id", "date_time", "type", "quantity", "barcode", "serial_number", "cell_id").
+			Suffix(`RETURNING id, reception_id, cell_id, date_time)
+				INSERT INTO product_movements (product_id, event, to_pvz_id, reception_id, cell_id, created_at)
+				SELECT ins.id, '` + model.MovementReceived + `', r.pvz_id, ins.reception_id, ins.cell_id, ins.date_time
+				FROM ins JOIN receptions r ON r.id = ins.reception_id`).
+			PlaceholderFormat(sq.Dollar)
+		for _, prod := range prods {
+			prod.ReceptionID = rec.ID
+			prod.Status = model.ProductStatusReceived // DEFAULT колонки
+			ins = ins.Values(prod.ID, prod.ReceptionID, prod.DateTime, prod.Type, prod.Quantity,
+				nullString(prod.Barcode), nullString(prod.SerialNumber), nullString(prod.CellID))
+		}
+		q, args, err := ins.ToSql()
+		if err != nil {
+			return err
+		}
+
+		if _, err = tx.db.ExecContext(ctx, q, args...); err != nil {
+			if pqErr, ok := pgError(err, pgUniqueViolation); ok && pqErr.Constraint == receptionBarcodeIndex {
+				return ErrDuplicateBarcode
+			}
+			return err
+		}
+		return tx.touchReceptions(ctx, rec.ID)
+	})
+}
+
+// touchReceptions увеличивает версию приёмок, у которых изменился состав товаров
+func (r *Repo) touchReceptions(ctx context.Context, ids ...string) error {
+	q, args, err := sq.Update("receptions").
+		Set("version", sq.Expr("version + 1")).
+		Where(sq.Eq{"id": ids}).
 		PlaceholderFormat(sq.Dollar).
 		ToSql()
 	if err != nil {
 		return err
 	}
-
 	_, err = r.db.ExecContext(ctx, q, args...)
 	return err
 }
 
-func (r *Repo) DeleteLastProduct(ctx context.Context, pvzID string) error {
-	rec, err := r.getActiveReception(ctx, pvzID)
-	if err != nil {
+// DeleteLastProduct убирает последнюю добавленную единицу товара (LIFO) под
+// блокировкой приёмки: у строки с quantity > 1 уменьшается количество, иначе
+// строка помечается удалённой. Вернуть единицу можно через UndoLastDelete.
+func (r *Repo) DeleteLastProduct(ctx context.Context, pvzID, actor string) error {
+	return r.inTx(ctx, func(tx *Repo) error {
+		rec, err := tx.getActiveReception(ctx, pvzID)
+		if err != nil {
+			return err
+		}
+		if rec == nil {
+			return fmt.Errorf("%w for pvz %s", ErrNoActiveReception, pvzID)
+		}
+
+		// у товаров одной пачки один date_time, последний отсканированный - с большим seq
+		qSel, argsSel, err := sq.Select("id", "quantity").
+			From("products").
+			Where(sq.Eq{"reception_id": rec.ID, "deleted_at": nil}).
+			OrderBy("date_time DESC", "seq DESC").
+			Limit(1).
+			PlaceholderFormat(sq.Dollar).
+			ToSql()
+		if err != nil {
+			return err
+		}
+
+		var last struct {
+			ID       string `db:"id"`
+			Quantity int    `db:"quantity"`
+		}
+		if err := tx.db.GetContext(ctx, &last, qSel, argsSel...); err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return ErrNoProducts
+			}
+			return err
+		}
+
+		if last.Quantity > 1 {
+			err = tx.removeProductUnit(ctx, last.ID, rec.ID, actor, time.Now())
+		} else {
+			_, err = tx.softDeleteProduct(ctx, last.ID, actor, time.Now())
+		}
+		if err != nil {
+			return err
+		}
+
+		// счётчик LIFO-удалений (в единицах) попадает в итог приёмки
+		qCnt, argsCnt, err := sq.Update("receptions").
+			Set("lifo_deletions", sq.Expr("lifo_deletions + 1")).
+			Set("version", sq.Expr("version + 1")).
+			Where(sq.Eq{"id": rec.ID}).
+			PlaceholderFormat(sq.Dollar).
+			ToSql()
+		if err != nil {
+			return err
+		}
+		_, err = tx.db.ExecContext(ctx, qCnt, argsCnt...)
 		return err
+	})
+}
+
+// CloseLastReception закрывает открытую приёмку ПВЗ под блокировкой строки.
+// ifVersion > 0 - закрыть, только если версия приёмки всё ещё такая.
+func (r *Repo) CloseLastReception(ctx context.Context, pvzID string, ifVersion int) (*model.Reception, error) {
+	var rec *model.Reception
+	err := r.inTx(ctx, func(tx *Repo) error {
+		var err error
+		rec, err = tx.getActiveReception(ctx, pvzID)
+		if err != nil {
+			return err
+		}
+		if rec == nil {
+			return ErrNoActiveReception
+		}
+		if err := checkVersion(rec.Version, ifVersion); err != nil {
+			return err
+		}
+		return tx.closeReception(ctx, rec, "", "")
+	})
+	if err != nil {
+		return nil, err
 	}
-	if rec == nil {
-		return fmt.Errorf("no active reception found for pvz %s", pvzID)
-	}
+	return rec, nil
+}
 
-	qSel, argsSel, err := sq.Select("id").
-		From("products").
-		Where(sq.Eq{"reception_id": rec.ID}).
-		OrderBy("date_time DESC").
-		Limit(1).
+// CloseStaleReceptions закрывает приёмки, открытые раньше openedBefore,
+// от имени actor с указанной причиной. Каждая приёмка закрывается в своей
+// транзакции; уже закрытые кем-то параллельно пропускаются.
+func (r *Repo) CloseStaleReceptions(ctx context.Context, openedBefore time.Time, actor, reason string) ([]*model.Reception, error) {
+	q, args, err := sq.Select("id").
+		From("receptions").
+		Where(sq.Eq{"status": "in_progress"}).
+		Where(sq.Lt{"date_time": openedBefore}).
+		OrderBy("date_time").
 		PlaceholderFormat(sq.Dollar).
 		ToSql()
 	if err != nil {
-		return err
+		return nil, err
+	}
+	var ids []string
+	if err := r.db.SelectContext(ctx, &ids, q, args...); err != nil {
+		return nil, err
 	}
 
-	var prodID string
-	if err := r.db.GetContext(ctx, &prodID, qSel, argsSel...); err != nil {
-		if isNoRowsErr(err) {
-			return errors.New("no products to delete")
+	closed := make([]*model.Reception, 0, len(ids))
+	for _, id := range ids {
+		var rec *model.Reception
+		err := r.inTx(ctx, func(tx *Repo) error {
+			var err error
+			rec, err = tx.lockOpenReception(ctx, id)
+			if err != nil || rec == nil {
+				return err
+			}
+			return tx.closeReception(ctx, rec, actor, reason)
+		})
+		if err != nil {
+			return closed, err
+		}
+		if rec != nil {
+			closed = append(closed, rec)
 		}
-		return err
 	}
+	return closed, nil
+}
 
-	qDel, argsDel, err := sq.Delete("products").
-		Where(sq.Eq{"id": prodID}).
+// closeReception переводит заблокированную приёмку в close и считает отчёт о расхождениях
+func (r *Repo) closeReception(ctx context.Context, rec *model.Reception, actor, reason string) error {
+	closedAt := time.Now()
+	qUp, argsUp, err := sq.Update("receptions").
+		Set("status", "close").
+		Set("closed_at", closedAt).
+		Set("closed_by", nullString(actor)).
+		Set("close_reason", nullString(reason)).
+		Set("version", sq.Expr("version + 1")).
+		Where(sq.Eq{"id": rec.ID}).
 		PlaceholderFormat(sq.Dollar).
 		ToSql()
 	if err != nil {
 		return err
 	}
-	_, err = r.db.ExecContext(ctx, qDel, argsDel...)
+	if _, err = r.db.ExecContext(ctx, qUp, argsUp...); err != nil {
+		return err
+	}
+	rec.Status = "close"
+	rec.ClosedAt = &closedAt
+	rec.ClosedBy = actor
+	rec.CloseReason = reason
+	rec.Version++
+
+	counts, err := r.countProductsByType(ctx, rec.ID)
+	if err != nil {
+		return err
+	}
+	if rec.Summary, err = r.saveReceptionSummary(ctx, rec, counts, closedAt); err != nil {
+		return err
+	}
+	rec.Discrepancy, err = r.saveDiscrepancyReport(ctx, rec.ID, counts, closedAt)
 	return err
 }
 
-func (r *Repo) CloseLastReception(ctx context.Context, pvzID string) (*model.Reception, error) {
-	rec, err := r.getActiveReception(ctx, pvzID)
+// ReopenLastReception переоткрывает последнюю приёмку ПВЗ, если она закрыта
+// не раньше closedAfter и после неё не создавалось новых приёмок.
+// ifVersion > 0 - переоткрыть, только если версия приёмки всё ещё такая.
+func (r *Repo) ReopenLastReception(ctx context.Context, pvzID string, ifVersion int, closedAfter time.Time, reopen *model.ReceptionReopen) (*model.Reception, error) {
+	var rec *model.Reception
+	err := r.inTx(ctx, func(tx *Repo) error {
+		var err error
+		rec, err = tx.reopenLastReception(ctx, pvzID, ifVersion, closedAfter, reopen)
+		return err
+	})
 	if err != nil {
 		return nil, err
 	}
-	if rec == nil {
-		return nil, fmt.Errorf("no active reception found")
+	return rec, nil
+}
+
+func (r *Repo) reopenLastReception(ctx context.Context, pvzID string, ifVersion int, closedAfter time.Time, reopen *model.ReceptionReopen) (*model.Reception, error) {
+	q, args, err := sq.Select(receptionColumns...).
+		From("receptions").
+		Where(sq.Eq{"pvz_id": pvzID}).
+		OrderBy("date_time DESC").
+		Limit(1).
+		Suffix("FOR UPDATE").
+		PlaceholderFormat(sq.Dollar).
+		ToSql()
+	if err != nil {
+		return nil, err
+	}
+
+	var rec model.Reception
+	if err := r.db.GetContext(ctx, &rec, q, args...); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("%w for pvz %s", ErrReceptionNotFound, pvzID)
+		}
+		return nil, err
+	}
+	if err := checkVersion(rec.Version, ifVersion); err != nil {
+		return nil, err
+	}
+	if rec.Status != "close" {
+		return nil, ErrReceptionNotClosed
+	}
+	if rec.ClosedAt == nil || rec.ClosedAt.Before(closedAfter) {
+		return nil, ErrReopenExpired
 	}
 
 	qUp, argsUp, err := sq.Update("receptions").
-		Set("status", "close").
+		Set("status", "in_progress").
+		Set("closed_at", nil).
+		Set("version", sq.Expr("version + 1")).
 		Where(sq.Eq{"id": rec.ID}).
 		PlaceholderFormat(sq.Dollar).
 		ToSql()
 	if err != nil {
 		return nil, err
 	}
-	_, err = r.db.ExecContext(ctx, qUp, argsUp...)
+	if _, err := r.db.ExecContext(ctx, qUp, argsUp...); err != nil {
+		if isOpenReceptionConflict(err) {
+			return nil, ErrReceptionAlreadyOpen
+		}
+		return nil, err
+	}
+	if err := r.dropClosingReports(ctx, rec.ID); err != nil {
+		return nil, err
+	}
+
+	reopen.ReceptionID = rec.ID
+	qIns, argsIns, err := sq.Insert("reception_reopens").
+		Columns("id", "reception_id", "reason", "reopened_by", "approval_id", "created_at").
+		Values(reopen.ID, reopen.ReceptionID, reopen.Reason, reopen.ReopenedBy, nullString(reopen.ApprovalID), reopen.CreatedAt).
+		PlaceholderFormat(sq.Dollar).
+		ToSql()
 	if err != nil {
 		return nil, err
 	}
-	rec.Status = "close"
-	return rec, nil
+	if _, err := r.db.ExecContext(ctx, qIns, argsIns...); err != nil {
+		if pqErr, ok := pgError(err, pgUniqueViolation); ok && pqErr.Constraint == reopenApprovalIndex {
+			return nil, ErrApprovalUsed
+		}
+		return nil, err
+	}
+
+	rec.Status = "in_progress"
+	rec.ClosedAt = nil
+	rec.Version++
+	return &rec, nil
 }
 
+// getActiveReception находит открытую приёмку ПВЗ и блокирует её строку (FOR UPDATE).
+// Вызывать внутри транзакции, иначе блокировка снимается сразу.
 func (r *Repo) getActiveReception(ctx context.Context, pvzID string) (*model.Reception, error) {
-	q := sq.Select("id", "pvz_id", "date_time", "status").
+	q := sq.Select(receptionColumns...).
 		From("receptions").
 		Where(sq.Eq{"pvz_id": pvzID, "status": "in_progress"}).
 		OrderBy("date_time DESC").
 		Limit(1).
+		Suffix("FOR UPDATE").
 		PlaceholderFormat(sq.Dollar)
 
 	sqlStr, args, err := q.ToSql()
@@ -243,7 +636,7 @@ func (r *Repo) getActiveReception(ctx context.Context, pvzID string) (*model.Rec
 
 	var rec model.Reception
 	if err := r.db.GetContext(ctx, &rec, sqlStr, args...); err != nil {
-		if isNoRowsErr(err) {
+		if errors.Is(err, sql.ErrNoRows) {
 			return nil, nil
 		}
 		return nil, err
@@ -251,37 +644,110 @@ func (r *Repo) getActiveReception(ctx context.Context, pvzID string) (*model.Rec
 	return &rec, nil
 }
 
-func (r *Repo) getReceptions(ctx context.Context, pvzID string, startDate, endDate *time.Time) ([]*model.Reception, error) {
-	q := sq.Select("id", "pvz_id", "date_time", "status").
+// lockOpenReception блокирует приёмку по id, если она всё ещё открыта
+func (r *Repo) lockOpenReception(ctx context.Context, receptionID string) (*model.Reception, error) {
+	q, args, err := sq.Select(receptionColumns...).
 		From("receptions").
-		Where(sq.Eq{"pvz_id": pvzID}).
-		PlaceholderFormat(sq.Dollar)
+		Where(sq.Eq{"id": receptionID, "status": "in_progress"}).
+		Suffix("FOR UPDATE").
+		PlaceholderFormat(sq.Dollar).
+		ToSql()
+	if err != nil {
+		return nil, err
+	}
 
+	var rec model.Reception
+	if err := r.db.GetContext(ctx, &rec, q, args...); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &rec, nil
+}
+
+// receptionColumns - колонки приёмки для выборок в model.Reception
+var receptionColumns = []string{"id", "pvz_id", "date_time", "status", "closed_at", "version"}
+
+// checkVersion сверяет версию объекта с ожидаемой из If-Match; want == 0 - без проверки
+func checkVersion(have, want int) error {
+	if want != 0 && have != want {
+		return ErrVersionMismatch
+	}
+	return nil
+}
+
+// receptionDateRange ограничивает выборку приёмок r диапазоном дат (границы включительно)
+func receptionDateRange(q sq.SelectBuilder, startDate, endDate *time.Time) sq.SelectBuilder {
 	if startDate != nil {
-		q = q.Where(sq.GtOrEq{"date_time": *startDate})
+		q = q.Where(sq.GtOrEq{"r.date_time": *startDate})
 	}
 	if endDate != nil {
-		q = q.Where(sq.LtOrEq{"date_time": *endDate})
+		q = q.Where(sq.LtOrEq{"r.date_time": *endDate})
 	}
-	q = q.OrderBy("date_time DESC")
+	return q
+}
+
+// getReceptions - приёмки нескольких ПВЗ (с итогами), новые первыми
+func (r *Repo) getReceptions(ctx context.Context, pvzIDs []string, startDate, endDate *time.Time) ([]*model.Reception, error) {
+	if len(pvzIDs) == 0 {
+		return nil, nil
+	}
+	q := sq.Select(append([]string{"r.id", "r.pvz_id", "r.date_time", "r.status", "r.version"}, summaryColumns...)...).
+		From("receptions r").
+		LeftJoin("reception_summaries s ON s.reception_id = r.id").
+		Where("r.pvz_id = ANY(?)", pq.Array(pvzIDs)).
+		OrderBy("r.date_time DESC").
+		PlaceholderFormat(sq.Dollar)
+	q = receptionDateRange(q, startDate, endDate)
 
 	sqlRec, argsRec, err := q.ToSql()
 	if err != nil {
 		return nil, err
 	}
-	var recs []*model.Reception
-	if err := r.db.SelectContext(ctx, &recs, sqlRec, argsRec...); err != nil {
+	var rows []struct {
+		model.Reception
+		summaryRow
+	}
+	if err := r.db.SelectContext(ctx, &rows, sqlRec, argsRec...); err != nil {
 		return nil, err
 	}
+
+	recs := make([]*model.Reception, 0, len(rows))
+	for i := range rows {
+		rec := rows[i].Reception
+		if rec.Summary, err = rows[i].summaryRow.toModel(); err != nil {
+			return nil, err
+		}
+		recs = append(recs, &rec)
+	}
 	return recs, nil
 }
 
-func (r *Repo) getProducts(ctx context.Context, receptionID string) ([]*model.Product, error) {
-	q := sq.Select("id", "reception_id", "date_time", "type").
+// productColumns - колонки товара для выборок в model.Product
+var productColumns = []string{
+	"id", "reception_id", "date_time", "type", "quantity",
+	"COALESCE(barcode, '') AS barcode", "COALESCE(serial_number, '') AS serial_number",
+	"status", "stored_at", "issued_at", "returned_at", "COALESCE(cell_id::text, '') AS cell_id",
+	"deleted_at", "COALESCE(deleted_by, '') AS deleted_by",
+}
+
+// getProducts - товары нескольких приёмок; status != "" оставляет только товары в этом статусе
+func (r *Repo) getProducts(ctx context.Context, receptionIDs []string, includeDeleted bool, status string) ([]*model.Product, error) {
+	if len(receptionIDs) == 0 {
+		return nil, nil
+	}
+	q := sq.Select(productColumns...).
 		From("products").
-		Where(sq.Eq{"reception_id": receptionID}).
-		OrderBy("date_time DESC").
+		Where("reception_id = ANY(?)", pq.Array(receptionIDs)).
+		OrderBy("date_time DESC", "seq DESC").
 		PlaceholderFormat(sq.Dollar)
+	if !includeDeleted {
+		q = q.Where(sq.Eq{"deleted_at": nil})
+	}
+	if status != "" {
+		q = q.Where(sq.Eq{"status": status})
+	}
 
 	sqlProd, argsProd, err := q.ToSql()
 	if err != nil {
@@ -298,17 +764,31 @@ func convertProducts(ps []*model.Product) []model.ProductResponse {
 	result := make([]model.ProductResponse, 0, len(ps))
 	for _, p := range ps {
 		result = append(result, model.ProductResponse{
-			ID:          p.ID,
-			DateTime:    p.DateTime,
-			Type:        p.Type,
-			ReceptionID: p.ReceptionID,
+			ID:           p.ID,
+			DateTime:     p.DateTime,
+			Type:         p.Type,
+			Quantity:     p.Quantity,
+			ReceptionID:  p.ReceptionID,
+			Barcode:      p.Barcode,
+			SerialNumber: p.SerialNumber,
+			CellID:       p.CellID,
+			Status:       p.Status,
+			StoredAt:     p.StoredAt,
+			IssuedAt:     p.IssuedAt,
+			ReturnedAt:   p.ReturnedAt,
+			DeletedAt:    p.DeletedAt,
+			DeletedBy:    p.DeletedBy,
 		})
 	}
 	return result
 }
 
-func isNoRowsErr(err error) bool {
-	return err != nil && strings.Contains(err.Error(), "no rows in result set")
+// nullString превращает пустую строку в NULL
+func nullString(s string) any {
+	if s == "" {
+		return nil
+	}
+	return s
 }
 
 func (r *Repo) CreateUser(ctx context.Context, u *model.User) error {
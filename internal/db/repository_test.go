@@ -2,6 +2,8 @@ package db_test
 
 import (
 	"context"
+	"database/sql"
+	"database/sql/driver"
 	"testing"
 	"time"
 
@@ -9,6 +11,7 @@ import (
 	"github.com/51mans0n/avito-pvz-task/internal/model"
 	"github.com/DATA-DOG/go-sqlmock"
 	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
 	"github.com/stretchr/testify/require"
 )
 
@@ -59,13 +62,11 @@ func TestRepo_CreateReception_Success(t *testing.T) {
 	xdb := sqlx.NewDb(sqlDB, "postgres")
 	repo := db.NewRepo(xdb)
 
-	mock.ExpectQuery(`SELECT count\(\*\) FROM receptions`).
-		WithArgs("82cc7cda-bd24-468f-b7b7-844d66b6693c", "in_progress").
-		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
-
+	mock.ExpectBegin()
 	mock.ExpectExec(`INSERT INTO receptions \(id,pvz_id,date_time,status\)`).
 		WithArgs("rec-111", "82cc7cda-bd24-468f-b7b7-844d66b6693c", sqlmock.AnyArg(), "in_progress").
 		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
 
 	rec := &model.Reception{
 		ID:       "rec-111",
@@ -86,9 +87,11 @@ func TestRepo_CreateReception_AlreadyOpen(t *testing.T) {
 	xdb := sqlx.NewDb(sqlDB, "postgres")
 	repo := db.NewRepo(xdb)
 
-	mock.ExpectQuery(`SELECT count\(\*\) FROM receptions`).
-		WithArgs("82cc7cda-bd24-468f-b7b7-844d66b6693c", "in_progress").
-		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+	mock.ExpectBegin()
+	mock.ExpectExec(`INSERT INTO receptions`).
+		WithArgs("rec-222", "82cc7cda-bd24-468f-b7b7-844d66b6693c", sqlmock.AnyArg(), "in_progress").
+		WillReturnError(&pq.Error{Code: "23505", Constraint: "receptions_one_open_per_pvz"})
+	mock.ExpectRollback()
 
 	rec := &model.Reception{
 		ID:     "rec-222",
@@ -97,8 +100,29 @@ func TestRepo_CreateReception_AlreadyOpen(t *testing.T) {
 	}
 
 	err = repo.CreateReception(context.Background(), rec)
-	require.Error(t, err)
-	require.Contains(t, err.Error(), "already an open reception")
+	require.ErrorIs(t, err, db.ErrReceptionAlreadyOpen)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRepo_CreateReception_UnknownPVZ(t *testing.T) {
+	sqlDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer sqlDB.Close()
+
+	xdb := sqlx.NewDb(sqlDB, "postgres")
+	repo := db.NewRepo(xdb)
+
+	mock.ExpectBegin()
+	mock.ExpectExec(`INSERT INTO receptions`).
+		WillReturnError(&pq.Error{Code: "23503", Constraint: "fk_pvz"})
+	mock.ExpectRollback()
+
+	err = repo.CreateReception(context.Background(), &model.Reception{
+		ID:     "rec-333",
+		PVZID:  "82cc7cda-bd24-468f-b7b7-844d66b6693c",
+		Status: "in_progress",
+	})
+	require.ErrorIs(t, err, db.ErrPVZNotFound)
 	require.NoError(t, mock.ExpectationsWereMet())
 }
 
@@ -109,19 +133,26 @@ func TestRepo_CreateProduct_Success(t *testing.T) {
 	xdb := sqlx.NewDb(sqlDB, "postgres")
 	repo := db.NewRepo(xdb)
 
-	mock.ExpectQuery(`SELECT id, pvz_id, date_time, status FROM receptions`).
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT id, pvz_id, date_time, status, closed_at, version FROM receptions`).
 		WithArgs("82cc7cda-bd24-468f-b7b7-844d66b6693c", "in_progress").
 		WillReturnRows(sqlmock.NewRows([]string{"id", "pvz_id", "date_time", "status"}).
 			AddRow("rec-active", "82cc7cda-bd24-468f-b7b7-844d66b6693c", time.Now(), "in_progress"))
+	expectProductType(mock, "электроника", true, true)
 
-	mock.ExpectExec(`INSERT INTO products \(id,reception_id,date_time,type\)`).
-		WithArgs("prod-xyz", "rec-active", sqlmock.AnyArg(), "электроника").
+	expectStorageCells(mock, nil)
+	mock.ExpectExec(`INSERT INTO products \(id,reception_id,date_time,type,quantity,barcode,serial_number,cell_id\)`).
+		WithArgs("prod-xyz", "rec-active", sqlmock.AnyArg(), "электроника", 1, nil, "SN-1", nil).
 		WillReturnResult(sqlmock.NewResult(1, 1))
+	expectTouchReceptions(mock, "rec-active")
+
+	mock.ExpectCommit()
 
 	err = repo.CreateProduct(context.Background(), "82cc7cda-bd24-468f-b7b7-844d66b6693c", &model.Product{
-		ID:       "prod-xyz",
-		Type:     "электроника",
-		DateTime: time.Now(),
+		ID:           "prod-xyz",
+		Type:         "электроника",
+		DateTime:     time.Now(),
+		SerialNumber: "SN-1",
 	})
 	require.NoError(t, err)
 	require.NoError(t, mock.ExpectationsWereMet())
@@ -134,17 +165,19 @@ func TestRepo_CreateProduct_NoActiveReception(t *testing.T) {
 	xdb := sqlx.NewDb(sqlDB, "postgres")
 	repo := db.NewRepo(xdb)
 
-	mock.ExpectQuery(`SELECT id, pvz_id, date_time, status FROM receptions`).
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT id, pvz_id, date_time, status, closed_at, version FROM receptions`).
 		WithArgs("82cc7cda-bd24-468f-b7b7-844d66b6693c", "in_progress").
 		WillReturnRows(sqlmock.NewRows([]string{"id", "pvz_id", "date_time", "status"})) // empty
 
+	mock.ExpectRollback()
+
 	err = repo.CreateProduct(context.Background(), "82cc7cda-bd24-468f-b7b7-844d66b6693c", &model.Product{
 		ID:       "prod-abc",
 		Type:     "обувь",
 		DateTime: time.Now(),
 	})
-	require.Error(t, err)
-	require.Contains(t, err.Error(), "no active reception found")
+	require.ErrorIs(t, err, db.ErrNoActiveReception)
 
 	require.NoError(t, mock.ExpectationsWereMet())
 }
@@ -156,21 +189,55 @@ func TestRepo_DeleteLastProduct_Success(t *testing.T) {
 	xdb := sqlx.NewDb(sqlDB, "postgres")
 	repo := db.NewRepo(xdb)
 
-	mock.ExpectQuery(`SELECT id, pvz_id, date_time, status FROM receptions`).
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT id, pvz_id, date_time, status, closed_at, version FROM receptions`).
 		WithArgs("82cc7cda-bd24-468f-b7b7-844d66b6693c", "in_progress").
 		WillReturnRows(sqlmock.NewRows([]string{"id", "pvz_id", "date_time", "status"}).
 			AddRow("rec-xxx", "82cc7cda-bd24-468f-b7b7-844d66b6693c", time.Now(), "in_progress"))
 
-	mock.ExpectQuery(`SELECT id FROM products`).
+	mock.ExpectQuery(`SELECT id, quantity FROM products WHERE deleted_at IS NULL AND reception_id = \$1 ORDER BY date_time DESC, seq DESC LIMIT 1`).
 		WithArgs("rec-xxx").
-		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow("prod-latest"))
+		WillReturnRows(sqlmock.NewRows([]string{"id", "quantity"}).AddRow("prod-latest", 1))
+
+	mock.ExpectExec(`UPDATE products SET deleted_at = \$1, deleted_by = \$2 WHERE deleted_at IS NULL AND id = \$3`).
+		WithArgs(sqlmock.AnyArg(), "employee", "prod-latest").
+		WillReturnResult(sqlmock.NewResult(0, 1))
 
-	mock.ExpectExec(`DELETE FROM products`).
-		WithArgs("prod-latest").
+	mock.ExpectExec(`UPDATE receptions SET lifo_deletions = lifo_deletions \+ 1, version = version \+ 1 WHERE id = \$1`).
+		WithArgs("rec-xxx").
 		WillReturnResult(sqlmock.NewResult(0, 1))
 
-	err = repo.DeleteLastProduct(context.Background(), "82cc7cda-bd24-468f-b7b7-844d66b6693c")
+	mock.ExpectCommit()
+
+	err = repo.DeleteLastProduct(context.Background(), "82cc7cda-bd24-468f-b7b7-844d66b6693c", "employee")
+	require.NoError(t, err)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRepo_DeleteLastProduct_DecrementsQuantity(t *testing.T) {
+	sqlDB, mock, err := sqlmock.New()
 	require.NoError(t, err)
+	defer sqlDB.Close()
+	repo := db.NewRepo(sqlx.NewDb(sqlDB, "postgres"))
+
+	mock.ExpectBegin()
+	expectActiveReception(mock, "pvz-1", "rec-1")
+	mock.ExpectQuery(`SELECT id, quantity FROM products`).
+		WithArgs("rec-1").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "quantity"}).AddRow("prod-box", 200))
+	// строка остаётся, убирается одна единица
+	mock.ExpectExec(`UPDATE products SET quantity = quantity - 1 WHERE id = \$1`).
+		WithArgs("prod-box").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`INSERT INTO product_unit_removals \(product_id,reception_id,removed_by,removed_at\)`).
+		WithArgs("prod-box", "rec-1", "employee", sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec(`UPDATE receptions SET lifo_deletions = lifo_deletions \+ 1, version = version \+ 1 WHERE id = \$1`).
+		WithArgs("rec-1").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	require.NoError(t, repo.DeleteLastProduct(context.Background(), "pvz-1", "employee"))
 	require.NoError(t, mock.ExpectationsWereMet())
 }
 
@@ -181,13 +248,15 @@ func TestRepo_DeleteLastProduct_NoActiveReception(t *testing.T) {
 	xdb := sqlx.NewDb(sqlDB, "postgres")
 	repo := db.NewRepo(xdb)
 
-	mock.ExpectQuery(`SELECT id, pvz_id, date_time, status FROM receptions`).
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT id, pvz_id, date_time, status, closed_at, version FROM receptions`).
 		WithArgs("82cc7cda-bd24-468f-b7b7-844d66b6693c", "in_progress").
 		WillReturnRows(sqlmock.NewRows([]string{"id", "pvz_id", "date_time", "status"}))
 
-	err = repo.DeleteLastProduct(context.Background(), "82cc7cda-bd24-468f-b7b7-844d66b6693c")
-	require.Error(t, err)
-	require.Contains(t, err.Error(), "no active reception")
+	mock.ExpectRollback()
+
+	err = repo.DeleteLastProduct(context.Background(), "82cc7cda-bd24-468f-b7b7-844d66b6693c", "employee")
+	require.ErrorIs(t, err, db.ErrNoActiveReception)
 
 	require.NoError(t, mock.ExpectationsWereMet())
 }
@@ -199,18 +268,20 @@ func TestRepo_DeleteLastProduct_NoProducts(t *testing.T) {
 	xdb := sqlx.NewDb(sqlDB, "postgres")
 	repo := db.NewRepo(xdb)
 
-	mock.ExpectQuery(`SELECT id, pvz_id, date_time, status FROM receptions`).
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT id, pvz_id, date_time, status, closed_at, version FROM receptions`).
 		WithArgs("82cc7cda-bd24-468f-b7b7-844d66b6693c", "in_progress").
 		WillReturnRows(sqlmock.NewRows([]string{"id", "pvz_id", "date_time", "status"}).
 			AddRow("rec-abc", "82cc7cda-bd24-468f-b7b7-844d66b6693c", time.Now(), "in_progress"))
 
-	mock.ExpectQuery(`SELECT id FROM products`).
+	mock.ExpectQuery(`SELECT id, quantity FROM products`).
 		WithArgs("rec-abc").
-		WillReturnRows(sqlmock.NewRows([]string{"id"})) // no rows
+		WillReturnRows(sqlmock.NewRows([]string{"id", "quantity"})) // no rows
 
-	err = repo.DeleteLastProduct(context.Background(), "82cc7cda-bd24-468f-b7b7-844d66b6693c")
-	require.Error(t, err)
-	require.Contains(t, err.Error(), "no products to delete")
+	mock.ExpectRollback()
+
+	err = repo.DeleteLastProduct(context.Background(), "82cc7cda-bd24-468f-b7b7-844d66b6693c", "employee")
+	require.ErrorIs(t, err, db.ErrNoProducts)
 
 	require.NoError(t, mock.ExpectationsWereMet())
 }
@@ -222,18 +293,30 @@ func TestRepo_CloseLastReception_Success(t *testing.T) {
 	xdb := sqlx.NewDb(sqlDB, "postgres")
 	repo := db.NewRepo(xdb)
 
-	mock.ExpectQuery(`SELECT id, pvz_id, date_time, status FROM receptions`).
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT id, pvz_id, date_time, status, closed_at, version FROM receptions`).
 		WithArgs("82cc7cda-bd24-468f-b7b7-844d66b6693c", "in_progress").
 		WillReturnRows(sqlmock.NewRows([]string{"id", "pvz_id", "date_time", "status"}).
 			AddRow("rec-xyz", "82cc7cda-bd24-468f-b7b7-844d66b6693c", time.Now(), "in_progress"))
 
-	mock.ExpectExec(`UPDATE receptions SET status = \$1 WHERE id = \$2`).
-		WithArgs("close", "rec-xyz").
+	mock.ExpectExec(`UPDATE receptions SET status = \$1, closed_at = \$2, closed_by = \$3, close_reason = \$4, version = version \+ 1 WHERE id = \$5`).
+		WithArgs("close", sqlmock.AnyArg(), nil, nil, "rec-xyz").
 		WillReturnResult(sqlmock.NewResult(0, 1))
 
-	rec, err := repo.CloseLastReception(context.Background(), "82cc7cda-bd24-468f-b7b7-844d66b6693c")
+	expectCloseSummary(mock, "rec-xyz", sqlmock.NewRows([]string{"type", "cnt"}))
+	mock.ExpectQuery(`SELECT type, expected_count, barcodes FROM reception_manifest_items`).
+		WithArgs("rec-xyz").
+		WillReturnRows(sqlmock.NewRows([]string{"type", "expected_count", "barcodes"}))
+
+	mock.ExpectCommit()
+
+	rec, err := repo.CloseLastReception(context.Background(), "82cc7cda-bd24-468f-b7b7-844d66b6693c", 0)
 	require.NoError(t, err)
 	require.Equal(t, "close", rec.Status)
+	require.NotNil(t, rec.ClosedAt)
+	require.Nil(t, rec.Discrepancy)
+	require.NotNil(t, rec.Summary)
+	require.Equal(t, 0, rec.Summary.TotalProducts)
 	require.NoError(t, mock.ExpectationsWereMet())
 }
 
@@ -244,14 +327,1421 @@ func TestRepo_CloseLastReception_NoActive(t *testing.T) {
 	xdb := sqlx.NewDb(sqlDB, "postgres")
 	repo := db.NewRepo(xdb)
 
-	mock.ExpectQuery(`SELECT id, pvz_id, date_time, status FROM receptions`).
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT id, pvz_id, date_time, status, closed_at, version FROM receptions`).
 		WithArgs("82cc7cda-bd24-468f-b7b7-844d66b6693c", "in_progress").
 		WillReturnRows(sqlmock.NewRows([]string{"id", "pvz_id", "date_time", "status"}))
 
-	rc, err := repo.CloseLastReception(context.Background(), "82cc7cda-bd24-468f-b7b7-844d66b6693c")
+	mock.ExpectRollback()
+
+	rc, err := repo.CloseLastReception(context.Background(), "82cc7cda-bd24-468f-b7b7-844d66b6693c", 0)
 	require.Nil(t, rc)
-	require.Error(t, err)
-	require.Contains(t, err.Error(), "no active reception")
+	require.ErrorIs(t, err, db.ErrNoActiveReception)
+
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRepo_CloseLastReception_VersionMismatch(t *testing.T) {
+	sqlDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer sqlDB.Close()
+	xdb := sqlx.NewDb(sqlDB, "postgres")
+	repo := db.NewRepo(xdb)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT id, pvz_id, date_time, status, closed_at, version FROM receptions`).
+		WithArgs("82cc7cda-bd24-468f-b7b7-844d66b6693c", "in_progress").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "pvz_id", "date_time", "status", "version"}).
+			AddRow("rec-xyz", "82cc7cda-bd24-468f-b7b7-844d66b6693c", time.Now(), "in_progress", 3))
+
+	mock.ExpectRollback()
+
+	rc, err := repo.CloseLastReception(context.Background(), "82cc7cda-bd24-468f-b7b7-844d66b6693c", 2)
+	require.Nil(t, rc)
+	require.ErrorIs(t, err, db.ErrVersionMismatch)
+
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRepo_GetReception(t *testing.T) {
+	sqlDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer sqlDB.Close()
+	repo := db.NewRepo(sqlx.NewDb(sqlDB, "postgres"))
+
+	mock.ExpectQuery(`SELECT id, pvz_id, date_time, status, closed_at, version FROM receptions WHERE id = \$1`).
+		WithArgs("rec-xyz").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "pvz_id", "date_time", "status", "closed_at", "version"}).
+			AddRow("rec-xyz", "pvz-1", time.Now(), "close", time.Now(), 4))
+	rec, err := repo.GetReception(context.Background(), "rec-xyz")
+	require.NoError(t, err)
+	require.Equal(t, 4, rec.Version)
+
+	mock.ExpectQuery(`FROM receptions WHERE id = \$1`).
+		WithArgs("rec-none").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	_, err = repo.GetReception(context.Background(), "rec-none")
+	require.ErrorIs(t, err, db.ErrReceptionNotFound)
+
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRepo_ReopenLastReception_Success(t *testing.T) {
+	sqlDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer sqlDB.Close()
+	xdb := sqlx.NewDb(sqlDB, "postgres")
+	repo := db.NewRepo(xdb)
+
+	closedAt := time.Now().Add(-5 * time.Minute)
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT id, pvz_id, date_time, status, closed_at, version FROM receptions`).
+		WithArgs("82cc7cda-bd24-468f-b7b7-844d66b6693c").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "pvz_id", "date_time", "status", "closed_at"}).
+			AddRow("rec-xyz", "82cc7cda-bd24-468f-b7b7-844d66b6693c", time.Now().Add(-time.Hour), "close", closedAt))
+
+	mock.ExpectExec(`UPDATE receptions SET status = \$1, closed_at = \$2, version = version \+ 1 WHERE id = \$3`).
+		WithArgs("in_progress", nil, "rec-xyz").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`DELETE FROM reception_summaries WHERE reception_id = \$1`).
+		WithArgs("rec-xyz").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`DELETE FROM reception_discrepancies WHERE reception_id = \$1`).
+		WithArgs("rec-xyz").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	mock.ExpectExec(`INSERT INTO reception_reopens`).
+		WithArgs("reopen-1", "rec-xyz", "one more box", "employee", "approval-1", sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+
+	mock.ExpectCommit()
+
+	rec, err := repo.ReopenLastReception(context.Background(), "82cc7cda-bd24-468f-b7b7-844d66b6693c", 0,
+		time.Now().Add(-15*time.Minute), &model.ReceptionReopen{
+			ID:         "reopen-1",
+			Reason:     "one more box",
+			ReopenedBy: "employee",
+			ApprovalID: "approval-1",
+			CreatedAt:  time.Now(),
+		})
+	require.NoError(t, err)
+	require.Equal(t, "in_progress", rec.Status)
+	require.Nil(t, rec.ClosedAt)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRepo_ReopenLastReception_ApprovalUsed(t *testing.T) {
+	sqlDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer sqlDB.Close()
+	repo := db.NewRepo(sqlx.NewDb(sqlDB, "postgres"))
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT id, pvz_id, date_time, status, closed_at, version FROM receptions`).
+		WithArgs("82cc7cda-bd24-468f-b7b7-844d66b6693c").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "pvz_id", "date_time", "status", "closed_at"}).
+			AddRow("rec-xyz", "82cc7cda-bd24-468f-b7b7-844d66b6693c", time.Now().Add(-time.Hour), "close", time.Now()))
+	mock.ExpectExec(`UPDATE receptions SET status`).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`DELETE FROM reception_summaries`).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`DELETE FROM reception_discrepancies`).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`INSERT INTO reception_reopens`).
+		WillReturnError(&pq.Error{Code: "23505", Constraint: "reception_reopens_approval_id"})
+	mock.ExpectRollback()
+
+	_, err = repo.ReopenLastReception(context.Background(), "82cc7cda-bd24-468f-b7b7-844d66b6693c", 0,
+		time.Now().Add(-15*time.Minute), &model.ReceptionReopen{ID: "reopen-2", Reason: "again", ApprovalID: "approval-1"})
+	require.ErrorIs(t, err, db.ErrApprovalUsed)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRepo_ReopenLastReception_WindowExpired(t *testing.T) {
+	sqlDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer sqlDB.Close()
+	xdb := sqlx.NewDb(sqlDB, "postgres")
+	repo := db.NewRepo(xdb)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT id, pvz_id, date_time, status, closed_at, version FROM receptions`).
+		WithArgs("82cc7cda-bd24-468f-b7b7-844d66b6693c").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "pvz_id", "date_time", "status", "closed_at"}).
+			AddRow("rec-xyz", "82cc7cda-bd24-468f-b7b7-844d66b6693c", time.Now().Add(-3*time.Hour), "close", time.Now().Add(-2*time.Hour)))
+
+	mock.ExpectRollback()
+
+	rec, err := repo.ReopenLastReception(context.Background(), "82cc7cda-bd24-468f-b7b7-844d66b6693c", 0,
+		time.Now().Add(-15*time.Minute), &model.ReceptionReopen{ID: "reopen-1", Reason: "late box"})
+	require.Nil(t, rec)
+	require.ErrorIs(t, err, db.ErrReopenExpired)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRepo_ReopenLastReception_NewerReceptionOpen(t *testing.T) {
+	sqlDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer sqlDB.Close()
+	xdb := sqlx.NewDb(sqlDB, "postgres")
+	repo := db.NewRepo(xdb)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT id, pvz_id, date_time, status, closed_at, version FROM receptions`).
+		WithArgs("82cc7cda-bd24-468f-b7b7-844d66b6693c").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "pvz_id", "date_time", "status", "closed_at"}).
+			AddRow("rec-new", "82cc7cda-bd24-468f-b7b7-844d66b6693c", time.Now(), "in_progress", nil))
+
+	mock.ExpectRollback()
+
+	_, err = repo.ReopenLastReception(context.Background(), "82cc7cda-bd24-468f-b7b7-844d66b6693c", 0,
+		time.Now().Add(-15*time.Minute), &model.ReceptionReopen{ID: "reopen-1", Reason: "late box"})
+	require.ErrorIs(t, err, db.ErrReceptionNotClosed)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRepo_CreateReception_WithManifest(t *testing.T) {
+	sqlDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer sqlDB.Close()
+	xdb := sqlx.NewDb(sqlDB, "postgres")
+	repo := db.NewRepo(xdb)
+
+	mock.ExpectBegin()
+	mock.ExpectExec(`INSERT INTO receptions`).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec(`INSERT INTO reception_manifest_items \(reception_id,type,expected_count,barcodes\) VALUES \(\$1,\$2,\$3,\$4\),\(\$5,\$6,\$7,\$8\)`).
+		WithArgs("rec-111", "обувь", 2, sqlmock.AnyArg(), "rec-111", "одежда", 1, sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(2, 2))
+	mock.ExpectCommit()
+
+	err = repo.CreateReception(context.Background(), &model.Reception{
+		ID:     "rec-111",
+		PVZID:  "82cc7cda-bd24-468f-b7b7-844d66b6693c",
+		Status: "in_progress",
+		Manifest: []model.ManifestItem{
+			{Type: "обувь", Count: 2},
+			{Type: "одежда", Count: 1, Barcodes: []string{"4600000000017"}},
+		},
+	})
+	require.NoError(t, err)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRepo_CloseLastReception_DiscrepancyReport(t *testing.T) {
+	sqlDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer sqlDB.Close()
+	xdb := sqlx.NewDb(sqlDB, "postgres")
+	repo := db.NewRepo(xdb)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT id, pvz_id, date_time, status, closed_at, version FROM receptions`).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "pvz_id", "date_time", "status"}).
+			AddRow("rec-xyz", "82cc7cda-bd24-468f-b7b7-844d66b6693c", time.Now(), "in_progress"))
+	mock.ExpectExec(`UPDATE receptions`).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	expectCloseSummary(mock, "rec-xyz", sqlmock.NewRows([]string{"type", "cnt"}).
+		AddRow("обувь", 1).
+		AddRow("электроника", 2))
+	mock.ExpectQuery(`SELECT type, expected_count, barcodes FROM reception_manifest_items`).
+		WithArgs("rec-xyz").
+		WillReturnRows(sqlmock.NewRows([]string{"type", "expected_count", "barcodes"}).
+			AddRow("обувь", 3, "{}").
+			AddRow("одежда", 1, "{}"))
+	mock.ExpectExec(`DELETE FROM reception_discrepancies`).
+		WithArgs("rec-xyz").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`INSERT INTO reception_discrepancies`).
+		WillReturnResult(sqlmock.NewResult(3, 3))
+	mock.ExpectCommit()
+
+	rec, err := repo.CloseLastReception(context.Background(), "82cc7cda-bd24-468f-b7b7-844d66b6693c", 0)
+	require.NoError(t, err)
+	require.NotNil(t, rec.Discrepancy)
+	require.Equal(t, []model.DiscrepancyLine{
+		{Type: "обувь", Expected: 3, Received: 1, Missing: 2},
+		{Type: "одежда", Expected: 1, Received: 0, Missing: 1},
+		{Type: "электроника", Expected: 0, Received: 2, Surplus: 2},
+	}, rec.Discrepancy.Lines)
+	require.Equal(t, 3, rec.Discrepancy.TotalMissing)
+	require.Equal(t, 2, rec.Discrepancy.TotalSurplus)
+	require.Equal(t, 3, rec.Summary.TotalProducts)
+	require.Equal(t, map[string]int{"обувь": 1, "электроника": 2}, rec.Summary.CountsByType)
+	require.Equal(t, 1, rec.Summary.LIFODeletions)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRepo_GetDiscrepancyReport(t *testing.T) {
+	sqlDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer sqlDB.Close()
+	xdb := sqlx.NewDb(sqlDB, "postgres")
+	repo := db.NewRepo(xdb)
+
+	mock.ExpectQuery(`SELECT type, expected, received, missing, surplus, missing_barcodes, unexpected_barcodes, created_at FROM reception_discrepancies`).
+		WithArgs("rec-xyz").
+		WillReturnRows(sqlmock.NewRows([]string{"type", "expected", "received", "missing", "surplus",
+			"missing_barcodes", "unexpected_barcodes", "created_at"}).
+			AddRow("обувь", 3, 1, 2, 0, "{A2,A3}", "{}", time.Now()).
+			AddRow("электроника", 0, 2, 0, 2, "{}", "{}", time.Now()))
+
+	report, err := repo.GetDiscrepancyReport(context.Background(), "rec-xyz")
+	require.NoError(t, err)
+	require.Len(t, report.Lines, 2)
+	require.Equal(t, []string{"A2", "A3"}, report.Lines[0].MissingBarcodes)
+	require.Nil(t, report.Lines[1].MissingBarcodes)
+	require.Equal(t, 2, report.TotalMissing)
+	require.Equal(t, 2, report.TotalSurplus)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRepo_CloseStaleReceptions(t *testing.T) {
+	sqlDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer sqlDB.Close()
+	xdb := sqlx.NewDb(sqlDB, "postgres")
+	repo := db.NewRepo(xdb)
+
+	openedBefore := time.Now().Add(-12 * time.Hour)
+	mock.ExpectQuery(`SELECT id FROM receptions WHERE status = \$1 AND date_time < \$2 ORDER BY date_time`).
+		WithArgs("in_progress", openedBefore).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow("rec-old").AddRow("rec-raced"))
+
+	// первая приёмка всё ещё открыта - закрываем
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT id, pvz_id, date_time, status, closed_at, version FROM receptions WHERE id = \$1 AND status = \$2 FOR UPDATE`).
+		WithArgs("rec-old", "in_progress").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "pvz_id", "date_time", "status"}).
+			AddRow("rec-old", "pvz-1", time.Now().Add(-20*time.Hour), "in_progress"))
+	mock.ExpectExec(`UPDATE receptions SET status = \$1, closed_at = \$2, closed_by = \$3, close_reason = \$4, version = version \+ 1 WHERE id = \$5`).
+		WithArgs("close", sqlmock.AnyArg(), "system", "stale", "rec-old").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	expectCloseSummary(mock, "rec-old", sqlmock.NewRows([]string{"type", "cnt"}))
+	mock.ExpectQuery(`SELECT type, expected_count, barcodes FROM reception_manifest_items`).
+		WillReturnRows(sqlmock.NewRows([]string{"type", "expected_count", "barcodes"}))
+	mock.ExpectCommit()
+
+	// вторую успели закрыть вручную - пропускаем
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT id, pvz_id, date_time, status, closed_at, version FROM receptions WHERE id = \$1`).
+		WithArgs("rec-raced", "in_progress").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "pvz_id", "date_time", "status"}))
+	mock.ExpectCommit()
+
+	closed, err := repo.CloseStaleReceptions(context.Background(), openedBefore, "system", "stale")
+	require.NoError(t, err)
+	require.Len(t, closed, 1)
+	require.Equal(t, "rec-old", closed[0].ID)
+	require.Equal(t, "system", closed[0].ClosedBy)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+// expectCloseSummary - запросы, которые closeReception делает для итога приёмки
+func expectCloseSummary(mock sqlmock.Sqlmock, receptionID string, counts *sqlmock.Rows) {
+	mock.ExpectQuery(`SELECT type, sum\(quantity\) AS cnt FROM products`).
+		WithArgs(receptionID).
+		WillReturnRows(counts)
+	mock.ExpectQuery(`SELECT r.lifo_deletions, min\(p.date_time\) AS first_scan_at, max\(p.date_time\) AS last_scan_at FROM receptions r LEFT JOIN products p`).
+		WithArgs(receptionID).
+		WillReturnRows(sqlmock.NewRows([]string{"lifo_deletions", "first_scan_at", "last_scan_at"}).
+			AddRow(1, time.Now().Add(-time.Hour), time.Now().Add(-time.Minute)))
+	mock.ExpectExec(`INSERT INTO reception_summaries .* ON CONFLICT \(reception_id\) DO UPDATE`).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+}
+
+func TestRepo_GetPVZListWithFilter_ReceptionSummary(t *testing.T) {
+	sqlDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer sqlDB.Close()
+	xdb := sqlx.NewDb(sqlDB, "postgres")
+	repo := db.NewRepo(xdb)
+
+	mock.ExpectQuery(`SELECT id, city, registration_date, version FROM pvz`).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "city", "registration_date"}).
+			AddRow("pvz-1", "Москва", time.Now()).
+			AddRow("pvz-2", "Казань", time.Now()))
+
+	summaryCols := []string{"id", "pvz_id", "date_time", "status",
+		"total_products", "counts_by_type", "first_scan_at", "last_scan_at", "duration_seconds", "summary_lifo_deletions"}
+	mock.ExpectQuery(`SELECT r.id, r.pvz_id, r.date_time, r.status, r.version, s.total_products, .* FROM receptions r LEFT JOIN reception_summaries s ON s.reception_id = r.id WHERE r.pvz_id = ANY\(\$1\)`).
+		WithArgs(pq.Array([]string{"pvz-1", "pvz-2"})).
+		WillReturnRows(sqlmock.NewRows(summaryCols).
+			AddRow("rec-open", "pvz-1", time.Now(), "in_progress", nil, nil, nil, nil, nil, nil).
+			AddRow("rec-closed", "pvz-1", time.Now().Add(-time.Hour), "close", 2, []byte(`{"обувь":2}`), time.Now(), time.Now(), 3600, 1))
+
+	prodCols := []string{"id", "reception_id", "date_time", "type", "barcode", "serial_number", "deleted_at", "deleted_by"}
+	// товары всех приёмок страницы - одним запросом
+	mock.ExpectQuery(`SELECT id, reception_id, date_time, type, .* FROM products WHERE reception_id = ANY\(\$1\) AND deleted_at IS NULL`).
+		WithArgs(pq.Array([]string{"rec-open", "rec-closed"})).
+		WillReturnRows(sqlmock.NewRows(prodCols).
+			AddRow("prod-1", "rec-closed", time.Now(), "обувь", "", "", nil, "").
+			AddRow("prod-2", "rec-closed", time.Now(), "обувь", "", "", nil, ""))
+
+	list, err := repo.GetPVZListWithFilter(context.Background(), db.PVZListFilter{Page: 1, Limit: 10})
+	require.NoError(t, err)
+	require.Len(t, list, 2)
+	require.NotNil(t, list[1].Receptions)
+	require.Empty(t, list[1].Receptions)
+	require.Len(t, list[0].Receptions, 2)
+	require.Nil(t, list[0].Receptions[0].Reception.Summary)
+	require.Empty(t, list[0].Receptions[0].Products)
+	require.Len(t, list[0].Receptions[1].Products, 2)
+
+	sum := list[0].Receptions[1].Reception.Summary
+	require.NotNil(t, sum)
+	require.Equal(t, 2, sum.TotalProducts)
+	require.Equal(t, map[string]int{"обувь": 2}, sum.CountsByType)
+	require.Equal(t, int64(3600), sum.DurationSeconds)
+	require.Equal(t, 1, sum.LIFODeletions)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRepo_CreateAttachment(t *testing.T) {
+	sqlDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer sqlDB.Close()
+	xdb := sqlx.NewDb(sqlDB, "postgres")
+	repo := db.NewRepo(xdb)
+
+	a := &model.Attachment{
+		ID: "att-1", OwnerType: model.AttachmentOwnerProduct, OwnerID: "prod-1",
+		FileName: "photo.jpg", ContentType: "image/jpeg", Size: 10, SHA256: "abc",
+		StorageKey: "product/att-1", UploadedBy: "employee", CreatedAt: time.Now(),
+	}
+
+	mock.ExpectExec(`INSERT INTO attachments .* WHERE EXISTS \(SELECT 1 FROM products WHERE id = \$3 AND deleted_at IS NULL\)`).
+		WithArgs(a.ID, a.OwnerType, a.OwnerID, a.FileName, a.ContentType, a.Size, a.SHA256, a.StorageKey, a.UploadedBy, a.CreatedAt).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	require.NoError(t, repo.CreateAttachment(context.Background(), a))
+
+	// товара нет - вставка не происходит
+	mock.ExpectExec(`INSERT INTO attachments`).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	err = repo.CreateAttachment(context.Background(), a)
+	require.ErrorIs(t, err, db.ErrAttachmentOwnerNotFound)
+
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRepo_GetAttachment_NotFound(t *testing.T) {
+	sqlDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer sqlDB.Close()
+	xdb := sqlx.NewDb(sqlDB, "postgres")
+	repo := db.NewRepo(xdb)
+
+	mock.ExpectQuery(`SELECT id, owner_type, owner_id, .* FROM attachments WHERE id = \$1`).
+		WithArgs("att-404").
+		WillReturnError(sql.ErrNoRows)
+
+	a, err := repo.GetAttachment(context.Background(), "att-404")
+	require.NoError(t, err)
+	require.Nil(t, a)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func expectLockedProduct(mock sqlmock.Sqlmock, status string) {
+	mock.ExpectQuery(`SELECT reception_id, type, quantity FROM products WHERE deleted_at IS NULL AND id = \$1`).
+		WithArgs("prod-1").
+		WillReturnRows(sqlmock.NewRows([]string{"reception_id", "type", "quantity"}).AddRow("rec-xyz", "обувь", 3))
+	mock.ExpectQuery(`SELECT id, pvz_id, date_time, status, closed_at, version FROM receptions WHERE id = \$1 FOR UPDATE`).
+		WithArgs("rec-xyz").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "pvz_id", "date_time", "status", "closed_at"}).
+			AddRow("rec-xyz", "pvz-1", time.Now().Add(-time.Hour), status, nil))
+}
+
+func TestRepo_DeleteProduct_OpenReception(t *testing.T) {
+	sqlDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer sqlDB.Close()
+	xdb := sqlx.NewDb(sqlDB, "postgres")
+	repo := db.NewRepo(xdb)
+
+	del := &model.ProductDeletion{ID: "del-1", ProductID: "prod-1", Reason: "duplicate", DeletedBy: "employee", DeletedAt: time.Now()}
+
+	mock.ExpectBegin()
+	expectLockedProduct(mock, "in_progress")
+	mock.ExpectExec(`UPDATE products SET deleted_at = \$1, deleted_by = \$2 WHERE deleted_at IS NULL AND id = \$3`).
+		WithArgs(del.DeletedAt, "employee", "prod-1").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`INSERT INTO product_deletions`).
+		WithArgs("del-1", "prod-1", "rec-xyz", "обувь", 3, "duplicate", nil, "employee", del.DeletedAt).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	expectTouchReceptions(mock, "rec-xyz")
+	mock.ExpectCommit()
+
+	require.NoError(t, repo.DeleteProduct(context.Background(), del, false))
+	require.Equal(t, "rec-xyz", del.ReceptionID)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRepo_DeleteProduct_ClosedReception(t *testing.T) {
+	sqlDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer sqlDB.Close()
+	xdb := sqlx.NewDb(sqlDB, "postgres")
+	repo := db.NewRepo(xdb)
+
+	del := &model.ProductDeletion{ID: "del-1", ProductID: "prod-1", Reason: "damaged", DeletedBy: "employee", DeletedAt: time.Now()}
+
+	// сотруднику закрытая приёмка недоступна
+	mock.ExpectBegin()
+	expectLockedProduct(mock, "close")
+	mock.ExpectRollback()
+	require.ErrorIs(t, repo.DeleteProduct(context.Background(), del, false), db.ErrReceptionClosed)
+
+	// модератор удаляет, итог приёмки пересчитывается
+	mock.ExpectBegin()
+	expectLockedProduct(mock, "close")
+	mock.ExpectExec(`UPDATE products SET deleted_at`).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`INSERT INTO product_deletions`).WillReturnResult(sqlmock.NewResult(1, 1))
+	expectTouchReceptions(mock, "rec-xyz")
+	expectCloseSummary(mock, "rec-xyz", sqlmock.NewRows([]string{"type", "cnt"}))
+	mock.ExpectQuery(`SELECT type, expected_count, barcodes FROM reception_manifest_items`).
+		WillReturnRows(sqlmock.NewRows([]string{"type", "expected_count", "barcodes"}))
+	mock.ExpectCommit()
+	require.NoError(t, repo.DeleteProduct(context.Background(), del, true))
+
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRepo_DeleteProduct_NotFound(t *testing.T) {
+	sqlDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer sqlDB.Close()
+	xdb := sqlx.NewDb(sqlDB, "postgres")
+	repo := db.NewRepo(xdb)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT reception_id, type, quantity FROM products`).WillReturnError(sql.ErrNoRows)
+	mock.ExpectRollback()
+
+	err = repo.DeleteProduct(context.Background(), &model.ProductDeletion{ProductID: "prod-404"}, true)
+	require.ErrorIs(t, err, db.ErrProductNotFound)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
 
+func TestRepo_GetPVZListWithFilter_IncludeDeleted(t *testing.T) {
+	sqlDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer sqlDB.Close()
+	xdb := sqlx.NewDb(sqlDB, "postgres")
+	repo := db.NewRepo(xdb)
+
+	mock.ExpectQuery(`SELECT id, city, registration_date, version FROM pvz`).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "city", "registration_date"}).
+			AddRow("pvz-1", "Москва", time.Now()))
+	mock.ExpectQuery(`FROM receptions r LEFT JOIN reception_summaries s`).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "pvz_id", "date_time", "status"}).
+			AddRow("rec-open", "pvz-1", time.Now(), "in_progress"))
+
+	deletedAt := time.Now()
+	// без фильтра по deleted_at
+	mock.ExpectQuery(`FROM products WHERE reception_id = ANY\(\$1\) ORDER BY date_time DESC, seq DESC$`).
+		WithArgs(pq.Array([]string{"rec-open"})).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "reception_id", "date_time", "type", "barcode", "serial_number", "deleted_at", "deleted_by"}).
+			AddRow("prod-1", "rec-open", time.Now(), "обувь", "", "", nil, "").
+			AddRow("prod-2", "rec-open", time.Now(), "обувь", "", "", deletedAt, "employee"))
+
+	list, err := repo.GetPVZListWithFilter(context.Background(), db.PVZListFilter{Page: 1, Limit: 10, IncludeDeleted: true})
+	require.NoError(t, err)
+	prods := list[0].Receptions[0].Products
+	require.Len(t, prods, 2)
+	require.Nil(t, prods[0].DeletedAt)
+	require.NotNil(t, prods[1].DeletedAt)
+	require.Equal(t, "employee", prods[1].DeletedBy)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func expectActiveReception(mock sqlmock.Sqlmock, pvzID, receptionID string) {
+	mock.ExpectQuery(`SELECT id, pvz_id, date_time, status, closed_at, version FROM receptions`).
+		WithArgs(pvzID, "in_progress").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "pvz_id", "date_time", "status"}).
+			AddRow(receptionID, pvzID, time.Now(), "in_progress"))
+}
+
+// expectTouchReceptions - рост версии приёмок после изменения их состава
+func expectTouchReceptions(mock sqlmock.Sqlmock, receptionIDs ...string) {
+	args := make([]driver.Value, len(receptionIDs))
+	for i, id := range receptionIDs {
+		args[i] = id
+	}
+	mock.ExpectExec(`UPDATE receptions SET version = version \+ 1 WHERE id IN`).
+		WithArgs(args...).
+		WillReturnResult(sqlmock.NewResult(0, int64(len(receptionIDs))))
+}
+
+func TestRepo_UndoLastDelete(t *testing.T) {
+	sqlDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer sqlDB.Close()
+	xdb := sqlx.NewDb(sqlDB, "postgres")
+	repo := db.NewRepo(xdb)
+
+	deletedAt := time.Now()
+	removalCols := []string{"id", "product_id", "removed_at"}
+	expectLast := func(removals *sqlmock.Rows) {
+		mock.ExpectQuery(`SELECT id, reception_id, date_time, type, quantity, deleted_at FROM products WHERE reception_id = \$1 AND deleted_at IS NOT NULL ORDER BY deleted_at DESC, seq DESC LIMIT 1`).
+			WithArgs("rec-1").
+			WillReturnRows(sqlmock.NewRows([]string{"id", "reception_id", "date_time", "type", "quantity", "deleted_at"}).
+				AddRow("prod-1", "rec-1", time.Now(), "обувь", 1, deletedAt))
+		mock.ExpectQuery(`SELECT id, product_id, removed_at FROM product_unit_removals WHERE reception_id = \$1 ORDER BY removed_at DESC, id DESC LIMIT 1`).
+			WithArgs("rec-1").
+			WillReturnRows(removals)
+	}
+	expectDeleted := func() {
+		expectLast(sqlmock.NewRows(removalCols).AddRow(7, "prod-2", deletedAt.Add(-time.Minute)))
+		mock.ExpectExec(`UPDATE products SET deleted_at = \$1, deleted_by = \$2 WHERE id = \$3`).
+			WithArgs(nil, nil, "prod-1").
+			WillReturnResult(sqlmock.NewResult(0, 1))
+	}
+
+	// товар удаляли по id - отменяем запись в журнале
+	mock.ExpectBegin()
+	expectActiveReception(mock, "pvz-1", "rec-1")
+	expectDeleted()
+	mock.ExpectExec(`UPDATE product_deletions SET undone_at = \$1 WHERE product_id = \$2 AND undone_at IS NULL`).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	expectTouchReceptions(mock, "rec-1")
+	mock.ExpectCommit()
+
+	prod, err := repo.UndoLastDelete(context.Background(), "pvz-1")
+	require.NoError(t, err)
+	require.Equal(t, "prod-1", prod.ID)
+
+	// товар удаляли через LIFO - уменьшаем счётчик
+	mock.ExpectBegin()
+	expectActiveReception(mock, "pvz-1", "rec-1")
+	expectDeleted()
+	mock.ExpectExec(`UPDATE product_deletions`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`UPDATE receptions SET lifo_deletions = GREATEST\(lifo_deletions - 1, 0\), version = version \+ 1 WHERE id = \$1`).
+		WithArgs("rec-1").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	_, err = repo.UndoLastDelete(context.Background(), "pvz-1")
+	require.NoError(t, err)
+
+	// последней LIFO убрали единицу из строки - возвращаем её в количество
+	mock.ExpectBegin()
+	expectActiveReception(mock, "pvz-1", "rec-1")
+	expectLast(sqlmock.NewRows(removalCols).AddRow(8, "prod-2", deletedAt.Add(time.Minute)))
+	mock.ExpectQuery(`UPDATE products SET quantity = quantity \+ 1 WHERE id = \$1 RETURNING id, reception_id, date_time, type, quantity`).
+		WithArgs("prod-2").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "reception_id", "date_time", "type", "quantity"}).
+			AddRow("prod-2", "rec-1", time.Now(), "одежда", 200))
+	mock.ExpectExec(`DELETE FROM product_unit_removals WHERE id = \$1`).
+		WithArgs(int64(8)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`UPDATE receptions SET lifo_deletions = GREATEST\(lifo_deletions - 1, 0\)`).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	prod, err = repo.UndoLastDelete(context.Background(), "pvz-1")
+	require.NoError(t, err)
+	require.Equal(t, "prod-2", prod.ID)
+	require.Equal(t, 200, prod.Quantity)
+
+	// штрихкод удалённого товара успели отсканировать заново
+	mock.ExpectBegin()
+	expectActiveReception(mock, "pvz-1", "rec-1")
+	expectLast(sqlmock.NewRows(removalCols))
+	mock.ExpectExec(`UPDATE products SET deleted_at`).
+		WillReturnError(&pq.Error{Code: "23505", Constraint: "products_reception_barcode"})
+	mock.ExpectRollback()
+
+	_, err = repo.UndoLastDelete(context.Background(), "pvz-1")
+	require.ErrorIs(t, err, db.ErrDuplicateBarcode)
+
+	// восстанавливать нечего
+	mock.ExpectBegin()
+	expectActiveReception(mock, "pvz-1", "rec-1")
+	mock.ExpectQuery(`FROM products`).WillReturnError(sql.ErrNoRows)
+	mock.ExpectQuery(`FROM product_unit_removals`).WillReturnRows(sqlmock.NewRows(removalCols))
+	mock.ExpectRollback()
+
+	_, err = repo.UndoLastDelete(context.Background(), "pvz-1")
+	require.ErrorIs(t, err, db.ErrNothingToUndo)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func expectProductType(mock sqlmock.Sqlmock, name string, requiresSerial, active bool) {
+	mock.ExpectQuery(`SELECT name, requires_serial, active FROM product_types WHERE name IN \(\$1\)`).
+		WithArgs(name).
+		WillReturnRows(sqlmock.NewRows([]string{"name", "requires_serial", "active"}).
+			AddRow(name, requiresSerial, active))
+}
+
+// expectStorageCells - выборка ячеек ПВЗ под блокировкой; nil - ячейки не заведены
+func expectStorageCells(mock sqlmock.Sqlmock, cells *sqlmock.Rows) {
+	if cells == nil {
+		cells = sqlmock.NewRows(cellCols)
+	}
+	mock.ExpectQuery(`FROM storage_cells c WHERE c.pvz_id = \$1 ORDER BY c.zone, c.rack, c.shelf FOR UPDATE OF c`).
+		WillReturnRows(cells)
+}
+
+var cellCols = []string{"id", "pvz_id", "zone", "rack", "shelf", "capacity", "created_at", "occupied"}
+
+func TestRepo_CreateProduct_TypeRules(t *testing.T) {
+	sqlDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer sqlDB.Close()
+	xdb := sqlx.NewDb(sqlDB, "postgres")
+	repo := db.NewRepo(xdb)
+	create := func(typ, serial string) error {
+		return repo.CreateProduct(context.Background(), "pvz-1", &model.Product{ID: "prod-1", Type: typ, SerialNumber: serial})
+	}
+
+	// опечатка в типе
+	mock.ExpectBegin()
+	expectActiveReception(mock, "pvz-1", "rec-1")
+	mock.ExpectQuery(`FROM product_types`).WithArgs("электроника ").
+		WillReturnRows(sqlmock.NewRows([]string{"name", "requires_serial", "active"}))
+	mock.ExpectRollback()
+	require.ErrorIs(t, create("электроника ", ""), db.ErrUnknownProductType)
+
+	// отключённый тип
+	mock.ExpectBegin()
+	expectActiveReception(mock, "pvz-1", "rec-1")
+	expectProductType(mock, "мебель", false, false)
+	mock.ExpectRollback()
+	require.ErrorIs(t, create("мебель", ""), db.ErrUnknownProductType)
+
+	// электронике нужен серийный номер
+	mock.ExpectBegin()
+	expectActiveReception(mock, "pvz-1", "rec-1")
+	expectProductType(mock, "электроника", true, true)
+	mock.ExpectRollback()
+	require.ErrorIs(t, create("электроника", ""), db.ErrSerialRequired)
+
+	// серийный номер - у одной единицы, строкой с количеством его не принять
+	mock.ExpectBegin()
+	expectActiveReception(mock, "pvz-1", "rec-1")
+	expectProductType(mock, "электроника", true, true)
+	mock.ExpectRollback()
+	err = repo.CreateProduct(context.Background(), "pvz-1",
+		&model.Product{ID: "prod-1", Type: "электроника", SerialNumber: "SN-1", Quantity: 5})
+	require.ErrorIs(t, err, db.ErrSerialQuantity)
+
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRepo_ProductTypes(t *testing.T) {
+	sqlDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer sqlDB.Close()
+	xdb := sqlx.NewDb(sqlDB, "postgres")
+	repo := db.NewRepo(xdb)
+	ctx := context.Background()
+
+	mock.ExpectQuery(`SELECT name, requires_serial, active, created_at FROM product_types WHERE active = \$1 ORDER BY name`).
+		WithArgs(true).
+		WillReturnRows(sqlmock.NewRows([]string{"name", "requires_serial", "active", "created_at"}).
+			AddRow("обувь", false, true, time.Now()).
+			AddRow("электроника", true, true, time.Now()))
+	list, err := repo.ListProductTypes(ctx, false)
+	require.NoError(t, err)
+	require.Len(t, list, 2)
+	require.True(t, list[1].RequiresSerial)
+
+	mock.ExpectExec(`INSERT INTO product_types`).
+		WillReturnError(&pq.Error{Code: "23505"})
+	require.ErrorIs(t, repo.CreateProductType(ctx, &model.ProductType{Name: "обувь"}), db.ErrProductTypeExists)
+
+	mock.ExpectQuery(`UPDATE product_types SET requires_serial = \$1, active = \$2 WHERE name = \$3 RETURNING created_at`).
+		WithArgs(false, false, "мебель").
+		WillReturnError(sql.ErrNoRows)
+	require.ErrorIs(t, repo.UpdateProductType(ctx, &model.ProductType{Name: "мебель"}), db.ErrProductTypeNotFound)
+
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRepo_CreateProduct_Barcode(t *testing.T) {
+	sqlDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer sqlDB.Close()
+	xdb := sqlx.NewDb(sqlDB, "postgres")
+	repo := db.NewRepo(xdb)
+	create := func() (*model.Product, error) {
+		prod := &model.Product{ID: "prod-1", Type: "обувь", Barcode: "4600000000017"}
+		return prod, repo.CreateProduct(context.Background(), "pvz-1", prod)
+	}
+	openScans := func(rows ...[2]string) {
+		r := sqlmock.NewRows([]string{"barcode", "id", "pvz_id"})
+		for _, row := range rows {
+			r.AddRow("4600000000017", row[0], row[1])
+		}
+		mock.ExpectQuery(`SELECT p.barcode, r.id, r.pvz_id FROM products p JOIN receptions r ON r.id = p.reception_id WHERE p.barcode IN \(\$1\) AND p.deleted_at IS NULL AND r.status = \$2`).
+			WithArgs("4600000000017", "in_progress").
+			WillReturnRows(r)
+	}
+
+	// тот же штрихкод в другой открытой приёмке - предупреждение
+	mock.ExpectBegin()
+	expectActiveReception(mock, "pvz-1", "rec-1")
+	expectProductType(mock, "обувь", false, true)
+	openScans([2]string{"rec-2", "pvz-2"})
+	expectStorageCells(mock, nil)
+	mock.ExpectExec(`INSERT INTO products`).
+		WithArgs("prod-1", "rec-1", sqlmock.AnyArg(), "обувь", 1, "4600000000017", nil, nil).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	expectTouchReceptions(mock, "rec-1")
+	mock.ExpectCommit()
+	prod, err := create()
+	require.NoError(t, err)
+	require.Len(t, prod.Warnings, 1)
+	require.Contains(t, prod.Warnings[0], "rec-2")
+
+	// повтор в той же приёмке
+	mock.ExpectBegin()
+	expectActiveReception(mock, "pvz-1", "rec-1")
+	expectProductType(mock, "обувь", false, true)
+	openScans([2]string{"rec-1", "pvz-1"})
+	mock.ExpectRollback()
+	_, err = create()
+	require.ErrorIs(t, err, db.ErrDuplicateBarcode)
+
+	// гонку ловит уникальный индекс
+	mock.ExpectBegin()
+	expectActiveReception(mock, "pvz-1", "rec-1")
+	expectProductType(mock, "обувь", false, true)
+	openScans()
+	expectStorageCells(mock, nil)
+	mock.ExpectExec(`INSERT INTO products`).
+		WillReturnError(&pq.Error{Code: "23505", Constraint: "products_reception_barcode"})
+	mock.ExpectRollback()
+	_, err = create()
+	require.ErrorIs(t, err, db.ErrDuplicateBarcode)
+
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRepo_GetProductByBarcode(t *testing.T) {
+	sqlDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer sqlDB.Close()
+	xdb := sqlx.NewDb(sqlDB, "postgres")
+	repo := db.NewRepo(xdb)
+
+	mock.ExpectQuery(`SELECT p.id, .* FROM products p JOIN receptions r ON r.id = p.reception_id JOIN pvz v ON v.id = r.pvz_id LEFT JOIN storage_cells c ON c.id = p.cell_id WHERE p.barcode = \$1 AND p.deleted_at IS NULL ORDER BY p.date_time DESC, p.seq DESC LIMIT 1`).
+		WithArgs("4600000000017").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "reception_id", "date_time", "type", "barcode", "serial_number",
+			"status", "stored_at", "issued_at", "returned_at",
+			"reception_date_time", "reception_status", "pvz_id", "city", "registration_date"}).
+			AddRow("prod-1", "rec-1", time.Now(), "обувь", "4600000000017", "", "stored", time.Now(), nil, nil,
+				time.Now(), "close", "pvz-1", "Москва", time.Now()))
+
+	found, err := repo.GetProductByBarcode(context.Background(), "4600000000017")
+	require.NoError(t, err)
+	require.Equal(t, "prod-1", found.Product.ID)
+	require.Equal(t, "rec-1", found.Reception.ID)
+	require.Equal(t, "pvz-1", found.Reception.PVZID)
+	require.Equal(t, "close", found.Reception.Status)
+	require.Equal(t, "stored", found.Product.Status)
+	require.Equal(t, "Москва", found.PVZ.City)
+
+	mock.ExpectQuery(`FROM products p`).WillReturnError(sql.ErrNoRows)
+	found, err = repo.GetProductByBarcode(context.Background(), "nope")
+	require.NoError(t, err)
+	require.Nil(t, found)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRepo_CreateProducts_Batch(t *testing.T) {
+	sqlDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer sqlDB.Close()
+	xdb := sqlx.NewDb(sqlDB, "postgres")
+	repo := db.NewRepo(xdb)
+
+	now := time.Now()
+	prods := []*model.Product{
+		{ID: "prod-1", Type: "обувь", DateTime: now, Barcode: "111"},
+		{ID: "prod-2", Type: "электроника", DateTime: now, SerialNumber: "SN-2"},
+		{ID: "prod-3", Type: "обувь", DateTime: now, Quantity: 24},
+	}
+
+	mock.ExpectBegin()
+	expectActiveReception(mock, "pvz-1", "rec-1")
+	mock.ExpectQuery(`FROM product_types WHERE name IN \(\$1,\$2\)`).
+		WithArgs("обувь", "электроника").
+		WillReturnRows(sqlmock.NewRows([]string{"name", "requires_serial", "active"}).
+			AddRow("обувь", false, true).
+			AddRow("электроника", true, true))
+	mock.ExpectQuery(`SELECT p.barcode, r.id, r.pvz_id FROM products p`).
+		WithArgs("111", "in_progress").
+		WillReturnRows(sqlmock.NewRows([]string{"barcode", "id", "pvz_id"}))
+	// одна вставка на всю пачку вместе с записями истории
+	expectStorageCells(mock, nil)
+	mock.ExpectExec(`WITH ins AS \( INSERT INTO products \(id,reception_id,date_time,type,quantity,barcode,serial_number,cell_id\) VALUES \(\$1,\$2,\$3,\$4,\$5,\$6,\$7,\$8\),\(\$9,.*\),\(\$17,.*\) RETURNING .*\) INSERT INTO product_movements`).
+		WithArgs("prod-1", "rec-1", now, "обувь", 1, "111", nil, nil,
+			"prod-2", "rec-1", now, "электроника", 1, nil, "SN-2", nil,
+			"prod-3", "rec-1", now, "обувь", 24, nil, nil, nil).
+		WillReturnResult(sqlmock.NewResult(0, 3))
+	expectTouchReceptions(mock, "rec-1")
+	mock.ExpectCommit()
+
+	require.NoError(t, repo.CreateProducts(context.Background(), "pvz-1", prods))
+	for _, p := range prods {
+		require.Equal(t, "rec-1", p.ReceptionID)
+	}
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRepo_CreateProducts_ItemErrors(t *testing.T) {
+	sqlDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer sqlDB.Close()
+	xdb := sqlx.NewDb(sqlDB, "postgres")
+	repo := db.NewRepo(xdb)
+
+	// второй товар без серийного номера - вся пачка отклоняется
+	mock.ExpectBegin()
+	expectActiveReception(mock, "pvz-1", "rec-1")
+	expectProductType(mock, "электроника", true, true)
+	mock.ExpectRollback()
+
+	err = repo.CreateProducts(context.Background(), "pvz-1", []*model.Product{
+		{ID: "prod-1", Type: "электроника", SerialNumber: "SN-1"},
+		{ID: "prod-2", Type: "электроника"},
+	})
+	var pe *db.ProductError
+	require.ErrorAs(t, err, &pe)
+	require.Equal(t, 1, pe.Index)
+	require.ErrorIs(t, err, db.ErrSerialRequired)
+
+	// один штрихкод дважды в пачке
+	mock.ExpectBegin()
+	expectActiveReception(mock, "pvz-1", "rec-1")
+	expectProductType(mock, "обувь", false, true)
+	mock.ExpectRollback()
+
+	err = repo.CreateProducts(context.Background(), "pvz-1", []*model.Product{
+		{ID: "prod-1", Type: "обувь", Barcode: "111"},
+		{ID: "prod-2", Type: "обувь", Barcode: "111"},
+	})
+	require.ErrorAs(t, err, &pe)
+	require.Equal(t, 1, pe.Index)
+	require.ErrorIs(t, err, db.ErrDuplicateBarcode)
+
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRepo_TransitionProduct(t *testing.T) {
+	sqlDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer sqlDB.Close()
+	xdb := sqlx.NewDb(sqlDB, "postgres")
+	repo := db.NewRepo(xdb)
+	at := time.Now()
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT p.status, r.status AS reception_status FROM products p JOIN receptions r ON r.id = p.reception_id WHERE p.deleted_at IS NULL AND p.id = \$1 FOR UPDATE OF p FOR SHARE OF r`).
+		WithArgs("prod-1").
+		WillReturnRows(sqlmock.NewRows([]string{"status", "reception_status"}).AddRow("received", "close"))
+	mock.ExpectQuery(`UPDATE products SET status = \$1, stored_at = \$2 WHERE id = \$3 RETURNING id, reception_id`).
+		WithArgs("stored", at, "prod-1").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "reception_id", "date_time", "type", "status", "stored_at"}).
+			AddRow("prod-1", "rec-1", at, "обувь", "stored", at))
+	mock.ExpectCommit()
+
+	prod, err := repo.TransitionProduct(context.Background(), "prod-1", model.ProductStatusStored, at)
+	require.NoError(t, err)
+	require.Equal(t, "stored", prod.Status)
+	require.NotNil(t, prod.StoredAt)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRepo_TransitionProduct_Rejected(t *testing.T) {
+	cases := []struct {
+		name            string
+		status, recStat string
+		to              string
+		want            error
+	}{
+		{"open reception", "received", "in_progress", model.ProductStatusStored, db.ErrReceptionOpen},
+		{"issued is final", "issued", "close", model.ProductStatusReturned, db.ErrInvalidTransition},
+		{"skip stored", "received", "close", model.ProductStatusIssued, db.ErrInvalidTransition},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			sqlDB, mock, err := sqlmock.New()
+			require.NoError(t, err)
+			defer sqlDB.Close()
+			repo := db.NewRepo(sqlx.NewDb(sqlDB, "postgres"))
+
+			mock.ExpectBegin()
+			mock.ExpectQuery(`SELECT p.status, r.status AS reception_status FROM products p`).
+				WillReturnRows(sqlmock.NewRows([]string{"status", "reception_status"}).AddRow(tc.status, tc.recStat))
+			mock.ExpectRollback()
+
+			_, err = repo.TransitionProduct(context.Background(), "prod-1", tc.to, time.Now())
+			require.ErrorIs(t, err, tc.want)
+			require.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+
+	sqlDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer sqlDB.Close()
+	repo := db.NewRepo(sqlx.NewDb(sqlDB, "postgres"))
+	mock.ExpectBegin()
+	mock.ExpectQuery(`FROM products p`).WillReturnError(sql.ErrNoRows)
+	mock.ExpectRollback()
+	_, err = repo.TransitionProduct(context.Background(), "prod-1", model.ProductStatusStored, time.Now())
+	require.ErrorIs(t, err, db.ErrProductNotFound)
+
+	_, err = repo.TransitionProduct(context.Background(), "prod-1", model.ProductStatusReceived, time.Now())
+	require.ErrorIs(t, err, db.ErrInvalidTransition)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRepo_ListProducts_Filters(t *testing.T) {
+	sqlDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer sqlDB.Close()
+	repo := db.NewRepo(sqlx.NewDb(sqlDB, "postgres"))
+	from, to := time.Now().Add(-time.Hour), time.Now()
+
+	mock.ExpectQuery(`SELECT p.id, .*, r.pvz_id FROM products p JOIN receptions r ON r.id = p.reception_id JOIN pvz v ON v.id = r.pvz_id `+
+		`WHERE p.deleted_at IS NULL AND v.city = \$1 AND r.pvz_id = \$2 AND p.reception_id = \$3 AND p.type = \$4 AND p.status = \$5 `+
+		`AND p.barcode = \$6 AND p.date_time >= \$7 AND p.date_time <= \$8 ORDER BY p.date_time DESC, p.id DESC LIMIT 11$`).
+		WithArgs("Москва", "pvz-1", "rec-1", "обувь", "stored", "111", from, to).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "pvz_id"}).AddRow("prod-1", "pvz-1"))
+
+	prods, next, err := repo.ListProducts(context.Background(), db.ProductListFilter{
+		PVZID: "pvz-1", City: "Москва", ReceptionID: "rec-1", Type: "обувь", Status: "stored",
+		Barcode: "111", From: &from, To: &to, Limit: 10,
+	})
+	require.NoError(t, err)
+	require.Len(t, prods, 1)
+	require.Equal(t, "pvz-1", prods[0].PVZID)
+	require.Empty(t, next)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRepo_ListProducts_Cursor(t *testing.T) {
+	sqlDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer sqlDB.Close()
+	repo := db.NewRepo(sqlx.NewDb(sqlDB, "postgres"))
+
+	t0 := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	cols := []string{"id", "date_time"}
+
+	// первая страница: запрошено 2, пришло 3 - есть продолжение
+	mock.ExpectQuery(`FROM products p JOIN receptions r ON r.id = p.reception_id WHERE p.deleted_at IS NULL ORDER BY p.date_time ASC, p.id ASC LIMIT 3$`).
+		WillReturnRows(sqlmock.NewRows(cols).
+			AddRow("prod-1", t0).
+			AddRow("prod-2", t0.Add(time.Minute)).
+			AddRow("prod-3", t0.Add(2*time.Minute)))
+	prods, next, err := repo.ListProducts(context.Background(), db.ProductListFilter{Asc: true, Limit: 2})
+	require.NoError(t, err)
+	require.Len(t, prods, 2)
+	require.NotEmpty(t, next)
+
+	// вторая страница продолжается строго после последнего товара
+	mock.ExpectQuery(`WHERE p.deleted_at IS NULL AND \(p.date_time, p.id\) > \(\$1, \$2\) ORDER BY p.date_time ASC, p.id ASC LIMIT 3$`).
+		WithArgs(t0.Add(time.Minute), "prod-2").
+		WillReturnRows(sqlmock.NewRows(cols).AddRow("prod-3", t0.Add(2*time.Minute)))
+	cursor := next
+	prods, next, err = repo.ListProducts(context.Background(), db.ProductListFilter{Asc: true, Limit: 2, Cursor: cursor})
+	require.NoError(t, err)
+	require.Len(t, prods, 1)
+	require.Empty(t, next)
+
+	// курсор не подходит к другой сортировке или другим фильтрам
+	_, _, err = repo.ListProducts(context.Background(), db.ProductListFilter{Limit: 2, Cursor: cursor})
+	require.ErrorIs(t, err, db.ErrInvalidCursor)
+	_, _, err = repo.ListProducts(context.Background(), db.ProductListFilter{Asc: true, Type: "обувь", Limit: 2, Cursor: cursor})
+	require.ErrorIs(t, err, db.ErrInvalidCursor)
+
+	_, _, err = repo.ListProducts(context.Background(), db.ProductListFilter{Limit: 2, Cursor: "%%%"})
+	require.ErrorIs(t, err, db.ErrInvalidCursor)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRepo_CreateProducts_AssignsCells(t *testing.T) {
+	sqlDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer sqlDB.Close()
+	repo := db.NewRepo(sqlx.NewDb(sqlDB, "postgres"))
+
+	prods := []*model.Product{
+		{ID: "prod-1", Type: "обувь"},
+		{ID: "prod-2", Type: "обувь"},
+		{ID: "prod-3", Type: "обувь"},
+	}
+
+	mock.ExpectBegin()
+	expectActiveReception(mock, "pvz-1", "rec-1")
+	mock.ExpectQuery(`FROM product_types`).
+		WillReturnRows(sqlmock.NewRows([]string{"name", "requires_serial", "active"}).AddRow("обувь", false, true))
+	// A-1-1 занята, в A-1-2 одно место
+	expectStorageCells(mock, sqlmock.NewRows(cellCols).
+		AddRow("cell-1", "pvz-1", "A", "1", "1", 2, time.Now(), 2).
+		AddRow("cell-2", "pvz-1", "A", "1", "2", 3, time.Now(), 2))
+	mock.ExpectExec(`INSERT INTO products`).
+		WithArgs("prod-1", "rec-1", sqlmock.AnyArg(), "обувь", 1, nil, nil, "cell-2",
+			"prod-2", "rec-1", sqlmock.AnyArg(), "обувь", 1, nil, nil, nil,
+			"prod-3", "rec-1", sqlmock.AnyArg(), "обувь", 1, nil, nil, nil).
+		WillReturnResult(sqlmock.NewResult(0, 3))
+	expectTouchReceptions(mock, "rec-1")
+	mock.ExpectCommit()
+
+	require.NoError(t, repo.CreateProducts(context.Background(), "pvz-1", prods))
+	require.Equal(t, "cell-2", prods[0].CellID)
+	require.Equal(t, "A-1-2", prods[0].Cell.Address())
+	require.Empty(t, prods[1].CellID)
+	require.Equal(t, []string{"no free storage cell in pvz"}, prods[2].Warnings)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRepo_CreateProducts_AssignsCellsByQuantity(t *testing.T) {
+	sqlDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer sqlDB.Close()
+	repo := db.NewRepo(sqlx.NewDb(sqlDB, "postgres"))
+
+	prods := []*model.Product{
+		{ID: "prod-1", Type: "обувь", Quantity: 3},
+		{ID: "prod-2", Type: "обувь", Quantity: 2},
+	}
+
+	mock.ExpectBegin()
+	expectActiveReception(mock, "pvz-1", "rec-1")
+	expectProductType(mock, "обувь", false, true)
+	// в A-1-1 два свободных места из пяти, в A-1-2 - пять
+	expectStorageCells(mock, sqlmock.NewRows(cellCols).
+		AddRow("cell-1", "pvz-1", "A", "1", "1", 5, time.Now(), 3).
+		AddRow("cell-2", "pvz-1", "A", "1", "2", 5, time.Now(), 0))
+	mock.ExpectExec(`INSERT INTO products`).
+		WithArgs("prod-1", "rec-1", sqlmock.AnyArg(), "обувь", 3, nil, nil, "cell-2",
+			"prod-2", "rec-1", sqlmock.AnyArg(), "обувь", 2, nil, nil, "cell-1").
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	expectTouchReceptions(mock, "rec-1")
+	mock.ExpectCommit()
+
+	require.NoError(t, repo.CreateProducts(context.Background(), "pvz-1", prods))
+	require.Equal(t, 3, prods[0].Cell.Occupied)
+	require.Equal(t, 5, prods[1].Cell.Occupied)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRepo_MoveProduct(t *testing.T) {
+	sqlDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer sqlDB.Close()
+	repo := db.NewRepo(sqlx.NewDb(sqlDB, "postgres"))
+
+	lockProduct := func(status, cellID string, quantity int) {
+		mock.ExpectQuery(`SELECT p.status, p.quantity, COALESCE\(p.cell_id::text, ''\) AS cell_id, r.pvz_id FROM products p JOIN receptions r ON r.id = p.reception_id WHERE p.deleted_at IS NULL AND p.id = \$1 FOR UPDATE OF p`).
+			WithArgs("prod-1").
+			WillReturnRows(sqlmock.NewRows([]string{"status", "quantity", "cell_id", "pvz_id"}).AddRow(status, quantity, cellID, "pvz-1"))
+	}
+	lockCell := func(occupied int) {
+		mock.ExpectQuery(`FROM storage_cells c WHERE c.id = \$1 AND c.pvz_id = \$2 FOR UPDATE OF c`).
+			WithArgs("cell-2", "pvz-1").
+			WillReturnRows(sqlmock.NewRows(cellCols).AddRow("cell-2", "pvz-1", "B", "2", "1", 2, time.Now(), occupied))
+	}
+
+	mock.ExpectBegin()
+	lockProduct("stored", "cell-1", 1)
+	lockCell(1)
+	mock.ExpectQuery(`UPDATE products SET cell_id = \$1 WHERE id = \$2 RETURNING`).
+		WithArgs("cell-2", "prod-1").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "cell_id", "status"}).AddRow("prod-1", "cell-2", "stored"))
+	mock.ExpectExec(`INSERT INTO product_movements`).
+		WithArgs("prod-1", model.MovementCellChanged, nil, "pvz-1", nil, nil, "cell-2", "employee", sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+	prod, err := repo.MoveProduct(context.Background(), "prod-1", "cell-2", "employee")
+	require.NoError(t, err)
+	require.Equal(t, "cell-2", prod.CellID)
+	require.Equal(t, 2, prod.Cell.Occupied)
+
+	mock.ExpectBegin()
+	lockProduct("stored", "cell-1", 1)
+	lockCell(2)
+	mock.ExpectRollback()
+	_, err = repo.MoveProduct(context.Background(), "prod-1", "cell-2", "employee")
+	require.ErrorIs(t, err, db.ErrStorageCellFull)
+
+	// место есть, но не на все единицы строки
+	mock.ExpectBegin()
+	lockProduct("stored", "cell-1", 2)
+	lockCell(1)
+	mock.ExpectRollback()
+	_, err = repo.MoveProduct(context.Background(), "prod-1", "cell-2", "employee")
+	require.ErrorIs(t, err, db.ErrStorageCellFull)
+
+	mock.ExpectBegin()
+	lockProduct("issued", "", 1)
+	mock.ExpectRollback()
+	_, err = repo.MoveProduct(context.Background(), "prod-1", "cell-2", "employee")
+	require.ErrorIs(t, err, db.ErrProductNotInStock)
+
+	// ячейка другого ПВЗ не находится
+	mock.ExpectBegin()
+	lockProduct("received", "", 1)
+	mock.ExpectQuery(`FROM storage_cells c`).WillReturnError(sql.ErrNoRows)
+	mock.ExpectRollback()
+	_, err = repo.MoveProduct(context.Background(), "prod-1", "cell-2", "employee")
+	require.ErrorIs(t, err, db.ErrStorageCellNotFound)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRepo_CreateStorageCell(t *testing.T) {
+	sqlDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer sqlDB.Close()
+	repo := db.NewRepo(sqlx.NewDb(sqlDB, "postgres"))
+	cell := &model.StorageCell{ID: "cell-1", PVZID: "pvz-1", Zone: "A", Rack: "1", Shelf: "1", Capacity: 5, CreatedAt: time.Now()}
+
+	mock.ExpectExec(`INSERT INTO storage_cells \(id,pvz_id,zone,rack,shelf,capacity,created_at\)`).
+		WithArgs("cell-1", "pvz-1", "A", "1", "1", 5, cell.CreatedAt).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	require.NoError(t, repo.CreateStorageCell(context.Background(), cell))
+
+	mock.ExpectExec(`INSERT INTO storage_cells`).
+		WillReturnError(&pq.Error{Code: "23505", Constraint: "storage_cells_address"})
+	require.ErrorIs(t, repo.CreateStorageCell(context.Background(), cell), db.ErrStorageCellExists)
+
+	mock.ExpectExec(`INSERT INTO storage_cells`).
+		WillReturnError(&pq.Error{Code: "23503"})
+	require.ErrorIs(t, repo.CreateStorageCell(context.Background(), cell), db.ErrPVZNotFound)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRepo_GetProduct_WithCell(t *testing.T) {
+	sqlDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer sqlDB.Close()
+	repo := db.NewRepo(sqlx.NewDb(sqlDB, "postgres"))
+
+	mock.ExpectQuery(`LEFT JOIN storage_cells c ON c.id = p.cell_id WHERE p.id = \$1 AND p.deleted_at IS NULL`).
+		WithArgs("prod-1").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "reception_id", "status", "cell_id",
+			"reception_status", "pvz_id", "city", "cell_zone", "cell_rack", "cell_shelf", "cell_capacity"}).
+			AddRow("prod-1", "rec-1", "stored", "cell-1", "close", "pvz-1", "Москва", "A", "03", "2", 10))
+
+	found, err := repo.GetProduct(context.Background(), "prod-1")
+	require.NoError(t, err)
+	require.Equal(t, "cell-1", found.Product.CellID)
+	require.Equal(t, "A-03-2", found.Cell.Address())
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+var (
+	transferCols     = []string{"id", "from_pvz_id", "to_pvz_id", "status", "created_by", "created_at", "dispatched_at", "received_at", "reception_id"}
+	transferProdCols = []string{"id", "status", "reception_id", "reception_status", "pvz_id", "quantity"}
+)
+
+func expectTransfer(mock sqlmock.Sqlmock, status string, productIDs ...string) {
+	mock.ExpectQuery(`SELECT .+ FROM transfers WHERE id = \$1 FOR UPDATE`).
+		WithArgs("tr-1").
+		WillReturnRows(sqlmock.NewRows(transferCols).
+			AddRow("tr-1", "pvz-1", "pvz-2", status, "employee", time.Now(), nil, nil, ""))
+	items := sqlmock.NewRows([]string{"product_id"})
+	for _, id := range productIDs {
+		items.AddRow(id)
+	}
+	mock.ExpectQuery(`SELECT product_id FROM transfer_items WHERE transfer_id = \$1 ORDER BY product_id`).
+		WithArgs("tr-1").
+		WillReturnRows(items)
+}
+
+func TestRepo_CreateTransfer(t *testing.T) {
+	sqlDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer sqlDB.Close()
+	repo := db.NewRepo(sqlx.NewDb(sqlDB, "postgres"))
+
+	tr := &model.Transfer{ID: "tr-1", FromPVZID: "pvz-1", ToPVZID: "pvz-2", Status: model.TransferStatusDraft,
+		CreatedBy: "employee", CreatedAt: time.Now(), ProductIDs: []string{"p1", "p2"}}
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`FROM products p JOIN receptions r ON r.id = p.reception_id WHERE p.deleted_at IS NULL AND p.id IN \(\$1,\$2\) FOR UPDATE OF p`).
+		WithArgs("p1", "p2").
+		WillReturnRows(sqlmock.NewRows(transferProdCols).
+			AddRow("p1", "stored", "rec-1", "close", "pvz-1", 1).
+			AddRow("p2", "received", "rec-1", "close", "pvz-1", 1))
+	mock.ExpectExec(`INSERT INTO transfers`).
+		WithArgs("tr-1", "pvz-1", "pvz-2", "draft", "employee", sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec(`INSERT INTO transfer_items \(transfer_id,product_id\) VALUES \(\$1,\$2\),\(\$3,\$4\)`).
+		WithArgs("tr-1", "p1", "tr-1", "p2").
+		WillReturnResult(sqlmock.NewResult(2, 2))
+	mock.ExpectCommit()
+	require.NoError(t, repo.CreateTransfer(context.Background(), tr))
+
+	// товары должны быть на складе ПВЗ отправления и в закрытой приёмке
+	cases := []struct {
+		row *sqlmock.Rows
+		err error
+	}{
+		{sqlmock.NewRows(transferProdCols).AddRow("p1", "stored", "rec-1", "close", "pvz-1", 1), db.ErrProductNotFound},
+		{sqlmock.NewRows(transferProdCols).AddRow("p1", "stored", "rec-1", "close", "pvz-1", 1).
+			AddRow("p2", "stored", "rec-9", "close", "pvz-3", 1), db.ErrProductNotFound},
+		{sqlmock.NewRows(transferProdCols).AddRow("p1", "stored", "rec-1", "close", "pvz-1", 1).
+			AddRow("p2", "issued", "rec-1", "close", "pvz-1", 1), db.ErrProductNotInStock},
+		{sqlmock.NewRows(transferProdCols).AddRow("p1", "stored", "rec-1", "close", "pvz-1", 1).
+			AddRow("p2", "received", "rec-2", "in_progress", "pvz-1", 1), db.ErrReceptionOpen},
+	}
+	for _, tc := range cases {
+		mock.ExpectBegin()
+		mock.ExpectQuery(`FROM products p`).WillReturnRows(tc.row)
+		mock.ExpectRollback()
+		err = repo.CreateTransfer(context.Background(), tr)
+		require.ErrorIs(t, err, tc.err)
+		var pe *db.ProductError
+		require.ErrorAs(t, err, &pe)
+		require.Equal(t, 1, pe.Index)
+	}
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRepo_DispatchTransfer(t *testing.T) {
+	sqlDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer sqlDB.Close()
+	repo := db.NewRepo(sqlx.NewDb(sqlDB, "postgres"))
+	at := time.Date(2025, 4, 10, 12, 0, 0, 0, time.UTC)
+
+	mock.ExpectBegin()
+	expectTransfer(mock, model.TransferStatusDraft, "p1")
+	mock.ExpectQuery(`FROM products p`).
+		WithArgs("p1").
+		WillReturnRows(sqlmock.NewRows(transferProdCols).AddRow("p1", "stored", "rec-1", "close", "pvz-1", 1))
+	mock.ExpectExec(`UPDATE products SET status = \$1, cell_id = \$2 WHERE id IN \(\$3\)`).
+		WithArgs(model.ProductStatusInTransit, nil, "p1").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`INSERT INTO product_movements`).
+		WithArgs("p1", model.MovementDispatched, "pvz-1", "pvz-2", "rec-1", "tr-1", nil, "employee", at).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec(`UPDATE transfers SET status = \$1, dispatched_at = \$2 WHERE id = \$3`).
+		WithArgs(model.TransferStatusDispatched, at, "tr-1").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	tr, err := repo.DispatchTransfer(context.Background(), "tr-1", "employee", at)
+	require.NoError(t, err)
+	require.Equal(t, model.TransferStatusDispatched, tr.Status)
+	require.Equal(t, at, *tr.DispatchedAt)
+
+	// повторная отправка
+	mock.ExpectBegin()
+	expectTransfer(mock, model.TransferStatusDispatched, "p1")
+	mock.ExpectRollback()
+	_, err = repo.DispatchTransfer(context.Background(), "tr-1", "employee", at)
+	require.ErrorIs(t, err, db.ErrTransferState)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`FROM transfers`).WillReturnError(sql.ErrNoRows)
+	mock.ExpectRollback()
+	_, err = repo.DispatchTransfer(context.Background(), "tr-1", "employee", at)
+	require.ErrorIs(t, err, db.ErrTransferNotFound)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRepo_ReceiveTransfer_OpensReception(t *testing.T) {
+	sqlDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer sqlDB.Close()
+	repo := db.NewRepo(sqlx.NewDb(sqlDB, "postgres"))
+	at := time.Date(2025, 4, 11, 9, 0, 0, 0, time.UTC)
+
+	mock.ExpectBegin()
+	expectTransfer(mock, model.TransferStatusDispatched, "p1")
+	mock.ExpectQuery(`FROM receptions WHERE pvz_id = \$1 AND status = \$2`).
+		WithArgs("pvz-2", "in_progress").
+		WillReturnError(sql.ErrNoRows)
+	mock.ExpectExec(`INSERT INTO receptions`).
+		WithArgs(sqlmock.AnyArg(), "pvz-2", at, "in_progress").
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectQuery(`FROM products p`).
+		WithArgs("p1").
+		WillReturnRows(sqlmock.NewRows(transferProdCols).AddRow("p1", "in_transit", "rec-1", "close", "pvz-1", 3))
+	// строка из трёх единиц не влезает в A-1-1 и уходит в A-1-2
+	expectStorageCells(mock, sqlmock.NewRows(cellCols).
+		AddRow("cell-6", "pvz-2", "A", "1", "1", 2, time.Now(), 0).
+		AddRow("cell-7", "pvz-2", "A", "1", "2", 5, time.Now(), 0))
+	mock.ExpectExec(`UPDATE products SET reception_id = \$1, date_time = \$2, status = \$3, cell_id = \$4 WHERE id = \$5`).
+		WithArgs(sqlmock.AnyArg(), at, model.ProductStatusReceived, "cell-7", "p1").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`INSERT INTO product_movements`).
+		WithArgs("p1", model.MovementArrived, "pvz-1", "pvz-2", sqlmock.AnyArg(), "tr-1", "cell-7", "employee", at).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	// версия растёт у новой приёмки и у приёмки, откуда товар уехал
+	mock.ExpectExec(`UPDATE receptions SET version = version \+ 1 WHERE id IN \(\$1,\$2\)`).
+		WithArgs(sqlmock.AnyArg(), "rec-1").
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectExec(`UPDATE transfers SET status = \$1, received_at = \$2, reception_id = \$3 WHERE id = \$4`).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	tr, err := repo.ReceiveTransfer(context.Background(), "tr-1", "employee", at)
+	require.NoError(t, err)
+	require.Equal(t, model.TransferStatusReceived, tr.Status)
+	require.NotEmpty(t, tr.ReceptionID)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRepo_ListProductMovements(t *testing.T) {
+	sqlDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer sqlDB.Close()
+	repo := db.NewRepo(sqlx.NewDb(sqlDB, "postgres"))
+
+	now := time.Now()
+	mock.ExpectQuery(`FROM product_movements WHERE product_id = \$1 ORDER BY created_at, id`).
+		WithArgs("p1").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "product_id", "event", "from_pvz_id", "to_pvz_id", "reception_id", "transfer_id", "cell_id", "actor", "created_at"}).
+			AddRow(1, "p1", model.MovementReceived, "", "pvz-1", "rec-1", "", "cell-1", "", now).
+			AddRow(2, "p1", model.MovementDispatched, "pvz-1", "pvz-2", "rec-1", "tr-1", "", "employee", now))
+
+	moves, err := repo.ListProductMovements(context.Background(), "p1")
+	require.NoError(t, err)
+	require.Len(t, moves, 2)
+	require.Equal(t, "tr-1", moves[1].TransferID)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRepo_GetPVZListWithFilter_DateRangeSelectsPVZ(t *testing.T) {
+	sqlDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer sqlDB.Close()
+	repo := db.NewRepo(sqlx.NewDb(sqlDB, "postgres"))
+
+	start := time.Date(2025, 4, 1, 0, 0, 0, 0, time.UTC)
+	end := time.Date(2025, 4, 30, 0, 0, 0, 0, time.UTC)
+	recCols := []string{"id", "pvz_id", "date_time", "status"}
+
+	// страница только из ПВЗ, у которых есть приёмки в диапазоне
+	mock.ExpectQuery(`SELECT id, city, registration_date, version FROM pvz WHERE EXISTS \(SELECT 1 FROM receptions r WHERE r.pvz_id = pvz.id AND r.date_time >= \$1 AND r.date_time <= \$2\) ORDER BY registration_date DESC LIMIT 10 OFFSET 10`).
+		WithArgs(start, end).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "city", "registration_date"}).AddRow("pvz-1", "Москва", time.Now()))
+	mock.ExpectQuery(`FROM receptions r LEFT JOIN reception_summaries s ON s.reception_id = r.id WHERE r.pvz_id = ANY\(\$1\) AND r.date_time >= \$2 AND r.date_time <= \$3 ORDER BY r.date_time DESC`).
+		WithArgs(pq.Array([]string{"pvz-1"}), start, end).
+		WillReturnRows(sqlmock.NewRows(recCols).AddRow("rec-1", "pvz-1", start.Add(time.Hour), "close"))
+	mock.ExpectQuery(`FROM products`).WillReturnRows(sqlmock.NewRows([]string{"id", "reception_id"}))
+
+	list, err := repo.GetPVZListWithFilter(context.Background(), db.PVZListFilter{StartDate: &start, EndDate: &end, Page: 2, Limit: 10})
+	require.NoError(t, err)
+	require.Len(t, list, 1)
+	require.Len(t, list[0].Receptions, 1)
+
+	// старый режим: все ПВЗ, фильтруются только приёмки
+	mock.ExpectQuery(`SELECT id, city, registration_date, version FROM pvz ORDER BY registration_date DESC LIMIT 10 OFFSET 0`).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "city", "registration_date"}).AddRow("pvz-2", "Казань", time.Now()))
+	mock.ExpectQuery(`FROM receptions r`).
+		WithArgs(pq.Array([]string{"pvz-2"}), start, end).
+		WillReturnRows(sqlmock.NewRows(recCols))
+
+	list, err = repo.GetPVZListWithFilter(context.Background(), db.PVZListFilter{StartDate: &start, EndDate: &end, Page: 1, Limit: 10, IncludeEmpty: true})
+	require.NoError(t, err)
+	require.Len(t, list, 1)
+	require.Empty(t, list[0].Receptions)
 	require.NoError(t, mock.ExpectationsWereMet())
 }
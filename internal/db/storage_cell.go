@@ -0,0 +1,186 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	sq "github.com/Masterminds/squirrel"
+
+	"github.com/51mans0n/avito-pvz-task/internal/model"
+)
+
+// cellColumns - ячейка вместе с текущей заполненностью
+var cellColumns = []string{
+	"c.id", "c.pvz_id", "c.zone", "c.rack", "c.shelf", "c.capacity", "c.created_at",
+	"(SELECT coalesce(sum(p.quantity), 0) FROM products p WHERE p.cell_id = c.id AND p.deleted_at IS NULL) AS occupied",
+}
+
+// CreateStorageCell заводит ячейку хранения в ПВЗ
+func (r *Repo) CreateStorageCell(ctx context.Context, c *model.StorageCell) error {
+	q, args, err := sq.Insert("storage_cells").
+		Columns("id", "pvz_id", "zone", "rack", "shelf", "capacity", "created_at").
+		Values(c.ID, c.PVZID, c.Zone, c.Rack, c.Shelf, c.Capacity, c.CreatedAt).
+		PlaceholderFormat(sq.Dollar).
+		ToSql()
+	if err != nil {
+		return err
+	}
+	if _, err := r.db.ExecContext(ctx, q, args...); err != nil {
+		if pqErr, ok := pgError(err, pgUniqueViolation); ok && pqErr.Constraint == storageCellAddress {
+			return ErrStorageCellExists
+		}
+		if _, ok := pgError(err, pgForeignKeyViolation); ok {
+			return ErrPVZNotFound
+		}
+		return err
+	}
+	return nil
+}
+
+// ListStorageCells - ячейки ПВЗ по адресу с заполненностью
+func (r *Repo) ListStorageCells(ctx context.Context, pvzID string) ([]*model.StorageCell, error) {
+	return r.getStorageCells(ctx, pvzID, false)
+}
+
+// getStorageCells - ячейки ПВЗ; forUpdate блокирует их до конца транзакции
+func (r *Repo) getStorageCells(ctx context.Context, pvzID string, forUpdate bool) ([]*model.StorageCell, error) {
+	q := sq.Select(cellColumns...).
+		From("storage_cells c").
+		Where(sq.Eq{"c.pvz_id": pvzID}).
+		OrderBy("c.zone", "c.rack", "c.shelf").
+		PlaceholderFormat(sq.Dollar)
+	if forUpdate {
+		q = q.Suffix("FOR UPDATE OF c")
+	}
+
+	sqlCells, args, err := q.ToSql()
+	if err != nil {
+		return nil, err
+	}
+	var cells []*model.StorageCell
+	if err := r.db.SelectContext(ctx, &cells, sqlCells, args...); err != nil {
+		return nil, err
+	}
+	return cells, nil
+}
+
+// assignCells раскладывает новые товары по свободным ячейкам ПВЗ по порядку адресов.
+// Если ячейки заведены, но места нет, товар принимается без ячейки с предупреждением.
+func (r *Repo) assignCells(ctx context.Context, pvzID string, prods []*model.Product) error {
+	cells, err := r.getStorageCells(ctx, pvzID, true)
+	if err != nil {
+		return err
+	}
+	placeInCells(cells, prods)
+	return nil
+}
+
+// placeInCells кладёт каждую строку товара целиком в первую по адресу ячейку,
+// где хватает места на все её единицы, и увеличивает заполненность
+func placeInCells(cells []*model.StorageCell, prods []*model.Product) {
+	if len(cells) == 0 {
+		return
+	}
+
+	for _, prod := range prods {
+		cell := firstFit(cells, prod.Quantity)
+		if cell == nil {
+			prod.Warnings = append(prod.Warnings, "no free storage cell in pvz")
+			continue
+		}
+		cell.Occupied += prod.Quantity
+		prod.CellID = cell.ID
+		prod.Cell = cell
+	}
+}
+
+func firstFit(cells []*model.StorageCell, quantity int) *model.StorageCell {
+	for _, c := range cells {
+		if c.Fits(quantity) {
+			return c
+		}
+	}
+	return nil
+}
+
+// MoveProduct перекладывает товар в другую ячейку того же ПВЗ и пишет это в историю
+func (r *Repo) MoveProduct(ctx context.Context, productID, cellID, actor string) (*model.Product, error) {
+	var prod model.Product
+	err := r.inTx(ctx, func(tx *Repo) error {
+		q, args, err := sq.Select("p.status", "p.quantity", "COALESCE(p.cell_id::text, '') AS cell_id", "r.pvz_id").
+			From("products p").
+			Join("receptions r ON r.id = p.reception_id").
+			Where(sq.Eq{"p.id": productID, "p.deleted_at": nil}).
+			Suffix("FOR UPDATE OF p").
+			PlaceholderFormat(sq.Dollar).
+			ToSql()
+		if err != nil {
+			return err
+		}
+		var cur struct {
+			Status   string `db:"status"`
+			Quantity int    `db:"quantity"`
+			CellID   string `db:"cell_id"`
+			PVZID    string `db:"pvz_id"`
+		}
+		if err := tx.db.GetContext(ctx, &cur, q, args...); err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return ErrProductNotFound
+			}
+			return err
+		}
+		if cur.Status == model.ProductStatusIssued || cur.Status == model.ProductStatusReturned || cur.Status == model.ProductStatusInTransit {
+			return ErrProductNotInStock
+		}
+
+		qCell, argsCell, err := sq.Select(cellColumns...).
+			From("storage_cells c").
+			Where(sq.Eq{"c.id": cellID, "c.pvz_id": cur.PVZID}).
+			Suffix("FOR UPDATE OF c").
+			PlaceholderFormat(sq.Dollar).
+			ToSql()
+		if err != nil {
+			return err
+		}
+		var cell model.StorageCell
+		if err := tx.db.GetContext(ctx, &cell, qCell, argsCell...); err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return ErrStorageCellNotFound
+			}
+			return err
+		}
+		if cur.CellID != cellID && !cell.Fits(cur.Quantity) {
+			return fmt.Errorf("%w: %s", ErrStorageCellFull, cell.Address())
+		}
+
+		qUpd, argsUpd, err := sq.Update("products").
+			Set("cell_id", cellID).
+			Where(sq.Eq{"id": productID}).
+			Suffix("RETURNING " + strings.Join(productColumns, ", ")).
+			PlaceholderFormat(sq.Dollar).
+			ToSql()
+		if err != nil {
+			return err
+		}
+		if err := tx.db.GetContext(ctx, &prod, qUpd, argsUpd...); err != nil {
+			return err
+		}
+		prod.Cell = &cell
+		if cur.CellID == cellID {
+			return nil
+		}
+		cell.Occupied += cur.Quantity
+		return tx.insertMovements(ctx, []model.ProductMovement{{
+			ProductID: productID, Event: model.MovementCellChanged, ToPVZID: cur.PVZID,
+			ReceptionID: prod.ReceptionID, CellID: cellID, Actor: actor, CreatedAt: time.Now(),
+		}})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &prod, nil
+}
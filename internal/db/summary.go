@@ -0,0 +1,133 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"time"
+
+	sq "github.com/Masterminds/squirrel"
+
+	"github.com/51mans0n/avito-pvz-task/internal/model"
+)
+
+// saveReceptionSummary считает итог приёмки на момент закрытия и сохраняет его
+// (при повторном закрытии после переоткрытия итог перезаписывается).
+func (r *Repo) saveReceptionSummary(ctx context.Context, rec *model.Reception, counts map[string]int, closedAt time.Time) (*model.ReceptionSummary, error) {
+	q, args, err := sq.Select("r.lifo_deletions", "min(p.date_time) AS first_scan_at", "max(p.date_time) AS last_scan_at").
+		From("receptions r").
+		LeftJoin("products p ON p.reception_id = r.id AND p.deleted_at IS NULL").
+		Where(sq.Eq{"r.id": rec.ID}).
+		GroupBy("r.id").
+		PlaceholderFormat(sq.Dollar).
+		ToSql()
+	if err != nil {
+		return nil, err
+	}
+
+	var row struct {
+		LIFODeletions int          `db:"lifo_deletions"`
+		FirstScanAt   sql.NullTime `db:"first_scan_at"`
+		LastScanAt    sql.NullTime `db:"last_scan_at"`
+	}
+	if err := r.db.GetContext(ctx, &row, q, args...); err != nil {
+		return nil, err
+	}
+
+	sum := &model.ReceptionSummary{
+		CountsByType:    counts,
+		DurationSeconds: int64(closedAt.Sub(rec.DateTime).Seconds()),
+		LIFODeletions:   row.LIFODeletions,
+	}
+	for _, n := range counts {
+		sum.TotalProducts += n
+	}
+	if row.FirstScanAt.Valid {
+		sum.FirstScanAt = &row.FirstScanAt.Time
+	}
+	if row.LastScanAt.Valid {
+		sum.LastScanAt = &row.LastScanAt.Time
+	}
+
+	countsJSON, err := json.Marshal(counts)
+	if err != nil {
+		return nil, err
+	}
+	qIns, argsIns, err := sq.Insert("reception_summaries").
+		Columns("reception_id", "total_products", "counts_by_type", "first_scan_at", "last_scan_at",
+			"duration_seconds", "lifo_deletions", "created_at").
+		Values(rec.ID, sum.TotalProducts, countsJSON, sum.FirstScanAt, sum.LastScanAt,
+			sum.DurationSeconds, sum.LIFODeletions, closedAt).
+		Suffix(`ON CONFLICT (reception_id) DO UPDATE SET
+			total_products = EXCLUDED.total_products,
+			counts_by_type = EXCLUDED.counts_by_type,
+			first_scan_at = EXCLUDED.first_scan_at,
+			last_scan_at = EXCLUDED.last_scan_at,
+			duration_seconds = EXCLUDED.duration_seconds,
+			lifo_deletions = EXCLUDED.lifo_deletions,
+			created_at = EXCLUDED.created_at`).
+		PlaceholderFormat(sq.Dollar).
+		ToSql()
+	if err != nil {
+		return nil, err
+	}
+	if _, err := r.db.ExecContext(ctx, qIns, argsIns...); err != nil {
+		return nil, err
+	}
+	return sum, nil
+}
+
+// summaryColumns - колонки reception_summaries для LEFT JOIN к приёмкам
+var summaryColumns = []string{
+	"s.total_products", "s.counts_by_type", "s.first_scan_at", "s.last_scan_at",
+	"s.duration_seconds", "s.lifo_deletions AS summary_lifo_deletions",
+}
+
+// summaryRow - nullable-колонки итога из LEFT JOIN
+type summaryRow struct {
+	TotalProducts   sql.NullInt64 `db:"total_products"`
+	CountsByType    []byte        `db:"counts_by_type"`
+	FirstScanAt     sql.NullTime  `db:"first_scan_at"`
+	LastScanAt      sql.NullTime  `db:"last_scan_at"`
+	DurationSeconds sql.NullInt64 `db:"duration_seconds"`
+	LIFODeletions   sql.NullInt64 `db:"summary_lifo_deletions"`
+}
+
+func (s summaryRow) toModel() (*model.ReceptionSummary, error) {
+	if !s.TotalProducts.Valid {
+		return nil, nil
+	}
+	sum := &model.ReceptionSummary{
+		TotalProducts:   int(s.TotalProducts.Int64),
+		DurationSeconds: s.DurationSeconds.Int64,
+		LIFODeletions:   int(s.LIFODeletions.Int64),
+	}
+	if err := json.Unmarshal(s.CountsByType, &sum.CountsByType); err != nil {
+		return nil, err
+	}
+	if s.FirstScanAt.Valid {
+		sum.FirstScanAt = &s.FirstScanAt.Time
+	}
+	if s.LastScanAt.Valid {
+		sum.LastScanAt = &s.LastScanAt.Time
+	}
+	return sum, nil
+}
+
+// dropClosingReports удаляет итог и расхождения переоткрытой приёмки: они
+// описывают прошлое закрытие и пересчитаются при следующем
+func (r *Repo) dropClosingReports(ctx context.Context, receptionID string) error {
+	for _, table := range []string{"reception_summaries", "reception_discrepancies"} {
+		q, args, err := sq.Delete(table).
+			Where(sq.Eq{"reception_id": receptionID}).
+			PlaceholderFormat(sq.Dollar).
+			ToSql()
+		if err != nil {
+			return err
+		}
+		if _, err := r.db.ExecContext(ctx, q, args...); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,368 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/google/uuid"
+
+	"github.com/51mans0n/avito-pvz-task/internal/metrics"
+	"github.com/51mans0n/avito-pvz-task/internal/model"
+)
+
+var transferColumns = []string{
+	"id", "from_pvz_id", "to_pvz_id", "status", "created_by", "created_at",
+	"dispatched_at", "received_at", "COALESCE(reception_id::text, '') AS reception_id",
+}
+
+// transferProduct - товар перемещения с приёмкой, в которой он сейчас числится
+type transferProduct struct {
+	ID              string `db:"id"`
+	Status          string `db:"status"`
+	ReceptionID     string `db:"reception_id"`
+	ReceptionStatus string `db:"reception_status"`
+	PVZID           string `db:"pvz_id"`
+	Quantity        int    `db:"quantity"`
+}
+
+// CreateTransfer создаёт черновик перемещения. Товары должны лежать в ПВЗ
+// отправления в закрытых приёмках; ошибка конкретного товара - *ProductError.
+func (r *Repo) CreateTransfer(ctx context.Context, t *model.Transfer) error {
+	return r.inTx(ctx, func(tx *Repo) error {
+		if _, err := tx.checkTransferProducts(ctx, t.FromPVZID, t.ProductIDs, model.ProductStatusReceived, model.ProductStatusStored); err != nil {
+			return err
+		}
+
+		q, args, err := sq.Insert("transfers").
+			Columns("id", "from_pvz_id", "to_pvz_id", "status", "created_by", "created_at").
+			Values(t.ID, t.FromPVZID, t.ToPVZID, t.Status, t.CreatedBy, t.CreatedAt).
+			PlaceholderFormat(sq.Dollar).
+			ToSql()
+		if err != nil {
+			return err
+		}
+		if _, err := tx.db.ExecContext(ctx, q, args...); err != nil {
+			if _, ok := pgError(err, pgForeignKeyViolation); ok {
+				return ErrPVZNotFound
+			}
+			return err
+		}
+
+		items := sq.Insert("transfer_items").Columns("transfer_id", "product_id").PlaceholderFormat(sq.Dollar)
+		for _, id := range t.ProductIDs {
+			items = items.Values(t.ID, id)
+		}
+		qItems, argsItems, err := items.ToSql()
+		if err != nil {
+			return err
+		}
+		_, err = tx.db.ExecContext(ctx, qItems, argsItems...)
+		return err
+	})
+}
+
+// GetTransfer возвращает перемещение с id товаров (nil, если его нет)
+func (r *Repo) GetTransfer(ctx context.Context, id string) (*model.Transfer, error) {
+	return r.getTransfer(ctx, id, false)
+}
+
+// DispatchTransfer отправляет черновик: товары получают статус in_transit и
+// освобождают ячейки, в историю каждого пишется отправка.
+func (r *Repo) DispatchTransfer(ctx context.Context, id, actor string, at time.Time) (*model.Transfer, error) {
+	var t *model.Transfer
+	err := r.inTx(ctx, func(tx *Repo) error {
+		var err error
+		if t, err = tx.getTransfer(ctx, id, true); err != nil {
+			return err
+		}
+		if t == nil {
+			return ErrTransferNotFound
+		}
+		if t.Status != model.TransferStatusDraft {
+			return fmt.Errorf("%w: transfer is %s", ErrTransferState, t.Status)
+		}
+
+		// с момента создания черновика товары могли выдать или отправить другим перемещением
+		prods, err := tx.checkTransferProducts(ctx, t.FromPVZID, t.ProductIDs, model.ProductStatusReceived, model.ProductStatusStored)
+		if err != nil {
+			return err
+		}
+
+		qUpd, argsUpd, err := sq.Update("products").
+			Set("status", model.ProductStatusInTransit).
+			Set("cell_id", nil).
+			Where(sq.Eq{"id": t.ProductIDs}).
+			PlaceholderFormat(sq.Dollar).
+			ToSql()
+		if err != nil {
+			return err
+		}
+		if _, err := tx.db.ExecContext(ctx, qUpd, argsUpd...); err != nil {
+			return err
+		}
+
+		moves := make([]model.ProductMovement, 0, len(prods))
+		for _, p := range prods {
+			moves = append(moves, model.ProductMovement{
+				ProductID: p.ID, Event: model.MovementDispatched, FromPVZID: t.FromPVZID, ToPVZID: t.ToPVZID,
+				ReceptionID: p.ReceptionID, TransferID: t.ID, Actor: actor, CreatedAt: at,
+			})
+		}
+		if err := tx.insertMovements(ctx, moves); err != nil {
+			return err
+		}
+
+		t.Status, t.DispatchedAt = model.TransferStatusDispatched, &at
+		return tx.updateTransferStatus(ctx, t, "dispatched_at", at)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// ReceiveTransfer принимает перемещение в ПВЗ назначения: товары попадают в его
+// открытую приёмку (или в новую, если открытой нет) и раскладываются по ячейкам.
+func (r *Repo) ReceiveTransfer(ctx context.Context, id, actor string, at time.Time) (*model.Transfer, error) {
+	var (
+		t       *model.Transfer
+		created bool
+	)
+	err := r.inTx(ctx, func(tx *Repo) error {
+		var err error
+		if t, err = tx.getTransfer(ctx, id, true); err != nil {
+			return err
+		}
+		if t == nil {
+			return ErrTransferNotFound
+		}
+		if t.Status != model.TransferStatusDispatched {
+			return fmt.Errorf("%w: transfer is %s", ErrTransferState, t.Status)
+		}
+
+		rec, err := tx.getActiveReception(ctx, t.ToPVZID)
+		if err != nil {
+			return err
+		}
+		created = rec == nil
+		if rec == nil {
+			rec = &model.Reception{ID: uuid.New().String(), PVZID: t.ToPVZID, DateTime: at, Status: "in_progress"}
+			if err := tx.CreateReception(ctx, rec); err != nil {
+				return err
+			}
+		}
+
+		prods, err := tx.checkTransferProducts(ctx, t.FromPVZID, t.ProductIDs, model.ProductStatusInTransit)
+		if err != nil {
+			return err
+		}
+		placed := make([]*model.Product, 0, len(prods))
+		for _, p := range prods {
+			placed = append(placed, &model.Product{ID: p.ID, Quantity: p.Quantity})
+		}
+		if err := tx.assignCells(ctx, t.ToPVZID, placed); err != nil {
+			return err
+		}
+
+		moves := make([]model.ProductMovement, 0, len(placed))
+		for _, p := range placed {
+			// для приёмки назначения товар принят сейчас: так он попадает в её LIFO
+			// и не сдвигает первое сканирование в итоге; исходный приём остаётся в истории
+			q, args, err := sq.Update("products").
+				Set("reception_id", rec.ID).
+				Set("date_time", at).
+				Set("status", model.ProductStatusReceived).
+				Set("cell_id", nullString(p.CellID)).
+				Where(sq.Eq{"id": p.ID}).
+				PlaceholderFormat(sq.Dollar).
+				ToSql()
+			if err != nil {
+				return err
+			}
+			if _, err := tx.db.ExecContext(ctx, q, args...); err != nil {
+				if pqErr, ok := pgError(err, pgUniqueViolation); ok && pqErr.Constraint == receptionBarcodeIndex {
+					return &ProductError{Index: indexOf(placed, p), Err: ErrDuplicateBarcode}
+				}
+				return err
+			}
+			moves = append(moves, model.ProductMovement{
+				ProductID: p.ID, Event: model.MovementArrived, FromPVZID: t.FromPVZID, ToPVZID: t.ToPVZID,
+				ReceptionID: rec.ID, TransferID: t.ID, CellID: p.CellID, Actor: actor, CreatedAt: at,
+			})
+		}
+		if err := tx.insertMovements(ctx, moves); err != nil {
+			return err
+		}
+		// состав меняется и у приёмки назначения, и у приёмок, откуда товары уехали
+		touched := []string{rec.ID}
+		for _, p := range prods {
+			if !slices.Contains(touched, p.ReceptionID) {
+				touched = append(touched, p.ReceptionID)
+			}
+		}
+		if err := tx.touchReceptions(ctx, touched...); err != nil {
+			return err
+		}
+
+		t.Status, t.ReceivedAt, t.ReceptionID = model.TransferStatusReceived, &at, rec.ID
+		return tx.updateTransferStatus(ctx, t, "received_at", at)
+	})
+	if err != nil {
+		return nil, err
+	}
+	if created {
+		metrics.ReceptionsAdded.Inc()
+	}
+	return t, nil
+}
+
+// ListProductMovements - история перемещений товара по порядку
+func (r *Repo) ListProductMovements(ctx context.Context, productID string) ([]*model.ProductMovement, error) {
+	var moves []*model.ProductMovement
+	err := r.onReader(ctx, func(rr *Repo) error {
+		var err error
+		moves, err = rr.listProductMovements(ctx, productID)
+		return err
+	})
+	return moves, err
+}
+
+func (r *Repo) listProductMovements(ctx context.Context, productID string) ([]*model.ProductMovement, error) {
+	q, args, err := sq.Select("id", "product_id", "event",
+		"COALESCE(from_pvz_id::text, '') AS from_pvz_id", "COALESCE(to_pvz_id::text, '') AS to_pvz_id",
+		"COALESCE(reception_id::text, '') AS reception_id", "COALESCE(transfer_id::text, '') AS transfer_id",
+		"COALESCE(cell_id::text, '') AS cell_id", "COALESCE(actor, '') AS actor", "created_at").
+		From("product_movements").
+		Where(sq.Eq{"product_id": productID}).
+		OrderBy("created_at", "id").
+		PlaceholderFormat(sq.Dollar).
+		ToSql()
+	if err != nil {
+		return nil, err
+	}
+	var moves []*model.ProductMovement
+	if err := r.db.SelectContext(ctx, &moves, q, args...); err != nil {
+		return nil, err
+	}
+	return moves, nil
+}
+
+// getTransfer читает перемещение и его товары; forUpdate блокирует строку перемещения
+func (r *Repo) getTransfer(ctx context.Context, id string, forUpdate bool) (*model.Transfer, error) {
+	qb := sq.Select(transferColumns...).
+		From("transfers").
+		Where(sq.Eq{"id": id}).
+		PlaceholderFormat(sq.Dollar)
+	if forUpdate {
+		qb = qb.Suffix("FOR UPDATE")
+	}
+	q, args, err := qb.ToSql()
+	if err != nil {
+		return nil, err
+	}
+	var t model.Transfer
+	if err := r.db.GetContext(ctx, &t, q, args...); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	qItems, argsItems, err := sq.Select("product_id").
+		From("transfer_items").
+		Where(sq.Eq{"transfer_id": id}).
+		OrderBy("product_id").
+		PlaceholderFormat(sq.Dollar).
+		ToSql()
+	if err != nil {
+		return nil, err
+	}
+	if err := r.db.SelectContext(ctx, &t.ProductIDs, qItems, argsItems...); err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+// checkTransferProducts блокирует товары перемещения и проверяет, что все они живые,
+// числятся в ПВЗ pvzID, в закрытых приёмках и в одном из статусов allowed.
+func (r *Repo) checkTransferProducts(ctx context.Context, pvzID string, ids []string, allowed ...string) ([]transferProduct, error) {
+	q, args, err := sq.Select("p.id", "p.status", "p.reception_id", "r.status AS reception_status", "r.pvz_id", "p.quantity").
+		From("products p").
+		Join("receptions r ON r.id = p.reception_id").
+		Where(sq.Eq{"p.id": ids, "p.deleted_at": nil}).
+		Suffix("FOR UPDATE OF p").
+		PlaceholderFormat(sq.Dollar).
+		ToSql()
+	if err != nil {
+		return nil, err
+	}
+	var rows []transferProduct
+	if err := r.db.SelectContext(ctx, &rows, q, args...); err != nil {
+		return nil, err
+	}
+	byID := make(map[string]transferProduct, len(rows))
+	for _, p := range rows {
+		byID[p.ID] = p
+	}
+	return validateTransferProducts(pvzID, ids, byID, allowed)
+}
+
+// validateTransferProducts проверяет найденные товары перемещения в порядке ids
+func validateTransferProducts(pvzID string, ids []string, byID map[string]transferProduct, allowed []string) ([]transferProduct, error) {
+	prods := make([]transferProduct, 0, len(ids))
+	for i, id := range ids {
+		p, ok := byID[id]
+		switch {
+		case !ok || p.PVZID != pvzID:
+			return nil, &ProductError{Index: i, Err: ErrProductNotFound}
+		case !slices.Contains(allowed, p.Status):
+			return nil, &ProductError{Index: i, Err: fmt.Errorf("%w: product is %s", ErrProductNotInStock, p.Status)}
+		case p.ReceptionStatus == "in_progress" && p.Status != model.ProductStatusInTransit:
+			return nil, &ProductError{Index: i, Err: ErrReceptionOpen}
+		}
+		prods = append(prods, p)
+	}
+	return prods, nil
+}
+
+func (r *Repo) updateTransferStatus(ctx context.Context, t *model.Transfer, column string, at time.Time) error {
+	upd := sq.Update("transfers").
+		Set("status", t.Status).
+		Set(column, at).
+		Where(sq.Eq{"id": t.ID}).
+		PlaceholderFormat(sq.Dollar)
+	if t.ReceptionID != "" {
+		upd = upd.Set("reception_id", t.ReceptionID)
+	}
+	q, args, err := upd.ToSql()
+	if err != nil {
+		return err
+	}
+	_, err = r.db.ExecContext(ctx, q, args...)
+	return err
+}
+
+// insertMovements пишет записи истории перемещений одним запросом
+func (r *Repo) insertMovements(ctx context.Context, moves []model.ProductMovement) error {
+	if len(moves) == 0 {
+		return nil
+	}
+	ins := sq.Insert("product_movements").
+		Columns("product_id", "event", "from_pvz_id", "to_pvz_id", "reception_id", "transfer_id", "cell_id", "actor", "created_at").
+		PlaceholderFormat(sq.Dollar)
+	for _, m := range moves {
+		ins = ins.Values(m.ProductID, m.Event, nullString(m.FromPVZID), nullString(m.ToPVZID),
+			nullString(m.ReceptionID), nullString(m.TransferID), nullString(m.CellID), nullString(m.Actor), m.CreatedAt)
+	}
+	q, args, err := ins.ToSql()
+	if err != nil {
+		return err
+	}
+	_, err = r.db.ExecContext(ctx, q, args...)
+	return err
+}
@@ -0,0 +1,81 @@
+package db
+
+import (
+	"context"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// dbtx - общее подмножество *sqlx.DB и *sqlx.Tx, на котором работают методы Repo
+type dbtx interface {
+	sqlx.ExtContext
+	GetContext(ctx context.Context, dest any, query string, args ...any) error
+	SelectContext(ctx context.Context, dest any, query string, args ...any) error
+}
+
+const (
+	pgSerializationFailure = "40001"
+	pgDeadlockDetected     = "40P01"
+
+	maxTxRetries = 3
+	txRetryDelay = 20 * time.Millisecond
+)
+
+// WithTx - unit of work: выполняет fn в одной транзакции, repo внутри fn
+// работает в её рамках. Вложенный WithTx переиспользует текущую транзакцию.
+// Ошибки сериализации и дедлоки повторяются целиком до maxTxRetries раз.
+func (r *Repo) WithTx(ctx context.Context, fn func(repo Repository) error) error {
+	if r.tx != nil {
+		return fn(r)
+	}
+
+	for attempt := 0; ; attempt++ {
+		err := r.runTx(ctx, fn)
+		if err == nil || !isRetryableTxErr(err) || attempt >= maxTxRetries {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(txRetryDelay * time.Duration(attempt+1)):
+		}
+	}
+}
+
+// inTx - то же, что WithTx, но отдаёт в fn конкретный *Repo (для внутренних нужд)
+func (r *Repo) inTx(ctx context.Context, fn func(tx *Repo) error) error {
+	return r.WithTx(ctx, func(repo Repository) error {
+		return fn(repo.(*Repo))
+	})
+}
+
+func (r *Repo) runTx(ctx context.Context, fn func(repo Repository) error) (err error) {
+	tx, err := r.pool.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if p := recover(); p != nil {
+			_ = tx.Rollback()
+			panic(p)
+		}
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	if err = fn(&Repo{pool: r.pool, db: tx, tx: tx}); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func isRetryableTxErr(err error) bool {
+	if _, ok := pgError(err, pgSerializationFailure); ok {
+		return true
+	}
+	_, ok := pgError(err, pgDeadlockDetected)
+	return ok
+}
@@ -0,0 +1,156 @@
+package db_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/51mans0n/avito-pvz-task/internal/db"
+	"github.com/51mans0n/avito-pvz-task/internal/model"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRepo_WithTx_CommitsAllWrites(t *testing.T) {
+	sqlDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer sqlDB.Close()
+	repo := db.NewRepo(sqlx.NewDb(sqlDB, "postgres"))
+
+	mock.ExpectBegin()
+	mock.ExpectExec("INSERT INTO pvz").WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec("INSERT INTO receptions").WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	err = repo.WithTx(context.Background(), func(tx db.Repository) error {
+		if err := tx.CreatePVZ(context.Background(), &model.PVZ{ID: "pvz-1", City: "Москва"}); err != nil {
+			return err
+		}
+		return tx.CreateReception(context.Background(), &model.Reception{ID: "rec-1", PVZID: "pvz-1", Status: "in_progress"})
+	})
+	require.NoError(t, err)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRepo_WithTx_RollbackOnError(t *testing.T) {
+	sqlDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer sqlDB.Close()
+	repo := db.NewRepo(sqlx.NewDb(sqlDB, "postgres"))
+
+	boom := errors.New("boom")
+	mock.ExpectBegin()
+	mock.ExpectExec("INSERT INTO pvz").WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectRollback()
+
+	err = repo.WithTx(context.Background(), func(tx db.Repository) error {
+		if err := tx.CreatePVZ(context.Background(), &model.PVZ{ID: "pvz-1", City: "Москва"}); err != nil {
+			return err
+		}
+		return boom
+	})
+	require.ErrorIs(t, err, boom)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRepo_WithTx_RetriesSerializationFailure(t *testing.T) {
+	sqlDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer sqlDB.Close()
+	repo := db.NewRepo(sqlx.NewDb(sqlDB, "postgres"))
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT id, pvz_id, date_time, status, closed_at, version FROM receptions`).
+		WillReturnError(&pq.Error{Code: "40001"})
+	mock.ExpectRollback()
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT id, pvz_id, date_time, status, closed_at, version FROM receptions`).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "pvz_id", "date_time", "status"}).
+			AddRow("rec-1", "pvz-1", time.Now(), "in_progress"))
+	expectProductType(mock, "обувь", false, true)
+	expectStorageCells(mock, nil)
+	mock.ExpectExec(`INSERT INTO products`).WillReturnResult(sqlmock.NewResult(1, 1))
+	expectTouchReceptions(mock, "rec-1")
+	mock.ExpectCommit()
+
+	err = repo.CreateProduct(context.Background(), "pvz-1", &model.Product{ID: "prod-1", Type: "обувь"})
+	require.NoError(t, err)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRepo_CreateProducts_RetryRecomputesPlacement(t *testing.T) {
+	sqlDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer sqlDB.Close()
+	repo := db.NewRepo(sqlx.NewDb(sqlDB, "postgres"))
+
+	attempt := func(occupied int, cellID any, insertErr error) {
+		mock.ExpectBegin()
+		expectActiveReception(mock, "pvz-1", "rec-1")
+		expectProductType(mock, "обувь", false, true)
+		mock.ExpectQuery(`SELECT p.barcode, r.id, r.pvz_id FROM products p`).
+			WithArgs("111", "in_progress").
+			WillReturnRows(sqlmock.NewRows([]string{"barcode", "id", "pvz_id"}).AddRow("111", "rec-2", "pvz-2"))
+		expectStorageCells(mock, sqlmock.NewRows(cellCols).
+			AddRow("cell-1", "pvz-1", "A", "1", "1", 1, time.Now(), occupied))
+		ins := mock.ExpectExec(`INSERT INTO products`).
+			WithArgs("prod-1", "rec-1", sqlmock.AnyArg(), "обувь", 1, "111", nil, cellID)
+		if insertErr != nil {
+			ins.WillReturnError(insertErr)
+			mock.ExpectRollback()
+			return
+		}
+		ins.WillReturnResult(sqlmock.NewResult(1, 1))
+		expectTouchReceptions(mock, "rec-1")
+		mock.ExpectCommit()
+	}
+	// первая попытка занимает ячейку и падает на дедлоке; к повтору ячейку
+	// заняли параллельно, и товар принимается без неё
+	attempt(0, "cell-1", &pq.Error{Code: "40P01"})
+	attempt(1, nil, nil)
+
+	prod := &model.Product{ID: "prod-1", Type: "обувь", Barcode: "111"}
+	require.NoError(t, repo.CreateProducts(context.Background(), "pvz-1", []*model.Product{prod}))
+	require.Empty(t, prod.CellID)
+	require.Nil(t, prod.Cell)
+	require.Len(t, prod.Warnings, 2)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRepo_WithTx_NestedReusesTx(t *testing.T) {
+	sqlDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer sqlDB.Close()
+	repo := db.NewRepo(sqlx.NewDb(sqlDB, "postgres"))
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT id, pvz_id, date_time, status, closed_at, version FROM receptions`).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "pvz_id", "date_time", "status"}).
+			AddRow("rec-1", "pvz-1", time.Now(), "in_progress"))
+	expectProductType(mock, "обувь", false, true)
+	expectStorageCells(mock, nil)
+	mock.ExpectExec(`INSERT INTO products`).WillReturnResult(sqlmock.NewResult(1, 1))
+	expectTouchReceptions(mock, "rec-1")
+	mock.ExpectQuery(`SELECT id, pvz_id, date_time, status, closed_at, version FROM receptions`).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "pvz_id", "date_time", "status"}).
+			AddRow("rec-1", "pvz-1", time.Now(), "in_progress"))
+	mock.ExpectExec(`UPDATE receptions`).WillReturnResult(sqlmock.NewResult(0, 1))
+	expectCloseSummary(mock, "rec-1", sqlmock.NewRows([]string{"type", "cnt"}).AddRow("обувь", 1))
+	mock.ExpectQuery(`SELECT type, expected_count, barcodes FROM reception_manifest_items`).
+		WillReturnRows(sqlmock.NewRows([]string{"type", "expected_count", "barcodes"}))
+	mock.ExpectCommit()
+
+	err = repo.WithTx(context.Background(), func(tx db.Repository) error {
+		if err := tx.CreateProduct(context.Background(), "pvz-1", &model.Product{ID: "prod-1", Type: "обувь"}); err != nil {
+			return err
+		}
+		_, err := tx.CloseLastReception(context.Background(), "pvz-1", 0)
+		return err
+	})
+	require.NoError(t, err)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
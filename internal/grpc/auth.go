@@ -0,0 +1,61 @@
+package grpcserver
+
+import (
+	"context"
+	"slices"
+	"strings"
+
+	"github.com/51mans0n/avito-pvz-task/internal/auth"
+	"github.com/51mans0n/avito-pvz-task/internal/logging"
+	pvz_v1 "github.com/51mans0n/avito-pvz-task/pkg/proto/pvz/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// methodRoles - какие роли допускаются к методу. Методов, которых здесь нет,
+// проверка не касается: чтение списков открыто, как и раньше.
+var methodRoles = map[string][]string{
+	pvz_v1.PVZService_AddProducts_FullMethodName: {"employee"},
+}
+
+// UnaryAuthInterceptor проверяет токен из метаданных для unary-методов
+func UnaryAuthInterceptor(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	if err := authorize(ctx, info.FullMethod); err != nil {
+		return nil, err
+	}
+	return handler(ctx, req)
+}
+
+// StreamAuthInterceptor проверяет токен из метаданных для стримов
+func StreamAuthInterceptor(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	if err := authorize(ss.Context(), info.FullMethod); err != nil {
+		return err
+	}
+	return handler(srv, ss)
+}
+
+// authorize берёт "authorization: Bearer <token>" из метаданных и сверяет роль,
+// как AuthMiddleware в HTTP API
+func authorize(ctx context.Context, method string) error {
+	roles, ok := methodRoles[method]
+	if !ok {
+		return nil
+	}
+
+	md, _ := metadata.FromIncomingContext(ctx)
+	values := md.Get("authorization")
+	if len(values) == 0 || !strings.HasPrefix(values[0], "Bearer ") {
+		return status.Error(codes.Unauthenticated, "missing bearer token")
+	}
+	role, err := auth.ExtractRole(strings.TrimPrefix(values[0], "Bearer "))
+	if err != nil {
+		logging.S().Debugw("reject token", "method", method, "err", err)
+		return status.Error(codes.Unauthenticated, "unauthorized")
+	}
+	if !slices.Contains(roles, role) {
+		return status.Error(codes.PermissionDenied, "forbidden")
+	}
+	return nil
+}
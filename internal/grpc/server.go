@@ -2,25 +2,37 @@ package grpcserver
 
 import (
 	"context"
+	"errors"
+	"io"
+	"strings"
+	"time"
 
 	"github.com/51mans0n/avito-pvz-task/internal/db"
+	"github.com/51mans0n/avito-pvz-task/internal/logging"
+	"github.com/51mans0n/avito-pvz-task/internal/metrics"
+	"github.com/51mans0n/avito-pvz-task/internal/model"
 	pvz_v1 "github.com/51mans0n/avito-pvz-task/pkg/proto/pvz/v1"
+	"github.com/google/uuid"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 	"google.golang.org/protobuf/types/known/timestamppb"
 )
 
 type Server struct {
 	pvz_v1.UnimplementedPVZServiceServer
-	repo db.Repository
+	repo     db.Repository
+	maxBatch int // сколько товаров можно передать в одном AddProducts
 }
 
-func New(repo db.Repository) *Server {
-	return &Server{repo: repo}
+func New(repo db.Repository, maxBatch int) *Server {
+	return &Server{repo: repo, maxBatch: maxBatch}
 }
 
 func (s *Server) GetPVZList(ctx context.Context, _ *pvz_v1.GetPVZListRequest) (*pvz_v1.GetPVZListResponse, error) {
-	rows, err := s.repo.GetPVZListWithFilter(ctx, nil, nil, 1, 1000)
+	rows, err := s.repo.GetPVZListWithFilter(ctx, db.PVZListFilter{Page: 1, Limit: 1000})
 	if err != nil {
-		return nil, err
+		return nil, statusError(err, "get pvz list")
 	}
 
 	resp := &pvz_v1.GetPVZListResponse{}
@@ -29,7 +41,113 @@ func (s *Server) GetPVZList(ctx context.Context, _ *pvz_v1.GetPVZListRequest) (*
 			Id:               r.PVZ.ID,
 			City:             r.PVZ.City,
 			RegistrationDate: timestamppb.New(r.PVZ.RegistrationDate),
+			Version:          int64(r.PVZ.Version),
 		})
 	}
 	return resp, nil
 }
+
+func (s *Server) ListProductTypes(ctx context.Context, req *pvz_v1.ListProductTypesRequest) (*pvz_v1.ListProductTypesResponse, error) {
+	types, err := s.repo.ListProductTypes(ctx, req.GetIncludeInactive())
+	if err != nil {
+		return nil, statusError(err, "list product types")
+	}
+
+	resp := &pvz_v1.ListProductTypesResponse{}
+	for _, t := range types {
+		resp.ProductTypes = append(resp.ProductTypes, &pvz_v1.ProductType{
+			Name:           t.Name,
+			RequiresSerial: t.RequiresSerial,
+			Active:         t.Active,
+		})
+	}
+	return resp, nil
+}
+
+// AddProducts принимает товары из стрима и по его закрытию добавляет их
+// в открытую приёмку ПВЗ одной транзакцией
+func (s *Server) AddProducts(stream grpc.ClientStreamingServer[pvz_v1.AddProductRequest, pvz_v1.AddProductsResponse]) error {
+	var (
+		pvzID string
+		prods []*model.Product
+	)
+	now := time.Now()
+	for {
+		req, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return err
+		}
+
+		if pvzID == "" {
+			pvzID = req.GetPvzId()
+			if _, err := uuid.Parse(pvzID); err != nil {
+				return status.Error(codes.InvalidArgument, "pvz_id invalid")
+			}
+		}
+		if req.GetPvzId() != pvzID {
+			return status.Error(codes.InvalidArgument, "all products in a stream must belong to one pvz")
+		}
+		if req.GetType() == "" {
+			return status.Errorf(codes.InvalidArgument, "products[%d]: type is required", len(prods))
+		}
+		if req.GetQuantity() < 0 {
+			return status.Errorf(codes.InvalidArgument, "products[%d]: quantity must be positive", len(prods))
+		}
+		if len(prods) == s.maxBatch {
+			return status.Errorf(codes.InvalidArgument, "too many products, max %d", s.maxBatch)
+		}
+		prods = append(prods, &model.Product{
+			ID:           uuid.New().String(),
+			Type:         req.GetType(),
+			Quantity:     int(req.GetQuantity()),
+			DateTime:     now,
+			Barcode:      strings.TrimSpace(req.GetBarcode()),
+			SerialNumber: strings.TrimSpace(req.GetSerialNumber()),
+		})
+	}
+	if len(prods) == 0 {
+		return status.Error(codes.InvalidArgument, "no products in stream")
+	}
+
+	if err := s.repo.CreateProducts(stream.Context(), pvzID, prods); err != nil {
+		return statusError(err, "add products", "pvz", pvzID)
+	}
+
+	metrics.ProductsAdded.Add(float64(model.TotalQuantity(prods)))
+
+	resp := &pvz_v1.AddProductsResponse{ReceptionId: prods[0].ReceptionID}
+	for _, p := range prods {
+		resp.Ids = append(resp.Ids, p.ID)
+		resp.Warnings = append(resp.Warnings, p.Warnings...)
+	}
+	return stream.SendAndClose(resp)
+}
+
+// statusError переводит ошибку репозитория в gRPC-статус по её категории.
+// Внутренние ошибки уходят в лог, клиент получает Internal без подробностей.
+func statusError(err error, op string, keysAndValues ...any) error {
+	var code codes.Code
+	switch {
+	case errors.Is(err, db.ErrNotFound):
+		code = codes.NotFound
+	case errors.Is(err, db.ErrAlreadyExists):
+		code = codes.AlreadyExists
+	case errors.Is(err, db.ErrConflict):
+		code = codes.FailedPrecondition
+	case errors.Is(err, db.ErrValidation), errors.Is(err, db.ErrInvalidArgument):
+		code = codes.InvalidArgument
+	case errors.Is(err, db.ErrPrecondition):
+		code = codes.Aborted
+	case errors.Is(err, context.Canceled):
+		return status.Error(codes.Canceled, "request canceled")
+	case errors.Is(err, context.DeadlineExceeded):
+		return status.Error(codes.DeadlineExceeded, "deadline exceeded")
+	default:
+		logging.S().Errorw(op, append(keysAndValues, "err", err)...)
+		return status.Error(codes.Internal, "internal error")
+	}
+	return status.Error(code, err.Error())
+}
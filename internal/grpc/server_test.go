@@ -0,0 +1,130 @@
+package grpcserver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/51mans0n/avito-pvz-task/internal/db"
+	"github.com/51mans0n/avito-pvz-task/internal/model"
+	pvz_v1 "github.com/51mans0n/avito-pvz-task/pkg/proto/pvz/v1"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// fakeRepo реализует только то, что нужно AddProducts
+type fakeRepo struct {
+	db.Repository
+	pvzID string
+	got   []*model.Product
+	err   error
+}
+
+func (f *fakeRepo) CreateProducts(_ context.Context, pvzID string, prods []*model.Product) error {
+	if f.err != nil {
+		return f.err
+	}
+	f.pvzID, f.got = pvzID, prods
+	for _, p := range prods {
+		p.ReceptionID = "rec-1"
+	}
+	return nil
+}
+
+// fakeStream отдаёт заранее заготовленные запросы
+type fakeStream struct {
+	grpc.ServerStream
+	reqs []*pvz_v1.AddProductRequest
+	resp *pvz_v1.AddProductsResponse
+}
+
+func (s *fakeStream) Context() context.Context { return context.Background() }
+
+func (s *fakeStream) Recv() (*pvz_v1.AddProductRequest, error) {
+	if len(s.reqs) == 0 {
+		return nil, io.EOF
+	}
+	req := s.reqs[0]
+	s.reqs = s.reqs[1:]
+	return req, nil
+}
+
+func (s *fakeStream) SendAndClose(resp *pvz_v1.AddProductsResponse) error {
+	s.resp = resp
+	return nil
+}
+
+const testPVZ = "82cc7cda-bd24-468f-b7b7-844d66b6693c"
+
+func TestAddProducts(t *testing.T) {
+	repo := &fakeRepo{}
+	stream := &fakeStream{reqs: []*pvz_v1.AddProductRequest{
+		{PvzId: testPVZ, Type: "обувь", Barcode: "111", Quantity: 12},
+		{PvzId: testPVZ, Type: "электроника", SerialNumber: "SN-1"},
+	}}
+
+	require.NoError(t, New(repo, 10).AddProducts(stream))
+	require.Equal(t, testPVZ, repo.pvzID)
+	require.Len(t, repo.got, 2)
+	require.Equal(t, 12, repo.got[0].Quantity)
+	require.Equal(t, "rec-1", stream.resp.GetReceptionId())
+	require.Equal(t, []string{repo.got[0].ID, repo.got[1].ID}, stream.resp.GetIds())
+}
+
+func TestAddProducts_Validation(t *testing.T) {
+	cases := map[string][]*pvz_v1.AddProductRequest{
+		"empty stream": nil,
+		"bad pvz":      {{PvzId: "nope", Type: "обувь"}},
+		"mixed pvz":    {{PvzId: testPVZ, Type: "обувь"}, {PvzId: "5f0c8d2e-9a51-4c1b-8f3e-2d7a6b4c1e90", Type: "обувь"}},
+		"no type":      {{PvzId: testPVZ}},
+		"bad quantity": {{PvzId: testPVZ, Type: "обувь", Quantity: -3}},
+		"too many":     {{PvzId: testPVZ, Type: "обувь"}, {PvzId: testPVZ, Type: "обувь"}, {PvzId: testPVZ, Type: "обувь"}},
+	}
+	for name, reqs := range cases {
+		t.Run(name, func(t *testing.T) {
+			repo := &fakeRepo{}
+			err := New(repo, 2).AddProducts(&fakeStream{reqs: reqs})
+			require.Equal(t, codes.InvalidArgument, status.Code(err))
+			require.Nil(t, repo.got)
+		})
+	}
+}
+
+func TestAddProducts_RepoErrors(t *testing.T) {
+	reqs := []*pvz_v1.AddProductRequest{{PvzId: testPVZ, Type: "обувь", Barcode: "111"}}
+
+	err := New(&fakeRepo{err: db.ErrDuplicateBarcode}, 10).AddProducts(&fakeStream{reqs: reqs})
+	require.Equal(t, codes.AlreadyExists, status.Code(err))
+
+	err = New(&fakeRepo{err: &db.ProductError{Index: 0, Err: db.ErrSerialRequired}}, 10).AddProducts(&fakeStream{reqs: reqs})
+	require.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	err = New(&fakeRepo{err: fmt.Errorf("%w for pvz %s", db.ErrNoActiveReception, testPVZ)}, 10).AddProducts(&fakeStream{reqs: reqs})
+	require.Equal(t, codes.FailedPrecondition, status.Code(err))
+	require.Contains(t, status.Convert(err).Message(), "no active reception")
+
+	err = New(&fakeRepo{err: errors.New("pq: connection refused to 10.0.0.5")}, 10).AddProducts(&fakeStream{reqs: reqs})
+	require.Equal(t, codes.Internal, status.Code(err))
+	require.NotContains(t, status.Convert(err).Message(), "10.0.0.5")
+}
+
+func TestGetPVZList(t *testing.T) {
+	repo := db.NewMemRepo()
+	require.NoError(t, repo.CreatePVZ(context.Background(), &model.PVZ{ID: testPVZ, City: "Казань", RegistrationDate: time.Now()}))
+
+	resp, err := New(repo, 10).GetPVZList(context.Background(), &pvz_v1.GetPVZListRequest{})
+	require.NoError(t, err)
+	require.Len(t, resp.GetPvzs(), 1)
+	require.Equal(t, testPVZ, resp.GetPvzs()[0].GetId())
+	require.Equal(t, int64(1), resp.GetPvzs()[0].GetVersion())
+}
+
+func TestStatusError_VersionMismatch(t *testing.T) {
+	err := statusError(db.ErrVersionMismatch, "close reception")
+	require.Equal(t, codes.Aborted, status.Code(err))
+}
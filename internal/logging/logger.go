@@ -3,10 +3,11 @@ package logging
 import "go.uber.org/zap"
 
 // L  ― «сырое» *zap.Logger (нужен, когда хочется лог‑поля zap.Field).
-var L *zap.Logger
+// До вызова Init это no-op логгер, чтобы пакеты можно было тестировать без Init.
+var L = zap.NewNop()
 
 // lg ― «сахарный» *zap.SugaredLogger (короткие методы Info / Error / Fatal).
-var lg *zap.SugaredLogger
+var lg = L.Sugar()
 
 // Init инициализирует логгер.
 // prod=false ➜ zap.NewDevelopment, prod=true ➜ zap.NewProduction.
@@ -1,7 +1,10 @@
 package metrics
 
 import (
+	"database/sql"
+
 	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
 )
 
 var (
@@ -22,9 +25,50 @@ var (
 	PVZCreated      = prometheus.NewCounter(prometheus.CounterOpts{Name: "pvz_created_total"})
 	ReceptionsAdded = prometheus.NewCounter(prometheus.CounterOpts{Name: "receptions_created_total"})
 	ProductsAdded   = prometheus.NewCounter(prometheus.CounterOpts{Name: "products_created_total"})
+
+	ProductsDeleted = prometheus.NewCounterVec(
+		prometheus.CounterOpts{Name: "products_deleted_total", Help: "products deleted by id, by reason code"},
+		[]string{"reason"},
+	)
+
+	ProductTransitions = prometheus.NewCounterVec(
+		prometheus.CounterOpts{Name: "product_status_transitions_total", Help: "product lifecycle transitions, by target status"},
+		[]string{"status"},
+	)
+
+	ReceptionsAutoClosed = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "receptions_auto_closed_total",
+		Help: "receptions closed by the stale reception worker",
+	})
+
+	DBReads = prometheus.NewCounterVec(
+		prometheus.CounterOpts{Name: "db_reads_total", Help: "routed read queries, by pool (primary, replica)"},
+		[]string{"pool"},
+	)
+
+	ReplicaFallbacks = prometheus.NewCounterVec(
+		prometheus.CounterOpts{Name: "db_replica_fallbacks_total", Help: "reads sent to the primary instead of the replica, by reason"},
+		[]string{"reason"},
+	)
+
+	ReplicaLag = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "db_replica_lag_seconds",
+		Help: "replication lag measured by the last replica check",
+	})
+
+	ReplicaUp = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "db_replica_up",
+		Help: "1 if reads are served by the replica, 0 if they fall back to the primary",
+	})
 )
 
 func MustRegister() {
 	prometheus.MustRegister(HttpTotal, HttpDur,
-		PVZCreated, ReceptionsAdded, ProductsAdded)
+		PVZCreated, ReceptionsAdded, ProductsAdded, ProductsDeleted, ProductTransitions, ReceptionsAutoClosed,
+		DBReads, ReplicaFallbacks, ReplicaLag, ReplicaUp)
+}
+
+// RegisterDBPool публикует статистику пула соединений (go_sql_*) с меткой db_name=name
+func RegisterDBPool(name string, db *sql.DB) {
+	prometheus.MustRegister(collectors.NewDBStatsCollector(db, name))
 }
@@ -0,0 +1,350 @@
+// Package migrate применяет версионные SQL-миграции, встроенные в бинарь.
+//
+// Применённые версии хранятся в schema_migrations вместе с контрольной суммой
+// файла: если уже применённую миграцию поменяли, runner отказывается работать.
+// Все команды выполняются под pg_advisory_lock, так что несколько реплик,
+// стартующих с MIGRATE_ON_START, не накатывают схему одновременно.
+package migrate
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/51mans0n/avito-pvz-task/internal/logging"
+)
+
+// DownMarker отделяет up-часть файла миграции от отката
+const DownMarker = "-- +down"
+
+// LockID - ключ pg_advisory_lock, общий для всех экземпляров сервиса
+const LockID int64 = 4_513_200_045
+
+var fileName = regexp.MustCompile(`^(\d+)_([a-z0-9_]+)\.sql$`)
+
+// ErrChecksumMismatch - применённый файл миграции изменился после применения
+var ErrChecksumMismatch = errors.New("applied migration was modified")
+
+// Migration - один файл миграции
+type Migration struct {
+	Version  int
+	Name     string
+	Up       string
+	Down     string
+	Checksum string // sha256 всего файла
+}
+
+// Status - состояние миграции в базе
+type Status struct {
+	Migration
+	Applied   bool
+	AppliedAt *time.Time
+	Modified  bool // файл не совпадает с применённым
+}
+
+// Load читает NNN_name.sql из корня fsys и сортирует по версии
+func Load(fsys fs.FS) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, err
+	}
+
+	var list []Migration
+	seen := map[int]string{}
+	for _, e := range entries {
+		if e.IsDir() || !strings.HasSuffix(e.Name(), ".sql") {
+			continue
+		}
+		m := fileName.FindStringSubmatch(e.Name())
+		if m == nil {
+			return nil, fmt.Errorf("migration %s: name must be NNN_name.sql", e.Name())
+		}
+		version, _ := strconv.Atoi(m[1])
+		if prev, ok := seen[version]; ok {
+			return nil, fmt.Errorf("migration %s: version %d already used by %s", e.Name(), version, prev)
+		}
+		seen[version] = e.Name()
+
+		body, err := fs.ReadFile(fsys, e.Name())
+		if err != nil {
+			return nil, err
+		}
+		sum := sha256.Sum256(body)
+		up, down := split(string(body))
+		if strings.TrimSpace(up) == "" {
+			return nil, fmt.Errorf("migration %s: empty up section", e.Name())
+		}
+		list = append(list, Migration{
+			Version:  version,
+			Name:     m[2],
+			Up:       up,
+			Down:     down,
+			Checksum: hex.EncodeToString(sum[:]),
+		})
+	}
+
+	sort.Slice(list, func(i, j int) bool { return list[i].Version < list[j].Version })
+	return list, nil
+}
+
+// split делит файл по строке DownMarker
+func split(body string) (up, down string) {
+	lines := strings.SplitAfter(body, "\n")
+	for i, l := range lines {
+		if strings.TrimSpace(l) == DownMarker {
+			return strings.Join(lines[:i], ""), strings.Join(lines[i+1:], "")
+		}
+	}
+	return body, ""
+}
+
+// Migrator накатывает и откатывает миграции
+type Migrator struct {
+	db         *sql.DB
+	migrations []Migration
+}
+
+func New(db *sql.DB, fsys fs.FS) (*Migrator, error) {
+	list, err := Load(fsys)
+	if err != nil {
+		return nil, err
+	}
+	return &Migrator{db: db, migrations: list}, nil
+}
+
+// applied - запись schema_migrations
+type applied struct {
+	checksum  string
+	appliedAt time.Time
+}
+
+// Up применяет все непримененные миграции и возвращает их число
+func (m *Migrator) Up(ctx context.Context) (int, error) {
+	return m.Goto(ctx, m.latest())
+}
+
+// Down откатывает n последних применённых миграций
+func (m *Migrator) Down(ctx context.Context, n int) (int, error) {
+	if n <= 0 {
+		return 0, nil
+	}
+	done := 0
+	err := m.locked(ctx, func(conn *sql.Conn, state map[int]applied) error {
+		for i := len(m.migrations) - 1; i >= 0 && done < n; i-- {
+			mg := m.migrations[i]
+			if _, ok := state[mg.Version]; !ok {
+				continue
+			}
+			if err := m.rollback(ctx, conn, mg); err != nil {
+				return err
+			}
+			done++
+		}
+		return nil
+	})
+	return done, err
+}
+
+// Goto приводит базу к версии version: накатывает недостающие миграции
+// с номером <= version и откатывает применённые с номером > version.
+// Возвращает число выполненных шагов.
+func (m *Migrator) Goto(ctx context.Context, version int) (int, error) {
+	if version != 0 && m.find(version) == nil {
+		return 0, fmt.Errorf("unknown migration version %d", version)
+	}
+	done := 0
+	err := m.locked(ctx, func(conn *sql.Conn, state map[int]applied) error {
+		for i := len(m.migrations) - 1; i >= 0; i-- {
+			mg := m.migrations[i]
+			if _, ok := state[mg.Version]; !ok || mg.Version <= version {
+				continue
+			}
+			if err := m.rollback(ctx, conn, mg); err != nil {
+				return err
+			}
+			done++
+		}
+		for _, mg := range m.migrations {
+			if _, ok := state[mg.Version]; ok || mg.Version > version {
+				continue
+			}
+			if err := m.apply(ctx, conn, mg); err != nil {
+				return err
+			}
+			done++
+		}
+		return nil
+	})
+	return done, err
+}
+
+// Status возвращает состояние каждой миграции; изменённые файлы помечаются Modified
+func (m *Migrator) Status(ctx context.Context) ([]Status, error) {
+	var out []Status
+	err := m.withLock(ctx, func(conn *sql.Conn) error {
+		state, err := m.state(ctx, conn)
+		if err != nil {
+			return err
+		}
+		for _, mg := range m.migrations {
+			s := Status{Migration: mg}
+			if a, ok := state[mg.Version]; ok {
+				at := a.appliedAt
+				s.Applied, s.AppliedAt, s.Modified = true, &at, a.checksum != mg.Checksum
+			}
+			out = append(out, s)
+		}
+		return nil
+	})
+	return out, err
+}
+
+// locked берёт блокировку, читает schema_migrations и сверяет контрольные суммы
+func (m *Migrator) locked(ctx context.Context, fn func(conn *sql.Conn, state map[int]applied) error) error {
+	return m.withLock(ctx, func(conn *sql.Conn) error {
+		state, err := m.state(ctx, conn)
+		if err != nil {
+			return err
+		}
+		if err := m.verify(state); err != nil {
+			return err
+		}
+		return fn(conn, state)
+	})
+}
+
+// withLock выполняет fn на отдельном соединении под pg_advisory_lock
+func (m *Migrator) withLock(ctx context.Context, fn func(conn *sql.Conn) error) error {
+	conn, err := m.db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if _, err := conn.ExecContext(ctx, `SELECT pg_advisory_lock($1)`, LockID); err != nil {
+		return fmt.Errorf("acquire migration lock: %w", err)
+	}
+	defer func() {
+		// ctx мог быть отменён, а блокировку нужно отпустить в любом случае
+		if _, err := conn.ExecContext(context.Background(), `SELECT pg_advisory_unlock($1)`, LockID); err != nil {
+			logging.S().Warnw("release migration lock", "err", err)
+		}
+	}()
+
+	if _, err := conn.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS schema_migrations (
+    version    INTEGER PRIMARY KEY,
+    name       TEXT        NOT NULL,
+    checksum   TEXT        NOT NULL,
+    applied_at TIMESTAMPTZ NOT NULL DEFAULT now()
+)`); err != nil {
+		return fmt.Errorf("create schema_migrations: %w", err)
+	}
+	return fn(conn)
+}
+
+func (m *Migrator) state(ctx context.Context, conn *sql.Conn) (map[int]applied, error) {
+	rows, err := conn.QueryContext(ctx, `SELECT version, checksum, applied_at FROM schema_migrations`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	state := map[int]applied{}
+	for rows.Next() {
+		var (
+			v int
+			a applied
+		)
+		if err := rows.Scan(&v, &a.checksum, &a.appliedAt); err != nil {
+			return nil, err
+		}
+		state[v] = a
+	}
+	return state, rows.Err()
+}
+
+// verify проверяет, что каждая применённая версия есть среди файлов и не менялась
+func (m *Migrator) verify(state map[int]applied) error {
+	for v, a := range state {
+		mg := m.find(v)
+		if mg == nil {
+			return fmt.Errorf("applied migration %d is missing from the binary", v)
+		}
+		if mg.Checksum != a.checksum {
+			return fmt.Errorf("%w: %03d_%s", ErrChecksumMismatch, mg.Version, mg.Name)
+		}
+	}
+	return nil
+}
+
+func (m *Migrator) apply(ctx context.Context, conn *sql.Conn, mg Migration) error {
+	err := inTx(ctx, conn, func(tx *sql.Tx) error {
+		if _, err := tx.ExecContext(ctx, mg.Up); err != nil {
+			return err
+		}
+		_, err := tx.ExecContext(ctx,
+			`INSERT INTO schema_migrations (version, name, checksum) VALUES ($1, $2, $3)`,
+			mg.Version, mg.Name, mg.Checksum)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("apply %03d_%s: %w", mg.Version, mg.Name, err)
+	}
+	logging.S().Infow("migration applied", "version", mg.Version, "name", mg.Name)
+	return nil
+}
+
+func (m *Migrator) rollback(ctx context.Context, conn *sql.Conn, mg Migration) error {
+	if strings.TrimSpace(mg.Down) == "" {
+		return fmt.Errorf("rollback %03d_%s: no down section", mg.Version, mg.Name)
+	}
+	err := inTx(ctx, conn, func(tx *sql.Tx) error {
+		if _, err := tx.ExecContext(ctx, mg.Down); err != nil {
+			return err
+		}
+		_, err := tx.ExecContext(ctx, `DELETE FROM schema_migrations WHERE version = $1`, mg.Version)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("rollback %03d_%s: %w", mg.Version, mg.Name, err)
+	}
+	logging.S().Infow("migration rolled back", "version", mg.Version, "name", mg.Name)
+	return nil
+}
+
+func inTx(ctx context.Context, conn *sql.Conn, fn func(tx *sql.Tx) error) error {
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	if err := fn(tx); err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+func (m *Migrator) find(version int) *Migration {
+	for i := range m.migrations {
+		if m.migrations[i].Version == version {
+			return &m.migrations[i]
+		}
+	}
+	return nil
+}
+
+func (m *Migrator) latest() int {
+	if len(m.migrations) == 0 {
+		return 0
+	}
+	return m.migrations[len(m.migrations)-1].Version
+}
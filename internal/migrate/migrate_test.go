@@ -0,0 +1,171 @@
+package migrate
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/51mans0n/avito-pvz-task/migrations"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/require"
+)
+
+var testFS = fstest.MapFS{
+	"001_init.sql":     {Data: []byte("CREATE TABLE a (id INT);\n\n-- +down\nDROP TABLE a;\n")},
+	"002_add_b.sql":    {Data: []byte("CREATE TABLE b (id INT);\n-- +down\nDROP TABLE b;\n")},
+	"README.md":        {Data: []byte("not a migration")},
+	"sub/003_skip.sql": {Data: []byte("SELECT 1;")},
+}
+
+func TestLoad(t *testing.T) {
+	list, err := Load(testFS)
+	require.NoError(t, err)
+	require.Len(t, list, 2)
+
+	require.Equal(t, 1, list[0].Version)
+	require.Equal(t, "init", list[0].Name)
+	require.Equal(t, "CREATE TABLE a (id INT);\n\n", list[0].Up)
+	require.Equal(t, "DROP TABLE a;\n", list[0].Down)
+	require.Len(t, list[0].Checksum, 64)
+	require.Equal(t, 2, list[1].Version)
+}
+
+func TestLoad_Invalid(t *testing.T) {
+	_, err := Load(fstest.MapFS{"init.sql": {Data: []byte("SELECT 1;")}})
+	require.ErrorContains(t, err, "NNN_name.sql")
+
+	_, err = Load(fstest.MapFS{
+		"001_a.sql":  {Data: []byte("SELECT 1;")},
+		"0001_b.sql": {Data: []byte("SELECT 1;")},
+	})
+	require.ErrorContains(t, err, "already used")
+
+	_, err = Load(fstest.MapFS{"001_a.sql": {Data: []byte("-- +down\nSELECT 1;")}})
+	require.ErrorContains(t, err, "empty up section")
+}
+
+// встроенные миграции: версии по порядку, у каждой есть откат
+func TestEmbeddedMigrations(t *testing.T) {
+	list, err := Load(migrations.FS)
+	require.NoError(t, err)
+	require.NotEmpty(t, list)
+	for i, m := range list {
+		require.Equal(t, i+1, m.Version, m.Name)
+		require.NotEmpty(t, m.Down, "%03d_%s has no down section", m.Version, m.Name)
+	}
+}
+
+func newMock(t *testing.T) (*Migrator, sqlmock.Sqlmock) {
+	t.Helper()
+	sqlDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	t.Cleanup(func() { sqlDB.Close() })
+
+	m, err := New(sqlDB, testFS)
+	require.NoError(t, err)
+	return m, mock
+}
+
+func expectLock(mock sqlmock.Sqlmock, applied *sqlmock.Rows) {
+	mock.ExpectExec(regexp.QuoteMeta(`SELECT pg_advisory_lock($1)`)).
+		WithArgs(LockID).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`CREATE TABLE IF NOT EXISTS schema_migrations`).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery(`SELECT version, checksum, applied_at FROM schema_migrations`).
+		WillReturnRows(applied)
+}
+
+func expectUnlock(mock sqlmock.Sqlmock) {
+	mock.ExpectExec(regexp.QuoteMeta(`SELECT pg_advisory_unlock($1)`)).
+		WithArgs(LockID).WillReturnResult(sqlmock.NewResult(0, 0))
+}
+
+func appliedRows() *sqlmock.Rows {
+	return sqlmock.NewRows([]string{"version", "checksum", "applied_at"})
+}
+
+func TestMigrator_Up(t *testing.T) {
+	m, mock := newMock(t)
+	first := m.migrations[0]
+
+	expectLock(mock, appliedRows().AddRow(1, first.Checksum, time.Now()))
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta("CREATE TABLE b (id INT);")).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`INSERT INTO schema_migrations`).
+		WithArgs(2, "add_b", m.migrations[1].Checksum).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	expectUnlock(mock)
+
+	n, err := m.Up(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, 1, n)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestMigrator_Up_FailedMigrationRollsBack(t *testing.T) {
+	m, mock := newMock(t)
+
+	expectLock(mock, appliedRows())
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta("CREATE TABLE a (id INT);")).WillReturnError(errors.New("syntax error"))
+	mock.ExpectRollback()
+	expectUnlock(mock)
+
+	n, err := m.Up(context.Background())
+	require.ErrorContains(t, err, "apply 001_init: syntax error")
+	require.Zero(t, n)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestMigrator_ChecksumMismatch(t *testing.T) {
+	m, mock := newMock(t)
+
+	expectLock(mock, appliedRows().AddRow(1, "deadbeef", time.Now()))
+	expectUnlock(mock)
+
+	_, err := m.Up(context.Background())
+	require.ErrorIs(t, err, ErrChecksumMismatch)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestMigrator_Goto_RollsBack(t *testing.T) {
+	m, mock := newMock(t)
+
+	expectLock(mock, appliedRows().
+		AddRow(1, m.migrations[0].Checksum, time.Now()).
+		AddRow(2, m.migrations[1].Checksum, time.Now()))
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta("DROP TABLE b;")).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(regexp.QuoteMeta(`DELETE FROM schema_migrations WHERE version = $1`)).
+		WithArgs(2).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	expectUnlock(mock)
+
+	n, err := m.Goto(context.Background(), 1)
+	require.NoError(t, err)
+	require.Equal(t, 1, n)
+	require.NoError(t, mock.ExpectationsWereMet())
+
+	_, err = m.Goto(context.Background(), 7)
+	require.ErrorContains(t, err, "unknown migration version 7")
+}
+
+func TestMigrator_Status(t *testing.T) {
+	m, mock := newMock(t)
+	at := time.Date(2025, 4, 1, 9, 0, 0, 0, time.UTC)
+
+	expectLock(mock, appliedRows().AddRow(1, "deadbeef", at))
+	expectUnlock(mock)
+
+	list, err := m.Status(context.Background())
+	require.NoError(t, err)
+	require.Len(t, list, 2)
+	require.True(t, list[0].Applied)
+	require.True(t, list[0].Modified)
+	require.Equal(t, at, *list[0].AppliedAt)
+	require.False(t, list[1].Applied)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,24 @@
+package model
+
+import "time"
+
+// владельцы вложений
+const (
+	AttachmentOwnerReception = "reception"
+	AttachmentOwnerProduct   = "product"
+)
+
+// Attachment - метаданные файла (фото, накладная), приложенного к приёмке или товару.
+// Само содержимое лежит в blob.Store под StorageKey.
+type Attachment struct {
+	ID          string    `json:"id" db:"id"`
+	OwnerType   string    `json:"ownerType" db:"owner_type"`
+	OwnerID     string    `json:"ownerId" db:"owner_id"`
+	FileName    string    `json:"fileName" db:"file_name"`
+	ContentType string    `json:"contentType" db:"content_type"`
+	Size        int64     `json:"size" db:"size_bytes"`
+	SHA256      string    `json:"sha256" db:"sha256"`
+	StorageKey  string    `json:"-" db:"storage_key"`
+	UploadedBy  string    `json:"uploadedBy" db:"uploaded_by"`
+	CreatedAt   time.Time `json:"createdAt" db:"created_at"`
+}
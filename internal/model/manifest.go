@@ -0,0 +1,32 @@
+package model
+
+import "time"
+
+// ManifestItem - сколько товаров одного типа поставщик обещал привезти
+type ManifestItem struct {
+	Type     string   `json:"type"`
+	Count    int      `json:"count"`
+	Barcodes []string `json:"barcodes,omitempty"`
+}
+
+// DiscrepancyLine - расхождение по одному типу товара
+type DiscrepancyLine struct {
+	Type     string `json:"type" db:"type"`
+	Expected int    `json:"expected" db:"expected"`
+	Received int    `json:"received" db:"received"`
+	Missing  int    `json:"missing" db:"missing"`
+	Surplus  int    `json:"surplus" db:"surplus"`
+
+	// сверка по штрихкодам - только для типов, у которых в манифесте есть штрихкоды
+	MissingBarcodes    []string `json:"missingBarcodes,omitempty" db:"-"`    // есть в манифесте, не отсканированы
+	UnexpectedBarcodes []string `json:"unexpectedBarcodes,omitempty" db:"-"` // отсканированы, в манифесте их нет
+}
+
+// DiscrepancyReport - отчёт о расхождениях факта с манифестом на момент закрытия приёмки
+type DiscrepancyReport struct {
+	ReceptionID  string            `json:"receptionId"`
+	CreatedAt    time.Time         `json:"createdAt"`
+	Lines        []DiscrepancyLine `json:"lines"`
+	TotalMissing int               `json:"totalMissing"`
+	TotalSurplus int               `json:"totalSurplus"`
+}
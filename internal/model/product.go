@@ -5,6 +5,37 @@ import "time"
 type Product struct {
 	ID          string    `db:"id"`
 	ReceptionID string    `db:"reception_id"`
+	PVZID       string    `db:"pvz_id" json:",omitempty"` // заполняется только поиском товаров
 	DateTime    time.Time `db:"date_time"`
 	Type        string    `db:"type"`
+	Quantity    int       `db:"quantity"` // единиц в строке; по умолчанию 1
+
+	Barcode      string `db:"barcode" json:",omitempty"`
+	SerialNumber string `db:"serial_number" json:",omitempty"` // обязателен для типов с RequiresSerial
+
+	// статус после приёмки и время каждого перехода (ProductStatus*)
+	Status     string     `db:"status"`
+	StoredAt   *time.Time `db:"stored_at" json:",omitempty"`
+	IssuedAt   *time.Time `db:"issued_at" json:",omitempty"`
+	ReturnedAt *time.Time `db:"returned_at" json:",omitempty"`
+
+	// ячейка хранения; пусто, если ячейки не заведены или всё занято
+	CellID string       `db:"cell_id" json:",omitempty"`
+	Cell   *StorageCell `db:"-" json:",omitempty"`
+
+	// предупреждения при приёмке, например тот же штрихкод в другой открытой приёмке
+	Warnings []string `db:"-" json:",omitempty"`
+
+	// мягкое удаление: удалённые товары не попадают в выборки и итоги
+	DeletedAt *time.Time `db:"deleted_at" json:"-"`
+	DeletedBy string     `db:"deleted_by" json:"-"`
+}
+
+// TotalQuantity - сколько единиц во всех строках товаров
+func TotalQuantity(prods []*Product) int {
+	n := 0
+	for _, p := range prods {
+		n += p.Quantity
+	}
+	return n
 }
@@ -0,0 +1,34 @@
+package model
+
+import "time"
+
+// коды причин удаления товара из приёмки
+const (
+	DeletionReasonScanError = "scan_error" // отсканирован не тот товар
+	DeletionReasonDuplicate = "duplicate"  // товар отсканирован дважды
+	DeletionReasonDamaged   = "damaged"    // товар повреждён и не принимается
+	DeletionReasonWrongPVZ  = "wrong_pvz"  // товар привезли не в тот ПВЗ
+	DeletionReasonOther     = "other"      // требует комментария
+)
+
+// DeletionReasons - допустимые коды причин удаления
+var DeletionReasons = []string{
+	DeletionReasonScanError,
+	DeletionReasonDuplicate,
+	DeletionReasonDamaged,
+	DeletionReasonWrongPVZ,
+	DeletionReasonOther,
+}
+
+// ProductDeletion - запись об удалении конкретного товара (для отчётности)
+type ProductDeletion struct {
+	ID          string    `json:"id" db:"id"`
+	ProductID   string    `json:"productId" db:"product_id"`
+	ReceptionID string    `json:"receptionId" db:"reception_id"`
+	ProductType string    `json:"productType" db:"product_type"`
+	Quantity    int       `json:"quantity" db:"quantity"`
+	Reason      string    `json:"reason" db:"reason"`
+	Comment     string    `json:"comment,omitempty" db:"comment"`
+	DeletedBy   string    `json:"deletedBy" db:"deleted_by"`
+	DeletedAt   time.Time `json:"deletedAt" db:"deleted_at"`
+}
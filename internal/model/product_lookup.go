@@ -0,0 +1,10 @@
+package model
+
+// ProductLookup - товар, найденный по штрихкоду или id, вместе с приёмкой,
+// ПВЗ и ячейкой хранения
+type ProductLookup struct {
+	Product   ProductResponse   `json:"product"`
+	Reception ReceptionResponse `json:"reception"`
+	PVZ       PVZResponse       `json:"pvz"`
+	Cell      *StorageCell      `json:"cell,omitempty"`
+}
@@ -0,0 +1,34 @@
+package model
+
+import "slices"
+
+// статусы товара после приёмки
+const (
+	ProductStatusReceived = "received" // принят в приёмку
+	ProductStatusStored   = "stored"   // лежит на складе ПВЗ
+	ProductStatusIssued   = "issued"   // выдан покупателю
+	ProductStatusReturned = "returned" // возвращён отправителю
+
+	// везут в другой ПВЗ; ставится и снимается только перемещением (Transfer)
+	ProductStatusInTransit = "in_transit"
+)
+
+// ProductStatuses - все статусы товара
+var ProductStatuses = []string{
+	ProductStatusReceived,
+	ProductStatusStored,
+	ProductStatusIssued,
+	ProductStatusReturned,
+	ProductStatusInTransit,
+}
+
+// productTransitions - допустимые переходы; issued и returned конечные
+var productTransitions = map[string][]string{
+	ProductStatusReceived: {ProductStatusStored, ProductStatusReturned},
+	ProductStatusStored:   {ProductStatusIssued, ProductStatusReturned},
+}
+
+// CanTransition - можно ли перевести товар из статуса from в to
+func CanTransition(from, to string) bool {
+	return slices.Contains(productTransitions[from], to)
+}
@@ -0,0 +1,11 @@
+package model
+
+import "time"
+
+// ProductType - элемент справочника типов товаров, которым управляют модераторы
+type ProductType struct {
+	Name           string    `json:"name" db:"name"`
+	RequiresSerial bool      `json:"requiresSerial" db:"requires_serial"` // например, электроника
+	Active         bool      `json:"active" db:"active"`                  // неактивный тип нельзя принять
+	CreatedAt      time.Time `json:"createdAt" db:"created_at"`
+}
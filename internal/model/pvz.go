@@ -6,4 +6,5 @@ type PVZ struct {
 	ID               string    `json:"id"`
 	City             string    `json:"city"`
 	RegistrationDate time.Time `json:"registrationDate"`
+	Version          int       `json:"version"` // растёт при каждом изменении, отдаётся в ETag
 }
@@ -3,8 +3,29 @@ package model
 import "time"
 
 type Reception struct {
-	ID       string    `json:"id" db:"id"`
-	PVZID    string    `json:"pvzId" db:"pvz_id"`
-	DateTime time.Time `json:"dateTime" db:"date_time"`
-	Status   string    `json:"status" db:"status"`
+	ID       string     `json:"id" db:"id"`
+	PVZID    string     `json:"pvzId" db:"pvz_id"`
+	DateTime time.Time  `json:"dateTime" db:"date_time"`
+	Status   string     `json:"status" db:"status"`
+	ClosedAt *time.Time `json:"closedAt,omitempty" db:"closed_at"`
+	Version  int        `json:"version" db:"version"` // растёт при каждом изменении, отдаётся в ETag
+
+	// кто и почему закрыл приёмку; заполняется только при автозакрытии
+	ClosedBy    string `json:"closedBy,omitempty" db:"-"`
+	CloseReason string `json:"closeReason,omitempty" db:"-"`
+
+	// заполняются только при создании / закрытии, в таблице receptions не хранятся
+	Manifest    []ManifestItem     `json:"manifest,omitempty" db:"-"`
+	Summary     *ReceptionSummary  `json:"summary,omitempty" db:"-"`
+	Discrepancy *DiscrepancyReport `json:"discrepancy,omitempty" db:"-"`
+}
+
+// ReceptionReopen - запись о переоткрытии закрытой приёмки
+type ReceptionReopen struct {
+	ID          string    `json:"id" db:"id"`
+	ReceptionID string    `json:"receptionId" db:"reception_id"`
+	Reason      string    `json:"reason" db:"reason"`
+	ReopenedBy  string    `json:"reopenedBy" db:"reopened_by"`
+	ApprovalID  string    `json:"approvalId,omitempty" db:"approval_id"`
+	CreatedAt   time.Time `json:"createdAt" db:"created_at"`
 }
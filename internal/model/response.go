@@ -11,6 +11,7 @@ type PVZResponse struct {
 	ID               string    `json:"id"`
 	City             string    `json:"city"`
 	RegistrationDate time.Time `json:"registrationDate"`
+	Version          int       `json:"version"`
 }
 
 type ReceptionWithProd struct {
@@ -23,11 +24,49 @@ type ReceptionResponse struct {
 	PVZID    string    `json:"pvzId"`
 	DateTime time.Time `json:"dateTime"`
 	Status   string    `json:"status"` // in_progress, close
+	Version  int       `json:"version"`
+
+	Summary *ReceptionSummary `json:"summary,omitempty"` // только у закрытых
 }
 
 type ProductResponse struct {
 	ID          string    `json:"id"`
 	DateTime    time.Time `json:"dateTime"`
 	Type        string    `json:"type"` // электроника, одежда, обувь
+	Quantity    int       `json:"quantity"`
 	ReceptionID string    `json:"receptionId"`
+	PVZID       string    `json:"pvzId,omitempty"`
+
+	Barcode      string `json:"barcode,omitempty"`
+	SerialNumber string `json:"serialNumber,omitempty"`
+
+	CellID string `json:"cellId,omitempty"`
+
+	Status     string     `json:"status,omitempty"` // received, stored, issued, returned
+	StoredAt   *time.Time `json:"storedAt,omitempty"`
+	IssuedAt   *time.Time `json:"issuedAt,omitempty"`
+	ReturnedAt *time.Time `json:"returnedAt,omitempty"`
+
+	// заполнены только у удалённых товаров (видны модератору с includeDeleted)
+	DeletedAt *time.Time `json:"deletedAt,omitempty"`
+	DeletedBy string     `json:"deletedBy,omitempty"`
+}
+
+// ProductPage - страница поиска товаров
+type ProductPage struct {
+	Items      []ProductResponse `json:"items"`
+	NextCursor string            `json:"nextCursor,omitempty"` // пусто на последней странице
+}
+
+// ProductBatchResponse - результат пакетного добавления товаров
+type ProductBatchResponse struct {
+	ReceptionID string   `json:"receptionId"`
+	IDs         []string `json:"ids"`
+	Warnings    []string `json:"warnings,omitempty"`
+}
+
+// ErrorResponse - тело ответа с ошибкой; Code стабилен и годится для обработки на клиенте
+type ErrorResponse struct {
+	Code    string `json:"code,omitempty"`
+	Message string `json:"message"`
 }
@@ -0,0 +1,28 @@
+package model
+
+import (
+	"fmt"
+	"time"
+)
+
+// StorageCell - ячейка хранения внутри ПВЗ
+type StorageCell struct {
+	ID        string    `json:"id" db:"id"`
+	PVZID     string    `json:"pvzId" db:"pvz_id"`
+	Zone      string    `json:"zone" db:"zone"`
+	Rack      string    `json:"rack" db:"rack"`
+	Shelf     string    `json:"shelf" db:"shelf"`
+	Capacity  int       `json:"capacity" db:"capacity"`
+	Occupied  int       `json:"occupied" db:"occupied"` // единицы живых товаров, которые сейчас лежат в ячейке
+	CreatedAt time.Time `json:"createdAt" db:"created_at"`
+}
+
+// Fits - помещается ли в ячейку строка из quantity единиц
+func (c *StorageCell) Fits(quantity int) bool {
+	return c.Occupied+quantity <= c.Capacity
+}
+
+// Address - адрес ячейки для сотрудника, например "A-03-2"
+func (c *StorageCell) Address() string {
+	return fmt.Sprintf("%s-%s-%s", c.Zone, c.Rack, c.Shelf)
+}
@@ -0,0 +1,13 @@
+package model
+
+import "time"
+
+// ReceptionSummary - итог закрытой приёмки
+type ReceptionSummary struct {
+	TotalProducts   int            `json:"totalProducts"`
+	CountsByType    map[string]int `json:"countsByType"`
+	FirstScanAt     *time.Time     `json:"firstScanAt,omitempty"`
+	LastScanAt      *time.Time     `json:"lastScanAt,omitempty"`
+	DurationSeconds int64          `json:"durationSeconds"`
+	LIFODeletions   int            `json:"lifoDeletions"`
+}
@@ -0,0 +1,47 @@
+package model
+
+import "time"
+
+// статусы перемещения между ПВЗ
+const (
+	TransferStatusDraft      = "draft"      // собирается, товары ещё на месте
+	TransferStatusDispatched = "dispatched" // отправлено, товары в пути
+	TransferStatusReceived   = "received"   // принято в ПВЗ назначения
+)
+
+// Transfer - перемещение выбранных товаров из одного ПВЗ в другой
+type Transfer struct {
+	ID           string     `json:"id" db:"id"`
+	FromPVZID    string     `json:"fromPvzId" db:"from_pvz_id"`
+	ToPVZID      string     `json:"toPvzId" db:"to_pvz_id"`
+	Status       string     `json:"status" db:"status"`
+	CreatedBy    string     `json:"createdBy" db:"created_by"`
+	CreatedAt    time.Time  `json:"createdAt" db:"created_at"`
+	DispatchedAt *time.Time `json:"dispatchedAt,omitempty" db:"dispatched_at"`
+	ReceivedAt   *time.Time `json:"receivedAt,omitempty" db:"received_at"`
+	ReceptionID  string     `json:"receptionId,omitempty" db:"reception_id"` // приёмка в ПВЗ назначения
+
+	ProductIDs []string `json:"productIds" db:"-"`
+}
+
+// события истории перемещений товара
+const (
+	MovementReceived    = "received"            // принят в приёмку
+	MovementDispatched  = "transfer_dispatched" // отправлен в другой ПВЗ
+	MovementArrived     = "transfer_received"   // принят в ПВЗ назначения
+	MovementCellChanged = "cell_changed"        // переложен в другую ячейку
+)
+
+// ProductMovement - запись истории перемещений товара
+type ProductMovement struct {
+	ID          int64     `json:"-" db:"id"`
+	ProductID   string    `json:"productId" db:"product_id"`
+	Event       string    `json:"event" db:"event"`
+	FromPVZID   string    `json:"fromPvzId,omitempty" db:"from_pvz_id"`
+	ToPVZID     string    `json:"toPvzId,omitempty" db:"to_pvz_id"`
+	ReceptionID string    `json:"receptionId,omitempty" db:"reception_id"`
+	TransferID  string    `json:"transferId,omitempty" db:"transfer_id"`
+	CellID      string    `json:"cellId,omitempty" db:"cell_id"`
+	Actor       string    `json:"actor,omitempty" db:"actor"`
+	CreatedAt   time.Time `json:"createdAt" db:"created_at"`
+}
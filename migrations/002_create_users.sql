@@ -5,3 +5,21 @@ CREATE TABLE IF NOT EXISTS users (
     role        TEXT NOT NULL CHECK (role IN ('employee','moderator')),
     created_at  TIMESTAMPTZ NOT NULL DEFAULT now()
 );
+
+-- базы, поднятые старым scripts/sql/001_init_tables.sql, создали users раньше
+-- и с другой схемой: приводим её к той, с которой работает код
+DO $$
+BEGIN
+    IF EXISTS (SELECT 1 FROM information_schema.columns
+               WHERE table_name = 'users' AND column_name = 'password_hash') THEN
+        ALTER TABLE users RENAME COLUMN password_hash TO pass_hash;
+    END IF;
+    IF NOT EXISTS (SELECT 1 FROM pg_constraint WHERE conname = 'users_role_check') THEN
+        ALTER TABLE users ADD CONSTRAINT users_role_check CHECK (role IN ('employee','moderator')) NOT VALID;
+    END IF;
+END $$;
+
+ALTER TABLE users ALTER COLUMN created_at TYPE TIMESTAMPTZ;
+
+-- +down
+DROP TABLE IF EXISTS users;
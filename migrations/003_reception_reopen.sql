@@ -0,0 +1,19 @@
+ALTER TABLE receptions ADD COLUMN IF NOT EXISTS closed_at TIMESTAMP;
+
+CREATE TABLE IF NOT EXISTS reception_reopens (
+    id           UUID PRIMARY KEY,
+    reception_id UUID NOT NULL REFERENCES receptions(id),
+    reason       TEXT NOT NULL,
+    reopened_by  TEXT NOT NULL,
+    approval_id  TEXT,
+    created_at   TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+-- разрешение модератора одноразовое: второй reopen по тому же approval_id отклоняется
+CREATE UNIQUE INDEX IF NOT EXISTS reception_reopens_approval_id
+    ON reception_reopens (approval_id)
+    WHERE approval_id IS NOT NULL;
+
+-- +down
+DROP TABLE IF EXISTS reception_reopens;
+ALTER TABLE receptions DROP COLUMN IF EXISTS closed_at;
@@ -0,0 +1,20 @@
+-- до индекса параллельные запросы могли открыть на ПВЗ несколько приёмок, и
+-- на такой базе CREATE UNIQUE INDEX упадёт. Поэтому сначала закрываем все
+-- открытые приёмки ПВЗ, кроме самой новой; closed_at - момент миграции.
+UPDATE receptions r
+SET status = 'close', closed_at = NOW()
+WHERE r.status = 'in_progress'
+  AND EXISTS (
+    SELECT 1 FROM receptions n
+    WHERE n.pvz_id = r.pvz_id
+      AND n.status = 'in_progress'
+      AND (n.date_time, n.id) > (r.date_time, r.id)
+  );
+
+-- на ПВЗ может быть не больше одной открытой приёмки
+CREATE UNIQUE INDEX IF NOT EXISTS receptions_one_open_per_pvz
+    ON receptions (pvz_id)
+    WHERE status = 'in_progress';
+
+-- +down
+DROP INDEX IF EXISTS receptions_one_open_per_pvz;
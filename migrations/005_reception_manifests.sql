@@ -0,0 +1,24 @@
+-- ожидаемое содержимое поставки, которое прислал поставщик
+CREATE TABLE IF NOT EXISTS reception_manifest_items (
+    reception_id   UUID   NOT NULL REFERENCES receptions(id),
+    type           TEXT   NOT NULL,
+    expected_count INT    NOT NULL CHECK (expected_count >= 0),
+    barcodes       TEXT[] NOT NULL DEFAULT '{}',
+    PRIMARY KEY (reception_id, type)
+);
+
+-- расхождения факта с манифестом, считаются при закрытии приёмки
+CREATE TABLE IF NOT EXISTS reception_discrepancies (
+    reception_id UUID      NOT NULL REFERENCES receptions(id),
+    type         TEXT      NOT NULL,
+    expected     INT       NOT NULL,
+    received     INT       NOT NULL,
+    missing      INT       NOT NULL,
+    surplus      INT       NOT NULL,
+    created_at   TIMESTAMP NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (reception_id, type)
+);
+
+-- +down
+DROP TABLE IF EXISTS reception_discrepancies;
+DROP TABLE IF EXISTS reception_manifest_items;
@@ -0,0 +1,12 @@
+-- кто и почему закрыл приёмку (для автозакрытия - системный актор)
+ALTER TABLE receptions ADD COLUMN IF NOT EXISTS closed_by    TEXT;
+ALTER TABLE receptions ADD COLUMN IF NOT EXISTS close_reason TEXT;
+
+CREATE INDEX IF NOT EXISTS receptions_in_progress_date_time
+    ON receptions (date_time)
+    WHERE status = 'in_progress';
+
+-- +down
+DROP INDEX IF EXISTS receptions_in_progress_date_time;
+ALTER TABLE receptions DROP COLUMN IF EXISTS close_reason;
+ALTER TABLE receptions DROP COLUMN IF EXISTS closed_by;
@@ -0,0 +1,18 @@
+-- сколько раз сотрудник удалял последний товар (LIFO) в приёмке
+ALTER TABLE receptions ADD COLUMN IF NOT EXISTS lifo_deletions INT NOT NULL DEFAULT 0;
+
+-- итог приёмки, фиксируется при закрытии
+CREATE TABLE IF NOT EXISTS reception_summaries (
+    reception_id     UUID PRIMARY KEY REFERENCES receptions(id),
+    total_products   INT       NOT NULL,
+    counts_by_type   JSONB     NOT NULL DEFAULT '{}',
+    first_scan_at    TIMESTAMP,
+    last_scan_at     TIMESTAMP,
+    duration_seconds BIGINT    NOT NULL,
+    lifo_deletions   INT       NOT NULL,
+    created_at       TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+-- +down
+DROP TABLE IF EXISTS reception_summaries;
+ALTER TABLE receptions DROP COLUMN IF EXISTS lifo_deletions;
@@ -0,0 +1,19 @@
+-- файлы (фото, накладные), приложенные к приёмке или товару;
+-- содержимое хранится во внешнем blob-хранилище по storage_key
+CREATE TABLE IF NOT EXISTS attachments (
+    id           UUID PRIMARY KEY,
+    owner_type   TEXT      NOT NULL CHECK (owner_type IN ('reception', 'product')),
+    owner_id     UUID      NOT NULL,
+    file_name    TEXT      NOT NULL,
+    content_type TEXT      NOT NULL,
+    size_bytes   BIGINT    NOT NULL,
+    sha256       TEXT      NOT NULL,
+    storage_key  TEXT      NOT NULL UNIQUE,
+    uploaded_by  TEXT      NOT NULL,
+    created_at   TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS attachments_owner ON attachments (owner_type, owner_id, created_at);
+
+-- +down
+DROP TABLE IF EXISTS attachments;
@@ -0,0 +1,17 @@
+-- журнал удалений конкретных товаров с кодом причины (для отчётности)
+CREATE TABLE IF NOT EXISTS product_deletions (
+    id           UUID PRIMARY KEY,
+    product_id   UUID      NOT NULL,
+    reception_id UUID      NOT NULL REFERENCES receptions(id),
+    product_type TEXT      NOT NULL,
+    reason       TEXT      NOT NULL CHECK (reason IN ('scan_error', 'duplicate', 'damaged', 'wrong_pvz', 'other')),
+    comment      TEXT,
+    deleted_by   TEXT      NOT NULL,
+    deleted_at   TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS product_deletions_reception ON product_deletions (reception_id);
+CREATE INDEX IF NOT EXISTS product_deletions_reason_deleted_at ON product_deletions (reason, deleted_at);
+
+-- +down
+DROP TABLE IF EXISTS product_deletions;
@@ -0,0 +1,17 @@
+-- мягкое удаление товаров: строка остаётся в таблице, и пока приёмка открыта, товар можно вернуть
+ALTER TABLE products ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMP;
+ALTER TABLE products ADD COLUMN IF NOT EXISTS deleted_by TEXT;
+
+-- LIFO и выборки работают только с живыми товарами
+CREATE INDEX IF NOT EXISTS products_reception_alive ON products (reception_id, date_time DESC) WHERE deleted_at IS NULL;
+CREATE INDEX IF NOT EXISTS products_reception_deleted ON products (reception_id, deleted_at DESC) WHERE deleted_at IS NOT NULL;
+
+-- удаление можно отменить; отменённые записи не учитываются в отчётах
+ALTER TABLE product_deletions ADD COLUMN IF NOT EXISTS undone_at TIMESTAMP;
+
+-- +down
+ALTER TABLE product_deletions DROP COLUMN IF EXISTS undone_at;
+DROP INDEX IF EXISTS products_reception_deleted;
+DROP INDEX IF EXISTS products_reception_alive;
+ALTER TABLE products DROP COLUMN IF EXISTS deleted_by;
+ALTER TABLE products DROP COLUMN IF EXISTS deleted_at;
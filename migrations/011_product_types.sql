@@ -0,0 +1,29 @@
+-- справочник типов товаров; правила приёмки задаются на уровне типа
+CREATE TABLE IF NOT EXISTS product_types (
+    name            TEXT PRIMARY KEY,
+    requires_serial BOOLEAN   NOT NULL DEFAULT FALSE,
+    active          BOOLEAN   NOT NULL DEFAULT TRUE,
+    created_at      TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+INSERT INTO product_types (name, requires_serial) VALUES
+    ('электроника', TRUE),
+    ('одежда', FALSE),
+    ('обувь', FALSE)
+ON CONFLICT (name) DO NOTHING;
+
+ALTER TABLE products ADD COLUMN IF NOT EXISTS serial_number TEXT;
+
+-- NOT VALID: старые строки с опечатками не мешают миграции, новые проверяются
+DO $$
+BEGIN
+    IF NOT EXISTS (SELECT 1 FROM pg_constraint WHERE conname = 'products_type_fk') THEN
+        ALTER TABLE products ADD CONSTRAINT products_type_fk
+            FOREIGN KEY (type) REFERENCES product_types (name) NOT VALID;
+    END IF;
+END $$;
+
+-- +down
+ALTER TABLE products DROP CONSTRAINT IF EXISTS products_type_fk;
+ALTER TABLE products DROP COLUMN IF EXISTS serial_number;
+DROP TABLE IF EXISTS product_types;
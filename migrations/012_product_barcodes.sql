@@ -0,0 +1,17 @@
+-- штрихкод посылки; у старых товаров его нет
+ALTER TABLE products ADD COLUMN IF NOT EXISTS barcode TEXT;
+
+-- один и тот же штрихкод нельзя дважды принять в одну приёмку
+CREATE UNIQUE INDEX IF NOT EXISTS products_reception_barcode
+    ON products (reception_id, barcode)
+    WHERE barcode IS NOT NULL AND deleted_at IS NULL;
+
+-- поиск по штрихкоду и проверка дублей в других открытых приёмках
+CREATE INDEX IF NOT EXISTS products_barcode
+    ON products (barcode, date_time DESC)
+    WHERE barcode IS NOT NULL AND deleted_at IS NULL;
+
+-- +down
+DROP INDEX IF EXISTS products_barcode;
+DROP INDEX IF EXISTS products_reception_barcode;
+ALTER TABLE products DROP COLUMN IF EXISTS barcode;
@@ -0,0 +1,26 @@
+-- жизненный цикл товара после приёмки: received -> stored -> issued | returned
+ALTER TABLE products ADD COLUMN IF NOT EXISTS status TEXT NOT NULL DEFAULT 'received';
+ALTER TABLE products ADD COLUMN IF NOT EXISTS stored_at TIMESTAMP;
+ALTER TABLE products ADD COLUMN IF NOT EXISTS issued_at TIMESTAMP;
+ALTER TABLE products ADD COLUMN IF NOT EXISTS returned_at TIMESTAMP;
+
+DO $$
+BEGIN
+    IF NOT EXISTS (SELECT 1 FROM pg_constraint WHERE conname = 'products_status_check') THEN
+        ALTER TABLE products ADD CONSTRAINT products_status_check
+            CHECK (status IN ('received', 'stored', 'issued', 'returned'));
+    END IF;
+END $$;
+
+-- списки товаров ПВЗ с фильтром по статусу
+CREATE INDEX IF NOT EXISTS products_reception_status
+    ON products (reception_id, status, date_time DESC)
+    WHERE deleted_at IS NULL;
+
+-- +down
+DROP INDEX IF EXISTS products_reception_status;
+ALTER TABLE products DROP CONSTRAINT IF EXISTS products_status_check;
+ALTER TABLE products DROP COLUMN IF EXISTS returned_at;
+ALTER TABLE products DROP COLUMN IF EXISTS issued_at;
+ALTER TABLE products DROP COLUMN IF EXISTS stored_at;
+ALTER TABLE products DROP COLUMN IF EXISTS status;
@@ -0,0 +1,24 @@
+-- ячейки хранения ПВЗ: зона / стеллаж / полка и сколько посылок туда помещается
+CREATE TABLE IF NOT EXISTS storage_cells (
+    id         UUID PRIMARY KEY,
+    pvz_id     UUID      NOT NULL REFERENCES pvz (id),
+    zone       TEXT      NOT NULL,
+    rack       TEXT      NOT NULL,
+    shelf      TEXT      NOT NULL,
+    capacity   INTEGER   NOT NULL CHECK (capacity > 0),
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    CONSTRAINT storage_cells_address UNIQUE (pvz_id, zone, rack, shelf)
+);
+
+-- где лежит товар; выданный или возвращённый товар ячейку освобождает
+ALTER TABLE products ADD COLUMN IF NOT EXISTS cell_id UUID REFERENCES storage_cells (id);
+
+-- заполненность ячеек
+CREATE INDEX IF NOT EXISTS products_cell
+    ON products (cell_id)
+    WHERE cell_id IS NOT NULL AND deleted_at IS NULL;
+
+-- +down
+DROP INDEX IF EXISTS products_cell;
+ALTER TABLE products DROP COLUMN IF EXISTS cell_id;
+DROP TABLE IF EXISTS storage_cells;
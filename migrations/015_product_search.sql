@@ -0,0 +1,23 @@
+-- индексы поиска товаров по всем ПВЗ (GET /products): keyset-пагинация по (date_time, id)
+CREATE INDEX IF NOT EXISTS products_alive_date
+    ON products (date_time DESC, id DESC)
+    WHERE deleted_at IS NULL;
+
+CREATE INDEX IF NOT EXISTS products_status_date
+    ON products (status, date_time DESC, id DESC)
+    WHERE deleted_at IS NULL;
+
+CREATE INDEX IF NOT EXISTS products_type_date
+    ON products (type, date_time DESC, id DESC)
+    WHERE deleted_at IS NULL;
+
+-- фильтры по ПВЗ и городу идут через приёмки
+CREATE INDEX IF NOT EXISTS receptions_pvz_date ON receptions (pvz_id, date_time DESC);
+CREATE INDEX IF NOT EXISTS pvz_city ON pvz (city);
+
+-- +down
+DROP INDEX IF EXISTS pvz_city;
+DROP INDEX IF EXISTS receptions_pvz_date;
+DROP INDEX IF EXISTS products_type_date;
+DROP INDEX IF EXISTS products_status_date;
+DROP INDEX IF EXISTS products_alive_date;
@@ -0,0 +1,57 @@
+-- перемещение товаров между ПВЗ: draft -> dispatched -> received
+CREATE TABLE IF NOT EXISTS transfers (
+    id            UUID PRIMARY KEY,
+    from_pvz_id   UUID      NOT NULL REFERENCES pvz (id),
+    to_pvz_id     UUID      NOT NULL REFERENCES pvz (id),
+    status        TEXT      NOT NULL DEFAULT 'draft' CHECK (status IN ('draft', 'dispatched', 'received')),
+    created_by    TEXT      NOT NULL,
+    created_at    TIMESTAMP NOT NULL DEFAULT NOW(),
+    dispatched_at TIMESTAMP,
+    received_at   TIMESTAMP,
+    reception_id  UUID REFERENCES receptions (id), -- приёмка в ПВЗ назначения
+    CHECK (from_pvz_id <> to_pvz_id)
+);
+
+CREATE TABLE IF NOT EXISTS transfer_items (
+    transfer_id UUID NOT NULL REFERENCES transfers (id),
+    product_id  UUID NOT NULL REFERENCES products (id),
+    PRIMARY KEY (transfer_id, product_id)
+);
+
+-- товар в пути между ПВЗ
+ALTER TABLE products DROP CONSTRAINT IF EXISTS products_status_check;
+ALTER TABLE products ADD CONSTRAINT products_status_check
+    CHECK (status IN ('received', 'stored', 'issued', 'returned', 'in_transit'));
+
+-- история перемещений товара: приёмка, отправка, прибытие, смена ячейки
+CREATE TABLE IF NOT EXISTS product_movements (
+    id           BIGSERIAL PRIMARY KEY,
+    product_id   UUID      NOT NULL,
+    event        TEXT      NOT NULL,
+    from_pvz_id  UUID,
+    to_pvz_id    UUID,
+    reception_id UUID,
+    transfer_id  UUID,
+    cell_id      UUID,
+    actor        TEXT,
+    created_at   TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS product_movements_product ON product_movements (product_id, created_at, id);
+
+-- у уже принятых товаров история начинается с приёмки
+INSERT INTO product_movements (product_id, event, to_pvz_id, reception_id, cell_id, created_at)
+SELECT p.id, 'received', r.pvz_id, p.reception_id, p.cell_id, p.date_time
+FROM products p
+JOIN receptions r ON r.id = p.reception_id
+WHERE NOT EXISTS (SELECT 1 FROM product_movements m WHERE m.product_id = p.id);
+
+-- +down
+DROP TABLE IF EXISTS product_movements;
+-- товары в пути возвращаются в исходный ПВЗ как принятые
+UPDATE products SET status = 'received' WHERE status = 'in_transit';
+ALTER TABLE products DROP CONSTRAINT IF EXISTS products_status_check;
+ALTER TABLE products ADD CONSTRAINT products_status_check
+    CHECK (status IN ('received', 'stored', 'issued', 'returned'));
+DROP TABLE IF EXISTS transfer_items;
+DROP TABLE IF EXISTS transfers;
@@ -0,0 +1,30 @@
+-- строка товара с количеством: коробка одинаковых товаров принимается одной строкой
+ALTER TABLE products ADD COLUMN IF NOT EXISTS quantity INTEGER NOT NULL DEFAULT 1;
+
+DO $$
+BEGIN
+    IF NOT EXISTS (SELECT 1 FROM pg_constraint WHERE conname = 'products_quantity_check') THEN
+        ALTER TABLE products ADD CONSTRAINT products_quantity_check CHECK (quantity > 0);
+    END IF;
+END $$;
+
+-- LIFO-удаление одной единицы из строки с quantity > 1; отмена снимает последнюю запись
+CREATE TABLE IF NOT EXISTS product_unit_removals (
+    id           BIGSERIAL PRIMARY KEY,
+    product_id   UUID      NOT NULL REFERENCES products(id),
+    reception_id UUID      NOT NULL REFERENCES receptions(id),
+    removed_by   TEXT,
+    removed_at   TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS product_unit_removals_reception
+    ON product_unit_removals (reception_id, removed_at DESC);
+
+-- сколько единиц было в удалённой строке
+ALTER TABLE product_deletions ADD COLUMN IF NOT EXISTS quantity INTEGER NOT NULL DEFAULT 1;
+
+-- +down
+ALTER TABLE product_deletions DROP COLUMN IF EXISTS quantity;
+DROP TABLE IF EXISTS product_unit_removals;
+ALTER TABLE products DROP CONSTRAINT IF EXISTS products_quantity_check;
+ALTER TABLE products DROP COLUMN IF EXISTS quantity;
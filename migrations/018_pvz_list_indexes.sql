@@ -0,0 +1,11 @@
+-- список ПВЗ: страница по дате регистрации, затем приёмки (receptions_pvz_date)
+-- и товары страницы через ANY одним запросом
+CREATE INDEX IF NOT EXISTS pvz_registration_date ON pvz (registration_date DESC);
+
+-- товары приёмок вместе с удалёнными (includeDeleted у модератора);
+-- живые товары покрывает products_reception_alive
+CREATE INDEX IF NOT EXISTS products_reception_date ON products (reception_id, date_time DESC);
+
+-- +down
+DROP INDEX IF EXISTS products_reception_date;
+DROP INDEX IF EXISTS pvz_registration_date;
@@ -0,0 +1,7 @@
+-- версии для оптимистичных блокировок: ETag на чтении, If-Match на изменении
+ALTER TABLE pvz ADD COLUMN IF NOT EXISTS version INTEGER NOT NULL DEFAULT 1;
+ALTER TABLE receptions ADD COLUMN IF NOT EXISTS version INTEGER NOT NULL DEFAULT 1;
+
+-- +down
+ALTER TABLE receptions DROP COLUMN IF EXISTS version;
+ALTER TABLE pvz DROP COLUMN IF EXISTS version;
@@ -0,0 +1,7 @@
+-- штрихкоды из манифеста, которые не отсканировали, и отсканированные сверх манифеста
+ALTER TABLE reception_discrepancies ADD COLUMN IF NOT EXISTS missing_barcodes TEXT[] NOT NULL DEFAULT '{}';
+ALTER TABLE reception_discrepancies ADD COLUMN IF NOT EXISTS unexpected_barcodes TEXT[] NOT NULL DEFAULT '{}';
+
+-- +down
+ALTER TABLE reception_discrepancies DROP COLUMN IF EXISTS unexpected_barcodes;
+ALTER TABLE reception_discrepancies DROP COLUMN IF EXISTS missing_barcodes;
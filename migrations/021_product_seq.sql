@@ -0,0 +1,11 @@
+-- порядок вставки товара: у товаров одной пачки одинаковый date_time,
+-- и LIFO среди них должен брать последний отсканированный
+ALTER TABLE products ADD COLUMN IF NOT EXISTS seq BIGSERIAL;
+
+DROP INDEX IF EXISTS products_reception_alive;
+CREATE INDEX IF NOT EXISTS products_reception_alive ON products (reception_id, date_time DESC, seq DESC) WHERE deleted_at IS NULL;
+
+-- +down
+DROP INDEX IF EXISTS products_reception_alive;
+CREATE INDEX IF NOT EXISTS products_reception_alive ON products (reception_id, date_time DESC) WHERE deleted_at IS NULL;
+ALTER TABLE products DROP COLUMN IF EXISTS seq;
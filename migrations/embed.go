@@ -0,0 +1,11 @@
+// Package migrations встраивает SQL-миграции в бинарь сервиса.
+//
+// Файл NNN_name.sql: сначала up-часть, после строки "-- +down" - откат.
+package migrations
+
+import "embed"
+
+// FS - все *.sql миграции
+//
+//go:embed *.sql
+var FS embed.FS